@@ -9,12 +9,26 @@ import (
 
 type (
 	Config struct {
-		App    `yaml:"app"`
-		HTTP   `yaml:"http"`
-		Log    `yaml:"log"`
-		PG     `yaml:"postgres"`
-		JWT    `yaml:"jwt"`
-		Hasher `yaml:"hasher"`
+		App           `yaml:"app"`
+		HTTP          `yaml:"http"`
+		Log           `yaml:"log"`
+		PG            `yaml:"postgres"`
+		JWT           `yaml:"jwt"`
+		Hasher        `yaml:"hasher"`
+		Ledger        `yaml:"ledger"`
+		Escrow        `yaml:"escrow"`
+		Approvals     `yaml:"approvals"`
+		Allowances    `yaml:"allowances"`
+		Credit        `yaml:"credit"`
+		Interest      `yaml:"interest"`
+		PaymentQR     `yaml:"payment_qr"`
+		Vouchers      `yaml:"vouchers"`
+		Disputes      `yaml:"disputes"`
+		Transfers     `yaml:"transfers"`
+		Sessions      `yaml:"sessions"`
+		TwoFactor     `yaml:"two_factor"`
+		Lockout       `yaml:"lockout"`
+		PasswordReset `yaml:"password_reset"`
 	}
 	App struct {
 		Name    string `env-required:"true" yaml:"name" env:"APP_NAME"`
@@ -31,13 +45,125 @@ type (
 		URL         string `env-required:"true"                      env:"PG_URL"`
 	}
 
+	// JWT.SigningKey is the id of the key in Keys that signs new tokens
 	JWT struct {
-		SignKey  string        `env-required:"true"                  env:"JWT_SIGN_KEY"`
-		TokenTTL time.Duration `env-required:"true" yaml:"token_ttl" env:"JWT_TOKEN_TTL"`
+		TokenTTL   time.Duration `env-required:"true" yaml:"token_ttl"   env:"JWT_TOKEN_TTL"`
+		RefreshTTL time.Duration `env-required:"true" yaml:"refresh_ttl" env:"JWT_REFRESH_TTL"`
+		Issuer     string        `env-required:"true" yaml:"issuer"      env:"JWT_ISSUER"`
+		Audience   string        `env-required:"true" yaml:"audience"    env:"JWT_AUDIENCE"`
+		Leeway     time.Duration `env-required:"true" yaml:"leeway"      env:"JWT_LEEWAY"`
+		SigningKey string        `env-required:"true" yaml:"signing_key" env:"JWT_SIGNING_KEY"`
+		Keys       []JWTKey      `env-required:"true" yaml:"keys"`
+		// TwoFactorTTL is how long a password sign-in waits for the two-factor code
+		TwoFactorTTL time.Duration `env-required:"true" yaml:"two_factor_ttl" env:"JWT_TWO_FACTOR_TTL"`
 	}
 
+	// JWTKey is a PEM key file, a key with VerifyUntil is rotated out: it only verifies tokens until then.
+	// A retired key may have only PublicKeyFile.
+	JWTKey struct {
+		ID             string    `yaml:"id"`
+		Algorithm      string    `yaml:"algorithm"`
+		PrivateKeyFile string    `yaml:"private_key_file"`
+		PublicKeyFile  string    `yaml:"public_key_file"`
+		VerifyUntil    time.Time `yaml:"verify_until"`
+	}
+
+	// Hasher.Salt is only used to verify legacy SHA-256 hashes, Argon2Memory is in KiB
 	Hasher struct {
-		Salt string `env-required:"true" env:"HASHER_SALT"`
+		Salt          string `env-required:"true"                       env:"HASHER_SALT"`
+		Algorithm     string `env-required:"true" yaml:"algorithm"      env:"HASHER_ALGORITHM"`
+		BcryptCost    int    `env-required:"true" yaml:"bcrypt_cost"    env:"HASHER_BCRYPT_COST"`
+		Argon2Time    uint32 `env-required:"true" yaml:"argon2_time"    env:"HASHER_ARGON2_TIME"`
+		Argon2Memory  uint32 `env-required:"true" yaml:"argon2_memory"  env:"HASHER_ARGON2_MEMORY"`
+		Argon2Threads uint8  `env-required:"true" yaml:"argon2_threads" env:"HASHER_ARGON2_THREADS"`
+	}
+
+	Ledger struct {
+		HoldingWallet  string `env-required:"true" yaml:"holding_wallet" env:"LEDGER_HOLDING_WALLET"`
+		PromoWallet    string `env-required:"true" yaml:"promo_wallet" env:"LEDGER_PROMO_WALLET"`
+		FeeWallet      string `env-required:"true" yaml:"fee_wallet" env:"LEDGER_FEE_WALLET"`
+		InterestWallet string `env-required:"true" yaml:"interest_wallet" env:"LEDGER_INTEREST_WALLET"`
+		Currency       string `env-required:"true" yaml:"currency" env:"LEDGER_CURRENCY"`
+	}
+
+	Escrow struct {
+		DefaultTimeout  time.Duration `env-required:"true" yaml:"default_timeout" env:"ESCROW_DEFAULT_TIMEOUT"`
+		MaxTimeout      time.Duration `env-required:"true" yaml:"max_timeout" env:"ESCROW_MAX_TIMEOUT"`
+		ReleaseInterval time.Duration `env-required:"true" yaml:"release_interval" env:"ESCROW_RELEASE_INTERVAL"`
+	}
+
+	Approvals struct {
+		DefaultTTL     time.Duration `env-required:"true" yaml:"default_ttl" env:"APPROVALS_DEFAULT_TTL"`
+		ExpireInterval time.Duration `env-required:"true" yaml:"expire_interval" env:"APPROVALS_EXPIRE_INTERVAL"`
+	}
+
+	PaymentQR struct {
+		GUID         string `env-required:"true" yaml:"guid" env:"PAYMENT_QR_GUID"`
+		MerchantName string `env-required:"true" yaml:"merchant_name" env:"PAYMENT_QR_MERCHANT_NAME"`
+		MerchantCity string `env-required:"true" yaml:"merchant_city" env:"PAYMENT_QR_MERCHANT_CITY"`
+		CountryCode  string `env-required:"true" yaml:"country_code" env:"PAYMENT_QR_COUNTRY_CODE"`
+		ImageSize    int    `env-required:"true" yaml:"image_size" env:"PAYMENT_QR_IMAGE_SIZE"`
+	}
+
+	Transfers struct {
+		MaxDelay        time.Duration `env-required:"true" yaml:"max_delay" env:"TRANSFERS_MAX_DELAY"`
+		ExecuteInterval time.Duration `env-required:"true" yaml:"execute_interval" env:"TRANSFERS_EXECUTE_INTERVAL"`
+	}
+
+	Sessions struct {
+		RevocationCacheTTL time.Duration `env-required:"true" yaml:"revocation_cache_ttl" env:"SESSIONS_REVOCATION_CACHE_TTL"`
+		CleanupInterval    time.Duration `env-required:"true" yaml:"cleanup_interval" env:"SESSIONS_CLEANUP_INTERVAL"`
+	}
+
+	// TwoFactor.Skew is the number of 30 second steps accepted before and after the current one,
+	// TwoFactor.LockDuration how long MaxAttempts wrong codes of a user lock the second factor
+	TwoFactor struct {
+		Issuer       string        `env-required:"true" yaml:"issuer" env:"TWO_FACTOR_ISSUER"`
+		Skew         int           `env-required:"true" yaml:"skew" env:"TWO_FACTOR_SKEW"`
+		MaxAttempts  int           `env-required:"true" yaml:"max_attempts" env:"TWO_FACTOR_MAX_ATTEMPTS"`
+		LockDuration time.Duration `env-required:"true" yaml:"lock_duration" env:"TWO_FACTOR_LOCK_DURATION"`
+		QRSize       int           `env-required:"true" yaml:"qr_size" env:"TWO_FACTOR_QR_SIZE"`
+	}
+
+	// Lockout.Storage is "postgres", shared by all instances, or "memory", counted by every instance on its own
+	Lockout struct {
+		Storage         string        `env-required:"true" yaml:"storage" env:"LOCKOUT_STORAGE"`
+		UserThreshold   int           `env-required:"true" yaml:"user_threshold" env:"LOCKOUT_USER_THRESHOLD"`
+		IPThreshold     int           `env-required:"true" yaml:"ip_threshold" env:"LOCKOUT_IP_THRESHOLD"`
+		BaseDelay       time.Duration `env-required:"true" yaml:"base_delay" env:"LOCKOUT_BASE_DELAY"`
+		MaxDelay        time.Duration `env-required:"true" yaml:"max_delay" env:"LOCKOUT_MAX_DELAY"`
+		ResetAfter      time.Duration `env-required:"true" yaml:"reset_after" env:"LOCKOUT_RESET_AFTER"`
+		CleanupInterval time.Duration `env-required:"true" yaml:"cleanup_interval" env:"LOCKOUT_CLEANUP_INTERVAL"`
+	}
+
+	// PasswordReset.OutboxFile receives reset tokens as JSON lines, without it they are written to the log
+	PasswordReset struct {
+		TokenTTL       time.Duration `env-required:"true" yaml:"token_ttl" env:"PASSWORD_RESET_TOKEN_TTL"`
+		MaxRequests    int           `env-required:"true" yaml:"max_requests" env:"PASSWORD_RESET_MAX_REQUESTS"`
+		RequestsWindow time.Duration `env-required:"true" yaml:"requests_window" env:"PASSWORD_RESET_REQUESTS_WINDOW"`
+		OutboxFile     string        `yaml:"outbox_file" env:"PASSWORD_RESET_OUTBOX_FILE"`
+	}
+
+	Allowances struct {
+		Interval time.Duration `env-required:"true" yaml:"interval" env:"ALLOWANCES_INTERVAL"`
+	}
+
+	Credit struct {
+		FeeInterval time.Duration `env-required:"true" yaml:"fee_interval" env:"CREDIT_FEE_INTERVAL"`
+	}
+
+	Interest struct {
+		Interval time.Duration `env-required:"true" yaml:"interval" env:"INTEREST_INTERVAL"`
+	}
+
+	Vouchers struct {
+		MaxAttempts    int           `env-required:"true" yaml:"max_attempts" env:"VOUCHERS_MAX_ATTEMPTS"`
+		AttemptsWindow time.Duration `env-required:"true" yaml:"attempts_window" env:"VOUCHERS_ATTEMPTS_WINDOW"`
+	}
+
+	Disputes struct {
+		Window            time.Duration `env-required:"true" yaml:"window" env:"DISPUTES_WINDOW"`
+		MaxAttachmentSize int64         `env-required:"true" yaml:"max_attachment_size" env:"DISPUTES_MAX_ATTACHMENT_SIZE"`
 	}
 )
 
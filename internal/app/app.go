@@ -2,14 +2,21 @@ package app
 
 import (
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
 	"infotecs_trainee_task/config"
 	v1 "infotecs_trainee_task/internal/controller/http/v1"
 	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/memory"
+	"infotecs_trainee_task/internal/repo/pgdb"
 	"infotecs_trainee_task/internal/service"
 	"infotecs_trainee_task/pkg/hasher"
 	"infotecs_trainee_task/pkg/httpserver"
+	"infotecs_trainee_task/pkg/keyset"
+	"infotecs_trainee_task/pkg/notify"
 	"infotecs_trainee_task/pkg/postgres"
+	"infotecs_trainee_task/pkg/scheduler"
 	"infotecs_trainee_task/pkg/validator"
 	"os"
 	"os/signal"
@@ -23,14 +30,14 @@ func Run(configPath string) {
 	// Configuration
 	cfg, err := config.NewConfig(configPath)
 	if err != nil {
-		log.Error("Config error: %s", err)
+		log.Error(fmt.Sprintf("Config error: %s", err))
 	}
 
 	// Repositories
 	log.Info("Initializing postgres...")
 	pg, err := postgres.New(cfg.PG.URL, postgres.MaxPoolSize(cfg.PG.MaxPoolSize))
 	if err != nil {
-		log.Error(fmt.Sprintf("app - Run - pgdb.NewServices: %w", err))
+		log.Error(fmt.Sprintf("app - Run - pgdb.NewServices: %v", err))
 	}
 	defer pg.Close()
 
@@ -38,16 +45,115 @@ func Run(configPath string) {
 	log.Info("Initializing repositories...")
 	repositories := repo.NewRepositories(pg)
 
+	holdingWallet, err := uuid.Parse(cfg.Ledger.HoldingWallet)
+	if err != nil {
+		log.Error(fmt.Sprintf("app - Run - uuid.Parse: %v", err))
+	}
+
+	promoWallet, err := uuid.Parse(cfg.Ledger.PromoWallet)
+	if err != nil {
+		log.Error(fmt.Sprintf("app - Run - uuid.Parse: %v", err))
+	}
+
+	feeWallet, err := uuid.Parse(cfg.Ledger.FeeWallet)
+	if err != nil {
+		log.Error(fmt.Sprintf("app - Run - uuid.Parse: %v", err))
+	}
+
+	interestWallet, err := uuid.Parse(cfg.Ledger.InterestWallet)
+	if err != nil {
+		log.Error(fmt.Sprintf("app - Run - uuid.Parse: %v", err))
+	}
+
+	repositories.Lockout, err = newLoginAttemptRepo(cfg.Lockout, pg)
+	if err != nil {
+		log.Error(fmt.Sprintf("app - Run - newLoginAttemptRepo: %v", err))
+		os.Exit(1)
+	}
+
+	passwordHasher, err := newPasswordHasher(cfg.Hasher)
+	if err != nil {
+		log.Error(fmt.Sprintf("app - Run - newPasswordHasher: %v", err))
+		os.Exit(1)
+	}
+
+	tokenKeys, err := newTokenKeys(cfg.JWT)
+	if err != nil {
+		log.Error(fmt.Sprintf("app - Run - newTokenKeys: %v", err))
+		os.Exit(1)
+	}
+
 	// Services dependencies
 	log.Info("Initializing services...")
 	deps := service.Dependencies{
 		Repos:    repositories,
-		Hasher:   hasher.NewSHA256Hasher(cfg.Hasher.Salt),
-		SignKey:  cfg.JWT.SignKey,
-		TokenTTL: cfg.JWT.TokenTTL,
+		Hasher:   passwordHasher,
+		Notifier: newNotifier(cfg.PasswordReset),
+		Token: service.TokenConfig{
+			Keys:               tokenKeys,
+			TTL:                cfg.JWT.TokenTTL,
+			RefreshTTL:         cfg.JWT.RefreshTTL,
+			Issuer:             cfg.JWT.Issuer,
+			Audience:           cfg.JWT.Audience,
+			Leeway:             cfg.JWT.Leeway,
+			RevocationCacheTTL: cfg.Sessions.RevocationCacheTTL,
+			TwoFactorTTL:       cfg.JWT.TwoFactorTTL,
+			ResetTTL:           cfg.PasswordReset.TokenTTL,
+		},
+		ResetMaxRequests:    cfg.PasswordReset.MaxRequests,
+		ResetRequestsWindow: cfg.PasswordReset.RequestsWindow,
+		TwoFactor: service.TwoFactorConfig{
+			Issuer:       cfg.TwoFactor.Issuer,
+			Skew:         cfg.TwoFactor.Skew,
+			MaxAttempts:  cfg.TwoFactor.MaxAttempts,
+			LockDuration: cfg.TwoFactor.LockDuration,
+			ImageSize:    cfg.TwoFactor.QRSize,
+		},
+		Lockout: service.LockoutConfig{
+			UserThreshold: cfg.Lockout.UserThreshold,
+			IPThreshold:   cfg.Lockout.IPThreshold,
+			BaseDelay:     cfg.Lockout.BaseDelay,
+			MaxDelay:      cfg.Lockout.MaxDelay,
+			ResetAfter:    cfg.Lockout.ResetAfter,
+		},
+
+		HoldingWallet:  holdingWallet,
+		PromoWallet:    promoWallet,
+		FeeWallet:      feeWallet,
+		InterestWallet: interestWallet,
+		Currency:       cfg.Ledger.Currency,
+		PaymentQR: service.PaymentQR{
+			GUID:         cfg.PaymentQR.GUID,
+			MerchantName: cfg.PaymentQR.MerchantName,
+			MerchantCity: cfg.PaymentQR.MerchantCity,
+			CountryCode:  cfg.PaymentQR.CountryCode,
+			ImageSize:    cfg.PaymentQR.ImageSize,
+		},
+		EscrowTimeout:    cfg.Escrow.DefaultTimeout,
+		EscrowMaxTimeout: cfg.Escrow.MaxTimeout,
+		ApprovalTTL:      cfg.Approvals.DefaultTTL,
+		MaxTransferDelay: cfg.Transfers.MaxDelay,
+
+		VoucherMaxAttempts:    cfg.Vouchers.MaxAttempts,
+		VoucherAttemptsWindow: cfg.Vouchers.AttemptsWindow,
+
+		DisputeWindow:            cfg.Disputes.Window,
+		DisputeMaxAttachmentSize: cfg.Disputes.MaxAttachmentSize,
 	}
 	services := service.NewServices(&deps)
 
+	// Background jobs
+	log.Info("Starting background jobs...")
+	jobs := scheduler.New()
+	jobs.Every("escrow-release-expired", cfg.Escrow.ReleaseInterval, services.Escrow.ReleaseExpired)
+	jobs.Every("approvals-expire-pending", cfg.Approvals.ExpireInterval, services.Approval.ExpirePending)
+	jobs.Every("transfers-execute-scheduled", cfg.Transfers.ExecuteInterval, services.Scheduled.ExecuteDue)
+	jobs.Every("allowances-pay-due", cfg.Allowances.Interval, services.Family.PayAllowances)
+	jobs.Every("credit-accrue-fees", cfg.Credit.FeeInterval, services.Credit.AccrueFees)
+	jobs.Every("interest-accrue-pay", cfg.Interest.Interval, services.Interest.AccrueAndPay)
+	jobs.Every("sessions-cleanup-revoked", cfg.Sessions.CleanupInterval, services.Auth.CleanupRevokedTokens)
+	jobs.Every("lockout-cleanup-attempts", cfg.Lockout.CleanupInterval, services.Lockout.CleanupAttempts)
+
 	// Echo handler
 	log.Info("Initializing handlers and routes...")
 	handler := echo.New()
@@ -69,13 +175,84 @@ func Run(configPath string) {
 	case s := <-interrupt:
 		log.Info("app - Run - signal: " + s.String())
 	case err = <-httpServer.Notify():
-		log.Error(fmt.Sprintf("app - Run - httpServer.Notify: %w", err))
+		log.Error(fmt.Sprintf("app - Run - httpServer.Notify: %v", err))
 	}
 
 	// Graceful shutdown
 	log.Info("Shutting down...")
 	err = httpServer.Shutdown()
 	if err != nil {
-		log.Error(fmt.Sprintf("app - Run - httpServer.Shutdown: %w", err))
+		log.Error(fmt.Sprintf("app - Run - httpServer.Shutdown: %v", err))
+	}
+	jobs.Shutdown()
+}
+
+// newPasswordHasher hashes new passwords with the configured algorithm and keeps verifying legacy SHA-256 hashes
+func newPasswordHasher(cfg config.Hasher) (hasher.PasswordHasher, error) {
+	var current hasher.PasswordHasher
+	switch cfg.Algorithm {
+	case "argon2id":
+		current = hasher.NewArgon2idHasher(hasher.Argon2Params{
+			Time:    cfg.Argon2Time,
+			Memory:  cfg.Argon2Memory,
+			Threads: cfg.Argon2Threads,
+		})
+	case "bcrypt":
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid bcrypt cost %d", cfg.BcryptCost)
+		}
+		current = hasher.NewBcryptHasher(cfg.BcryptCost)
+	default:
+		return nil, fmt.Errorf("unknown password hashing algorithm %q", cfg.Algorithm)
+	}
+
+	return hasher.NewMigratingHasher(current, hasher.NewSHA256Hasher(cfg.Salt)), nil
+}
+
+// newLoginAttemptRepo picks where failed sign-ins are counted
+func newLoginAttemptRepo(cfg config.Lockout, pg *postgres.Postgres) (repo.LoginAttempt, error) {
+	switch cfg.Storage {
+	case "postgres":
+		return pgdb.NewLoginAttemptRepo(pg), nil
+	case "memory":
+		return memory.NewLoginAttemptRepo(), nil
+	default:
+		return nil, fmt.Errorf("unknown lockout storage %q", cfg.Storage)
+	}
+}
+
+// newNotifier delivers password reset tokens to the outbox file, or to the log when there is none
+func newNotifier(cfg config.PasswordReset) notify.Notifier {
+	if cfg.OutboxFile == "" {
+		return notify.NewLog()
+	}
+	return notify.NewOutbox(cfg.OutboxFile)
+}
+
+// newTokenKeys reads the PEM files of the configured JWT keys
+func newTokenKeys(cfg config.JWT) (*keyset.KeySet, error) {
+	keys := make([]keyset.Key, 0, len(cfg.Keys))
+	for _, keyCfg := range cfg.Keys {
+		var privatePEM, publicPEM []byte
+		var err error
+
+		if keyCfg.PrivateKeyFile != "" {
+			if privatePEM, err = os.ReadFile(keyCfg.PrivateKeyFile); err != nil {
+				return nil, err
+			}
+		}
+		if keyCfg.PublicKeyFile != "" {
+			if publicPEM, err = os.ReadFile(keyCfg.PublicKeyFile); err != nil {
+				return nil, err
+			}
+		}
+
+		key, err := keyset.ParseKey(keyCfg.ID, keyCfg.Algorithm, privatePEM, publicPEM, keyCfg.VerifyUntil)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
 	}
+
+	return keyset.New(cfg.SigningKey, keys...)
 }
@@ -0,0 +1,111 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+)
+
+type adminRoutes struct {
+	walletService service.Wallet
+	creditService service.Credit
+	escrowService service.Escrow
+}
+
+func newAdminRoutes(g *echo.Group, services *service.Services) {
+	r := &adminRoutes{
+		walletService: services.Wallet,
+		creditService: services.Credit,
+		escrowService: services.Escrow,
+	}
+
+	g.GET("/reconciliation", r.Reconcile)
+	g.PUT("/wallet/:walletId/credit", r.SetCreditLine)
+	g.POST("/escrow/:escrowId/resolve", r.ResolveEscrow)
+}
+
+func (r *adminRoutes) Reconcile(c echo.Context) error {
+	reconciliation, err := r.walletService.Reconcile(c.Request().Context())
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+		return err
+	}
+
+	type response struct {
+		Reconciliation entity.Reconciliation `json:"reconciliation"`
+	}
+
+	return c.JSON(http.StatusOK, response{Reconciliation: reconciliation})
+}
+
+// creditLineRequest.Rate is the daily fee share of a negative balance
+type creditLineRequest struct {
+	Limit float64 `json:"limit" validate:"gte=0"`
+	Rate  float64 `json:"rate" validate:"gte=0,lte=1"`
+}
+
+func (r *adminRoutes) SetCreditLine(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var input creditLineRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	err = r.creditService.SetCreditLine(c.Request().Context(), walletId, input.Limit, input.Rate)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrCannotGetWallet):
+			newErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrSystemWallet), errors.Is(err, service.ErrInvalidCreditLine):
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+		}
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type resolveEscrowRequest struct {
+	Decision string `json:"decision" validate:"required,oneof=release refund"`
+}
+
+func (r *adminRoutes) ResolveEscrow(c echo.Context) error {
+	escrowId, err := uuid.Parse(c.Param("escrowId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var input resolveEscrowRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	if err = r.escrowService.ResolveEscrow(c.Request().Context(), escrowId, input.Decision); err != nil {
+		return escrowErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
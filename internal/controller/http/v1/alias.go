@@ -0,0 +1,135 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+)
+
+type aliasRoutes struct {
+	aliasService service.Alias
+}
+
+func newAliasRoutes(g *echo.Group, aliasService service.Alias) {
+	r := &aliasRoutes{aliasService: aliasService}
+
+	g.GET("/", r.GetAliases)
+	g.POST("/", r.CreateAlias)
+	g.DELETE("/:alias", r.DeleteAlias)
+	g.PUT("/default-wallet", r.SetDefaultWallet)
+	g.GET("/resolve", r.Resolve)
+}
+
+func (r *aliasRoutes) GetAliases(c echo.Context) error {
+	aliases, err := r.aliasService.GetAliases(c.Request().Context(), getUserId(c))
+	if err != nil {
+		return aliasErrorResponse(c, err)
+	}
+
+	type response struct {
+		Aliases []entity.Alias `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Aliases: aliases})
+}
+
+type createAliasRequest struct {
+	Alias  string    `json:"alias" validate:"required,max=64"`
+	Wallet uuid.UUID `json:"wallet" validate:"required"`
+}
+
+func (r *aliasRoutes) CreateAlias(c echo.Context) error {
+	var input createAliasRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	err := r.aliasService.CreateAlias(c.Request().Context(), getUserId(c), entity.Alias{
+		Alias:  input.Alias,
+		Wallet: input.Wallet,
+	})
+	if err != nil {
+		return aliasErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *aliasRoutes) DeleteAlias(c echo.Context) error {
+	if err := r.aliasService.DeleteAlias(c.Request().Context(), getUserId(c), c.Param("alias")); err != nil {
+		return aliasErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type defaultWalletRequest struct {
+	Wallet *uuid.UUID `json:"wallet"`
+}
+
+// SetDefaultWallet sets the wallet receiving transfers sent to the username, null wallet unsets it
+func (r *aliasRoutes) SetDefaultWallet(c echo.Context) error {
+	var input defaultWalletRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	var wallet uuid.NullUUID
+	if input.Wallet != nil {
+		wallet = uuid.NullUUID{UUID: *input.Wallet, Valid: true}
+	}
+
+	if err := r.aliasService.SetDefaultWallet(c.Request().Context(), getUserId(c), wallet); err != nil {
+		return aliasErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *aliasRoutes) Resolve(c echo.Context) error {
+	to := c.QueryParam("to")
+	if to == "" {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params to is required")
+		return errors.New("to is required")
+	}
+
+	wallet, err := r.aliasService.Resolve(c.Request().Context(), to)
+	if err != nil {
+		return aliasErrorResponse(c, err)
+	}
+
+	type response struct {
+		Wallet uuid.UUID `json:"wallet"`
+	}
+
+	return c.JSON(http.StatusOK, response{Wallet: wallet})
+}
+
+func aliasErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrAliasNotFound),
+		errors.Is(err, service.ErrNoDefaultWallet),
+		errors.Is(err, service.ErrCannotGetWallet),
+		errors.Is(err, service.ErrUserNotFound):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrWalletAccessDenied):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrAliasAlreadyExists), errors.Is(err, service.ErrAmbiguousAlias):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrInvalidAlias):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
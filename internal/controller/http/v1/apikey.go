@@ -0,0 +1,145 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+	"time"
+)
+
+type apiKeyRoutes struct {
+	apiKeyService service.APIKey
+	// owner returns the user whose keys are managed
+	owner func(c echo.Context) (uuid.UUID, error)
+}
+
+// newAPIKeyRoutes lets users manage their own keys
+func newAPIKeyRoutes(g *echo.Group, apiKeyService service.APIKey) {
+	r := &apiKeyRoutes{
+		apiKeyService: apiKeyService,
+		owner: func(c echo.Context) (uuid.UUID, error) {
+			return getUserId(c), nil
+		},
+	}
+	r.register(g)
+}
+
+// newAdminAPIKeyRoutes lets admins manage the keys of the user in the :userId param, e.g. of a service account
+func newAdminAPIKeyRoutes(g *echo.Group, apiKeyService service.APIKey) {
+	r := &apiKeyRoutes{
+		apiKeyService: apiKeyService,
+		owner: func(c echo.Context) (uuid.UUID, error) {
+			return uuid.Parse(c.Param("userId"))
+		},
+	}
+	r.register(g)
+}
+
+func (r *apiKeyRoutes) register(g *echo.Group) {
+	g.POST("/", r.CreateAPIKey)
+	g.GET("/", r.GetAPIKeys)
+	g.DELETE("/:keyId", r.RevokeAPIKey)
+}
+
+// createAPIKeyRequest.Wallets restricts the key to the wallets, empty allows every wallet of the owner
+type createAPIKeyRequest struct {
+	Name      string      `json:"name" validate:"required,max=64"`
+	Scopes    []string    `json:"scopes" validate:"required,min=1"`
+	Wallets   []uuid.UUID `json:"wallets"`
+	ExpiresAt *time.Time  `json:"expires_at"`
+}
+
+func (r *apiKeyRoutes) CreateAPIKey(c echo.Context) error {
+	owner, err := r.owner(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var input createAPIKeyRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	key, secret, err := r.apiKeyService.CreateAPIKey(c.Request().Context(), getUserId(c), entity.APIKey{
+		Owner:     owner,
+		Name:      input.Name,
+		Scopes:    input.Scopes,
+		Wallets:   input.Wallets,
+		ExpiresAt: input.ExpiresAt,
+	})
+	if err != nil {
+		return apiKeyErrorResponse(c, err)
+	}
+
+	type response struct {
+		Uuid   uuid.UUID `json:"uuid"`
+		Key    string    `json:"key"`
+		Prefix string    `json:"prefix"`
+	}
+
+	return c.JSON(http.StatusOK, response{Uuid: key.UUID, Key: secret, Prefix: key.Prefix})
+}
+
+func (r *apiKeyRoutes) GetAPIKeys(c echo.Context) error {
+	owner, err := r.owner(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	keys, err := r.apiKeyService.GetAPIKeys(c.Request().Context(), owner)
+	if err != nil {
+		return apiKeyErrorResponse(c, err)
+	}
+
+	type response struct {
+		Keys []entity.APIKey `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Keys: keys})
+}
+
+func (r *apiKeyRoutes) RevokeAPIKey(c echo.Context) error {
+	owner, err := r.owner(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	keyId, err := uuid.Parse(c.Param("keyId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	if err = r.apiKeyService.RevokeAPIKey(c.Request().Context(), owner, keyId); err != nil {
+		return apiKeyErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func apiKeyErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrAPIKeyNotFound), errors.Is(err, service.ErrUserNotFound),
+		errors.Is(err, service.ErrCannotGetWallet):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrWalletAccessDenied):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrInvalidAPIKey):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
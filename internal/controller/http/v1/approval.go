@@ -0,0 +1,187 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+)
+
+type approvalRoutes struct {
+	approvalService service.Approval
+}
+
+func newApprovalRoutes(g *echo.Group, wallet *echo.Group, approvalService service.Approval) {
+	r := &approvalRoutes{approvalService: approvalService}
+
+	wallet.PUT("/:walletId/approval-policy", r.SetPolicy)
+	wallet.GET("/:walletId/approval-policy", r.GetPolicy)
+	wallet.DELETE("/:walletId/approval-policy", r.DeletePolicy)
+
+	g.GET("/", r.GetPendingTransfers)
+	g.GET("/:transferId", r.GetPendingTransfer)
+	g.POST("/:transferId/approve", r.Approve)
+	g.POST("/:transferId/reject", r.Reject)
+}
+
+type setPolicyRequest struct {
+	Threshold         float64     `json:"threshold" validate:"min=0"`
+	RequiredApprovals int         `json:"required_approvals" validate:"required,min=1"`
+	Approvers         []uuid.UUID `json:"approvers" validate:"required,min=1"`
+	TTLSeconds        int64       `json:"ttl_seconds" validate:"min=0"`
+}
+
+func (r *approvalRoutes) SetPolicy(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var input setPolicyRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	err = r.approvalService.SetPolicy(c.Request().Context(), getUserId(c), entity.ApprovalPolicy{
+		Wallet:            walletId,
+		Threshold:         input.Threshold,
+		RequiredApprovals: input.RequiredApprovals,
+		TTLSeconds:        input.TTLSeconds,
+		Approvers:         input.Approvers,
+	})
+	if err != nil {
+		return approvalErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *approvalRoutes) GetPolicy(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	policy, err := r.approvalService.GetPolicy(c.Request().Context(), getUserId(c), walletId)
+	if err != nil {
+		return approvalErrorResponse(c, err)
+	}
+
+	type response struct {
+		Policy entity.ApprovalPolicy `json:"policy"`
+	}
+
+	return c.JSON(http.StatusOK, response{Policy: policy})
+}
+
+func (r *approvalRoutes) DeletePolicy(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	if err = r.approvalService.DeletePolicy(c.Request().Context(), getUserId(c), walletId); err != nil {
+		return approvalErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *approvalRoutes) GetPendingTransfers(c echo.Context) error {
+	transfers, err := r.approvalService.GetPendingTransfers(c.Request().Context(), getUserId(c))
+	if err != nil {
+		return approvalErrorResponse(c, err)
+	}
+
+	type response struct {
+		Transfers []entity.PendingTransfer `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Transfers: transfers})
+}
+
+func (r *approvalRoutes) GetPendingTransfer(c echo.Context) error {
+	transferId, err := uuid.Parse(c.Param("transferId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	transfer, err := r.approvalService.GetPendingTransfer(c.Request().Context(), getUserId(c), transferId)
+	if err != nil {
+		return approvalErrorResponse(c, err)
+	}
+
+	type response struct {
+		Transfer entity.PendingTransfer `json:"transfer"`
+	}
+
+	return c.JSON(http.StatusOK, response{Transfer: transfer})
+}
+
+func (r *approvalRoutes) Approve(c echo.Context) error {
+	transferId, err := uuid.Parse(c.Param("transferId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	status, err := r.approvalService.Approve(c.Request().Context(), getUserId(c), transferId)
+	if err != nil {
+		return approvalErrorResponse(c, err)
+	}
+
+	type response struct {
+		Status string `json:"status"`
+	}
+
+	return c.JSON(http.StatusOK, response{Status: status})
+}
+
+func (r *approvalRoutes) Reject(c echo.Context) error {
+	transferId, err := uuid.Parse(c.Param("transferId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	if err = r.approvalService.Reject(c.Request().Context(), getUserId(c), transferId); err != nil {
+		return approvalErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func approvalErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrApprovalPolicyNotFound),
+		errors.Is(err, service.ErrPendingTransferNotFound),
+		errors.Is(err, service.ErrCannotGetWallet):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrWalletAccessDenied),
+		errors.Is(err, service.ErrNotApprover),
+		errors.Is(err, service.ErrSelfApproval):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrTransferNotPending),
+		errors.Is(err, service.ErrTransferAlreadyDecided):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrInvalidApprovalPolicy),
+		errors.Is(err, service.ErrApproverNotFound),
+		errors.Is(err, service.ErrNotEnoughMoney):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
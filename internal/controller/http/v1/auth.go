@@ -12,11 +12,18 @@ type authRoutes struct {
 	authService service.Auth
 }
 
-func newAuthRoutes(g *echo.Group, authService service.Auth) {
+func newAuthRoutes(g *echo.Group, authService service.Auth, authMiddleware *AuthMiddleware) {
 	r := &authRoutes{authService: authService}
 
 	g.POST("/sign-up", r.signUp)
 	g.POST("/sign-in", r.signIn)
+	g.POST("/sign-in/2fa", r.signInTwoFactor)
+	g.POST("/refresh", r.refresh)
+	g.POST("/logout", r.logout, authMiddleware.UserIdentity)
+	g.POST("/logout-all", r.logoutAll, authMiddleware.UserIdentity)
+	g.POST("/password", r.changePassword, authMiddleware.UserIdentity)
+	g.POST("/password/reset-request", r.requestPasswordReset)
+	g.POST("/password/reset", r.resetPassword)
 }
 
 type signUpInput struct {
@@ -76,9 +83,10 @@ func (r *authRoutes) signIn(c echo.Context) error {
 		return err
 	}
 
-	token, err := r.authService.GenerateToken(c.Request().Context(), service.InputData{
+	result, err := r.authService.SignIn(c.Request().Context(), service.InputData{
 		Username: input.Username,
 		Password: input.Password,
+		IP:       c.RealIP(),
 	})
 
 	if err != nil {
@@ -86,15 +94,244 @@ func (r *authRoutes) signIn(c echo.Context) error {
 			newErrorResponse(c, http.StatusBadRequest, "invalid username or password")
 			return err
 		}
+		if errors.Is(err, service.ErrSignInLocked) {
+			newErrorResponse(c, http.StatusTooManyRequests, err.Error())
+			return err
+		}
 		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
 		return err
 	}
 
-	type response struct {
-		Token string `json:"token"`
+	if result.TwoFactorToken != "" {
+		type response struct {
+			TwoFactorRequired bool   `json:"two_factor_required"`
+			TwoFactorToken    string `json:"two_factor_token"`
+		}
+
+		return c.JSON(http.StatusOK, response{TwoFactorRequired: true, TwoFactorToken: result.TwoFactorToken})
 	}
 
-	return c.JSON(http.StatusOK, response{
-		Token: token,
-	})
+	return c.JSON(http.StatusOK, newTokenResponse(result.Tokens))
+}
+
+// signInTwoFactorInput.Code is a code of the authenticator app or a recovery code
+type signInTwoFactorInput struct {
+	TwoFactorToken string `json:"two_factor_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+func (r *authRoutes) signInTwoFactor(c echo.Context) error {
+	var input signInTwoFactorInput
+
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	pair, err := r.authService.SignInTwoFactor(c.Request().Context(), input.TwoFactorToken, input.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidTwoFactorToken), errors.Is(err, service.ErrInvalidTwoFactorCode),
+			errors.Is(err, service.ErrTwoFactorNotEnabled):
+			newErrorResponse(c, http.StatusUnauthorized, err.Error())
+		case errors.Is(err, service.ErrTooManyAttempts), errors.Is(err, service.ErrTwoFactorLocked):
+			newErrorResponse(c, http.StatusTooManyRequests, err.Error())
+		default:
+			newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newTokenResponse(pair))
+}
+
+type refreshInput struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+func (r *authRoutes) refresh(c echo.Context) error {
+	var input refreshInput
+
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	pair, err := r.authService.Refresh(c.Request().Context(), input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			newErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return err
+		}
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newTokenResponse(pair))
+}
+
+// logoutInput.RefreshToken is optional, when given its family is revoked together with the access token
+type logoutInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (r *authRoutes) logout(c echo.Context) error {
+	var input logoutInput
+
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	// the middleware has already checked the header
+	token, _ := bearerToken(c.Request())
+	if err := r.authService.Logout(c.Request().Context(), token, input.RefreshToken); err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (r *authRoutes) logoutAll(c echo.Context) error {
+	if err := r.authService.LogoutAll(c.Request().Context(), getUserId(c)); err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			newErrorResponse(c, http.StatusNotFound, err.Error())
+			return err
+		}
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+type changePasswordInput struct {
+	OldPassword string `json:"old_password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,password"`
+}
+
+// changePassword signs the user out everywhere, the client has to sign in again with the new password
+func (r *authRoutes) changePassword(c echo.Context) error {
+	var input changePasswordInput
+
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	err := r.authService.ChangePassword(c.Request().Context(), getUserId(c), input.OldPassword, input.NewPassword)
+	if err != nil {
+		return passwordErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+type requestPasswordResetInput struct {
+	Username string `json:"username" validate:"required,min=4,max=32"`
+}
+
+// requestPasswordReset answers the same whether the username exists or not
+func (r *authRoutes) requestPasswordReset(c echo.Context) error {
+	var input requestPasswordResetInput
+
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	if err := r.authService.RequestPasswordReset(c.Request().Context(), input.Username); err != nil {
+		return passwordErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+type resetPasswordInput struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,password"`
+}
+
+func (r *authRoutes) resetPassword(c echo.Context) error {
+	var input resetPasswordInput
+
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	if err := r.authService.ResetPassword(c.Request().Context(), input.Token, input.NewPassword); err != nil {
+		return passwordErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func passwordErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidPassword), errors.Is(err, service.ErrInvalidResetToken):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrUserNotFound):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
+
+type tokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func newTokenResponse(pair service.TokenPair) tokenResponse {
+	return tokenResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	}
+}
+
+type wellKnownRoutes struct {
+	authService service.Auth
+}
+
+func newWellKnownRoutes(g *echo.Group, authService service.Auth) {
+	r := &wellKnownRoutes{authService: authService}
+
+	g.GET("/jwks.json", r.jwks)
+}
+
+func (r *wellKnownRoutes) jwks(c echo.Context) error {
+	// verifiers refetch after a while to pick up rotated keys
+	c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=300")
+
+	return c.JSON(http.StatusOK, r.authService.JWKS())
 }
@@ -0,0 +1,160 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+	"strconv"
+)
+
+type contactRoutes struct {
+	contactService service.Contact
+}
+
+func newContactRoutes(g *echo.Group, wallet *echo.Group, contactService service.Contact) {
+	r := &contactRoutes{contactService: contactService}
+
+	g.GET("/", r.GetContacts)
+	g.POST("/", r.CreateContact)
+	g.PUT("/:contactId", r.UpdateContact)
+	g.DELETE("/:contactId", r.DeleteContact)
+
+	wallet.GET("/:walletId/counterparties", r.GetCounterparties)
+}
+
+func (r *contactRoutes) GetContacts(c echo.Context) error {
+	contacts, err := r.contactService.GetContacts(c.Request().Context(), getUserId(c))
+	if err != nil {
+		return contactErrorResponse(c, err)
+	}
+
+	type response struct {
+		Contacts []entity.Contact `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Contacts: contacts})
+}
+
+// contactRequest.To is a wallet id or an alias
+type contactRequest struct {
+	Name string `json:"name" validate:"required,max=128"`
+	To   string `json:"to" validate:"required,max=64"`
+}
+
+func (r *contactRoutes) CreateContact(c echo.Context) error {
+	var input contactRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	contact, err := r.contactService.CreateContact(c.Request().Context(), getUserId(c), entity.Contact{
+		Name: input.Name,
+		To:   input.To,
+	})
+	if err != nil {
+		return contactErrorResponse(c, err)
+	}
+
+	type response struct {
+		Uuid uuid.UUID `json:"uuid"`
+	}
+
+	return c.JSON(http.StatusOK, response{Uuid: contact})
+}
+
+func (r *contactRoutes) UpdateContact(c echo.Context) error {
+	contactId, err := uuid.Parse(c.Param("contactId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var input contactRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	err = r.contactService.UpdateContact(c.Request().Context(), getUserId(c), entity.Contact{
+		UUID: contactId,
+		Name: input.Name,
+		To:   input.To,
+	})
+	if err != nil {
+		return contactErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *contactRoutes) DeleteContact(c echo.Context) error {
+	contactId, err := uuid.Parse(c.Param("contactId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	if err = r.contactService.DeleteContact(c.Request().Context(), getUserId(c), contactId); err != nil {
+		return contactErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *contactRoutes) GetCounterparties(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var limit int
+	if param := c.QueryParam("limit"); param != "" {
+		if limit, err = strconv.Atoi(param); err != nil {
+			newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+			return err
+		}
+	}
+
+	counterparties, err := r.contactService.GetCounterparties(c.Request().Context(), getUserId(c), walletId, limit)
+	if err != nil {
+		return contactErrorResponse(c, err)
+	}
+
+	type response struct {
+		Counterparties []entity.Counterparty `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Counterparties: counterparties})
+}
+
+func contactErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrContactNotFound),
+		errors.Is(err, service.ErrCannotGetWallet),
+		errors.Is(err, service.ErrAliasNotFound),
+		errors.Is(err, service.ErrNoDefaultWallet):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrWalletAccessDenied):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrContactAlreadyExists), errors.Is(err, service.ErrAmbiguousAlias):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
@@ -0,0 +1,276 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"io"
+	"mime"
+	"net/http"
+)
+
+type disputeRoutes struct {
+	disputeService service.Dispute
+}
+
+func newDisputeRoutes(g *echo.Group, admin *echo.Group, wallet *echo.Group, disputeService service.Dispute) {
+	r := &disputeRoutes{disputeService: disputeService}
+
+	g.POST("/", r.OpenDispute)
+	g.GET("/:disputeId", r.GetDispute)
+	g.POST("/:disputeId/comments", r.AddComment)
+	g.POST("/:disputeId/attachments", r.AddAttachment)
+	g.GET("/:disputeId/attachments/:attachmentId", r.GetAttachment)
+
+	wallet.GET("/:walletId/disputes", r.GetWalletDisputes)
+
+	admin.GET("/disputes", r.GetDisputes)
+	admin.POST("/disputes/:disputeId/resolve", r.ResolveDispute)
+}
+
+// openDisputeRequest.Amount zero disputes the whole transaction
+type openDisputeRequest struct {
+	Transaction uuid.UUID `json:"transaction" validate:"required"`
+	Amount      float64   `json:"amount" validate:"gte=0"`
+	Reason      string    `json:"reason" validate:"required,max=1024"`
+}
+
+func (r *disputeRoutes) OpenDispute(c echo.Context) error {
+	var input openDisputeRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	disputeUUID, err := r.disputeService.OpenDispute(c.Request().Context(), service.DisputeInput{
+		UserUUID:    getUserId(c),
+		Transaction: input.Transaction,
+		Amount:      input.Amount,
+		Reason:      input.Reason,
+	})
+	if err != nil {
+		return disputeErrorResponse(c, err)
+	}
+
+	type response struct {
+		Uuid uuid.UUID `json:"uuid"`
+	}
+
+	return c.JSON(http.StatusOK, response{Uuid: disputeUUID})
+}
+
+func (r *disputeRoutes) GetDispute(c echo.Context) error {
+	disputeId, err := uuid.Parse(c.Param("disputeId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	dispute, err := r.disputeService.GetDispute(c.Request().Context(), getUserId(c), disputeId)
+	if err != nil {
+		return disputeErrorResponse(c, err)
+	}
+
+	type response struct {
+		Dispute entity.Dispute `json:"dispute"`
+	}
+
+	return c.JSON(http.StatusOK, response{Dispute: dispute})
+}
+
+func (r *disputeRoutes) GetWalletDisputes(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	disputes, err := r.disputeService.GetWalletDisputes(c.Request().Context(), getUserId(c), walletId)
+	if err != nil {
+		return disputeErrorResponse(c, err)
+	}
+
+	type response struct {
+		Disputes []entity.Dispute `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Disputes: disputes})
+}
+
+type disputeCommentRequest struct {
+	Note string `json:"note" validate:"required,max=1024"`
+}
+
+func (r *disputeRoutes) AddComment(c echo.Context) error {
+	disputeId, err := uuid.Parse(c.Param("disputeId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var input disputeCommentRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	if err = r.disputeService.AddComment(c.Request().Context(), getUserId(c), disputeId, input.Note); err != nil {
+		return disputeErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// AddAttachment takes the multipart "file" field, the content type is detected from the data
+func (r *disputeRoutes) AddAttachment(c echo.Context) error {
+	disputeId, err := uuid.Parse(c.Param("disputeId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(data))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	attachmentUUID, err := r.disputeService.AddAttachment(c.Request().Context(), getUserId(c), entity.Attachment{
+		Dispute:     disputeId,
+		Name:        header.Filename,
+		ContentType: contentType,
+		Data:        data,
+	})
+	if err != nil {
+		return disputeErrorResponse(c, err)
+	}
+
+	type response struct {
+		Uuid uuid.UUID `json:"uuid"`
+	}
+
+	return c.JSON(http.StatusOK, response{Uuid: attachmentUUID})
+}
+
+func (r *disputeRoutes) GetAttachment(c echo.Context) error {
+	disputeId, err := uuid.Parse(c.Param("disputeId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	attachmentId, err := uuid.Parse(c.Param("attachmentId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	attachment, err := r.disputeService.GetAttachment(c.Request().Context(), getUserId(c), disputeId, attachmentId)
+	if err != nil {
+		return disputeErrorResponse(c, err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{
+		"filename": attachment.Name,
+	}))
+
+	return c.Blob(http.StatusOK, attachment.ContentType, attachment.Data)
+}
+
+// GetDisputes lists disputes for admins, the status query param filters them
+func (r *disputeRoutes) GetDisputes(c echo.Context) error {
+	disputes, err := r.disputeService.GetDisputes(c.Request().Context(), c.QueryParam("status"))
+	if err != nil {
+		return disputeErrorResponse(c, err)
+	}
+
+	type response struct {
+		Disputes []entity.Dispute `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Disputes: disputes})
+}
+
+type resolveDisputeRequest struct {
+	Decision string `json:"decision" validate:"required,oneof=payer receiver"`
+	Note     string `json:"note" validate:"max=1024"`
+}
+
+func (r *disputeRoutes) ResolveDispute(c echo.Context) error {
+	disputeId, err := uuid.Parse(c.Param("disputeId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var input resolveDisputeRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	err = r.disputeService.ResolveDispute(c.Request().Context(), getUserId(c), disputeId, input.Decision, input.Note)
+	if err != nil {
+		return disputeErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func disputeErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrDisputeNotFound),
+		errors.Is(err, service.ErrAttachmentNotFound),
+		errors.Is(err, service.ErrTransactionNotFound),
+		errors.Is(err, service.ErrCannotGetWallet):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrWalletAccessDenied), errors.Is(err, service.ErrSystemWallet):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrDisputeAlreadyExists), errors.Is(err, service.ErrDisputeNotOpen):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrInvalidDispute),
+		errors.Is(err, service.ErrInvalidDisputeDecision),
+		errors.Is(err, service.ErrInvalidAttachment),
+		errors.Is(err, service.ErrDisputeWindowClosed):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
@@ -9,6 +9,12 @@ import (
 var (
 	ErrInvalidAuthHeader = fmt.Errorf("invalid auth header")
 	ErrCannotParseToken  = fmt.Errorf("cannot parse token")
+	ErrTokenExpired      = fmt.Errorf("token expired")
+	ErrTokenRevoked      = fmt.Errorf("token revoked")
+	ErrAdminOnly         = fmt.Errorf("admin role required")
+	ErrAPIKeyNotAllowed  = fmt.Errorf("route is not available to api keys")
+	ErrAPIKeyScope       = fmt.Errorf("api key lacks scope")
+	ErrAPIKeyWallet      = fmt.Errorf("api key is not allowed to access the wallet")
 )
 
 func newErrorResponse(c echo.Context, errStatus int, message string) {
@@ -0,0 +1,159 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+	"time"
+)
+
+type escrowRoutes struct {
+	escrowService service.Escrow
+}
+
+func newEscrowRoutes(g *echo.Group, escrowService service.Escrow) {
+	r := &escrowRoutes{escrowService: escrowService}
+
+	g.POST("/", r.CreateEscrow)
+	g.GET("/", r.GetWalletEscrows)
+	g.GET("/:escrowId", r.GetEscrow)
+	g.POST("/:escrowId/release", r.ReleaseEscrow)
+	g.POST("/:escrowId/cancel", r.CancelEscrow)
+}
+
+// createEscrowRequest.TOTPCode is needed above the two-factor transfer threshold of the user
+type createEscrowRequest struct {
+	Buyer          uuid.UUID `json:"buyer" validate:"required"`
+	Seller         uuid.UUID `json:"seller" validate:"required"`
+	Amount         float64   `json:"amount" validate:"required,gt=0"`
+	TimeoutSeconds int64     `json:"timeout_seconds" validate:"min=0"`
+	TOTPCode       string    `json:"totp_code"`
+}
+
+func (r *escrowRoutes) CreateEscrow(c echo.Context) error {
+	var input createEscrowRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	escrowUUID, err := r.escrowService.CreateEscrow(c.Request().Context(), service.EscrowInput{
+		UserUUID: getUserId(c),
+		Buyer:    input.Buyer,
+		Seller:   input.Seller,
+		Amount:   input.Amount,
+		Timeout:  time.Duration(input.TimeoutSeconds) * time.Second,
+		TOTPCode: input.TOTPCode,
+	})
+	if err != nil {
+		return escrowErrorResponse(c, err)
+	}
+
+	type response struct {
+		Uuid uuid.UUID `json:"uuid"`
+	}
+
+	return c.JSON(http.StatusOK, response{Uuid: escrowUUID})
+}
+
+func (r *escrowRoutes) GetWalletEscrows(c echo.Context) error {
+	walletId, err := uuid.Parse(c.QueryParam("wallet"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	escrows, err := r.escrowService.GetWalletEscrows(c.Request().Context(), getUserId(c), walletId)
+	if err != nil {
+		return escrowErrorResponse(c, err)
+	}
+
+	type response struct {
+		Escrows []entity.Escrow `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Escrows: escrows})
+}
+
+func (r *escrowRoutes) GetEscrow(c echo.Context) error {
+	escrowId, err := uuid.Parse(c.Param("escrowId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	escrow, err := r.escrowService.GetEscrow(c.Request().Context(), getUserId(c), escrowId)
+	if err != nil {
+		return escrowErrorResponse(c, err)
+	}
+
+	type response struct {
+		Escrow entity.Escrow `json:"escrow"`
+	}
+
+	return c.JSON(http.StatusOK, response{Escrow: escrow})
+}
+
+func (r *escrowRoutes) ReleaseEscrow(c echo.Context) error {
+	escrowId, err := uuid.Parse(c.Param("escrowId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	if err = r.escrowService.ReleaseEscrow(c.Request().Context(), getUserId(c), escrowId); err != nil {
+		return escrowErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *escrowRoutes) CancelEscrow(c echo.Context) error {
+	escrowId, err := uuid.Parse(c.Param("escrowId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	if err = r.escrowService.CancelEscrow(c.Request().Context(), getUserId(c), escrowId); err != nil {
+		return escrowErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func escrowErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrEscrowNotFound), errors.Is(err, service.ErrCannotGetWallet):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrWalletAccessDenied),
+		errors.Is(err, service.ErrSystemWallet),
+		errors.Is(err, service.ErrSpendLimitExceeded),
+		errors.Is(err, service.ErrCounterpartyNotAllowed),
+		errors.Is(err, service.ErrChildSpendLimitExceeded),
+		errors.Is(err, service.ErrEscrowNeedsApproval),
+		errors.Is(err, service.ErrTwoFactorCodeRequired),
+		errors.Is(err, service.ErrInvalidTwoFactorCode):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrTwoFactorLocked):
+		newErrorResponse(c, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, service.ErrEscrowAlreadySettled):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrEscrowSameWallet),
+		errors.Is(err, service.ErrInvalidEscrowTimeout),
+		errors.Is(err, service.ErrInvalidEscrowDecision),
+		errors.Is(err, service.ErrNotEnoughMoney):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
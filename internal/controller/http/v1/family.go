@@ -0,0 +1,246 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+)
+
+type familyRoutes struct {
+	familyService service.Family
+}
+
+func newFamilyRoutes(wallet *echo.Group, familyService service.Family) {
+	r := &familyRoutes{familyService: familyService}
+
+	wallet.POST("/:walletId/children", r.AddChild)
+	wallet.GET("/:walletId/children", r.GetChildren)
+	wallet.PUT("/:walletId/children/:childId/policy", r.SetPolicy)
+	wallet.GET("/:walletId/children/:childId/policy", r.GetPolicy)
+	wallet.POST("/:walletId/children/:childId/allowances", r.CreateAllowance)
+	wallet.GET("/:walletId/children/:childId/allowances", r.GetAllowances)
+	wallet.DELETE("/:walletId/children/:childId/allowances/:allowanceId", r.DeleteAllowance)
+}
+
+type addChildRequest struct {
+	Child *uuid.UUID `json:"child"`
+}
+
+// AddChild creates a new child wallet, or links an existing one when the child is given
+func (r *familyRoutes) AddChild(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var input addChildRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	child := uuid.Nil
+	if input.Child != nil {
+		child = *input.Child
+		err = r.familyService.LinkChild(c.Request().Context(), getUserId(c), walletId, child)
+	} else {
+		child, err = r.familyService.CreateChild(c.Request().Context(), getUserId(c), walletId)
+	}
+	if err != nil {
+		return familyErrorResponse(c, err)
+	}
+
+	type response struct {
+		Uuid uuid.UUID `json:"uuid"`
+	}
+
+	return c.JSON(http.StatusOK, response{Uuid: child})
+}
+
+func (r *familyRoutes) GetChildren(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	children, err := r.familyService.GetChildren(c.Request().Context(), getUserId(c), walletId)
+	if err != nil {
+		return familyErrorResponse(c, err)
+	}
+
+	type response struct {
+		Children []entity.Wallet `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Children: children})
+}
+
+type childPolicyRequest struct {
+	SpendLimit     *float64    `json:"spend_limit" validate:"omitempty,gt=0"`
+	LimitPeriod    string      `json:"limit_period" validate:"required,oneof=day week month"`
+	Counterparties []uuid.UUID `json:"counterparties"`
+}
+
+func (r *familyRoutes) SetPolicy(c echo.Context) error {
+	walletId, childId, err := parseChildParams(c)
+	if err != nil {
+		return err
+	}
+
+	var input childPolicyRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	err = r.familyService.SetChildPolicy(c.Request().Context(), getUserId(c), walletId, entity.ChildPolicy{
+		Wallet:         childId,
+		SpendLimit:     input.SpendLimit,
+		LimitPeriod:    input.LimitPeriod,
+		Counterparties: input.Counterparties,
+	})
+	if err != nil {
+		return familyErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *familyRoutes) GetPolicy(c echo.Context) error {
+	walletId, childId, err := parseChildParams(c)
+	if err != nil {
+		return err
+	}
+
+	policy, err := r.familyService.GetChildPolicy(c.Request().Context(), getUserId(c), walletId, childId)
+	if err != nil {
+		return familyErrorResponse(c, err)
+	}
+
+	type response struct {
+		Policy entity.ChildPolicy `json:"policy"`
+	}
+
+	return c.JSON(http.StatusOK, response{Policy: policy})
+}
+
+type allowanceRequest struct {
+	Amount float64 `json:"amount" validate:"required,gt=0"`
+	Period string  `json:"period" validate:"required,oneof=day week month"`
+}
+
+func (r *familyRoutes) CreateAllowance(c echo.Context) error {
+	walletId, childId, err := parseChildParams(c)
+	if err != nil {
+		return err
+	}
+
+	var input allowanceRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	allowance, err := r.familyService.CreateAllowance(c.Request().Context(), getUserId(c), entity.Allowance{
+		Parent: walletId,
+		Child:  childId,
+		Amount: input.Amount,
+		Period: input.Period,
+	})
+	if err != nil {
+		return familyErrorResponse(c, err)
+	}
+
+	type response struct {
+		Uuid uuid.UUID `json:"uuid"`
+	}
+
+	return c.JSON(http.StatusOK, response{Uuid: allowance})
+}
+
+func (r *familyRoutes) GetAllowances(c echo.Context) error {
+	walletId, childId, err := parseChildParams(c)
+	if err != nil {
+		return err
+	}
+
+	allowances, err := r.familyService.GetAllowances(c.Request().Context(), getUserId(c), walletId, childId)
+	if err != nil {
+		return familyErrorResponse(c, err)
+	}
+
+	type response struct {
+		Allowances []entity.Allowance `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Allowances: allowances})
+}
+
+func (r *familyRoutes) DeleteAllowance(c echo.Context) error {
+	walletId, childId, err := parseChildParams(c)
+	if err != nil {
+		return err
+	}
+
+	allowanceId, err := uuid.Parse(c.Param("allowanceId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	err = r.familyService.DeleteAllowance(c.Request().Context(), getUserId(c), walletId, childId, allowanceId)
+	if err != nil {
+		return familyErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func parseChildParams(c echo.Context) (uuid.UUID, uuid.UUID, error) {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	childId, err := uuid.Parse(c.Param("childId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	return walletId, childId, nil
+}
+
+func familyErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrCannotGetWallet),
+		errors.Is(err, service.ErrChildPolicyNotFound),
+		errors.Is(err, service.ErrAllowanceNotFound):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrWalletAccessDenied):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrNotChildWallet),
+		errors.Is(err, service.ErrInvalidWalletHierarchy),
+		errors.Is(err, service.ErrInvalidPeriod):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
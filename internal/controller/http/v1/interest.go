@@ -0,0 +1,170 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+)
+
+type interestRoutes struct {
+	interestService service.Interest
+}
+
+func newInterestRoutes(admin *echo.Group, wallet *echo.Group, interestService service.Interest) {
+	r := &interestRoutes{interestService: interestService}
+
+	wallet.GET("/:walletId/interest", r.GetSummary)
+
+	admin.POST("/interest-products", r.CreateProduct)
+	admin.GET("/interest-products", r.GetProducts)
+	admin.PUT("/interest-products/:productId", r.UpdateProduct)
+	admin.PUT("/wallet/:walletId/interest", r.AssignProduct)
+}
+
+// interestProductRequest.DayCount is 360 or 365, zero means 365
+type interestProductRequest struct {
+	Name       string  `json:"name" validate:"required,max=128"`
+	APR        float64 `json:"apr" validate:"required,gt=0,lte=100"`
+	DayCount   int     `json:"day_count" validate:"omitempty,oneof=360 365"`
+	MinBalance float64 `json:"min_balance" validate:"gte=0"`
+	Active     *bool   `json:"active"`
+}
+
+func (r *interestRoutes) CreateProduct(c echo.Context) error {
+	var input interestProductRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	productUUID, err := r.interestService.CreateProduct(c.Request().Context(), entity.InterestProduct{
+		Name:       input.Name,
+		APR:        input.APR,
+		DayCount:   input.DayCount,
+		MinBalance: input.MinBalance,
+		Active:     input.Active == nil || *input.Active,
+	})
+	if err != nil {
+		return interestErrorResponse(c, err)
+	}
+
+	type response struct {
+		Uuid uuid.UUID `json:"uuid"`
+	}
+
+	return c.JSON(http.StatusOK, response{Uuid: productUUID})
+}
+
+func (r *interestRoutes) GetProducts(c echo.Context) error {
+	products, err := r.interestService.GetProducts(c.Request().Context())
+	if err != nil {
+		return interestErrorResponse(c, err)
+	}
+
+	type response struct {
+		Products []entity.InterestProduct `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Products: products})
+}
+
+func (r *interestRoutes) UpdateProduct(c echo.Context) error {
+	productId, err := uuid.Parse(c.Param("productId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var input interestProductRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	err = r.interestService.UpdateProduct(c.Request().Context(), entity.InterestProduct{
+		UUID:       productId,
+		Name:       input.Name,
+		APR:        input.APR,
+		DayCount:   input.DayCount,
+		MinBalance: input.MinBalance,
+		Active:     input.Active == nil || *input.Active,
+	})
+	if err != nil {
+		return interestErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// assignProductRequest.Product null removes the product from the wallet
+type assignProductRequest struct {
+	Product uuid.NullUUID `json:"product"`
+}
+
+func (r *interestRoutes) AssignProduct(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var input assignProductRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = r.interestService.AssignProduct(c.Request().Context(), walletId, input.Product); err != nil {
+		return interestErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *interestRoutes) GetSummary(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	summary, err := r.interestService.GetSummary(c.Request().Context(), getUserId(c), walletId)
+	if err != nil {
+		return interestErrorResponse(c, err)
+	}
+
+	type response struct {
+		Interest entity.InterestSummary `json:"interest"`
+	}
+
+	return c.JSON(http.StatusOK, response{Interest: summary})
+}
+
+func interestErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrInterestProductNotFound), errors.Is(err, service.ErrCannotGetWallet):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrWalletAccessDenied):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrInterestProductAlreadyExists):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrInvalidInterestProduct), errors.Is(err, service.ErrSystemWallet):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
@@ -0,0 +1,202 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+	"time"
+)
+
+type invoiceRoutes struct {
+	invoiceService service.Invoice
+}
+
+func newInvoiceRoutes(g *echo.Group, invoiceService service.Invoice) {
+	r := &invoiceRoutes{invoiceService: invoiceService}
+
+	g.POST("/", r.CreateInvoice)
+	g.GET("/", r.GetInvoices)
+	g.GET("/:invoiceId", r.GetInvoice)
+	g.POST("/:invoiceId/pay", r.PayInvoice)
+	g.POST("/:invoiceId/cancel", r.CancelInvoice)
+}
+
+type invoiceItemRequest struct {
+	Name     string  `json:"name" validate:"required,max=255"`
+	Quantity int     `json:"quantity" validate:"required,gt=0"`
+	Price    float64 `json:"price" validate:"gte=0"`
+}
+
+type createInvoiceRequest struct {
+	Wallet       uuid.UUID            `json:"wallet" validate:"required"`
+	Amount       float64              `json:"amount" validate:"required_without=Items,gte=0"`
+	Currency     string               `json:"currency" validate:"omitempty,len=3"`
+	Description  string               `json:"description"`
+	Items        []invoiceItemRequest `json:"items" validate:"dive"`
+	AllowPartial bool                 `json:"allow_partial"`
+	ExpiresAt    *time.Time           `json:"expires_at"`
+}
+
+func (r *invoiceRoutes) CreateInvoice(c echo.Context) error {
+	var input createInvoiceRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	items := make([]entity.InvoiceItem, 0, len(input.Items))
+	for _, item := range input.Items {
+		items = append(items, entity.InvoiceItem{
+			Name:     item.Name,
+			Quantity: item.Quantity,
+			Price:    item.Price,
+		})
+	}
+
+	invoice, err := r.invoiceService.CreateInvoice(c.Request().Context(), getUserId(c), entity.Invoice{
+		Wallet:       input.Wallet,
+		Amount:       input.Amount,
+		Currency:     input.Currency,
+		Description:  input.Description,
+		Items:        items,
+		AllowPartial: input.AllowPartial,
+		ExpiresAt:    input.ExpiresAt,
+	})
+	if err != nil {
+		return invoiceErrorResponse(c, err)
+	}
+
+	type response struct {
+		Uuid uuid.UUID `json:"uuid"`
+	}
+
+	return c.JSON(http.StatusOK, response{Uuid: invoice})
+}
+
+func (r *invoiceRoutes) GetInvoices(c echo.Context) error {
+	invoices, err := r.invoiceService.GetInvoices(c.Request().Context(), getUserId(c))
+	if err != nil {
+		return invoiceErrorResponse(c, err)
+	}
+
+	type response struct {
+		Invoices []entity.Invoice `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Invoices: invoices})
+}
+
+func (r *invoiceRoutes) GetInvoice(c echo.Context) error {
+	invoiceId, err := uuid.Parse(c.Param("invoiceId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	invoice, err := r.invoiceService.GetInvoice(c.Request().Context(), invoiceId)
+	if err != nil {
+		return invoiceErrorResponse(c, err)
+	}
+
+	type response struct {
+		Invoice entity.Invoice `json:"invoice"`
+	}
+
+	return c.JSON(http.StatusOK, response{Invoice: invoice})
+}
+
+// payInvoiceRequest.TOTPCode is needed above the two-factor transfer threshold of the user
+type payInvoiceRequest struct {
+	Wallet   uuid.UUID `json:"wallet" validate:"required"`
+	Amount   float64   `json:"amount" validate:"gte=0"`
+	TOTPCode string    `json:"totp_code"`
+}
+
+func (r *invoiceRoutes) PayInvoice(c echo.Context) error {
+	invoiceId, err := uuid.Parse(c.Param("invoiceId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var input payInvoiceRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	invoice, err := r.invoiceService.PayInvoice(c.Request().Context(), service.InvoicePaymentInput{
+		UserUUID: getUserId(c),
+		Invoice:  invoiceId,
+		Wallet:   input.Wallet,
+		Amount:   input.Amount,
+		TOTPCode: input.TOTPCode,
+	})
+	if err != nil {
+		return invoiceErrorResponse(c, err)
+	}
+
+	type response struct {
+		Invoice entity.Invoice `json:"invoice"`
+	}
+
+	return c.JSON(http.StatusOK, response{Invoice: invoice})
+}
+
+func (r *invoiceRoutes) CancelInvoice(c echo.Context) error {
+	invoiceId, err := uuid.Parse(c.Param("invoiceId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	if err = r.invoiceService.CancelInvoice(c.Request().Context(), getUserId(c), invoiceId); err != nil {
+		return invoiceErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func invoiceErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvoiceNotFound),
+		errors.Is(err, service.ErrMerchantNotFound),
+		errors.Is(err, service.ErrCannotGetWallet):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrWalletAccessDenied),
+		errors.Is(err, service.ErrSystemWallet),
+		errors.Is(err, service.ErrSpendLimitExceeded),
+		errors.Is(err, service.ErrCounterpartyNotAllowed),
+		errors.Is(err, service.ErrChildSpendLimitExceeded),
+		errors.Is(err, service.ErrInvoiceNeedsApproval),
+		errors.Is(err, service.ErrTwoFactorCodeRequired),
+		errors.Is(err, service.ErrInvalidTwoFactorCode):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrTwoFactorLocked):
+		newErrorResponse(c, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, service.ErrInvoiceNotPayable), errors.Is(err, service.ErrInvoiceExpired):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrInvalidInvoice),
+		errors.Is(err, service.ErrInvalidCurrency),
+		errors.Is(err, service.ErrInvalidInvoiceAmount),
+		errors.Is(err, service.ErrNotSettlementWallet),
+		errors.Is(err, service.ErrNotEnoughMoney):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
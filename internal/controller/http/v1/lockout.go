@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+)
+
+type lockoutRoutes struct {
+	lockoutService service.Lockout
+}
+
+func newLockoutRoutes(admin *echo.Group, lockoutService service.Lockout) {
+	r := &lockoutRoutes{lockoutService: lockoutService}
+
+	admin.GET("/lockouts", r.GetLockouts)
+	admin.POST("/users/:userId/unlock", r.Unlock)
+}
+
+func (r *lockoutRoutes) GetLockouts(c echo.Context) error {
+	locked, err := r.lockoutService.GetLockouts(c.Request().Context())
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+		return err
+	}
+
+	type response struct {
+		Lockouts []entity.LoginAttempts `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Lockouts: locked})
+}
+
+func (r *lockoutRoutes) Unlock(c echo.Context) error {
+	userId, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	if err = r.lockoutService.Unlock(c.Request().Context(), getUserId(c), userId); err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			newErrorResponse(c, http.StatusNotFound, err.Error())
+			return err
+		}
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+)
+
+type meRoutes struct {
+	userService service.User
+}
+
+func newMeRoutes(g *echo.Group, userService service.User) {
+	r := &meRoutes{userService: userService}
+
+	g.GET("/me", r.GetProfile)
+	g.PATCH("/me", r.UpdateProfile)
+	g.DELETE("/me", r.DeleteAccount)
+}
+
+type profileResponse struct {
+	Profile entity.Profile `json:"profile"`
+}
+
+func (r *meRoutes) GetProfile(c echo.Context) error {
+	profile, err := r.userService.GetProfile(c.Request().Context(), getUserId(c))
+	if err != nil {
+		return userErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, profileResponse{Profile: profile})
+}
+
+// updateProfileRequest fields are optional, an empty display_name removes it
+type updateProfileRequest struct {
+	Username    *string `json:"username" validate:"omitempty,min=4,max=32"`
+	DisplayName *string `json:"display_name" validate:"omitempty,max=64"`
+}
+
+func (r *meRoutes) UpdateProfile(c echo.Context) error {
+	var input updateProfileRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	profile, err := r.userService.UpdateProfile(c.Request().Context(), getUserId(c), service.ProfileInput{
+		Username:    input.Username,
+		DisplayName: input.DisplayName,
+	})
+	if err != nil {
+		return userErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, profileResponse{Profile: profile})
+}
+
+// deleteAccountRequest.Password confirms the deletion, a stolen access token alone can not delete the account
+type deleteAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+func (r *meRoutes) DeleteAccount(c echo.Context) error {
+	var input deleteAccountRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	if err := r.userService.DeleteAccount(c.Request().Context(), getUserId(c), input.Password); err != nil {
+		return userErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func userErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrUserAlreadyExists), errors.Is(err, service.ErrWalletsNotEmpty):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrInvalidPassword):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
@@ -0,0 +1,206 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+)
+
+type memberRoutes struct {
+	memberService service.Member
+}
+
+func newMemberRoutes(g *echo.Group, wallet *echo.Group, memberService service.Member) {
+	r := &memberRoutes{memberService: memberService}
+
+	wallet.GET("/:walletId/members", r.GetMembers)
+	wallet.POST("/:walletId/members", r.InviteMember)
+	wallet.PATCH("/:walletId/members/:memberId", r.UpdateMember)
+	wallet.DELETE("/:walletId/members/:memberId", r.RemoveMember)
+	wallet.POST("/:walletId/invitation/accept", r.AcceptInvitation)
+	wallet.POST("/:walletId/invitation/decline", r.DeclineInvitation)
+
+	g.GET("/", r.GetInvitations)
+}
+
+func (r *memberRoutes) GetMembers(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	members, err := r.memberService.GetMembers(c.Request().Context(), getUserId(c), walletId)
+	if err != nil {
+		return memberErrorResponse(c, err)
+	}
+
+	type response struct {
+		Members []entity.WalletMember `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Members: members})
+}
+
+type inviteMemberRequest struct {
+	Member     uuid.UUID `json:"member" validate:"required"`
+	Role       string    `json:"role" validate:"required,oneof=owner spender viewer"`
+	SpendLimit *float64  `json:"spend_limit" validate:"omitempty,gt=0"`
+}
+
+func (r *memberRoutes) InviteMember(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var input inviteMemberRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	err = r.memberService.InviteMember(c.Request().Context(), getUserId(c), entity.WalletMember{
+		Wallet:     walletId,
+		Member:     input.Member,
+		Role:       input.Role,
+		SpendLimit: input.SpendLimit,
+	})
+	if err != nil {
+		return memberErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type updateMemberRequest struct {
+	Role       string   `json:"role" validate:"required,oneof=owner spender viewer"`
+	SpendLimit *float64 `json:"spend_limit" validate:"omitempty,gt=0"`
+}
+
+func (r *memberRoutes) UpdateMember(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	memberId, err := uuid.Parse(c.Param("memberId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var input updateMemberRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	err = r.memberService.UpdateMember(c.Request().Context(), getUserId(c), entity.WalletMember{
+		Wallet:     walletId,
+		Member:     memberId,
+		Role:       input.Role,
+		SpendLimit: input.SpendLimit,
+	})
+	if err != nil {
+		return memberErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *memberRoutes) RemoveMember(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	memberId, err := uuid.Parse(c.Param("memberId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	if err = r.memberService.RemoveMember(c.Request().Context(), getUserId(c), walletId, memberId); err != nil {
+		return memberErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *memberRoutes) AcceptInvitation(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	if err = r.memberService.AcceptInvitation(c.Request().Context(), getUserId(c), walletId); err != nil {
+		return memberErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *memberRoutes) DeclineInvitation(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	if err = r.memberService.DeclineInvitation(c.Request().Context(), getUserId(c), walletId); err != nil {
+		return memberErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *memberRoutes) GetInvitations(c echo.Context) error {
+	invitations, err := r.memberService.GetInvitations(c.Request().Context(), getUserId(c))
+	if err != nil {
+		return memberErrorResponse(c, err)
+	}
+
+	type response struct {
+		Invitations []entity.WalletMember `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Invitations: invitations})
+}
+
+func memberErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrCannotGetWallet),
+		errors.Is(err, service.ErrMemberNotFound),
+		errors.Is(err, service.ErrInvitationNotFound),
+		errors.Is(err, service.ErrUserNotFound):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrWalletAccessDenied):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrMemberAlreadyExists),
+		errors.Is(err, service.ErrLastWalletOwner):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrInvalidWalletRole):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+)
+
+type merchantRoutes struct {
+	merchantService service.Merchant
+}
+
+func newMerchantRoutes(g *echo.Group, merchantService service.Merchant) {
+	r := &merchantRoutes{merchantService: merchantService}
+
+	g.POST("/", r.CreateMerchant)
+	g.GET("/", r.GetMerchant)
+	g.POST("/wallets", r.AddWallet)
+	g.DELETE("/wallets/:walletId", r.RemoveWallet)
+}
+
+type createMerchantRequest struct {
+	Name    string      `json:"name" validate:"required,max=128"`
+	Wallets []uuid.UUID `json:"wallets" validate:"required,min=1"`
+}
+
+func (r *merchantRoutes) CreateMerchant(c echo.Context) error {
+	var input createMerchantRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	merchant, err := r.merchantService.CreateMerchant(c.Request().Context(), getUserId(c), entity.Merchant{
+		Name:    input.Name,
+		Wallets: input.Wallets,
+	})
+	if err != nil {
+		return merchantErrorResponse(c, err)
+	}
+
+	type response struct {
+		Uuid uuid.UUID `json:"uuid"`
+	}
+
+	return c.JSON(http.StatusOK, response{Uuid: merchant})
+}
+
+func (r *merchantRoutes) GetMerchant(c echo.Context) error {
+	merchant, err := r.merchantService.GetMerchant(c.Request().Context(), getUserId(c))
+	if err != nil {
+		return merchantErrorResponse(c, err)
+	}
+
+	type response struct {
+		Merchant entity.Merchant `json:"merchant"`
+	}
+
+	return c.JSON(http.StatusOK, response{Merchant: merchant})
+}
+
+type merchantWalletRequest struct {
+	Wallet uuid.UUID `json:"wallet" validate:"required"`
+}
+
+func (r *merchantRoutes) AddWallet(c echo.Context) error {
+	var input merchantWalletRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	if err := r.merchantService.AddWallet(c.Request().Context(), getUserId(c), input.Wallet); err != nil {
+		return merchantErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *merchantRoutes) RemoveWallet(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	if err = r.merchantService.RemoveWallet(c.Request().Context(), getUserId(c), walletId); err != nil {
+		return merchantErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func merchantErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrMerchantNotFound),
+		errors.Is(err, service.ErrCannotGetWallet),
+		errors.Is(err, service.ErrNotSettlementWallet):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrWalletAccessDenied):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrMerchantAlreadyExists), errors.Is(err, service.ErrSettlementWalletExists):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
@@ -1,34 +1,72 @@
 package v1
 
 import (
+	"errors"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"infotecs_trainee_task/internal/service"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 )
 
 const (
-	userIdCtx = "userId"
+	userIdCtx    = "userId"
+	apiKeyCtx    = "apiKey"
+	apiKeyHeader = "X-API-Key"
 )
 
 type AuthMiddleware struct {
-	authService service.Auth
+	authService   service.Auth
+	apiKeyService service.APIKey
+	// scopes maps "METHOD path" of the routes open to API keys to the scope they require
+	scopes map[string]string
 }
 
+func NewAuthMiddleware(authService service.Auth, apiKeyService service.APIKey) *AuthMiddleware {
+	return &AuthMiddleware{
+		authService:   authService,
+		apiKeyService: apiKeyService,
+		scopes:        make(map[string]string),
+	}
+}
+
+// Scope opens the route to API keys with the scope, API keys are rejected on every other route
+func (h *AuthMiddleware) Scope(route *echo.Route, scope string) {
+	h.scopes[route.Method+" "+route.Path] = scope
+}
+
+// UserIdentity accepts a Bearer JWT or an API key in X-API-Key, the API key acts as its owner
 func (h *AuthMiddleware) UserIdentity(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
+		if secret := c.Request().Header.Get(apiKeyHeader); secret != "" {
+			return h.apiKeyIdentity(c, next, secret)
+		}
+
 		token, ok := bearerToken(c.Request())
 		if !ok {
-			slog.Error("AuthMiddleware.UserIdentity: bearerToken: ", ErrInvalidAuthHeader)
+			slog.Error("AuthMiddleware.UserIdentity: bearerToken: ", "error", ErrInvalidAuthHeader)
 			newErrorResponse(c, http.StatusUnauthorized, ErrInvalidAuthHeader.Error())
 			return nil
 		}
 
-		userId, err := h.authService.ParseToken(token)
+		userId, err := h.authService.ParseToken(c.Request().Context(), token)
 		if err != nil {
-			slog.Error("AuthMiddleware.UserIdentity: h.authService.ParseToken: ", err)
-			newErrorResponse(c, http.StatusUnauthorized, ErrCannotParseToken.Error())
+			slog.Error("AuthMiddleware.UserIdentity: h.authService.ParseToken: ", "error", err)
+			switch {
+			// tells clients to refresh the token instead of signing in again
+			case errors.Is(err, service.ErrTokenExpired):
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token", error_description="token expired"`)
+				newErrorResponse(c, http.StatusUnauthorized, ErrTokenExpired.Error())
+			case errors.Is(err, service.ErrTokenRevoked):
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token", error_description="token revoked"`)
+				newErrorResponse(c, http.StatusUnauthorized, ErrTokenRevoked.Error())
+			case errors.Is(err, service.ErrCannotCheckToken):
+				newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+			default:
+				newErrorResponse(c, http.StatusUnauthorized, ErrCannotParseToken.Error())
+			}
 			return err
 		}
 
@@ -38,6 +76,66 @@ func (h *AuthMiddleware) UserIdentity(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
+func (h *AuthMiddleware) apiKeyIdentity(c echo.Context, next echo.HandlerFunc, secret string) error {
+	key, err := h.apiKeyService.Authenticate(c.Request().Context(), secret)
+	if err != nil {
+		slog.Error("AuthMiddleware.apiKeyIdentity: h.apiKeyService.Authenticate: ", "error", err)
+		if errors.Is(err, service.ErrInvalidAPIKeyCredentials) {
+			newErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return err
+		}
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+		return err
+	}
+
+	scope, ok := h.scopes[c.Request().Method+" "+c.Path()]
+	if !ok {
+		newErrorResponse(c, http.StatusForbidden, ErrAPIKeyNotAllowed.Error())
+		return ErrAPIKeyNotAllowed
+	}
+
+	if !slices.Contains(key.Scopes, scope) {
+		newErrorResponse(c, http.StatusForbidden, ErrAPIKeyScope.Error()+" "+scope)
+		return ErrAPIKeyScope
+	}
+
+	if walletId := c.Param("walletId"); walletId != "" && len(key.Wallets) > 0 {
+		wallet, err := uuid.Parse(walletId)
+		if err != nil || !slices.Contains(key.Wallets, wallet) {
+			newErrorResponse(c, http.StatusForbidden, ErrAPIKeyWallet.Error())
+			return ErrAPIKeyWallet
+		}
+	}
+
+	c.Set(userIdCtx, key.Owner)
+	c.Set(apiKeyCtx, key.UUID)
+
+	return next(c)
+}
+
+func (h *AuthMiddleware) AdminOnly(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		isAdmin, err := h.authService.IsAdmin(c.Request().Context(), getUserId(c))
+		if err != nil {
+			slog.Error("AuthMiddleware.AdminOnly: h.authService.IsAdmin: ", "error", err)
+			newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+			return err
+		}
+
+		if !isAdmin {
+			newErrorResponse(c, http.StatusForbidden, ErrAdminOnly.Error())
+			return ErrAdminOnly
+		}
+
+		return next(c)
+	}
+}
+
+func getUserId(c echo.Context) uuid.UUID {
+	userId, _ := c.Get(userIdCtx).(uuid.UUID)
+	return userId
+}
+
 func bearerToken(r *http.Request) (string, bool) {
 	const prefix = "Bearer "
 
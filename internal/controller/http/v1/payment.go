@@ -0,0 +1,128 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+	"time"
+)
+
+type paymentRoutes struct {
+	paymentService service.Payment
+}
+
+func newPaymentRoutes(g *echo.Group, paymentService service.Payment) {
+	r := &paymentRoutes{paymentService: paymentService}
+
+	g.POST("/payload", r.EncodePayload)
+	g.POST("/qr", r.EncodeQR)
+	g.POST("/decode", r.DecodePayload)
+}
+
+type paymentRequest struct {
+	Wallet    uuid.UUID  `json:"wallet" validate:"required"`
+	Amount    float64    `json:"amount" validate:"gte=0"`
+	Currency  string     `json:"currency" validate:"omitempty,len=3"`
+	Memo      string     `json:"memo" validate:"max=25"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (r *paymentRoutes) EncodePayload(c echo.Context) error {
+	request, err := bindPaymentRequest(c)
+	if err != nil {
+		return err
+	}
+
+	payload, err := r.paymentService.EncodePayload(c.Request().Context(), getUserId(c), request)
+	if err != nil {
+		return paymentErrorResponse(c, err)
+	}
+
+	type response struct {
+		Payload string `json:"payload"`
+	}
+
+	return c.JSON(http.StatusOK, response{Payload: payload})
+}
+
+func (r *paymentRoutes) EncodeQR(c echo.Context) error {
+	request, err := bindPaymentRequest(c)
+	if err != nil {
+		return err
+	}
+
+	image, err := r.paymentService.EncodeQR(c.Request().Context(), getUserId(c), request)
+	if err != nil {
+		return paymentErrorResponse(c, err)
+	}
+
+	return c.Blob(http.StatusOK, "image/png", image)
+}
+
+type decodePayloadRequest struct {
+	Payload string `json:"payload" validate:"required"`
+}
+
+func (r *paymentRoutes) DecodePayload(c echo.Context) error {
+	var input decodePayloadRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	quote, err := r.paymentService.DecodePayload(c.Request().Context(), input.Payload)
+	if err != nil {
+		return paymentErrorResponse(c, err)
+	}
+
+	type response struct {
+		Quote entity.PaymentRequest `json:"quote"`
+	}
+
+	return c.JSON(http.StatusOK, response{Quote: quote})
+}
+
+func bindPaymentRequest(c echo.Context) (entity.PaymentRequest, error) {
+	var input paymentRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return entity.PaymentRequest{}, err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return entity.PaymentRequest{}, err
+	}
+
+	return entity.PaymentRequest{
+		To:        input.Wallet,
+		Amount:    input.Amount,
+		Currency:  input.Currency,
+		Memo:      input.Memo,
+		ExpiresAt: input.ExpiresAt,
+	}, nil
+}
+
+func paymentErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrCannotGetWallet):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrWalletAccessDenied):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrInvalidPaymentPayload),
+		errors.Is(err, service.ErrPaymentRequestExpired),
+		errors.Is(err, service.ErrInvalidCurrency):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
@@ -0,0 +1,202 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+	"time"
+)
+
+type pocketRoutes struct {
+	pocketService service.Pocket
+}
+
+func newPocketRoutes(wallet *echo.Group, pocketService service.Pocket) {
+	r := &pocketRoutes{pocketService: pocketService}
+
+	wallet.GET("/:walletId/pockets", r.GetPockets)
+	wallet.POST("/:walletId/pockets", r.CreatePocket)
+	wallet.PUT("/:walletId/pockets/:pocketId", r.UpdatePocket)
+	wallet.DELETE("/:walletId/pockets/:pocketId", r.DeletePocket)
+	wallet.POST("/:walletId/pockets/:pocketId/deposit", r.Deposit)
+	wallet.POST("/:walletId/pockets/:pocketId/withdraw", r.Withdraw)
+}
+
+func (r *pocketRoutes) GetPockets(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	pockets, err := r.pocketService.GetPockets(c.Request().Context(), getUserId(c), walletId)
+	if err != nil {
+		return pocketErrorResponse(c, err)
+	}
+
+	type response struct {
+		Pockets []entity.Pocket `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Pockets: pockets})
+}
+
+type pocketRequest struct {
+	Name     string     `json:"name" validate:"required,max=64"`
+	Target   *float64   `json:"target" validate:"omitempty,gt=0"`
+	Deadline *time.Time `json:"deadline"`
+}
+
+func (r *pocketRoutes) CreatePocket(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var input pocketRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	pocket, err := r.pocketService.CreatePocket(c.Request().Context(), getUserId(c), entity.Pocket{
+		Wallet:   walletId,
+		Name:     input.Name,
+		Target:   input.Target,
+		Deadline: input.Deadline,
+	})
+	if err != nil {
+		return pocketErrorResponse(c, err)
+	}
+
+	type response struct {
+		Uuid uuid.UUID `json:"uuid"`
+	}
+
+	return c.JSON(http.StatusOK, response{Uuid: pocket})
+}
+
+func (r *pocketRoutes) UpdatePocket(c echo.Context) error {
+	walletId, pocketId, err := parsePocketParams(c)
+	if err != nil {
+		return err
+	}
+
+	var input pocketRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	err = r.pocketService.UpdatePocket(c.Request().Context(), getUserId(c), entity.Pocket{
+		UUID:     pocketId,
+		Wallet:   walletId,
+		Name:     input.Name,
+		Target:   input.Target,
+		Deadline: input.Deadline,
+	})
+	if err != nil {
+		return pocketErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *pocketRoutes) DeletePocket(c echo.Context) error {
+	walletId, pocketId, err := parsePocketParams(c)
+	if err != nil {
+		return err
+	}
+
+	err = r.pocketService.DeletePocket(c.Request().Context(), getUserId(c), walletId, pocketId)
+	if err != nil {
+		return pocketErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type pocketMoveRequest struct {
+	Amount float64 `json:"amount" validate:"required,gt=0"`
+}
+
+func (r *pocketRoutes) Deposit(c echo.Context) error {
+	return r.move(c, r.pocketService.Deposit)
+}
+
+func (r *pocketRoutes) Withdraw(c echo.Context) error {
+	return r.move(c, r.pocketService.Withdraw)
+}
+
+type pocketMove func(ctx context.Context, userUUID, walletUUID, pocketUUID uuid.UUID, amount float64) error
+
+func (r *pocketRoutes) move(c echo.Context, move pocketMove) error {
+	walletId, pocketId, err := parsePocketParams(c)
+	if err != nil {
+		return err
+	}
+
+	var input pocketMoveRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	if err = move(c.Request().Context(), getUserId(c), walletId, pocketId, input.Amount); err != nil {
+		return pocketErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func parsePocketParams(c echo.Context) (uuid.UUID, uuid.UUID, error) {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	pocketId, err := uuid.Parse(c.Param("pocketId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	return walletId, pocketId, nil
+}
+
+func pocketErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrCannotGetWallet), errors.Is(err, service.ErrPocketNotFound):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrWalletAccessDenied):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrPocketAlreadyExists):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrNotEnoughMoney), errors.Is(err, service.ErrNotEnoughPocketMoney):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
@@ -0,0 +1,180 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+	"time"
+)
+
+type promotionRoutes struct {
+	promotionService service.Promotion
+}
+
+func newPromotionRoutes(g *echo.Group, promotionService service.Promotion) {
+	r := &promotionRoutes{promotionService: promotionService}
+
+	g.POST("/", r.CreateCampaign)
+	g.GET("/", r.GetCampaigns)
+	g.GET("/report", r.GetReport)
+	g.GET("/:campaignId", r.GetCampaign)
+	g.PUT("/:campaignId", r.UpdateCampaign)
+}
+
+type campaignRequest struct {
+	Name        string     `json:"name" validate:"required,max=128"`
+	Percent     float64    `json:"percent" validate:"gte=0,lte=100"`
+	FixedAmount float64    `json:"fixed_amount" validate:"gte=0"`
+	MinAmount   float64    `json:"min_amount" validate:"gte=0"`
+	Budget      float64    `json:"budget" validate:"required,gt=0"`
+	UserCap     *float64   `json:"user_cap" validate:"omitempty,gt=0"`
+	CapPeriod   *string    `json:"cap_period" validate:"omitempty,oneof=day week month"`
+	Active      *bool      `json:"active"`
+	StartsAt    *time.Time `json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at"`
+}
+
+type createCampaignRequest struct {
+	campaignRequest
+	Kind     string        `json:"kind" validate:"required,oneof=cashback first_transfer"`
+	Receiver uuid.NullUUID `json:"receiver"`
+}
+
+// campaign fills the shared rules, a campaign is active unless the request says otherwise
+func (input campaignRequest) campaign() entity.PromoCampaign {
+	campaign := entity.PromoCampaign{
+		Name:        input.Name,
+		Percent:     input.Percent,
+		FixedAmount: input.FixedAmount,
+		MinAmount:   input.MinAmount,
+		Budget:      input.Budget,
+		UserCap:     input.UserCap,
+		CapPeriod:   input.CapPeriod,
+		Active:      input.Active == nil || *input.Active,
+		EndsAt:      input.EndsAt,
+	}
+	if input.StartsAt != nil {
+		campaign.StartsAt = *input.StartsAt
+	}
+	return campaign
+}
+
+func (r *promotionRoutes) CreateCampaign(c echo.Context) error {
+	var input createCampaignRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	campaign := input.campaign()
+	campaign.Kind = input.Kind
+	campaign.Receiver = input.Receiver
+
+	campaignUUID, err := r.promotionService.CreateCampaign(c.Request().Context(), campaign)
+	if err != nil {
+		return promotionErrorResponse(c, err)
+	}
+
+	type response struct {
+		Uuid uuid.UUID `json:"uuid"`
+	}
+
+	return c.JSON(http.StatusOK, response{Uuid: campaignUUID})
+}
+
+func (r *promotionRoutes) GetCampaigns(c echo.Context) error {
+	campaigns, err := r.promotionService.GetCampaigns(c.Request().Context())
+	if err != nil {
+		return promotionErrorResponse(c, err)
+	}
+
+	type response struct {
+		Campaigns []entity.PromoCampaign `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Campaigns: campaigns})
+}
+
+func (r *promotionRoutes) GetCampaign(c echo.Context) error {
+	campaignId, err := uuid.Parse(c.Param("campaignId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	campaign, err := r.promotionService.GetCampaign(c.Request().Context(), campaignId)
+	if err != nil {
+		return promotionErrorResponse(c, err)
+	}
+
+	type response struct {
+		Campaign entity.PromoCampaign `json:"campaign"`
+	}
+
+	return c.JSON(http.StatusOK, response{Campaign: campaign})
+}
+
+func (r *promotionRoutes) UpdateCampaign(c echo.Context) error {
+	campaignId, err := uuid.Parse(c.Param("campaignId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var input campaignRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	campaign := input.campaign()
+	campaign.UUID = campaignId
+
+	if err = r.promotionService.UpdateCampaign(c.Request().Context(), campaign); err != nil {
+		return promotionErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *promotionRoutes) GetReport(c echo.Context) error {
+	report, err := r.promotionService.GetReport(c.Request().Context())
+	if err != nil {
+		return promotionErrorResponse(c, err)
+	}
+
+	type response struct {
+		Report []entity.PromoReport `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Report: report})
+}
+
+func promotionErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrCampaignNotFound), errors.Is(err, service.ErrCannotGetWallet):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrCampaignAlreadyExists):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrInvalidCampaign),
+		errors.Is(err, service.ErrInvalidPeriod),
+		errors.Is(err, service.ErrSystemWallet):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
@@ -9,6 +9,9 @@ import (
 )
 
 func NewRouter(handler *echo.Echo, services *service.Services) {
+	// the service is reached directly, client supplied X-Forwarded-For and X-Real-IP headers are not trusted
+	handler.IPExtractor = echo.ExtractIPDirect()
+
 	handler.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
 		Format: `{"time":"${time_rfc3339_nano}", "method":"${method}","uri":"${uri}", "status":${status},"error":"${error}"}` + "\n",
 		Output: setLogsFile(),
@@ -20,15 +23,41 @@ func NewRouter(handler *echo.Echo, services *service.Services) {
 		return c.NoContent(200)
 	})
 
+	authMiddleware := NewAuthMiddleware(services.Auth, services.APIKey)
+
 	auth := handler.Group("/auth")
 	{
-		newAuthRoutes(auth, services.Auth)
+		newAuthRoutes(auth, services.Auth, authMiddleware)
 	}
 
-	authMiddleware := &AuthMiddleware{services.Auth}
+	newWellKnownRoutes(handler.Group("/.well-known"), services.Auth)
+
 	v1 := handler.Group("/api/v1", authMiddleware.UserIdentity)
 	{
-		newWalletRoutes(v1.Group("/wallet"), services.Wallet)
+		newMeRoutes(v1, services.User)
+		wallet := v1.Group("/wallet")
+		newWalletRoutes(wallet, services.Wallet, authMiddleware)
+		newAPIKeyRoutes(v1.Group("/api-keys"), services.APIKey)
+		newTwoFactorRoutes(v1.Group("/2fa"), services.TwoFactor)
+		newMemberRoutes(v1.Group("/invitations"), wallet, services.Member)
+		newPocketRoutes(wallet, services.Pocket)
+		newFamilyRoutes(wallet, services.Family)
+		newApprovalRoutes(v1.Group("/approvals"), wallet, services.Approval)
+		newScheduledRoutes(wallet, services.Scheduled)
+		newEscrowRoutes(v1.Group("/escrow"), services.Escrow)
+		newMerchantRoutes(v1.Group("/merchant"), services.Merchant)
+		newInvoiceRoutes(v1.Group("/invoices"), services.Invoice)
+		newPaymentRoutes(v1.Group("/payments"), services.Payment)
+		newVoucherRoutes(v1.Group("/vouchers"), services.Voucher)
+		newAliasRoutes(v1.Group("/aliases"), services.Alias)
+		newContactRoutes(v1.Group("/contacts"), wallet, services.Contact)
+		admin := v1.Group("/admin", authMiddleware.AdminOnly)
+		newAdminRoutes(admin, services)
+		newAdminAPIKeyRoutes(admin.Group("/users/:userId/api-keys"), services.APIKey)
+		newLockoutRoutes(admin, services.Lockout)
+		newPromotionRoutes(admin.Group("/promotions"), services.Promotion)
+		newInterestRoutes(admin, wallet, services.Interest)
+		newDisputeRoutes(v1.Group("/disputes"), admin, wallet, services.Dispute)
 	}
 }
 
@@ -36,7 +65,7 @@ func setLogsFile() *os.File {
 	file, err := os.OpenFile("/logs/request.log", os.O_CREATE|os.O_APPEND|os.O_APPEND, 0666)
 
 	if err != nil {
-		slog.Error("can not open file", err)
+		slog.Error("can not open file", "error", err)
 	}
 
 	return file
@@ -0,0 +1,109 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+	"time"
+)
+
+type scheduledRoutes struct {
+	scheduledService service.ScheduledTransfer
+}
+
+func newScheduledRoutes(wallet *echo.Group, scheduledService service.ScheduledTransfer) {
+	r := &scheduledRoutes{scheduledService: scheduledService}
+
+	wallet.PUT("/:walletId/transfer-delay", r.SetTransferDelay)
+	wallet.GET("/:walletId/scheduled", r.GetScheduledTransfers)
+	wallet.POST("/:walletId/scheduled/:transferId/cancel", r.CancelTransfer)
+}
+
+// transferDelayRequest.Seconds zero executes transfers immediately
+type transferDelayRequest struct {
+	Seconds int64 `json:"seconds" validate:"min=0"`
+}
+
+func (r *scheduledRoutes) SetTransferDelay(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	var input transferDelayRequest
+	if err = c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err = c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	delay := time.Duration(input.Seconds) * time.Second
+	if err = r.scheduledService.SetTransferDelay(c.Request().Context(), getUserId(c), walletId, delay); err != nil {
+		return scheduledErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *scheduledRoutes) GetScheduledTransfers(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	transfers, err := r.scheduledService.GetScheduledTransfers(c.Request().Context(), getUserId(c), walletId)
+	if err != nil {
+		return scheduledErrorResponse(c, err)
+	}
+
+	type response struct {
+		Transfers []entity.ScheduledTransfer `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Transfers: transfers})
+}
+
+func (r *scheduledRoutes) CancelTransfer(c echo.Context) error {
+	walletId, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	transferId, err := uuid.Parse(c.Param("transferId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	if err = r.scheduledService.CancelTransfer(c.Request().Context(), getUserId(c), walletId, transferId); err != nil {
+		return scheduledErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func scheduledErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrScheduledTransferNotFound), errors.Is(err, service.ErrCannotGetWallet):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrWalletAccessDenied):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrTransferNotScheduled):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrInvalidTransferDelay):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
@@ -0,0 +1,166 @@
+package v1
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+)
+
+type twoFactorRoutes struct {
+	twoFactorService service.TwoFactor
+}
+
+func newTwoFactorRoutes(g *echo.Group, twoFactorService service.TwoFactor) {
+	r := &twoFactorRoutes{twoFactorService: twoFactorService}
+
+	g.GET("/", r.GetTwoFactor)
+	g.POST("/enroll", r.Enroll)
+	g.POST("/confirm", r.Confirm)
+	g.POST("/disable", r.Disable)
+	g.POST("/recovery-codes", r.RegenerateRecoveryCodes)
+	g.PUT("/transfer-threshold", r.SetTransferThreshold)
+}
+
+// twoFactorCodeRequest.Code is a code of the authenticator app, or a recovery code where it is accepted
+type twoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (r *twoFactorRoutes) GetTwoFactor(c echo.Context) error {
+	totp, err := r.twoFactorService.GetTwoFactor(c.Request().Context(), getUserId(c))
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+
+	type response struct {
+		Enabled bool `json:"enabled"`
+		entity.TOTP
+	}
+
+	return c.JSON(http.StatusOK, response{Enabled: totp.ConfirmedAt != nil, TOTP: totp})
+}
+
+func (r *twoFactorRoutes) Enroll(c echo.Context) error {
+	enrollment, err := r.twoFactorService.Enroll(c.Request().Context(), getUserId(c))
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+
+	// QR is a PNG, encoding/json sends it base64 encoded
+	type response struct {
+		Secret string `json:"secret"`
+		URI    string `json:"uri"`
+		QR     []byte `json:"qr"`
+	}
+
+	return c.JSON(http.StatusOK, response{Secret: enrollment.Secret, URI: enrollment.URI, QR: enrollment.QR})
+}
+
+func (r *twoFactorRoutes) Confirm(c echo.Context) error {
+	var input twoFactorCodeRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	codes, err := r.twoFactorService.Confirm(c.Request().Context(), getUserId(c), input.Code)
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (r *twoFactorRoutes) Disable(c echo.Context) error {
+	var input twoFactorCodeRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	if err := r.twoFactorService.Disable(c.Request().Context(), getUserId(c), input.Code); err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (r *twoFactorRoutes) RegenerateRecoveryCodes(c echo.Context) error {
+	var input twoFactorCodeRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	codes, err := r.twoFactorService.RegenerateRecoveryCodes(c.Request().Context(), getUserId(c), input.Code)
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// setTransferThresholdRequest.Threshold null stops asking codes for transfers
+type setTransferThresholdRequest struct {
+	Threshold *float64 `json:"threshold" validate:"omitempty,gte=0"`
+	Code      string   `json:"code" validate:"required"`
+}
+
+func (r *twoFactorRoutes) SetTransferThreshold(c echo.Context) error {
+	var input setTransferThresholdRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	err := r.twoFactorService.SetTransferThreshold(c.Request().Context(), getUserId(c), input.Threshold, input.Code)
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func twoFactorErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrTwoFactorNotEnabled), errors.Is(err, service.ErrUserNotFound):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrTwoFactorAlreadyEnabled):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrInvalidTwoFactorCode):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrTwoFactorLocked):
+		newErrorResponse(c, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, service.ErrInvalidTransferThreshold):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
@@ -0,0 +1,150 @@
+package v1
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/service"
+	"net/http"
+	"time"
+)
+
+type voucherRoutes struct {
+	voucherService service.Voucher
+}
+
+func newVoucherRoutes(g *echo.Group, voucherService service.Voucher) {
+	r := &voucherRoutes{voucherService: voucherService}
+
+	g.POST("/", r.CreateVoucher)
+	g.GET("/", r.GetVouchers)
+	g.POST("/redeem", r.RedeemVoucher)
+	g.POST("/:voucherId/revoke", r.RevokeVoucher)
+}
+
+// createVoucherRequest.TOTPCode is needed when the funding is above the two-factor transfer threshold of the user
+type createVoucherRequest struct {
+	Wallet         uuid.UUID  `json:"wallet" validate:"required"`
+	Amount         float64    `json:"amount" validate:"required,gt=0"`
+	Currency       string     `json:"currency" validate:"omitempty,len=3"`
+	MaxRedemptions int        `json:"max_redemptions" validate:"gte=0"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	TOTPCode       string     `json:"totp_code"`
+}
+
+func (r *voucherRoutes) CreateVoucher(c echo.Context) error {
+	var input createVoucherRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	voucher, code, err := r.voucherService.CreateVoucher(c.Request().Context(), getUserId(c), entity.Voucher{
+		Wallet:         input.Wallet,
+		Amount:         input.Amount,
+		Currency:       input.Currency,
+		MaxRedemptions: input.MaxRedemptions,
+		ExpiresAt:      input.ExpiresAt,
+	}, input.TOTPCode)
+	if err != nil {
+		return voucherErrorResponse(c, err)
+	}
+
+	type response struct {
+		Uuid uuid.UUID `json:"uuid"`
+		Code string    `json:"code"`
+	}
+
+	return c.JSON(http.StatusOK, response{Uuid: voucher, Code: code})
+}
+
+func (r *voucherRoutes) GetVouchers(c echo.Context) error {
+	vouchers, err := r.voucherService.GetVouchers(c.Request().Context(), getUserId(c))
+	if err != nil {
+		return voucherErrorResponse(c, err)
+	}
+
+	type response struct {
+		Vouchers []entity.Voucher `json:"array"`
+	}
+
+	return c.JSON(http.StatusOK, response{Vouchers: vouchers})
+}
+
+type redeemVoucherRequest struct {
+	Code   string    `json:"code" validate:"required,max=64"`
+	Wallet uuid.UUID `json:"wallet" validate:"required"`
+}
+
+func (r *voucherRoutes) RedeemVoucher(c echo.Context) error {
+	var input redeemVoucherRequest
+	if err := c.Bind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return err
+	}
+
+	if err := c.Validate(input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	voucher, err := r.voucherService.RedeemVoucher(c.Request().Context(), getUserId(c), input.Wallet, input.Code)
+	if err != nil {
+		return voucherErrorResponse(c, err)
+	}
+
+	type response struct {
+		Amount   float64   `json:"amount"`
+		Currency string    `json:"currency"`
+		Wallet   uuid.UUID `json:"wallet"`
+	}
+
+	return c.JSON(http.StatusOK, response{Amount: voucher.Amount, Currency: voucher.Currency, Wallet: input.Wallet})
+}
+
+func (r *voucherRoutes) RevokeVoucher(c echo.Context) error {
+	voucherId, err := uuid.Parse(c.Param("voucherId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request params "+err.Error())
+		return err
+	}
+
+	if err = r.voucherService.RevokeVoucher(c.Request().Context(), getUserId(c), voucherId); err != nil {
+		return voucherErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func voucherErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrVoucherNotFound),
+		errors.Is(err, service.ErrCannotGetWallet),
+		errors.Is(err, service.ErrUserNotFound):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrWalletAccessDenied),
+		errors.Is(err, service.ErrSystemWallet),
+		errors.Is(err, service.ErrNotVoucherIssuer),
+		errors.Is(err, service.ErrVoucherNeedsApproval),
+		errors.Is(err, service.ErrTwoFactorCodeRequired),
+		errors.Is(err, service.ErrInvalidTwoFactorCode):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrVoucherUnavailable), errors.Is(err, service.ErrVoucherAlreadyRedeemed):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrTooManyAttempts), errors.Is(err, service.ErrTwoFactorLocked):
+		newErrorResponse(c, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, service.ErrInvalidVoucher),
+		errors.Is(err, service.ErrInvalidCurrency),
+		errors.Is(err, service.ErrNotEnoughMoney):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, "internal server error")
+	}
+	return err
+}
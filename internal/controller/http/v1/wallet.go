@@ -14,18 +14,18 @@ type walletRoutes struct {
 	walletService service.Wallet
 }
 
-func newWalletRoutes(g *echo.Group, walletService service.Wallet) {
+func newWalletRoutes(g *echo.Group, walletService service.Wallet, authMiddleware *AuthMiddleware) {
 	r := &walletRoutes{walletService: walletService}
 
-	g.POST("/", r.CreateWallet)
-	g.POST("/:walletId/send", r.TransferCash)
-	g.GET("/:walletId/history", r.GetHistory)
-	g.GET("/:walletId", r.GetState)
+	authMiddleware.Scope(g.POST("/", r.CreateWallet), entity.ScopeWalletCreate)
+	authMiddleware.Scope(g.POST("/:walletId/send", r.TransferCash), entity.ScopeWalletTransfer)
+	authMiddleware.Scope(g.GET("/:walletId/history", r.GetHistory), entity.ScopeWalletRead)
+	authMiddleware.Scope(g.GET("/:walletId", r.GetState), entity.ScopeWalletRead)
 }
 
 func (r *walletRoutes) CreateWallet(c echo.Context) error {
 
-	wallet, err := r.walletService.CreateWallet(c.Request().Context())
+	wallet, err := r.walletService.CreateWallet(c.Request().Context(), getUserId(c))
 
 	if err != nil {
 		if errors.Is(err, service.ErrWalletAlreadyExists) {
@@ -43,9 +43,12 @@ func (r *walletRoutes) CreateWallet(c echo.Context) error {
 	return c.JSON(http.StatusOK, response{Uuid: wallet})
 }
 
+// transferCashRequest.To is a wallet id, an alias or a username,
+// TOTPCode is needed above the two-factor transfer threshold of the user
 type transferCashRequest struct {
-	To     uuid.UUID `json:"to" validator:"required"`
-	Amount float64   `json:"amount" validator:"required,min=0"`
+	To       string  `json:"to" validate:"required"`
+	Amount   float64 `json:"amount" validate:"required,gt=0"`
+	TOTPCode string  `json:"totp_code"`
 }
 
 func (r *walletRoutes) TransferCash(c echo.Context) error {
@@ -66,29 +69,63 @@ func (r *walletRoutes) TransferCash(c echo.Context) error {
 		return err
 	}
 
-	err = r.walletService.MakeTransaction(
-		c.Request().Context(),
-		walletId,
-		transferInput.To,
-		transferInput.Amount,
-	)
+	result, err := r.walletService.MakeTransaction(c.Request().Context(), service.TransferInput{
+		UserUUID: getUserId(c),
+		Sender:   walletId,
+		To:       transferInput.To,
+		Amount:   transferInput.Amount,
+		TOTPCode: transferInput.TOTPCode,
+	})
 
 	if err != nil {
-		if errors.Is(err, service.ErrTransactionAlreadyExists) {
+		if errors.Is(err, service.ErrTransactionAlreadyExists) || errors.Is(err, service.ErrNotEnoughMoney) {
 			newErrorResponse(c, http.StatusBadRequest, err.Error())
 			return err
 		}
 
-		if errors.Is(err, service.ErrCannotGetWallet) {
+		if errors.Is(err, service.ErrCannotGetWallet) ||
+			errors.Is(err, service.ErrAliasNotFound) ||
+			errors.Is(err, service.ErrNoDefaultWallet) {
 			newErrorResponse(c, http.StatusNotFound, err.Error())
 			return err
 		}
 
+		if errors.Is(err, service.ErrAmbiguousAlias) {
+			newErrorResponse(c, http.StatusConflict, err.Error())
+			return err
+		}
+
+		if errors.Is(err, service.ErrSystemWallet) ||
+			errors.Is(err, service.ErrWalletAccessDenied) ||
+			errors.Is(err, service.ErrSpendLimitExceeded) ||
+			errors.Is(err, service.ErrCounterpartyNotAllowed) ||
+			errors.Is(err, service.ErrChildSpendLimitExceeded) ||
+			errors.Is(err, service.ErrTwoFactorCodeRequired) ||
+			errors.Is(err, service.ErrInvalidTwoFactorCode) {
+			newErrorResponse(c, http.StatusForbidden, err.Error())
+			return err
+		}
+
+		if errors.Is(err, service.ErrTwoFactorLocked) {
+			newErrorResponse(c, http.StatusTooManyRequests, err.Error())
+			return err
+		}
+
 		newErrorResponse(c, http.StatusInternalServerError, "internal server error "+err.Error())
 		return err
 	}
 
-	return c.NoContent(http.StatusOK)
+	type response struct {
+		Uuid   *uuid.UUID `json:"uuid,omitempty"`
+		Status string     `json:"status"`
+		To     uuid.UUID  `json:"to"`
+	}
+
+	if result.Status != entity.TransferStatusExecuted {
+		return c.JSON(http.StatusAccepted, response{Uuid: &result.Transfer, Status: result.Status, To: result.Receiver})
+	}
+
+	return c.JSON(http.StatusOK, response{Status: result.Status, To: result.Receiver})
 }
 
 func (r *walletRoutes) GetHistory(c echo.Context) error {
@@ -98,7 +135,7 @@ func (r *walletRoutes) GetHistory(c echo.Context) error {
 		return err
 	}
 
-	transactions, err := r.walletService.GetTransactionsHistory(c.Request().Context(), walletId)
+	transactions, err := r.walletService.GetTransactionsHistory(c.Request().Context(), getUserId(c), walletId)
 	if err != nil {
 		if errors.Is(err, service.ErrCannotGetTransaction) {
 			newErrorResponse(c, http.StatusBadRequest, "can not get transaction "+err.Error())
@@ -110,7 +147,13 @@ func (r *walletRoutes) GetHistory(c echo.Context) error {
 			return err
 		}
 
+		if errors.Is(err, service.ErrWalletAccessDenied) {
+			newErrorResponse(c, http.StatusForbidden, err.Error())
+			return err
+		}
+
 		newErrorResponse(c, http.StatusInternalServerError, "internal server error "+err.Error())
+		return err
 	}
 
 	type response struct {
@@ -127,17 +170,24 @@ func (r *walletRoutes) GetState(c echo.Context) error {
 		return err
 	}
 
-	walletState, err := r.walletService.GetWalletState(c.Request().Context(), walletId)
+	walletState, err := r.walletService.GetWalletState(c.Request().Context(), getUserId(c), walletId)
 	if err != nil {
-		if errors.Is(err, repoerrors.ErrNotFound) {
+		if errors.Is(err, repoerrors.ErrNotFound) || errors.Is(err, service.ErrCannotGetWallet) {
 			newErrorResponse(c, http.StatusNotFound, "wallet not found")
 			return err
 		}
+
+		if errors.Is(err, service.ErrWalletAccessDenied) {
+			newErrorResponse(c, http.StatusForbidden, err.Error())
+			return err
+		}
+
 		newErrorResponse(c, http.StatusInternalServerError, "internal server error "+err.Error())
+		return err
 	}
 
 	type response struct {
-		State entity.Wallet `json:"state"`
+		State entity.WalletState `json:"state"`
 	}
 
 	return c.JSON(http.StatusOK, response{State: walletState})
@@ -0,0 +1,13 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type Alias struct {
+	Alias     string    `json:"alias" db:"alias"`
+	Wallet    uuid.UUID `json:"wallet" db:"wallet"`
+	Owner     uuid.UUID `json:"owner" db:"owner"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
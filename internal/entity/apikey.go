@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	ScopeWalletRead     = "wallet:read"
+	ScopeWalletTransfer = "wallet:transfer"
+	ScopeWalletCreate   = "wallet:create"
+)
+
+// APIKey acts as its owner on the routes allowed by Scopes. Empty Wallets allows every wallet of the owner.
+type APIKey struct {
+	UUID       uuid.UUID   `json:"id" db:"uuid"`
+	Owner      uuid.UUID   `json:"owner" db:"owner"`
+	Name       string      `json:"name" db:"name"`
+	Prefix     string      `json:"prefix" db:"prefix"`
+	KeyHash    string      `json:"-" db:"key_hash"`
+	Scopes     []string    `json:"scopes" db:"scopes"`
+	Wallets    []uuid.UUID `json:"wallets" db:"wallets"`
+	CreatedBy  uuid.UUID   `json:"created_by" db:"created_by"`
+	CreatedAt  time.Time   `json:"created_at" db:"created_at"`
+	ExpiresAt  *time.Time  `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time  `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time  `json:"revoked_at,omitempty" db:"revoked_at"`
+}
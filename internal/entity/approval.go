@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	TransferStatusPending   = "pending"
+	TransferStatusExecuted  = "executed"
+	TransferStatusRejected  = "rejected"
+	TransferStatusExpired   = "expired"
+	TransferStatusScheduled = "scheduled"
+	TransferStatusCancelled = "cancelled"
+	TransferStatusFailed    = "failed"
+)
+
+const (
+	ApprovalDecisionApproved = "approved"
+	ApprovalDecisionRejected = "rejected"
+)
+
+type ApprovalPolicy struct {
+	Wallet            uuid.UUID   `json:"wallet" db:"wallet"`
+	Threshold         float64     `json:"threshold" db:"threshold"`
+	RequiredApprovals int         `json:"required_approvals" db:"required_approvals"`
+	TTLSeconds        int64       `json:"ttl_seconds" db:"ttl_seconds"`
+	Approvers         []uuid.UUID `json:"approvers"`
+}
+
+type PendingTransfer struct {
+	UUID              uuid.UUID  `json:"id" db:"uuid"`
+	Sender            uuid.UUID  `json:"from" db:"sender"`
+	Receiver          uuid.UUID  `json:"to" db:"receiver"`
+	Amount            float64    `json:"amount" db:"amount"`
+	Status            string     `json:"status" db:"status"`
+	RequiredApprovals int        `json:"required_approvals" db:"required_approvals"`
+	Approvals         int        `json:"approvals"`
+	CreatedBy         uuid.UUID  `json:"created_by" db:"created_by"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt         time.Time  `json:"expires_at" db:"expires_at"`
+	ResolvedAt        *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
+}
@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type Contact struct {
+	UUID      uuid.UUID `json:"id" db:"uuid"`
+	Owner     uuid.UUID `json:"owner" db:"owner"`
+	Name      string    `json:"name" db:"name"`
+	To        string    `json:"to" db:"target"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Counterparty is a wallet the wallet exchanged money with. Score weights each transfer by its age.
+type Counterparty struct {
+	Wallet    uuid.UUID `json:"wallet"`
+	Transfers int       `json:"transfers"`
+	LastAt    time.Time `json:"last_at"`
+	Score     float64   `json:"score"`
+}
@@ -0,0 +1,60 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	DisputeStatusOpen     = "open"
+	DisputeStatusReversed = "reversed"
+	DisputeStatusReleased = "released"
+)
+
+const (
+	DisputeEventOpened     = "opened"
+	DisputeEventHeld       = "held"
+	DisputeEventComment    = "comment"
+	DisputeEventAttachment = "attachment"
+	DisputeEventReversed   = "reversed"
+	DisputeEventReleased   = "released"
+)
+
+// Dispute of a completed transaction by its payer. Held is the part of Amount taken from the receiver
+// into the holding wallet when the dispute was opened.
+type Dispute struct {
+	UUID        uuid.UUID      `json:"id" db:"uuid"`
+	Transaction uuid.UUID      `json:"transaction" db:"transaction"`
+	Payer       uuid.UUID      `json:"payer" db:"payer"`
+	Receiver    uuid.UUID      `json:"receiver" db:"receiver"`
+	OpenedBy    uuid.UUID      `json:"opened_by" db:"opened_by"`
+	Amount      float64        `json:"amount" db:"amount"`
+	Held        float64        `json:"held" db:"held"`
+	Reason      string         `json:"reason" db:"reason"`
+	Status      string         `json:"status" db:"status"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+	ResolvedAt  *time.Time     `json:"resolved_at,omitempty" db:"resolved_at"`
+	Events      []DisputeEvent `json:"events,omitempty"`
+	Attachments []Attachment   `json:"attachments,omitempty"`
+}
+
+type DisputeEvent struct {
+	UUID      uuid.UUID     `json:"id" db:"uuid"`
+	Actor     uuid.NullUUID `json:"actor" db:"actor"`
+	Kind      string        `json:"kind" db:"kind"`
+	Note      string        `json:"note,omitempty" db:"note"`
+	Amount    *float64      `json:"amount,omitempty" db:"amount"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+}
+
+// Attachment is evidence added to a dispute, Data is only loaded for download
+type Attachment struct {
+	UUID        uuid.UUID `json:"id" db:"uuid"`
+	Dispute     uuid.UUID `json:"dispute" db:"dispute"`
+	UploadedBy  uuid.UUID `json:"uploaded_by" db:"uploaded_by"`
+	Name        string    `json:"name" db:"name"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	Size        int       `json:"size" db:"size"`
+	Data        []byte    `json:"-" db:"data"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	EscrowStatusHeld     = "held"
+	EscrowStatusReleased = "released"
+	EscrowStatusRefunded = "refunded"
+)
+
+type Escrow struct {
+	UUID       uuid.UUID  `json:"id" db:"uuid"`
+	Buyer      uuid.UUID  `json:"buyer" db:"buyer"`
+	Seller     uuid.UUID  `json:"seller" db:"seller"`
+	Amount     float64    `json:"amount" db:"amount"`
+	Status     string     `json:"status" db:"status"`
+	CreatedBy  uuid.UUID  `json:"created_by" db:"created_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
+}
+
+type Reconciliation struct {
+	TotalBalance   float64 `json:"total_balance"`
+	HoldingBalance float64 `json:"holding_balance"`
+	PocketBalance  float64 `json:"pocket_balance"`
+	EscrowHeld     float64 `json:"escrow_held"`
+	VoucherHeld    float64 `json:"voucher_held"`
+	DisputeHeld    float64 `json:"dispute_held"`
+	Balanced       bool    `json:"balanced"`
+}
@@ -0,0 +1,29 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+type ChildPolicy struct {
+	Wallet         uuid.UUID   `json:"wallet" db:"wallet"`
+	SpendLimit     *float64    `json:"spend_limit,omitempty" db:"spend_limit"`
+	LimitPeriod    string      `json:"limit_period" db:"limit_period"`
+	Counterparties []uuid.UUID `json:"counterparties"`
+}
+
+type Allowance struct {
+	UUID      uuid.UUID `json:"id" db:"uuid"`
+	Parent    uuid.UUID `json:"parent" db:"parent"`
+	Child     uuid.UUID `json:"child" db:"child"`
+	Amount    float64   `json:"amount" db:"amount"`
+	Period    string    `json:"period" db:"period"`
+	NextRun   time.Time `json:"next_run" db:"next_run"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
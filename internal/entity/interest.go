@@ -0,0 +1,24 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// InterestProduct accrues APR percent a year on positive balances from MinBalance, a day is 1/DayCount of a year
+type InterestProduct struct {
+	UUID       uuid.UUID `json:"id" db:"uuid"`
+	Name       string    `json:"name" db:"name"`
+	APR        float64   `json:"apr" db:"apr"`
+	DayCount   int       `json:"day_count" db:"day_count"`
+	MinBalance float64   `json:"min_balance" db:"min_balance"`
+	Active     bool      `json:"active" db:"active"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// InterestSummary shows the product of the wallet, interest accrued but not paid yet and paid so far
+type InterestSummary struct {
+	Product *InterestProduct `json:"product,omitempty"`
+	Accrued float64          `json:"accrued"`
+	Paid    float64          `json:"paid"`
+}
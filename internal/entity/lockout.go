@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+// LoginAttempts counts failed sign-ins under Key, "user:<username>" or "ip:<address>".
+// Sign-in is refused for the key while LockedUntil is in the future.
+type LoginAttempts struct {
+	Key           string     `json:"key" db:"key"`
+	Failures      int        `json:"failures" db:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at" db:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty" db:"locked_until"`
+}
@@ -0,0 +1,27 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	WalletRoleOwner   = "owner"
+	WalletRoleSpender = "spender"
+	WalletRoleViewer  = "viewer"
+)
+
+const (
+	MemberStatusInvited = "invited"
+	MemberStatusActive  = "active"
+)
+
+type WalletMember struct {
+	Wallet     uuid.UUID     `json:"wallet" db:"wallet"`
+	Member     uuid.UUID     `json:"member" db:"member"`
+	Role       string        `json:"role" db:"role"`
+	SpendLimit *float64      `json:"spend_limit,omitempty" db:"spend_limit"`
+	Status     string        `json:"status" db:"status"`
+	InvitedBy  uuid.NullUUID `json:"invited_by" db:"invited_by"`
+	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
+}
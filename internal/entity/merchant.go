@@ -0,0 +1,44 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	InvoiceStatusOpen          = "open"
+	InvoiceStatusPartiallyPaid = "partially_paid"
+	InvoiceStatusPaid          = "paid"
+	InvoiceStatusCancelled     = "cancelled"
+	InvoiceStatusExpired       = "expired"
+)
+
+type Merchant struct {
+	UUID      uuid.UUID   `json:"id" db:"uuid"`
+	Owner     uuid.UUID   `json:"owner" db:"owner"`
+	Name      string      `json:"name" db:"name"`
+	Wallets   []uuid.UUID `json:"wallets" db:"-"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+}
+
+type Invoice struct {
+	UUID         uuid.UUID     `json:"id" db:"uuid"`
+	Merchant     uuid.UUID     `json:"merchant" db:"merchant"`
+	Wallet       uuid.UUID     `json:"wallet" db:"wallet"`
+	Amount       float64       `json:"amount" db:"amount"`
+	Paid         float64       `json:"paid" db:"paid"`
+	Currency     string        `json:"currency" db:"currency"`
+	Description  string        `json:"description" db:"description"`
+	AllowPartial bool          `json:"allow_partial" db:"allow_partial"`
+	Status       string        `json:"status" db:"status"`
+	Items        []InvoiceItem `json:"items,omitempty" db:"-"`
+	ExpiresAt    *time.Time    `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt    time.Time     `json:"created_at" db:"created_at"`
+	PaidAt       *time.Time    `json:"paid_at,omitempty" db:"paid_at"`
+}
+
+type InvoiceItem struct {
+	Name     string  `json:"name" db:"name"`
+	Quantity int     `json:"quantity" db:"quantity"`
+	Price    float64 `json:"price" db:"price"`
+}
@@ -0,0 +1,16 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// PasswordResetToken lets the owner set a new password once before ExpiresAt
+type PasswordResetToken struct {
+	UUID      uuid.UUID  `db:"uuid"`
+	Owner     uuid.UUID  `db:"owner"`
+	TokenHash string     `db:"token_hash"`
+	CreatedAt time.Time  `db:"created_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+}
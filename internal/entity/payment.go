@@ -0,0 +1,15 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// PaymentRequest is what a payment QR code carries, decoded it prefills a transfer to the wallet
+type PaymentRequest struct {
+	To        uuid.UUID  `json:"to"`
+	Amount    float64    `json:"amount,omitempty"`
+	Currency  string     `json:"currency"`
+	Memo      string     `json:"memo,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
@@ -0,0 +1,19 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type Pocket struct {
+	UUID      uuid.UUID  `json:"id" db:"uuid"`
+	Wallet    uuid.UUID  `json:"wallet" db:"wallet"`
+	Name      string     `json:"name" db:"name"`
+	Balance   float64    `json:"balance" db:"balance"`
+	Target    *float64   `json:"target,omitempty" db:"target"`
+	Deadline  *time.Time `json:"deadline,omitempty" db:"deadline"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+
+	// Progress is the saved share of the target in percent, set only when the pocket has a target
+	Progress *float64 `json:"progress,omitempty" db:"-"`
+}
@@ -0,0 +1,51 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	PromoKindCashback      = "cashback"
+	PromoKindFirstTransfer = "first_transfer"
+)
+
+// PromoCampaign rewards transfers from the promo wallet until Budget is spent.
+// Receiver limits the campaign to transfers into one wallet, UserCap limits payouts of a user
+// per CapPeriod or, without a period, for the whole campaign.
+type PromoCampaign struct {
+	UUID        uuid.UUID     `json:"id" db:"uuid"`
+	Name        string        `json:"name" db:"name"`
+	Kind        string        `json:"kind" db:"kind"`
+	Percent     float64       `json:"percent" db:"percent"`
+	FixedAmount float64       `json:"fixed_amount" db:"fixed_amount"`
+	Receiver    uuid.NullUUID `json:"receiver" db:"receiver"`
+	MinAmount   float64       `json:"min_amount" db:"min_amount"`
+	Budget      float64       `json:"budget" db:"budget"`
+	Spent       float64       `json:"spent" db:"spent"`
+	UserCap     *float64      `json:"user_cap,omitempty" db:"user_cap"`
+	CapPeriod   *string       `json:"cap_period,omitempty" db:"cap_period"`
+	Active      bool          `json:"active" db:"active"`
+	StartsAt    time.Time     `json:"starts_at" db:"starts_at"`
+	EndsAt      *time.Time    `json:"ends_at,omitempty" db:"ends_at"`
+	CreatedAt   time.Time     `json:"created_at" db:"created_at"`
+}
+
+type PromoPayout struct {
+	UUID      uuid.UUID `json:"id" db:"uuid"`
+	Campaign  uuid.UUID `json:"campaign" db:"campaign"`
+	User      uuid.UUID `json:"user" db:"user_uuid"`
+	Wallet    uuid.UUID `json:"wallet" db:"wallet"`
+	Amount    float64   `json:"amount" db:"amount"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type PromoReport struct {
+	Campaign  uuid.UUID `json:"campaign"`
+	Name      string    `json:"name"`
+	Budget    float64   `json:"budget"`
+	Spent     float64   `json:"spent"`
+	Remaining float64   `json:"remaining"`
+	Payouts   int       `json:"payouts"`
+	Users     int       `json:"users"`
+}
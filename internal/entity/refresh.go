@@ -0,0 +1,19 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// RefreshToken is one link of a rotation chain. Family is shared by all tokens issued from one sign-in,
+// UsedAt is set when the token is exchanged for the next one.
+type RefreshToken struct {
+	UUID      uuid.UUID  `db:"uuid"`
+	Family    uuid.UUID  `db:"family"`
+	Owner     uuid.UUID  `db:"owner"`
+	TokenHash string     `db:"token_hash"`
+	CreatedAt time.Time  `db:"created_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
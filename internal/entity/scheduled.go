@@ -0,0 +1,19 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// ScheduledTransfer is executed at ExecuteAt, the sender may cancel it until then
+type ScheduledTransfer struct {
+	UUID       uuid.UUID  `json:"id" db:"uuid"`
+	Sender     uuid.UUID  `json:"from" db:"sender"`
+	Receiver   uuid.UUID  `json:"to" db:"receiver"`
+	Amount     float64    `json:"amount" db:"amount"`
+	Status     string     `json:"status" db:"status"`
+	CreatedBy  uuid.UUID  `json:"created_by" db:"created_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ExecuteAt  time.Time  `json:"execute_at" db:"execute_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
+}
@@ -0,0 +1,18 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// TOTP is the authenticator of the user, two-factor authentication is enabled once ConfirmedAt is set.
+// TransferThreshold nil never asks a code for transfers.
+type TOTP struct {
+	Owner             uuid.UUID  `json:"owner" db:"owner"`
+	Secret            string     `json:"-" db:"secret"`
+	ConfirmedAt       *time.Time `json:"confirmed_at,omitempty" db:"confirmed_at"`
+	LastCounter       int64      `json:"-" db:"last_counter"`
+	TransferThreshold *float64   `json:"transfer_threshold,omitempty" db:"transfer_threshold"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	RecoveryCodesLeft int        `json:"recovery_codes_left" db:"recovery_codes_left"`
+}
@@ -6,8 +6,12 @@ import (
 )
 
 type Transaction struct {
-	Sender    uuid.UUID `json:"from" db:"sender"`
-	Receiver  uuid.UUID `json:"to" db:"receiver"`
-	CreatedAt time.Time `json:"time" db:"created_at"`
-	Amount    float64   `json:"amount" db:"amount"`
+	UUID      uuid.UUID     `json:"id" db:"uuid"`
+	Sender    uuid.UUID     `json:"from" db:"sender"`
+	Receiver  uuid.UUID     `json:"to" db:"receiver"`
+	CreatedAt time.Time     `json:"time" db:"created_at"`
+	Amount    float64       `json:"amount" db:"amount"`
+	Invoice   uuid.NullUUID `json:"invoice" db:"invoice"`
+	Status    string        `json:"status"`
+	ExecuteAt *time.Time    `json:"execute_at,omitempty"`
 }
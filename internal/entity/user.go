@@ -5,9 +5,26 @@ import (
 	"time"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
-	UUID      uuid.UUID `db:"uuid"`
-	Username  string    `db:"username"`
-	Password  string    `db:"password"`
-	CreatedAt time.Time `db:"created_at"`
+	UUID        uuid.UUID  `db:"uuid"`
+	Username    string     `db:"username"`
+	Password    string     `db:"password"`
+	Role        string     `db:"role"`
+	DisplayName *string    `db:"display_name"`
+	CreatedAt   time.Time  `db:"created_at"`
+	DeletedAt   *time.Time `db:"deleted_at"`
+}
+
+// Profile is the user as shown to themselves, Wallets are the active memberships
+type Profile struct {
+	UUID        uuid.UUID      `json:"uuid"`
+	Username    string         `json:"username"`
+	DisplayName *string        `json:"display_name"`
+	CreatedAt   time.Time      `json:"created_at"`
+	Wallets     []WalletMember `json:"wallets"`
 }
@@ -0,0 +1,27 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	VoucherStatusActive   = "active"
+	VoucherStatusRedeemed = "redeemed"
+	VoucherStatusRevoked  = "revoked"
+)
+
+// Voucher is funded from Wallet at creation, each redemption pays Amount. The code itself is never stored.
+type Voucher struct {
+	UUID           uuid.UUID  `json:"id" db:"uuid"`
+	CodeHash       string     `json:"-" db:"code_hash"`
+	Issuer         uuid.UUID  `json:"issuer" db:"issuer"`
+	Wallet         uuid.UUID  `json:"wallet" db:"wallet"`
+	Amount         float64    `json:"amount" db:"amount"`
+	Currency       string     `json:"currency" db:"currency"`
+	MaxRedemptions int        `json:"max_redemptions" db:"max_redemptions"`
+	Redemptions    int        `json:"redemptions" db:"redemptions"`
+	Status         string     `json:"status" db:"status"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
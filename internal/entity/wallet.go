@@ -4,7 +4,24 @@ import (
 	"github.com/google/uuid"
 )
 
+// Wallet balance may go negative down to -CreditLimit, CreditRate is the daily fee share of a negative balance.
+// Transfers from the wallet are executed TransferDelaySeconds after they are made.
 type Wallet struct {
-	UUID    uuid.UUID `db:"uuid" json:"id"`
-	Balance float64   `db:"balance" json:"balance"`
+	UUID                 uuid.UUID     `db:"uuid" json:"id"`
+	Balance              float64       `db:"balance" json:"balance"`
+	Parent               uuid.NullUUID `db:"parent" json:"parent"`
+	CreditLimit          float64       `db:"credit_limit" json:"credit_limit"`
+	CreditRate           float64       `db:"credit_rate" json:"credit_rate"`
+	TransferDelaySeconds int64         `db:"transfer_delay_seconds" json:"transfer_delay_seconds"`
+}
+
+// WalletState describes the wallet for its members. Available is the spendable balance plus the credit limit,
+// Total adds the money put aside in pockets to the balance.
+type WalletState struct {
+	Wallet
+	Total          float64  `json:"total"`
+	Available      float64  `json:"available"`
+	Pockets        []Pocket `json:"pockets"`
+	EscrowHeld     float64  `json:"escrow_held"`
+	EscrowIncoming float64  `json:"escrow_incoming"`
 }
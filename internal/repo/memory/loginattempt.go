@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LoginAttemptRepo keeps failed sign-ins in the process, every instance counts on its own
+// and the counts are lost on restart. It suits a single instance and local runs.
+type LoginAttemptRepo struct {
+	mu       sync.Mutex
+	attempts map[string]entity.LoginAttempts
+}
+
+func NewLoginAttemptRepo() *LoginAttemptRepo {
+	return &LoginAttemptRepo{attempts: make(map[string]entity.LoginAttempts)}
+}
+
+func (r *LoginAttemptRepo) GetLoginAttempts(_ context.Context, key string) (entity.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts, ok := r.attempts[key]
+	if !ok {
+		return entity.LoginAttempts{}, repoerrors.ErrNotFound
+	}
+
+	return attempts, nil
+}
+
+func (r *LoginAttemptRepo) AddFailedLogin(
+	_ context.Context,
+	key string,
+	now time.Time,
+	resetAfter time.Duration,
+) (entity.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts, ok := r.attempts[key]
+	if !ok || attempts.LastFailureAt.Before(now.Add(-resetAfter)) {
+		attempts.Key, attempts.Failures = key, 0
+	}
+	attempts.Failures++
+	attempts.LastFailureAt = now
+	r.attempts[key] = attempts
+
+	return attempts, nil
+}
+
+func (r *LoginAttemptRepo) LockLogin(_ context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts, ok := r.attempts[key]
+	if !ok {
+		return repoerrors.ErrNotFound
+	}
+	attempts.LockedUntil = &until
+	r.attempts[key] = attempts
+
+	return nil
+}
+
+func (r *LoginAttemptRepo) ResetLoginAttempts(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.attempts[key]; !ok {
+		return repoerrors.ErrNotFound
+	}
+	delete(r.attempts, key)
+
+	return nil
+}
+
+func (r *LoginAttemptRepo) GetLockedLogins(_ context.Context, now time.Time) ([]entity.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var locked []entity.LoginAttempts
+	for _, attempts := range r.attempts {
+		if attempts.LockedUntil != nil && attempts.LockedUntil.After(now) {
+			locked = append(locked, attempts)
+		}
+	}
+
+	sort.Slice(locked, func(i, j int) bool {
+		return locked[i].LockedUntil.After(*locked[j].LockedUntil)
+	})
+
+	return locked, nil
+}
+
+func (r *LoginAttemptRepo) DeleteStaleLoginAttempts(_ context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for key, attempts := range r.attempts {
+		if attempts.LastFailureAt.Before(before) && (attempts.LockedUntil == nil || attempts.LockedUntil.Before(before)) {
+			delete(r.attempts, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,151 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+)
+
+type AliasRepo struct {
+	*postgres.Postgres
+}
+
+func NewAliasRepo(pg *postgres.Postgres) *AliasRepo {
+	return &AliasRepo{pg}
+}
+
+func (r *AliasRepo) CreateAlias(ctx context.Context, alias entity.Alias) error {
+	sql, args, _ := r.Builder.
+		Insert("wallet_aliases").
+		Columns("alias", "wallet", "owner").
+		Values(alias.Alias, alias.Wallet, alias.Owner).
+		ToSql()
+
+	_, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			switch pgErr.Code {
+			case "23505":
+				return repoerrors.ErrAlreadyExist
+			case "23503":
+				return repoerrors.ErrNotFound
+			}
+		}
+		return fmt.Errorf("AliasRepo.CreateAlias - r.Pool.Exec: %v", err)
+	}
+
+	return nil
+}
+
+func (r *AliasRepo) GetAlias(ctx context.Context, alias string) (entity.Alias, error) {
+	sql, args, _ := r.Builder.
+		Select("alias", "wallet", "owner", "created_at").
+		From("wallet_aliases").
+		Where(squirrel.Eq{"alias": alias}).
+		ToSql()
+
+	var result entity.Alias
+	err := r.Pool.QueryRow(ctx, sql, args...).
+		Scan(&result.Alias, &result.Wallet, &result.Owner, &result.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Alias{}, repoerrors.ErrNotFound
+		}
+		return entity.Alias{}, fmt.Errorf("AliasRepo.GetAlias - r.Pool.QueryRow: %v", err)
+	}
+
+	return result, nil
+}
+
+func (r *AliasRepo) GetUserAliases(ctx context.Context, owner uuid.UUID) ([]entity.Alias, error) {
+	sql, args, _ := r.Builder.
+		Select("alias", "wallet", "owner", "created_at").
+		From("wallet_aliases").
+		Where(squirrel.Eq{"owner": owner}).
+		OrderBy("created_at").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("AliasRepo.GetUserAliases - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var aliases []entity.Alias
+	for rows.Next() {
+		var alias entity.Alias
+		if err = rows.Scan(&alias.Alias, &alias.Wallet, &alias.Owner, &alias.CreatedAt); err != nil {
+			return nil, fmt.Errorf("AliasRepo.GetUserAliases - rows.Scan: %v", err)
+		}
+		aliases = append(aliases, alias)
+	}
+
+	return aliases, nil
+}
+
+func (r *AliasRepo) DeleteAlias(ctx context.Context, alias string, owner uuid.UUID) error {
+	sql, args, _ := r.Builder.
+		Delete("wallet_aliases").
+		Where(squirrel.Eq{"alias": alias, "owner": owner}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("AliasRepo.DeleteAlias - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// SetDefaultWallet sets the wallet receiving transfers sent to the username, uuid.NullUUID{} unsets it
+func (r *AliasRepo) SetDefaultWallet(ctx context.Context, userUUID uuid.UUID, wallet uuid.NullUUID) error {
+	sql, args, _ := r.Builder.
+		Update("users").
+		Set("default_wallet", wallet).
+		Where(squirrel.Eq{"uuid": userUUID}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("AliasRepo.SetDefaultWallet - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// GetDefaultWallet returns the owner and the default wallet of the user with the username
+func (r *AliasRepo) GetDefaultWallet(ctx context.Context, username string) (uuid.UUID, uuid.NullUUID, error) {
+	sql, args, _ := r.Builder.
+		Select("uuid", "default_wallet").
+		From("users").
+		Where(squirrel.Eq{"username": username}).
+		ToSql()
+
+	var (
+		userUUID uuid.UUID
+		wallet   uuid.NullUUID
+	)
+	err := r.Pool.QueryRow(ctx, sql, args...).Scan(&userUUID, &wallet)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, uuid.NullUUID{}, repoerrors.ErrNotFound
+		}
+		return uuid.Nil, uuid.NullUUID{}, fmt.Errorf("AliasRepo.GetDefaultWallet - r.Pool.QueryRow: %v", err)
+	}
+
+	return userUUID, wallet, nil
+}
@@ -0,0 +1,164 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+)
+
+type APIKeyRepo struct {
+	*postgres.Postgres
+}
+
+func NewAPIKeyRepo(pg *postgres.Postgres) *APIKeyRepo {
+	return &APIKeyRepo{pg}
+}
+
+var apiKeyColumns = []string{
+	"uuid", "owner", "name", "prefix", "key_hash", "scopes", "wallets",
+	"created_by", "created_at", "expires_at", "last_used_at", "revoked_at",
+}
+
+func scanAPIKey(row pgx.Row) (entity.APIKey, error) {
+	var key entity.APIKey
+	err := row.Scan(
+		&key.UUID,
+		&key.Owner,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		&key.Scopes,
+		&key.Wallets,
+		&key.CreatedBy,
+		&key.CreatedAt,
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+	)
+	return key, err
+}
+
+func (r *APIKeyRepo) CreateAPIKey(ctx context.Context, key entity.APIKey) (entity.APIKey, error) {
+	if key.Wallets == nil {
+		key.Wallets = []uuid.UUID{}
+	}
+
+	sql, args, _ := r.Builder.
+		Insert("api_keys").
+		Columns("owner", "name", "prefix", "key_hash", "scopes", "wallets", "created_by", "expires_at").
+		Values(key.Owner, key.Name, key.Prefix, key.KeyHash, key.Scopes, key.Wallets, key.CreatedBy, key.ExpiresAt).
+		Suffix("RETURNING uuid, created_at").
+		ToSql()
+
+	err := r.Pool.QueryRow(ctx, sql, args...).Scan(&key.UUID, &key.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23505" {
+				return entity.APIKey{}, repoerrors.ErrAlreadyExist
+			}
+			if pgErr.Code == "23503" {
+				return entity.APIKey{}, repoerrors.ErrNotFound
+			}
+		}
+		return entity.APIKey{}, fmt.Errorf("APIKeyRepo.CreateAPIKey - r.Pool.QueryRow: %v", err)
+	}
+
+	return key, nil
+}
+
+func (r *APIKeyRepo) GetAPIKeyByHash(ctx context.Context, keyHash string) (entity.APIKey, error) {
+	sql, args, _ := r.Builder.
+		Select(apiKeyColumns...).
+		From("api_keys").
+		Where(squirrel.Eq{"key_hash": keyHash}).
+		ToSql()
+
+	key, err := scanAPIKey(r.Pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.APIKey{}, repoerrors.ErrNotFound
+		}
+		return entity.APIKey{}, fmt.Errorf("APIKeyRepo.GetAPIKeyByHash - r.Pool.QueryRow: %v", err)
+	}
+
+	return key, nil
+}
+
+func (r *APIKeyRepo) GetUserAPIKeys(ctx context.Context, owner uuid.UUID) ([]entity.APIKey, error) {
+	sql, args, _ := r.Builder.
+		Select(apiKeyColumns...).
+		From("api_keys").
+		Where(squirrel.Eq{"owner": owner}).
+		OrderBy("created_at DESC").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyRepo.GetUserAPIKeys - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	keys := make([]entity.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("APIKeyRepo.GetUserAPIKeys - rows.Scan: %v", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey returns repoerrors.ErrNotFound if the owner has no such key or it is already revoked
+func (r *APIKeyRepo) RevokeAPIKey(ctx context.Context, owner, keyUUID uuid.UUID) error {
+	sql, args, _ := r.Builder.
+		Update("api_keys").
+		Set("revoked_at", squirrel.Expr("now()")).
+		Where(squirrel.And{
+			squirrel.Eq{"uuid": keyUUID},
+			squirrel.Eq{"owner": owner},
+			squirrel.Eq{"revoked_at": nil},
+		}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("APIKeyRepo.RevokeAPIKey - r.Pool.Exec: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// TouchAPIKey records the use of the key, at most once a minute so busy keys do not write on every request
+func (r *APIKeyRepo) TouchAPIKey(ctx context.Context, keyUUID uuid.UUID) error {
+	sql, args, _ := r.Builder.
+		Update("api_keys").
+		Set("last_used_at", squirrel.Expr("now()")).
+		Where(squirrel.And{
+			squirrel.Eq{"uuid": keyUUID},
+			squirrel.Or{
+				squirrel.Eq{"last_used_at": nil},
+				squirrel.Expr("last_used_at < now() - interval '1 minute'"),
+			},
+		}).
+		ToSql()
+
+	if _, err := r.Pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("APIKeyRepo.TouchAPIKey - r.Pool.Exec: %v", err)
+	}
+
+	return nil
+}
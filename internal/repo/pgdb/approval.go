@@ -0,0 +1,395 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+	"time"
+)
+
+type ApprovalRepo struct {
+	*postgres.Postgres
+}
+
+func NewApprovalRepo(pg *postgres.Postgres) *ApprovalRepo {
+	return &ApprovalRepo{pg}
+}
+
+var pendingTransferColumns = []string{
+	"uuid", "sender", "receiver", "amount", "status", "required_approvals",
+	"(select count(*) from transfer_approvals a where a.transfer = pending_transfers.uuid and a.decision = 'approved')",
+	"created_by", "created_at", "expires_at", "resolved_at",
+}
+
+func scanPendingTransfer(row pgx.Row) (entity.PendingTransfer, error) {
+	var transfer entity.PendingTransfer
+	err := row.Scan(
+		&transfer.UUID,
+		&transfer.Sender,
+		&transfer.Receiver,
+		&transfer.Amount,
+		&transfer.Status,
+		&transfer.RequiredApprovals,
+		&transfer.Approvals,
+		&transfer.CreatedBy,
+		&transfer.CreatedAt,
+		&transfer.ExpiresAt,
+		&transfer.ResolvedAt,
+	)
+	return transfer, err
+}
+
+// SetApprovalPolicy creates or replaces the wallet policy together with its approvers
+func (r *ApprovalRepo) SetApprovalPolicy(ctx context.Context, policy entity.ApprovalPolicy) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ApprovalRepo.SetApprovalPolicy - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Insert("approval_policies").
+		Columns("wallet", "threshold", "required_approvals", "ttl_seconds").
+		Values(policy.Wallet, policy.Threshold, policy.RequiredApprovals, policy.TTLSeconds).
+		Suffix("ON CONFLICT (wallet) DO UPDATE SET " +
+			"threshold = excluded.threshold, " +
+			"required_approvals = excluded.required_approvals, " +
+			"ttl_seconds = excluded.ttl_seconds").
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("ApprovalRepo.SetApprovalPolicy - tx.Exec: %v", err)
+	}
+
+	sql, args, _ = r.Builder.
+		Delete("approval_policy_approvers").
+		Where(squirrel.Eq{"wallet": policy.Wallet}).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("ApprovalRepo.SetApprovalPolicy - tx.Exec: %v", err)
+	}
+
+	insert := r.Builder.
+		Insert("approval_policy_approvers").
+		Columns("wallet", "approver")
+	for _, approver := range policy.Approvers {
+		insert = insert.Values(policy.Wallet, approver)
+	}
+
+	sql, args, _ = insert.ToSql()
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23503" {
+				return repoerrors.ErrNotFound
+			}
+		}
+		return fmt.Errorf("ApprovalRepo.SetApprovalPolicy - tx.Exec: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ApprovalRepo.SetApprovalPolicy - tx.Commit: %v", err)
+	}
+
+	return nil
+}
+
+func (r *ApprovalRepo) GetApprovalPolicy(ctx context.Context, walletUUID uuid.UUID) (entity.ApprovalPolicy, error) {
+	sql, args, _ := r.Builder.
+		Select("wallet", "threshold", "required_approvals", "ttl_seconds").
+		From("approval_policies").
+		Where(squirrel.Eq{"wallet": walletUUID}).
+		ToSql()
+
+	var policy entity.ApprovalPolicy
+	err := r.Pool.QueryRow(ctx, sql, args...).Scan(
+		&policy.Wallet,
+		&policy.Threshold,
+		&policy.RequiredApprovals,
+		&policy.TTLSeconds,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.ApprovalPolicy{}, repoerrors.ErrNotFound
+		}
+		return entity.ApprovalPolicy{}, fmt.Errorf("ApprovalRepo.GetApprovalPolicy - r.Pool.QueryRow: %v", err)
+	}
+
+	sql, args, _ = r.Builder.
+		Select("approver").
+		From("approval_policy_approvers").
+		Where(squirrel.Eq{"wallet": walletUUID}).
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return entity.ApprovalPolicy{}, fmt.Errorf("ApprovalRepo.GetApprovalPolicy - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var approver uuid.UUID
+		if err = rows.Scan(&approver); err != nil {
+			return entity.ApprovalPolicy{}, fmt.Errorf("ApprovalRepo.GetApprovalPolicy - rows.Scan: %v", err)
+		}
+		policy.Approvers = append(policy.Approvers, approver)
+	}
+
+	return policy, nil
+}
+
+func (r *ApprovalRepo) DeleteApprovalPolicy(ctx context.Context, walletUUID uuid.UUID) error {
+	sql, args, _ := r.Builder.
+		Delete("approval_policies").
+		Where(squirrel.Eq{"wallet": walletUUID}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("ApprovalRepo.DeleteApprovalPolicy - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// CreatePendingTransfer returns repoerrors.ErrLimitExceeded when the amount does not fit into the spend limit
+// of a child wallet, pending transfers count against it until they are resolved
+func (r *ApprovalRepo) CreatePendingTransfer(ctx context.Context, transfer entity.PendingTransfer) (uuid.UUID, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("ApprovalRepo.CreatePendingTransfer - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err = checkChildLimit(ctx, tx, r.Builder, transfer.Sender, transfer.Receiver, transfer.Amount); err != nil {
+		return uuid.Nil, err
+	}
+
+	sql, args, _ := r.Builder.
+		Insert("pending_transfers").
+		Columns("sender", "receiver", "amount", "status", "required_approvals", "created_by", "expires_at").
+		Values(
+			transfer.Sender,
+			transfer.Receiver,
+			transfer.Amount,
+			entity.TransferStatusPending,
+			transfer.RequiredApprovals,
+			transfer.CreatedBy,
+			transfer.ExpiresAt,
+		).
+		Suffix("RETURNING uuid").
+		ToSql()
+
+	var transferUUID uuid.UUID
+	if err = tx.QueryRow(ctx, sql, args...).Scan(&transferUUID); err != nil {
+		return uuid.Nil, fmt.Errorf("ApprovalRepo.CreatePendingTransfer - tx.QueryRow: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("ApprovalRepo.CreatePendingTransfer - tx.Commit: %v", err)
+	}
+
+	return transferUUID, nil
+}
+
+func (r *ApprovalRepo) GetPendingTransferById(ctx context.Context, uuid uuid.UUID) (entity.PendingTransfer, error) {
+	sql, args, _ := r.Builder.
+		Select(pendingTransferColumns...).
+		From("pending_transfers").
+		Where(squirrel.Eq{"uuid": uuid}).
+		ToSql()
+
+	transfer, err := scanPendingTransfer(r.Pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.PendingTransfer{}, repoerrors.ErrNotFound
+		}
+		return entity.PendingTransfer{}, fmt.Errorf("ApprovalRepo.GetPendingTransferById - r.Pool.QueryRow: %v", err)
+	}
+
+	return transfer, nil
+}
+
+// GetApproverPendingTransfers returns transfers waiting for a decision of the approver
+func (r *ApprovalRepo) GetApproverPendingTransfers(ctx context.Context, approver uuid.UUID) ([]entity.PendingTransfer, error) {
+	sql, args, _ := r.Builder.
+		Select(pendingTransferColumns...).
+		From("pending_transfers").
+		Where(squirrel.Eq{"status": entity.TransferStatusPending}).
+		Where(squirrel.Expr(
+			"sender in (select wallet from approval_policy_approvers where approver = ?)", approver,
+		)).
+		Where(squirrel.Expr(
+			"not exists (select 1 from transfer_approvals a where a.transfer = pending_transfers.uuid and a.approver = ?)", approver,
+		)).
+		OrderBy("created_at").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ApprovalRepo.GetApproverPendingTransfers - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var transfers []entity.PendingTransfer
+	for rows.Next() {
+		transfer, err := scanPendingTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ApprovalRepo.GetApproverPendingTransfers - rows.Scan: %v", err)
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers, nil
+}
+
+// ApproveTransfer records the approval and executes the transfer once enough approvals are collected.
+// It returns the transfer status after the approval.
+func (r *ApprovalRepo) ApproveTransfer(ctx context.Context, transferUUID, approver uuid.UUID) (string, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("ApprovalRepo.ApproveTransfer - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	pending, err := r.lockPendingTransfer(ctx, tx, transferUUID)
+	if err != nil {
+		return "", err
+	}
+
+	if err = r.insertDecision(ctx, tx, transferUUID, approver, entity.ApprovalDecisionApproved); err != nil {
+		return "", err
+	}
+
+	status := entity.TransferStatusPending
+	if pending.Approvals+1 >= pending.RequiredApprovals {
+		if err = transfer(ctx, tx, r.Builder, pending.Sender, pending.Receiver, pending.Amount); err != nil {
+			return "", err
+		}
+
+		status = entity.TransferStatusExecuted
+		if err = r.resolve(ctx, tx, transferUUID, status); err != nil {
+			return "", err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("ApprovalRepo.ApproveTransfer - tx.Commit: %v", err)
+	}
+
+	return status, nil
+}
+
+func (r *ApprovalRepo) RejectTransfer(ctx context.Context, transferUUID, approver uuid.UUID) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ApprovalRepo.RejectTransfer - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err = r.lockPendingTransfer(ctx, tx, transferUUID); err != nil {
+		return err
+	}
+
+	if err = r.insertDecision(ctx, tx, transferUUID, approver, entity.ApprovalDecisionRejected); err != nil {
+		return err
+	}
+
+	if err = r.resolve(ctx, tx, transferUUID, entity.TransferStatusRejected); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ApprovalRepo.RejectTransfer - tx.Commit: %v", err)
+	}
+
+	return nil
+}
+
+func (r *ApprovalRepo) ExpirePendingTransfers(ctx context.Context, now time.Time) error {
+	sql, args, _ := r.Builder.
+		Update("pending_transfers").
+		Set("status", entity.TransferStatusExpired).
+		Set("resolved_at", now).
+		Where(squirrel.And{
+			squirrel.Eq{"status": entity.TransferStatusPending},
+			squirrel.LtOrEq{"expires_at": now},
+		}).
+		ToSql()
+
+	if _, err := r.Pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("ApprovalRepo.ExpirePendingTransfers - r.Pool.Exec: %v", err)
+	}
+
+	return nil
+}
+
+// lockPendingTransfer locks a transfer that is still waiting for approvals and not expired
+func (r *ApprovalRepo) lockPendingTransfer(ctx context.Context, tx pgx.Tx, transferUUID uuid.UUID) (entity.PendingTransfer, error) {
+	sql, args, _ := r.Builder.
+		Select(pendingTransferColumns...).
+		From("pending_transfers").
+		Where(squirrel.And{
+			squirrel.Eq{"uuid": transferUUID},
+			squirrel.Eq{"status": entity.TransferStatusPending},
+			squirrel.Expr("expires_at > now()"),
+		}).
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	transfer, err := scanPendingTransfer(tx.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.PendingTransfer{}, repoerrors.ErrNotFound
+		}
+		return entity.PendingTransfer{}, fmt.Errorf("ApprovalRepo.lockPendingTransfer - tx.QueryRow: %v", err)
+	}
+
+	return transfer, nil
+}
+
+func (r *ApprovalRepo) insertDecision(ctx context.Context, tx pgx.Tx, transferUUID, approver uuid.UUID, decision string) error {
+	sql, args, _ := r.Builder.
+		Insert("transfer_approvals").
+		Columns("transfer", "approver", "decision").
+		Values(transferUUID, approver, decision).
+		ToSql()
+
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23505" {
+				return repoerrors.ErrAlreadyExist
+			}
+		}
+		return fmt.Errorf("ApprovalRepo.insertDecision - tx.Exec: %v", err)
+	}
+
+	return nil
+}
+
+func (r *ApprovalRepo) resolve(ctx context.Context, tx pgx.Tx, transferUUID uuid.UUID, status string) error {
+	sql, args, _ := r.Builder.
+		Update("pending_transfers").
+		Set("status", status).
+		Set("resolved_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"uuid": transferUUID}).
+		ToSql()
+
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("ApprovalRepo.resolve - tx.Exec: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+)
+
+type ContactRepo struct {
+	*postgres.Postgres
+}
+
+func NewContactRepo(pg *postgres.Postgres) *ContactRepo {
+	return &ContactRepo{pg}
+}
+
+func (r *ContactRepo) CreateContact(ctx context.Context, contact entity.Contact) (uuid.UUID, error) {
+	sql, args, _ := r.Builder.
+		Insert("contacts").
+		Columns("owner", "name", "target").
+		Values(contact.Owner, contact.Name, contact.To).
+		Suffix("RETURNING uuid").
+		ToSql()
+
+	var contactUUID uuid.UUID
+	err := r.Pool.QueryRow(ctx, sql, args...).Scan(&contactUUID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23505" {
+				return uuid.Nil, repoerrors.ErrAlreadyExist
+			}
+		}
+		return uuid.Nil, fmt.Errorf("ContactRepo.CreateContact - r.Pool.QueryRow: %v", err)
+	}
+
+	return contactUUID, nil
+}
+
+func (r *ContactRepo) GetContacts(ctx context.Context, owner uuid.UUID) ([]entity.Contact, error) {
+	sql, args, _ := r.Builder.
+		Select("uuid", "owner", "name", "target", "created_at").
+		From("contacts").
+		Where(squirrel.Eq{"owner": owner}).
+		OrderBy("name").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ContactRepo.GetContacts - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var contacts []entity.Contact
+	for rows.Next() {
+		var contact entity.Contact
+		if err = rows.Scan(&contact.UUID, &contact.Owner, &contact.Name, &contact.To, &contact.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ContactRepo.GetContacts - rows.Scan: %v", err)
+		}
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, nil
+}
+
+func (r *ContactRepo) UpdateContact(ctx context.Context, contact entity.Contact) error {
+	sql, args, _ := r.Builder.
+		Update("contacts").
+		Set("name", contact.Name).
+		Set("target", contact.To).
+		Where(squirrel.Eq{"uuid": contact.UUID, "owner": contact.Owner}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23505" {
+				return repoerrors.ErrAlreadyExist
+			}
+		}
+		return fmt.Errorf("ContactRepo.UpdateContact - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *ContactRepo) DeleteContact(ctx context.Context, contactUUID, owner uuid.UUID) error {
+	sql, args, _ := r.Builder.
+		Delete("contacts").
+		Where(squirrel.Eq{"uuid": contactUUID, "owner": owner}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("ContactRepo.DeleteContact - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
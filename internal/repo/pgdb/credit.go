@@ -0,0 +1,154 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+	"time"
+)
+
+type CreditRepo struct {
+	*postgres.Postgres
+}
+
+func NewCreditRepo(pg *postgres.Postgres) *CreditRepo {
+	return &CreditRepo{pg}
+}
+
+func (r *CreditRepo) SetCreditLine(ctx context.Context, walletUUID uuid.UUID, limit, rate float64) error {
+	sql, args, _ := r.Builder.
+		Update("wallets").
+		Set("credit_limit", limit).
+		Set("credit_rate", rate).
+		Where(squirrel.Eq{"uuid": walletUUID}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("CreditRepo.SetCreditLine - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// GetOverdrawnWallets returns wallets with a negative balance and a fee rate
+func (r *CreditRepo) GetOverdrawnWallets(ctx context.Context) ([]uuid.UUID, error) {
+	sql, args, _ := r.Builder.
+		Select("uuid").
+		From("wallets").
+		Where(squirrel.And{
+			squirrel.Lt{"balance": 0},
+			squirrel.Gt{"credit_rate": 0},
+		}).
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("CreditRepo.GetOverdrawnWallets - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var wallets []uuid.UUID
+	for rows.Next() {
+		var walletUUID uuid.UUID
+		if err = rows.Scan(&walletUUID); err != nil {
+			return nil, fmt.Errorf("CreditRepo.GetOverdrawnWallets - rows.Scan: %v", err)
+		}
+		wallets = append(wallets, walletUUID)
+	}
+
+	return wallets, nil
+}
+
+// ChargeFee charges the daily fee on the negative balance of the wallet to the fee wallet.
+// A wallet is charged once per day, a repeated call returns zero. The fee may take the wallet beyond its credit limit.
+func (r *CreditRepo) ChargeFee(ctx context.Context, walletUUID, feeWallet uuid.UUID, day time.Time) (float64, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("CreditRepo.ChargeFee - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Select("balance", "credit_rate").
+		From("wallets").
+		Where(squirrel.Eq{"uuid": walletUUID}).
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	var balance, rate float64
+	if err = tx.QueryRow(ctx, sql, args...).Scan(&balance, &rate); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, repoerrors.ErrNotFound
+		}
+		return 0, fmt.Errorf("CreditRepo.ChargeFee - tx.QueryRow: %v", err)
+	}
+
+	fee := -balance * rate
+	if fee < balanceEpsilon {
+		return 0, nil
+	}
+
+	sql, args, _ = r.Builder.
+		Insert("credit_fees").
+		Columns("wallet", "day", "balance", "rate", "amount").
+		Values(walletUUID, day, balance, rate, fee).
+		Suffix("ON CONFLICT DO NOTHING").
+		ToSql()
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, fmt.Errorf("CreditRepo.ChargeFee - tx.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, nil
+	}
+
+	sql, args, _ = r.Builder.
+		Update("wallets").
+		Set("balance", squirrel.Expr("balance - ?", fee)).
+		Where(squirrel.Eq{"uuid": walletUUID}).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return 0, fmt.Errorf("CreditRepo.ChargeFee - tx.Exec: %v", err)
+	}
+
+	sql, args, _ = r.Builder.
+		Update("wallets").
+		Set("balance", squirrel.Expr("balance + ?", fee)).
+		Where(squirrel.Eq{"uuid": feeWallet}).
+		ToSql()
+
+	tag, err = tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, fmt.Errorf("CreditRepo.ChargeFee - tx.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, repoerrors.ErrNotFound
+	}
+
+	sql, args, _ = r.Builder.
+		Insert("transactions").
+		Columns("sender", "receiver", "amount").
+		Values(walletUUID, feeWallet, fee).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return 0, fmt.Errorf("CreditRepo.ChargeFee - tx.Exec: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("CreditRepo.ChargeFee - tx.Commit: %v", err)
+	}
+
+	return fee, nil
+}
@@ -0,0 +1,475 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+)
+
+type DisputeRepo struct {
+	*postgres.Postgres
+}
+
+func NewDisputeRepo(pg *postgres.Postgres) *DisputeRepo {
+	return &DisputeRepo{pg}
+}
+
+var disputeColumns = []string{
+	"uuid", "transaction", "payer", "receiver", "opened_by", "amount", "held", "reason", "status",
+	"created_at", "resolved_at",
+}
+
+func scanDispute(row pgx.Row) (entity.Dispute, error) {
+	var dispute entity.Dispute
+	err := row.Scan(
+		&dispute.UUID,
+		&dispute.Transaction,
+		&dispute.Payer,
+		&dispute.Receiver,
+		&dispute.OpenedBy,
+		&dispute.Amount,
+		&dispute.Held,
+		&dispute.Reason,
+		&dispute.Status,
+		&dispute.CreatedAt,
+		&dispute.ResolvedAt,
+	)
+	return dispute, err
+}
+
+// OpenDispute registers the dispute and moves as much of the amount as the receiver has
+// into the holding wallet. Second dispute of a transaction returns repoerrors.ErrAlreadyExist.
+func (r *DisputeRepo) OpenDispute(ctx context.Context, dispute entity.Dispute, holdingWallet uuid.UUID) (uuid.UUID, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("DisputeRepo.OpenDispute - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Select("balance").
+		From("wallets").
+		Where(squirrel.Eq{"uuid": dispute.Receiver}).
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	var balance float64
+	if err = tx.QueryRow(ctx, sql, args...).Scan(&balance); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, repoerrors.ErrNotFound
+		}
+		return uuid.Nil, fmt.Errorf("DisputeRepo.OpenDispute - tx.QueryRow: %v", err)
+	}
+
+	held := max(min(dispute.Amount, balance), 0)
+	if held < balanceEpsilon {
+		held = 0
+	}
+
+	sql, args, _ = r.Builder.
+		Insert("disputes").
+		Columns("transaction", "payer", "receiver", "opened_by", "amount", "held", "reason", "status").
+		Values(
+			dispute.Transaction,
+			dispute.Payer,
+			dispute.Receiver,
+			dispute.OpenedBy,
+			dispute.Amount,
+			held,
+			dispute.Reason,
+			entity.DisputeStatusOpen,
+		).
+		Suffix("RETURNING uuid").
+		ToSql()
+
+	var disputeUUID uuid.UUID
+	if err = tx.QueryRow(ctx, sql, args...).Scan(&disputeUUID); err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23505" {
+				return uuid.Nil, repoerrors.ErrAlreadyExist
+			}
+		}
+		return uuid.Nil, fmt.Errorf("DisputeRepo.OpenDispute - tx.QueryRow: %v", err)
+	}
+
+	opened := entity.DisputeEvent{
+		Actor:  uuid.NullUUID{UUID: dispute.OpenedBy, Valid: true},
+		Kind:   entity.DisputeEventOpened,
+		Note:   dispute.Reason,
+		Amount: &dispute.Amount,
+	}
+	if err = r.addEvent(ctx, tx, disputeUUID, opened); err != nil {
+		return uuid.Nil, err
+	}
+
+	if held > 0 {
+		if err = transfer(ctx, tx, r.Builder, dispute.Receiver, holdingWallet, held); err != nil {
+			return uuid.Nil, err
+		}
+
+		if err = r.addEvent(ctx, tx, disputeUUID, entity.DisputeEvent{Kind: entity.DisputeEventHeld, Amount: &held}); err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("DisputeRepo.OpenDispute - tx.Commit: %v", err)
+	}
+
+	return disputeUUID, nil
+}
+
+func (r *DisputeRepo) GetDisputeById(ctx context.Context, disputeUUID uuid.UUID) (entity.Dispute, error) {
+	sql, args, _ := r.Builder.
+		Select(disputeColumns...).
+		From("disputes").
+		Where(squirrel.Eq{"uuid": disputeUUID}).
+		ToSql()
+
+	dispute, err := scanDispute(r.Pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Dispute{}, repoerrors.ErrNotFound
+		}
+		return entity.Dispute{}, fmt.Errorf("DisputeRepo.GetDisputeById - r.Pool.QueryRow: %v", err)
+	}
+
+	return dispute, nil
+}
+
+func (r *DisputeRepo) GetWalletDisputes(ctx context.Context, walletUUID uuid.UUID) ([]entity.Dispute, error) {
+	sql, args, _ := r.Builder.
+		Select(disputeColumns...).
+		From("disputes").
+		Where(squirrel.Or{
+			squirrel.Eq{"payer": walletUUID},
+			squirrel.Eq{"receiver": walletUUID},
+		}).
+		OrderBy("created_at DESC").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DisputeRepo.GetWalletDisputes - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var disputes []entity.Dispute
+	for rows.Next() {
+		dispute, err := scanDispute(rows)
+		if err != nil {
+			return nil, fmt.Errorf("DisputeRepo.GetWalletDisputes - rows.Scan: %v", err)
+		}
+		disputes = append(disputes, dispute)
+	}
+
+	return disputes, nil
+}
+
+// GetDisputes returns disputes in the status, empty status returns all of them
+func (r *DisputeRepo) GetDisputes(ctx context.Context, status string) ([]entity.Dispute, error) {
+	query := r.Builder.
+		Select(disputeColumns...).
+		From("disputes").
+		OrderBy("created_at")
+	if status != "" {
+		query = query.Where(squirrel.Eq{"status": status})
+	}
+	sql, args, _ := query.ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DisputeRepo.GetDisputes - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var disputes []entity.Dispute
+	for rows.Next() {
+		dispute, err := scanDispute(rows)
+		if err != nil {
+			return nil, fmt.Errorf("DisputeRepo.GetDisputes - rows.Scan: %v", err)
+		}
+		disputes = append(disputes, dispute)
+	}
+
+	return disputes, nil
+}
+
+func (r *DisputeRepo) GetDisputeEvents(ctx context.Context, disputeUUID uuid.UUID) ([]entity.DisputeEvent, error) {
+	sql, args, _ := r.Builder.
+		Select("uuid", "actor", "kind", "note", "amount", "created_at").
+		From("dispute_events").
+		Where(squirrel.Eq{"dispute": disputeUUID}).
+		OrderBy("created_at").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DisputeRepo.GetDisputeEvents - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var events []entity.DisputeEvent
+	for rows.Next() {
+		var event entity.DisputeEvent
+		if err = rows.Scan(&event.UUID, &event.Actor, &event.Kind, &event.Note, &event.Amount, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("DisputeRepo.GetDisputeEvents - rows.Scan: %v", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func (r *DisputeRepo) AddEvent(ctx context.Context, disputeUUID uuid.UUID, event entity.DisputeEvent) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("DisputeRepo.AddEvent - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err = r.addEvent(ctx, tx, disputeUUID, event); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("DisputeRepo.AddEvent - tx.Commit: %v", err)
+	}
+
+	return nil
+}
+
+// AddAttachment stores the file and records it in the timeline
+func (r *DisputeRepo) AddAttachment(ctx context.Context, attachment entity.Attachment) (uuid.UUID, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("DisputeRepo.AddAttachment - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Insert("dispute_attachments").
+		Columns("dispute", "uploaded_by", "name", "content_type", "data").
+		Values(attachment.Dispute, attachment.UploadedBy, attachment.Name, attachment.ContentType, attachment.Data).
+		Suffix("RETURNING uuid").
+		ToSql()
+
+	var attachmentUUID uuid.UUID
+	if err = tx.QueryRow(ctx, sql, args...).Scan(&attachmentUUID); err != nil {
+		return uuid.Nil, fmt.Errorf("DisputeRepo.AddAttachment - tx.QueryRow: %v", err)
+	}
+
+	event := entity.DisputeEvent{
+		Actor: uuid.NullUUID{UUID: attachment.UploadedBy, Valid: true},
+		Kind:  entity.DisputeEventAttachment,
+		Note:  attachment.Name,
+	}
+	if err = r.addEvent(ctx, tx, attachment.Dispute, event); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("DisputeRepo.AddAttachment - tx.Commit: %v", err)
+	}
+
+	return attachmentUUID, nil
+}
+
+// GetAttachments lists attachments of the dispute without their data
+func (r *DisputeRepo) GetAttachments(ctx context.Context, disputeUUID uuid.UUID) ([]entity.Attachment, error) {
+	sql, args, _ := r.Builder.
+		Select("uuid", "dispute", "uploaded_by", "name", "content_type", "length(data)", "created_at").
+		From("dispute_attachments").
+		Where(squirrel.Eq{"dispute": disputeUUID}).
+		OrderBy("created_at").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DisputeRepo.GetAttachments - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var attachments []entity.Attachment
+	for rows.Next() {
+		var attachment entity.Attachment
+		err = rows.Scan(
+			&attachment.UUID,
+			&attachment.Dispute,
+			&attachment.UploadedBy,
+			&attachment.Name,
+			&attachment.ContentType,
+			&attachment.Size,
+			&attachment.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("DisputeRepo.GetAttachments - rows.Scan: %v", err)
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, nil
+}
+
+func (r *DisputeRepo) GetAttachment(ctx context.Context, disputeUUID, attachmentUUID uuid.UUID) (entity.Attachment, error) {
+	sql, args, _ := r.Builder.
+		Select("uuid", "dispute", "uploaded_by", "name", "content_type", "data", "created_at").
+		From("dispute_attachments").
+		Where(squirrel.Eq{
+			"uuid":    attachmentUUID,
+			"dispute": disputeUUID,
+		}).
+		ToSql()
+
+	var attachment entity.Attachment
+	err := r.Pool.QueryRow(ctx, sql, args...).Scan(
+		&attachment.UUID,
+		&attachment.Dispute,
+		&attachment.UploadedBy,
+		&attachment.Name,
+		&attachment.ContentType,
+		&attachment.Data,
+		&attachment.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Attachment{}, repoerrors.ErrNotFound
+		}
+		return entity.Attachment{}, fmt.Errorf("DisputeRepo.GetAttachment - r.Pool.QueryRow: %v", err)
+	}
+	attachment.Size = len(attachment.Data)
+
+	return attachment, nil
+}
+
+// ResolveDispute settles an open dispute. On reversal the held part returns to the payer from the holding wallet
+// and the rest is charged back from the receiver, which may leave it negative. On release the held part
+// returns to the receiver. A dispute that is not open returns repoerrors.ErrNotFound.
+func (r *DisputeRepo) ResolveDispute(
+	ctx context.Context,
+	disputeUUID uuid.UUID,
+	event entity.DisputeEvent,
+	holdingWallet uuid.UUID,
+) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("DisputeRepo.ResolveDispute - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Select(disputeColumns...).
+		From("disputes").
+		Where(squirrel.Eq{
+			"uuid":   disputeUUID,
+			"status": entity.DisputeStatusOpen,
+		}).
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	dispute, err := scanDispute(tx.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return repoerrors.ErrNotFound
+		}
+		return fmt.Errorf("DisputeRepo.ResolveDispute - tx.QueryRow: %v", err)
+	}
+
+	status, amount := entity.DisputeStatusReleased, dispute.Held
+	receiver := dispute.Receiver
+	if event.Kind == entity.DisputeEventReversed {
+		status, amount = entity.DisputeStatusReversed, dispute.Amount
+		receiver = dispute.Payer
+	}
+
+	if dispute.Held > 0 {
+		if err = transfer(ctx, tx, r.Builder, holdingWallet, receiver, dispute.Held); err != nil {
+			return err
+		}
+	}
+
+	if rest := dispute.Amount - dispute.Held; status == entity.DisputeStatusReversed && rest >= balanceEpsilon {
+		if err = r.chargeBack(ctx, tx, dispute.Receiver, dispute.Payer, rest); err != nil {
+			return err
+		}
+	}
+
+	sql, args, _ = r.Builder.
+		Update("disputes").
+		Set("status", status).
+		Set("resolved_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"uuid": disputeUUID}).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("DisputeRepo.ResolveDispute - tx.Exec: %v", err)
+	}
+
+	event.Amount = &amount
+	if err = r.addEvent(ctx, tx, disputeUUID, event); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("DisputeRepo.ResolveDispute - tx.Commit: %v", err)
+	}
+
+	return nil
+}
+
+// chargeBack moves amount from the receiver back to the payer without the balance check
+func (r *DisputeRepo) chargeBack(ctx context.Context, tx pgx.Tx, receiver, payer uuid.UUID, amount float64) error {
+	sql, args, _ := r.Builder.
+		Update("wallets").
+		Set("balance", squirrel.Expr("balance - ?", amount)).
+		Where(squirrel.Eq{"uuid": receiver}).
+		ToSql()
+
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("DisputeRepo.chargeBack - tx.Exec: %v", err)
+	}
+
+	sql, args, _ = r.Builder.
+		Update("wallets").
+		Set("balance", squirrel.Expr("balance + ?", amount)).
+		Where(squirrel.Eq{"uuid": payer}).
+		ToSql()
+
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("DisputeRepo.chargeBack - tx.Exec: %v", err)
+	}
+
+	sql, args, _ = r.Builder.
+		Insert("transactions").
+		Columns("sender", "receiver", "amount").
+		Values(receiver, payer, amount).
+		ToSql()
+
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("DisputeRepo.chargeBack - tx.Exec: %v", err)
+	}
+
+	return nil
+}
+
+func (r *DisputeRepo) addEvent(ctx context.Context, tx pgx.Tx, disputeUUID uuid.UUID, event entity.DisputeEvent) error {
+	sql, args, _ := r.Builder.
+		Insert("dispute_events").
+		Columns("dispute", "actor", "kind", "note", "amount").
+		Values(disputeUUID, event.Actor, event.Kind, event.Note, event.Amount).
+		ToSql()
+
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("DisputeRepo.addEvent - tx.Exec: %v", err)
+	}
+
+	return nil
+}
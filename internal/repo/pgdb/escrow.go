@@ -0,0 +1,225 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+	"time"
+)
+
+type EscrowRepo struct {
+	*postgres.Postgres
+}
+
+func NewEscrowRepo(pg *postgres.Postgres) *EscrowRepo {
+	return &EscrowRepo{pg}
+}
+
+var escrowColumns = []string{
+	"uuid", "buyer", "seller", "amount", "status", "created_by", "created_at", "expires_at", "resolved_at",
+}
+
+func scanEscrow(row pgx.Row) (entity.Escrow, error) {
+	var escrow entity.Escrow
+	err := row.Scan(
+		&escrow.UUID,
+		&escrow.Buyer,
+		&escrow.Seller,
+		&escrow.Amount,
+		&escrow.Status,
+		&escrow.CreatedBy,
+		&escrow.CreatedAt,
+		&escrow.ExpiresAt,
+		&escrow.ResolvedAt,
+	)
+	return escrow, err
+}
+
+// CreateEscrow debits the buyer wallet into the holding wallet and registers the escrow in one transaction
+func (r *EscrowRepo) CreateEscrow(ctx context.Context, escrow entity.Escrow, holdingWallet uuid.UUID) (uuid.UUID, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("EscrowRepo.CreateEscrow - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// the hold counts against the spend limit of a child buyer as a payment to the seller
+	if err = checkChildLimit(ctx, tx, r.Builder, escrow.Buyer, escrow.Seller, escrow.Amount); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err = transfer(ctx, tx, r.Builder, escrow.Buyer, holdingWallet, escrow.Amount); err != nil {
+		return uuid.Nil, err
+	}
+
+	sql, args, _ := r.Builder.
+		Insert("escrows").
+		Columns("buyer", "seller", "amount", "status", "created_by", "expires_at").
+		Values(escrow.Buyer, escrow.Seller, escrow.Amount, entity.EscrowStatusHeld, escrow.CreatedBy, escrow.ExpiresAt).
+		Suffix("RETURNING uuid").
+		ToSql()
+
+	var escrowUUID uuid.UUID
+	if err = tx.QueryRow(ctx, sql, args...).Scan(&escrowUUID); err != nil {
+		return uuid.Nil, fmt.Errorf("EscrowRepo.CreateEscrow - tx.QueryRow: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("EscrowRepo.CreateEscrow - tx.Commit: %v", err)
+	}
+
+	return escrowUUID, nil
+}
+
+func (r *EscrowRepo) GetEscrowById(ctx context.Context, uuid uuid.UUID) (entity.Escrow, error) {
+	sql, args, _ := r.Builder.
+		Select(escrowColumns...).
+		From("escrows").
+		Where(squirrel.Eq{"uuid": uuid}).
+		ToSql()
+
+	escrow, err := scanEscrow(r.Pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Escrow{}, repoerrors.ErrNotFound
+		}
+		return entity.Escrow{}, fmt.Errorf("EscrowRepo.GetEscrowById - r.Pool.QueryRow: %v", err)
+	}
+
+	return escrow, nil
+}
+
+func (r *EscrowRepo) GetWalletEscrows(ctx context.Context, walletUUID uuid.UUID) ([]entity.Escrow, error) {
+	sql, args, _ := r.Builder.
+		Select(escrowColumns...).
+		From("escrows").
+		Where(squirrel.Or{
+			squirrel.Eq{"buyer": walletUUID},
+			squirrel.Eq{"seller": walletUUID},
+		}).
+		OrderBy("created_at DESC").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("EscrowRepo.GetWalletEscrows - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var escrows []entity.Escrow
+	for rows.Next() {
+		escrow, err := scanEscrow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("EscrowRepo.GetWalletEscrows - rows.Scan: %v", err)
+		}
+		escrows = append(escrows, escrow)
+	}
+
+	return escrows, nil
+}
+
+// GetWalletEscrowTotals returns amounts held from the wallet as a buyer and awaiting the wallet as a seller
+func (r *EscrowRepo) GetWalletEscrowTotals(ctx context.Context, walletUUID uuid.UUID) (held, incoming float64, err error) {
+	sql, args, _ := r.Builder.
+		Select().
+		Column(squirrel.Expr("coalesce(sum(amount) filter (where buyer = ?), 0)", walletUUID)).
+		Column(squirrel.Expr("coalesce(sum(amount) filter (where seller = ?), 0)", walletUUID)).
+		From("escrows").
+		Where(squirrel.Eq{"status": entity.EscrowStatusHeld}).
+		ToSql()
+
+	err = r.Pool.QueryRow(ctx, sql, args...).Scan(&held, &incoming)
+	if err != nil {
+		return 0, 0, fmt.Errorf("EscrowRepo.GetWalletEscrowTotals - r.Pool.QueryRow: %v", err)
+	}
+
+	return held, incoming, nil
+}
+
+func (r *EscrowRepo) GetExpiredEscrows(ctx context.Context, now time.Time) ([]uuid.UUID, error) {
+	sql, args, _ := r.Builder.
+		Select("uuid").
+		From("escrows").
+		Where(squirrel.And{
+			squirrel.Eq{"status": entity.EscrowStatusHeld},
+			squirrel.LtOrEq{"expires_at": now},
+		}).
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("EscrowRepo.GetExpiredEscrows - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var escrows []uuid.UUID
+	for rows.Next() {
+		var escrowUUID uuid.UUID
+		if err = rows.Scan(&escrowUUID); err != nil {
+			return nil, fmt.Errorf("EscrowRepo.GetExpiredEscrows - rows.Scan: %v", err)
+		}
+		escrows = append(escrows, escrowUUID)
+	}
+
+	return escrows, nil
+}
+
+// SettleEscrow moves held funds out of the holding wallet: to the seller on release, back to the buyer on refund.
+// Escrow row is locked, so an escrow that is not held anymore returns repoerrors.ErrNotFound.
+func (r *EscrowRepo) SettleEscrow(ctx context.Context, escrowUUID, holdingWallet uuid.UUID, status string) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("EscrowRepo.SettleEscrow - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Select(escrowColumns...).
+		From("escrows").
+		Where(squirrel.And{
+			squirrel.Eq{"uuid": escrowUUID},
+			squirrel.Eq{"status": entity.EscrowStatusHeld},
+		}).
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	escrow, err := scanEscrow(tx.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return repoerrors.ErrNotFound
+		}
+		return fmt.Errorf("EscrowRepo.SettleEscrow - tx.QueryRow: %v", err)
+	}
+
+	receiver := escrow.Seller
+	if status == entity.EscrowStatusRefunded {
+		receiver = escrow.Buyer
+	}
+
+	if err = transfer(ctx, tx, r.Builder, holdingWallet, receiver, escrow.Amount); err != nil {
+		return err
+	}
+
+	sql, args, _ = r.Builder.
+		Update("escrows").
+		Set("status", status).
+		Set("resolved_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"uuid": escrowUUID}).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("EscrowRepo.SettleEscrow - tx.Exec: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("EscrowRepo.SettleEscrow - tx.Commit: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,312 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+	"time"
+)
+
+type FamilyRepo struct {
+	*postgres.Postgres
+}
+
+func NewFamilyRepo(pg *postgres.Postgres) *FamilyRepo {
+	return &FamilyRepo{pg}
+}
+
+var allowanceColumns = []string{
+	"uuid", "parent", "child", "amount", "period", "next_run", "created_at",
+}
+
+func scanAllowance(row pgx.Row) (entity.Allowance, error) {
+	var allowance entity.Allowance
+	err := row.Scan(
+		&allowance.UUID,
+		&allowance.Parent,
+		&allowance.Child,
+		&allowance.Amount,
+		&allowance.Period,
+		&allowance.NextRun,
+		&allowance.CreatedAt,
+	)
+	return allowance, err
+}
+
+func (r *FamilyRepo) SetParent(ctx context.Context, child, parent uuid.UUID) error {
+	sql, args, _ := r.Builder.
+		Update("wallets").
+		Set("parent", parent).
+		Where(squirrel.Eq{"uuid": child}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("FamilyRepo.SetParent - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *FamilyRepo) GetChildren(ctx context.Context, parent uuid.UUID) ([]entity.Wallet, error) {
+	sql, args, _ := r.Builder.
+		Select("uuid", "balance", "parent").
+		From("wallets").
+		Where(squirrel.Eq{"parent": parent}).
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("FamilyRepo.GetChildren - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var children []entity.Wallet
+	for rows.Next() {
+		var wallet entity.Wallet
+		if err = rows.Scan(&wallet.UUID, &wallet.Balance, &wallet.Parent); err != nil {
+			return nil, fmt.Errorf("FamilyRepo.GetChildren - rows.Scan: %v", err)
+		}
+		children = append(children, wallet)
+	}
+
+	return children, nil
+}
+
+// SetChildPolicy creates or replaces the child wallet policy together with allowed counterparties
+func (r *FamilyRepo) SetChildPolicy(ctx context.Context, policy entity.ChildPolicy) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("FamilyRepo.SetChildPolicy - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Insert("child_wallet_policies").
+		Columns("wallet", "spend_limit", "limit_period").
+		Values(policy.Wallet, policy.SpendLimit, policy.LimitPeriod).
+		Suffix("ON CONFLICT (wallet) DO UPDATE SET " +
+			"spend_limit = excluded.spend_limit, " +
+			"limit_period = excluded.limit_period").
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("FamilyRepo.SetChildPolicy - tx.Exec: %v", err)
+	}
+
+	sql, args, _ = r.Builder.
+		Delete("child_wallet_counterparties").
+		Where(squirrel.Eq{"wallet": policy.Wallet}).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("FamilyRepo.SetChildPolicy - tx.Exec: %v", err)
+	}
+
+	if len(policy.Counterparties) > 0 {
+		insert := r.Builder.
+			Insert("child_wallet_counterparties").
+			Columns("wallet", "counterparty")
+		for _, counterparty := range policy.Counterparties {
+			insert = insert.Values(policy.Wallet, counterparty)
+		}
+
+		sql, args, _ = insert.ToSql()
+		if _, err = tx.Exec(ctx, sql, args...); err != nil {
+			var pgErr *pgconn.PgError
+			if ok := errors.As(err, &pgErr); ok {
+				if pgErr.Code == "23503" {
+					return repoerrors.ErrNotFound
+				}
+			}
+			return fmt.Errorf("FamilyRepo.SetChildPolicy - tx.Exec: %v", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("FamilyRepo.SetChildPolicy - tx.Commit: %v", err)
+	}
+
+	return nil
+}
+
+func (r *FamilyRepo) GetChildPolicy(ctx context.Context, child uuid.UUID) (entity.ChildPolicy, error) {
+	sql, args, _ := r.Builder.
+		Select("wallet", "spend_limit", "limit_period").
+		From("child_wallet_policies").
+		Where(squirrel.Eq{"wallet": child}).
+		ToSql()
+
+	var policy entity.ChildPolicy
+	err := r.Pool.QueryRow(ctx, sql, args...).Scan(&policy.Wallet, &policy.SpendLimit, &policy.LimitPeriod)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.ChildPolicy{}, repoerrors.ErrNotFound
+		}
+		return entity.ChildPolicy{}, fmt.Errorf("FamilyRepo.GetChildPolicy - r.Pool.QueryRow: %v", err)
+	}
+
+	sql, args, _ = r.Builder.
+		Select("counterparty").
+		From("child_wallet_counterparties").
+		Where(squirrel.Eq{"wallet": child}).
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return entity.ChildPolicy{}, fmt.Errorf("FamilyRepo.GetChildPolicy - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var counterparty uuid.UUID
+		if err = rows.Scan(&counterparty); err != nil {
+			return entity.ChildPolicy{}, fmt.Errorf("FamilyRepo.GetChildPolicy - rows.Scan: %v", err)
+		}
+		policy.Counterparties = append(policy.Counterparties, counterparty)
+	}
+
+	return policy, nil
+}
+
+func (r *FamilyRepo) CreateAllowance(ctx context.Context, allowance entity.Allowance) (uuid.UUID, error) {
+	sql, args, _ := r.Builder.
+		Insert("allowances").
+		Columns("parent", "child", "amount", "period", "next_run").
+		Values(allowance.Parent, allowance.Child, allowance.Amount, allowance.Period, allowance.NextRun).
+		Suffix("RETURNING uuid").
+		ToSql()
+
+	var allowanceUUID uuid.UUID
+	if err := r.Pool.QueryRow(ctx, sql, args...).Scan(&allowanceUUID); err != nil {
+		return uuid.Nil, fmt.Errorf("FamilyRepo.CreateAllowance - r.Pool.QueryRow: %v", err)
+	}
+
+	return allowanceUUID, nil
+}
+
+func (r *FamilyRepo) GetAllowances(ctx context.Context, child uuid.UUID) ([]entity.Allowance, error) {
+	sql, args, _ := r.Builder.
+		Select(allowanceColumns...).
+		From("allowances").
+		Where(squirrel.Eq{"child": child}).
+		OrderBy("created_at").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("FamilyRepo.GetAllowances - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var allowances []entity.Allowance
+	for rows.Next() {
+		allowance, err := scanAllowance(rows)
+		if err != nil {
+			return nil, fmt.Errorf("FamilyRepo.GetAllowances - rows.Scan: %v", err)
+		}
+		allowances = append(allowances, allowance)
+	}
+
+	return allowances, nil
+}
+
+func (r *FamilyRepo) DeleteAllowance(ctx context.Context, allowanceUUID, child uuid.UUID) error {
+	sql, args, _ := r.Builder.
+		Delete("allowances").
+		Where(squirrel.Eq{"uuid": allowanceUUID, "child": child}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("FamilyRepo.DeleteAllowance - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *FamilyRepo) GetDueAllowances(ctx context.Context, now time.Time) ([]uuid.UUID, error) {
+	sql, args, _ := r.Builder.
+		Select("uuid").
+		From("allowances").
+		Where(squirrel.LtOrEq{"next_run": now}).
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("FamilyRepo.GetDueAllowances - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var allowances []uuid.UUID
+	for rows.Next() {
+		var allowanceUUID uuid.UUID
+		if err = rows.Scan(&allowanceUUID); err != nil {
+			return nil, fmt.Errorf("FamilyRepo.GetDueAllowances - rows.Scan: %v", err)
+		}
+		allowances = append(allowances, allowanceUUID)
+	}
+
+	return allowances, nil
+}
+
+// PayAllowance tops up the child wallet and schedules the next run in one transaction,
+// so a payment is never repeated. When the parent lacks funds the run is skipped
+// and repoerrors.ErrInsufficientFunds is returned.
+func (r *FamilyRepo) PayAllowance(ctx context.Context, allowanceUUID uuid.UUID) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("FamilyRepo.PayAllowance - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Select(allowanceColumns...).
+		From("allowances").
+		Where(squirrel.Eq{"uuid": allowanceUUID}).
+		Where(squirrel.Expr("next_run <= now()")).
+		Suffix("FOR UPDATE SKIP LOCKED").
+		ToSql()
+
+	allowance, err := scanAllowance(tx.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return repoerrors.ErrNotFound
+		}
+		return fmt.Errorf("FamilyRepo.PayAllowance - tx.QueryRow: %v", err)
+	}
+
+	payErr := transfer(ctx, tx, r.Builder, allowance.Parent, allowance.Child, allowance.Amount)
+	if payErr != nil && !errors.Is(payErr, repoerrors.ErrInsufficientFunds) {
+		return payErr
+	}
+
+	sql, args, _ = r.Builder.
+		Update("allowances").
+		Set("next_run", squirrel.Expr("next_run + ?::interval", "1 "+allowance.Period)).
+		Where(squirrel.Eq{"uuid": allowanceUUID}).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("FamilyRepo.PayAllowance - tx.Exec: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("FamilyRepo.PayAllowance - tx.Commit: %v", err)
+	}
+
+	return payErr
+}
@@ -0,0 +1,336 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+	"time"
+)
+
+type InterestRepo struct {
+	*postgres.Postgres
+}
+
+func NewInterestRepo(pg *postgres.Postgres) *InterestRepo {
+	return &InterestRepo{pg}
+}
+
+var interestProductColumns = []string{"uuid", "name", "apr", "day_count", "min_balance", "active", "created_at"}
+
+func scanInterestProduct(row pgx.Row) (entity.InterestProduct, error) {
+	var product entity.InterestProduct
+	err := row.Scan(
+		&product.UUID,
+		&product.Name,
+		&product.APR,
+		&product.DayCount,
+		&product.MinBalance,
+		&product.Active,
+		&product.CreatedAt,
+	)
+	return product, err
+}
+
+func (r *InterestRepo) CreateProduct(ctx context.Context, product entity.InterestProduct) (uuid.UUID, error) {
+	sql, args, _ := r.Builder.
+		Insert("interest_products").
+		Columns("name", "apr", "day_count", "min_balance", "active").
+		Values(product.Name, product.APR, product.DayCount, product.MinBalance, product.Active).
+		Suffix("RETURNING uuid").
+		ToSql()
+
+	var productUUID uuid.UUID
+	if err := r.Pool.QueryRow(ctx, sql, args...).Scan(&productUUID); err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23505" {
+				return uuid.Nil, repoerrors.ErrAlreadyExist
+			}
+		}
+		return uuid.Nil, fmt.Errorf("InterestRepo.CreateProduct - r.Pool.QueryRow: %v", err)
+	}
+
+	return productUUID, nil
+}
+
+func (r *InterestRepo) GetProducts(ctx context.Context) ([]entity.InterestProduct, error) {
+	sql, args, _ := r.Builder.
+		Select(interestProductColumns...).
+		From("interest_products").
+		OrderBy("created_at").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("InterestRepo.GetProducts - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var products []entity.InterestProduct
+	for rows.Next() {
+		product, err := scanInterestProduct(rows)
+		if err != nil {
+			return nil, fmt.Errorf("InterestRepo.GetProducts - rows.Scan: %v", err)
+		}
+		products = append(products, product)
+	}
+
+	return products, nil
+}
+
+func (r *InterestRepo) UpdateProduct(ctx context.Context, product entity.InterestProduct) error {
+	sql, args, _ := r.Builder.
+		Update("interest_products").
+		Set("name", product.Name).
+		Set("apr", product.APR).
+		Set("day_count", product.DayCount).
+		Set("min_balance", product.MinBalance).
+		Set("active", product.Active).
+		Where(squirrel.Eq{"uuid": product.UUID}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23505" {
+				return repoerrors.ErrAlreadyExist
+			}
+		}
+		return fmt.Errorf("InterestRepo.UpdateProduct - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// AssignProduct sets the interest product of the wallet, an invalid product removes it
+func (r *InterestRepo) AssignProduct(ctx context.Context, walletUUID uuid.UUID, product uuid.NullUUID) error {
+	sql, args, _ := r.Builder.
+		Update("wallets").
+		Set("interest_product", product).
+		Where(squirrel.Eq{"uuid": walletUUID}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23503" {
+				return repoerrors.ErrNotFound
+			}
+		}
+		return fmt.Errorf("InterestRepo.AssignProduct - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// AccrueInterest records the interest of the day for every wallet with an active product in one transaction.
+// Days the job missed since its last run are accrued too; the balance of those days is not kept,
+// so they are accrued on the balance the wallet has now. Days already accrued are skipped,
+// so it returns the number of new accruals.
+func (r *InterestRepo) AccrueInterest(ctx context.Context, day time.Time) (int64, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("InterestRepo.AccrueInterest - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// concurrent runs wait here, so missed days are accrued once
+	if _, err = tx.Exec(ctx, "LOCK TABLE interest_runs IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return 0, fmt.Errorf("InterestRepo.AccrueInterest - tx.Exec: %v", err)
+	}
+
+	sql, args, _ := r.Builder.
+		Select().
+		Column("coalesce(max(day) + 1, ?::date)", day).
+		From("interest_runs").
+		ToSql()
+
+	var from time.Time
+	if err = tx.QueryRow(ctx, sql, args...).Scan(&from); err != nil {
+		return 0, fmt.Errorf("InterestRepo.AccrueInterest - tx.QueryRow: %v", err)
+	}
+	if from.After(day) {
+		from = day
+	}
+
+	accruals := r.Builder.
+		Select().
+		Column("w.uuid").
+		Column("d.day::date").
+		Column("p.uuid").
+		Column("w.balance").
+		Column("p.apr").
+		Column("w.balance * p.apr / 100 / p.day_count").
+		From("wallets w").
+		Join("interest_products p ON p.uuid = w.interest_product").
+		Join("generate_series(?::date, ?::date, interval '1 day') AS d(day) ON true", from, day).
+		Where(squirrel.And{
+			squirrel.Eq{"p.active": true},
+			squirrel.Gt{"w.balance": 0},
+			squirrel.Expr("w.balance >= p.min_balance"),
+		})
+
+	sql, args, _ = r.Builder.
+		Insert("interest_accruals").
+		Columns("wallet", "day", "product", "balance", "apr", "amount").
+		Select(accruals).
+		Suffix("ON CONFLICT DO NOTHING").
+		ToSql()
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, fmt.Errorf("InterestRepo.AccrueInterest - tx.Exec: %v", err)
+	}
+
+	sql, args, _ = r.Builder.
+		Insert("interest_runs").
+		Columns("day").
+		Values(day).
+		Suffix("ON CONFLICT DO NOTHING").
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return 0, fmt.Errorf("InterestRepo.AccrueInterest - tx.Exec: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("InterestRepo.AccrueInterest - tx.Commit: %v", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// GetUnpaidWallets returns wallets with unpaid interest accrued before the day
+func (r *InterestRepo) GetUnpaidWallets(ctx context.Context, before time.Time) ([]uuid.UUID, error) {
+	sql, args, _ := r.Builder.
+		Select("distinct wallet").
+		From("interest_accruals").
+		Where(squirrel.And{
+			squirrel.Lt{"day": before},
+			squirrel.Eq{"paid_at": nil},
+		}).
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("InterestRepo.GetUnpaidWallets - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var wallets []uuid.UUID
+	for rows.Next() {
+		var walletUUID uuid.UUID
+		if err = rows.Scan(&walletUUID); err != nil {
+			return nil, fmt.Errorf("InterestRepo.GetUnpaidWallets - rows.Scan: %v", err)
+		}
+		wallets = append(wallets, walletUUID)
+	}
+
+	return wallets, nil
+}
+
+// PayInterest pays the interest accrued before the day from the expense wallet as one ledger transaction.
+// Accruals are marked paid in the same transaction, so a repeated call pays nothing and returns zero.
+// A closed wallet returns repoerrors.ErrNotFound and its accruals stay unpaid.
+func (r *InterestRepo) PayInterest(ctx context.Context, walletUUID, expenseWallet uuid.UUID, before time.Time) (float64, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("InterestRepo.PayInterest - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Update("interest_accruals").
+		Set("paid_at", squirrel.Expr("now()")).
+		Where(squirrel.And{
+			squirrel.Eq{"wallet": walletUUID},
+			squirrel.Lt{"day": before},
+			squirrel.Eq{"paid_at": nil},
+		}).
+		Suffix("RETURNING amount").
+		ToSql()
+
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return 0, fmt.Errorf("InterestRepo.PayInterest - tx.Query: %v", err)
+	}
+
+	var interest float64
+	for rows.Next() {
+		var amount float64
+		if err = rows.Scan(&amount); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("InterestRepo.PayInterest - rows.Scan: %v", err)
+		}
+		interest += amount
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("InterestRepo.PayInterest - rows.Err: %v", err)
+	}
+
+	if interest >= balanceEpsilon {
+		if err = systemTransfer(ctx, tx, r.Builder, expenseWallet, walletUUID, interest); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("InterestRepo.PayInterest - tx.Commit: %v", err)
+	}
+
+	return interest, nil
+}
+
+func (r *InterestRepo) GetSummary(ctx context.Context, walletUUID uuid.UUID) (entity.InterestSummary, error) {
+	sql, args, _ := r.Builder.
+		Select().
+		Column("coalesce(sum(amount) filter (where paid_at is null), 0)").
+		Column("coalesce(sum(amount) filter (where paid_at is not null), 0)").
+		From("interest_accruals").
+		Where(squirrel.Eq{"wallet": walletUUID}).
+		ToSql()
+
+	var summary entity.InterestSummary
+	if err := r.Pool.QueryRow(ctx, sql, args...).Scan(&summary.Accrued, &summary.Paid); err != nil {
+		return entity.InterestSummary{}, fmt.Errorf("InterestRepo.GetSummary - r.Pool.QueryRow: %v", err)
+	}
+
+	columns := make([]string, 0, len(interestProductColumns))
+	for _, column := range interestProductColumns {
+		columns = append(columns, "p."+column)
+	}
+
+	sql, args, _ = r.Builder.
+		Select(columns...).
+		From("wallets w").
+		Join("interest_products p ON p.uuid = w.interest_product").
+		Where(squirrel.Eq{"w.uuid": walletUUID}).
+		ToSql()
+
+	product, err := scanInterestProduct(r.Pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return summary, nil
+		}
+		return entity.InterestSummary{}, fmt.Errorf("InterestRepo.GetSummary - r.Pool.QueryRow: %v", err)
+	}
+	summary.Product = &product
+
+	return summary, nil
+}
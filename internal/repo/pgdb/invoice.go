@@ -0,0 +1,252 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+	"time"
+)
+
+type InvoiceRepo struct {
+	*postgres.Postgres
+}
+
+func NewInvoiceRepo(pg *postgres.Postgres) *InvoiceRepo {
+	return &InvoiceRepo{pg}
+}
+
+var invoiceColumns = []string{
+	"uuid", "merchant", "wallet", "amount", "paid", "currency", "description",
+	"allow_partial", "status", "expires_at", "created_at", "paid_at",
+}
+
+func scanInvoice(row pgx.Row) (entity.Invoice, error) {
+	var invoice entity.Invoice
+	err := row.Scan(
+		&invoice.UUID,
+		&invoice.Merchant,
+		&invoice.Wallet,
+		&invoice.Amount,
+		&invoice.Paid,
+		&invoice.Currency,
+		&invoice.Description,
+		&invoice.AllowPartial,
+		&invoice.Status,
+		&invoice.ExpiresAt,
+		&invoice.CreatedAt,
+		&invoice.PaidAt,
+	)
+	return invoice, err
+}
+
+// CreateInvoice stores the invoice with its line items in one transaction
+func (r *InvoiceRepo) CreateInvoice(ctx context.Context, invoice entity.Invoice) (uuid.UUID, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("InvoiceRepo.CreateInvoice - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Insert("invoices").
+		Columns("merchant", "wallet", "amount", "currency", "description", "allow_partial", "status", "expires_at").
+		Values(
+			invoice.Merchant,
+			invoice.Wallet,
+			invoice.Amount,
+			invoice.Currency,
+			invoice.Description,
+			invoice.AllowPartial,
+			entity.InvoiceStatusOpen,
+			invoice.ExpiresAt,
+		).
+		Suffix("RETURNING uuid").
+		ToSql()
+
+	var invoiceUUID uuid.UUID
+	if err = tx.QueryRow(ctx, sql, args...).Scan(&invoiceUUID); err != nil {
+		return uuid.Nil, fmt.Errorf("InvoiceRepo.CreateInvoice - tx.QueryRow: %v", err)
+	}
+
+	if len(invoice.Items) > 0 {
+		insert := r.Builder.
+			Insert("invoice_items").
+			Columns("invoice", "position", "name", "quantity", "price")
+		for i, item := range invoice.Items {
+			insert = insert.Values(invoiceUUID, i+1, item.Name, item.Quantity, item.Price)
+		}
+
+		sql, args, _ = insert.ToSql()
+		if _, err = tx.Exec(ctx, sql, args...); err != nil {
+			return uuid.Nil, fmt.Errorf("InvoiceRepo.CreateInvoice - tx.Exec: %v", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("InvoiceRepo.CreateInvoice - tx.Commit: %v", err)
+	}
+
+	return invoiceUUID, nil
+}
+
+// GetInvoiceById returns the invoice with its line items
+func (r *InvoiceRepo) GetInvoiceById(ctx context.Context, invoiceUUID uuid.UUID) (entity.Invoice, error) {
+	sql, args, _ := r.Builder.
+		Select(invoiceColumns...).
+		From("invoices").
+		Where(squirrel.Eq{"uuid": invoiceUUID}).
+		ToSql()
+
+	invoice, err := scanInvoice(r.Pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Invoice{}, repoerrors.ErrNotFound
+		}
+		return entity.Invoice{}, fmt.Errorf("InvoiceRepo.GetInvoiceById - r.Pool.QueryRow: %v", err)
+	}
+
+	sql, args, _ = r.Builder.
+		Select("name", "quantity", "price").
+		From("invoice_items").
+		Where(squirrel.Eq{"invoice": invoiceUUID}).
+		OrderBy("position").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return entity.Invoice{}, fmt.Errorf("InvoiceRepo.GetInvoiceById - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item entity.InvoiceItem
+		if err = rows.Scan(&item.Name, &item.Quantity, &item.Price); err != nil {
+			return entity.Invoice{}, fmt.Errorf("InvoiceRepo.GetInvoiceById - rows.Scan: %v", err)
+		}
+		invoice.Items = append(invoice.Items, item)
+	}
+
+	return invoice, nil
+}
+
+// GetMerchantInvoices returns merchant invoices without line items, newest first
+func (r *InvoiceRepo) GetMerchantInvoices(ctx context.Context, merchantUUID uuid.UUID) ([]entity.Invoice, error) {
+	sql, args, _ := r.Builder.
+		Select(invoiceColumns...).
+		From("invoices").
+		Where(squirrel.Eq{"merchant": merchantUUID}).
+		OrderBy("created_at DESC").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("InvoiceRepo.GetMerchantInvoices - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var invoices []entity.Invoice
+	for rows.Next() {
+		invoice, err := scanInvoice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("InvoiceRepo.GetMerchantInvoices - rows.Scan: %v", err)
+		}
+		invoices = append(invoices, invoice)
+	}
+
+	return invoices, nil
+}
+
+// CancelInvoice cancels an open invoice nobody has paid yet, otherwise returns repoerrors.ErrNotFound
+func (r *InvoiceRepo) CancelInvoice(ctx context.Context, invoiceUUID uuid.UUID) error {
+	sql, args, _ := r.Builder.
+		Update("invoices").
+		Set("status", entity.InvoiceStatusCancelled).
+		Where(squirrel.Eq{"uuid": invoiceUUID, "status": entity.InvoiceStatusOpen}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("InvoiceRepo.CancelInvoice - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// PayInvoice transfers amount from the payer wallet to the invoice wallet and records the payment.
+// Invoice row is locked, so an invoice that can not take the amount anymore returns repoerrors.ErrNotFound.
+func (r *InvoiceRepo) PayInvoice(ctx context.Context, invoiceUUID, payer uuid.UUID, amount float64) (entity.Invoice, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return entity.Invoice{}, fmt.Errorf("InvoiceRepo.PayInvoice - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Select(invoiceColumns...).
+		From("invoices").
+		Where(squirrel.Eq{
+			"uuid":   invoiceUUID,
+			"status": []string{entity.InvoiceStatusOpen, entity.InvoiceStatusPartiallyPaid},
+		}).
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	invoice, err := scanInvoice(tx.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Invoice{}, repoerrors.ErrNotFound
+		}
+		return entity.Invoice{}, fmt.Errorf("InvoiceRepo.PayInvoice - tx.QueryRow: %v", err)
+	}
+
+	now := time.Now()
+	remaining := invoice.Amount - invoice.Paid
+	if invoice.ExpiresAt != nil && !invoice.ExpiresAt.After(now) ||
+		amount > remaining+balanceEpsilon ||
+		!invoice.AllowPartial && amount < remaining-balanceEpsilon {
+		return entity.Invoice{}, repoerrors.ErrNotFound
+	}
+
+	if err = checkChildLimit(ctx, tx, r.Builder, payer, invoice.Wallet, amount); err != nil {
+		return entity.Invoice{}, err
+	}
+
+	_, err = invoiceTransfer(ctx, tx, r.Builder, payer, invoice.Wallet, amount, uuid.NullUUID{UUID: invoiceUUID, Valid: true})
+	if err != nil {
+		return entity.Invoice{}, err
+	}
+
+	invoice.Paid += amount
+	invoice.Status = entity.InvoiceStatusPartiallyPaid
+	if invoice.Paid >= invoice.Amount-balanceEpsilon {
+		invoice.Status = entity.InvoiceStatusPaid
+		invoice.PaidAt = &now
+	}
+
+	sql, args, _ = r.Builder.
+		Update("invoices").
+		Set("paid", invoice.Paid).
+		Set("status", invoice.Status).
+		Set("paid_at", invoice.PaidAt).
+		Where(squirrel.Eq{"uuid": invoiceUUID}).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return entity.Invoice{}, fmt.Errorf("InvoiceRepo.PayInvoice - tx.Exec: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return entity.Invoice{}, fmt.Errorf("InvoiceRepo.PayInvoice - tx.Commit: %v", err)
+	}
+
+	return invoice, nil
+}
@@ -0,0 +1,159 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+)
+
+// transfer moves amount from sender to receiver inside tx and records it in the transactions ledger.
+// Sender balance is checked by the update itself, so concurrent transfers can not overdraw a wallet
+// beyond its credit limit. A closed receiver returns repoerrors.ErrNotFound.
+func transfer(ctx context.Context, tx pgx.Tx, builder squirrel.StatementBuilderType, sender, receiver uuid.UUID, amount float64) error {
+	_, err := invoiceTransfer(ctx, tx, builder, sender, receiver, amount, uuid.NullUUID{})
+	return err
+}
+
+// checkChildLimit locks the sender wallet inside tx and returns repoerrors.ErrLimitExceeded when amount does not fit
+// into the spend limit the parent set for a child wallet. Money sent since the start of the limit period, scheduled
+// transfers and transfers waiting for approvals all count. Sending back to the parent is always allowed.
+func checkChildLimit(ctx context.Context, tx pgx.Tx, builder squirrel.StatementBuilderType, sender, receiver uuid.UUID, amount float64) error {
+	sql, args, _ := builder.
+		Select("w.parent", "p.spend_limit", "coalesce(p.limit_period, '')").
+		From("wallets w").
+		LeftJoin("child_wallet_policies p ON p.wallet = w.uuid").
+		Where(squirrel.Eq{"w.uuid": sender}).
+		Suffix("FOR UPDATE OF w").
+		ToSql()
+
+	var (
+		parent uuid.NullUUID
+		limit  *float64
+		period string
+	)
+	if err := tx.QueryRow(ctx, sql, args...).Scan(&parent, &limit, &period); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return repoerrors.ErrNotFound
+		}
+		return fmt.Errorf("pgdb.checkChildLimit - tx.QueryRow: %v", err)
+	}
+
+	if !parent.Valid || limit == nil || receiver == parent.UUID {
+		return nil
+	}
+
+	sql, args, _ = builder.
+		Select().
+		Column(squirrel.Expr(
+			"(SELECT coalesce(sum(amount), 0) FROM transactions WHERE sender = ? AND created_at >= date_trunc(?, now())) + "+
+				"(SELECT coalesce(sum(amount), 0) FROM scheduled_transfers WHERE sender = ? AND status = ?) + "+
+				"(SELECT coalesce(sum(amount), 0) FROM pending_transfers WHERE sender = ? AND status = ?)",
+			sender, period,
+			sender, entity.TransferStatusScheduled,
+			sender, entity.TransferStatusPending,
+		)).
+		ToSql()
+
+	var spent float64
+	if err := tx.QueryRow(ctx, sql, args...).Scan(&spent); err != nil {
+		return fmt.Errorf("pgdb.checkChildLimit - tx.QueryRow: %v", err)
+	}
+
+	if spent+amount > *limit {
+		return repoerrors.ErrLimitExceeded
+	}
+
+	return nil
+}
+
+// invoiceTransfer is transfer that links the ledger record to the paid invoice and returns the record id
+func invoiceTransfer(
+	ctx context.Context,
+	tx pgx.Tx,
+	builder squirrel.StatementBuilderType,
+	sender, receiver uuid.UUID,
+	amount float64,
+	invoice uuid.NullUUID,
+) (uuid.UUID, error) {
+	sql, args, _ := builder.
+		Update("wallets").
+		Set("balance", squirrel.Expr("balance - ?", amount)).
+		Where(squirrel.And{
+			squirrel.Eq{"uuid": sender},
+			squirrel.Expr("balance + credit_limit >= ?", amount),
+		}).
+		ToSql()
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("pgdb.transfer - tx.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return uuid.Nil, repoerrors.ErrInsufficientFunds
+	}
+
+	return credit(ctx, tx, builder, sender, receiver, amount, invoice)
+}
+
+// systemTransfer is transfer from a system wallet that may go negative, like the interest expense wallet
+func systemTransfer(ctx context.Context, tx pgx.Tx, builder squirrel.StatementBuilderType, sender, receiver uuid.UUID, amount float64) error {
+	sql, args, _ := builder.
+		Update("wallets").
+		Set("balance", squirrel.Expr("balance - ?", amount)).
+		Where(squirrel.Eq{"uuid": sender}).
+		ToSql()
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("pgdb.systemTransfer - tx.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	_, err = credit(ctx, tx, builder, sender, receiver, amount, uuid.NullUUID{})
+	return err
+}
+
+// credit adds the debited amount to the receiver and records the ledger row, a closed receiver returns repoerrors.ErrNotFound
+func credit(
+	ctx context.Context,
+	tx pgx.Tx,
+	builder squirrel.StatementBuilderType,
+	sender, receiver uuid.UUID,
+	amount float64,
+	invoice uuid.NullUUID,
+) (uuid.UUID, error) {
+	sql, args, _ := builder.
+		Update("wallets").
+		Set("balance", squirrel.Expr("balance + ?", amount)).
+		Where(squirrel.Eq{"uuid": receiver, "closed_at": nil}).
+		ToSql()
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("pgdb.transfer - tx.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return uuid.Nil, repoerrors.ErrNotFound
+	}
+
+	sql, args, _ = builder.
+		Insert("transactions").
+		Columns("sender", "receiver", "amount", "invoice").
+		Values(sender, receiver, amount, invoice).
+		Suffix("RETURNING uuid").
+		ToSql()
+
+	var transactionUUID uuid.UUID
+	if err = tx.QueryRow(ctx, sql, args...).Scan(&transactionUUID); err != nil {
+		return uuid.Nil, fmt.Errorf("pgdb.transfer - tx.QueryRow: %v", err)
+	}
+
+	return transactionUUID, nil
+}
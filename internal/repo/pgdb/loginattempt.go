@@ -0,0 +1,155 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+	"time"
+)
+
+type LoginAttemptRepo struct {
+	*postgres.Postgres
+}
+
+func NewLoginAttemptRepo(pg *postgres.Postgres) *LoginAttemptRepo {
+	return &LoginAttemptRepo{pg}
+}
+
+var loginAttemptColumns = []string{"key", "failures", "last_failure_at", "locked_until"}
+
+func scanLoginAttempts(row pgx.Row) (entity.LoginAttempts, error) {
+	var attempts entity.LoginAttempts
+	err := row.Scan(
+		&attempts.Key,
+		&attempts.Failures,
+		&attempts.LastFailureAt,
+		&attempts.LockedUntil,
+	)
+	return attempts, err
+}
+
+func (r *LoginAttemptRepo) GetLoginAttempts(ctx context.Context, key string) (entity.LoginAttempts, error) {
+	sql, args, _ := r.Builder.
+		Select(loginAttemptColumns...).
+		From("login_attempts").
+		Where(squirrel.Eq{"key": key}).
+		ToSql()
+
+	attempts, err := scanLoginAttempts(r.Pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.LoginAttempts{}, repoerrors.ErrNotFound
+		}
+		return entity.LoginAttempts{}, fmt.Errorf("LoginAttemptRepo.GetLoginAttempts - r.Pool.QueryRow: %v", err)
+	}
+
+	return attempts, nil
+}
+
+// AddFailedLogin counts the failure in one statement, so concurrent attempts are not lost
+func (r *LoginAttemptRepo) AddFailedLogin(
+	ctx context.Context,
+	key string,
+	now time.Time,
+	resetAfter time.Duration,
+) (entity.LoginAttempts, error) {
+	sql, args, _ := r.Builder.
+		Insert("login_attempts").
+		Columns("key", "failures", "last_failure_at").
+		Values(key, 1, now).
+		Suffix("ON CONFLICT (key) DO UPDATE SET "+
+			"failures = CASE WHEN login_attempts.last_failure_at < ? THEN 1 ELSE login_attempts.failures + 1 END, "+
+			"last_failure_at = excluded.last_failure_at "+
+			"RETURNING key, failures, last_failure_at, locked_until", now.Add(-resetAfter)).
+		ToSql()
+
+	attempts, err := scanLoginAttempts(r.Pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		return entity.LoginAttempts{}, fmt.Errorf("LoginAttemptRepo.AddFailedLogin - r.Pool.QueryRow: %v", err)
+	}
+
+	return attempts, nil
+}
+
+func (r *LoginAttemptRepo) LockLogin(ctx context.Context, key string, until time.Time) error {
+	sql, args, _ := r.Builder.
+		Update("login_attempts").
+		Set("locked_until", until).
+		Where(squirrel.Eq{"key": key}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("LoginAttemptRepo.LockLogin - r.Pool.Exec: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *LoginAttemptRepo) ResetLoginAttempts(ctx context.Context, key string) error {
+	sql, args, _ := r.Builder.
+		Delete("login_attempts").
+		Where(squirrel.Eq{"key": key}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("LoginAttemptRepo.ResetLoginAttempts - r.Pool.Exec: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *LoginAttemptRepo) GetLockedLogins(ctx context.Context, now time.Time) ([]entity.LoginAttempts, error) {
+	sql, args, _ := r.Builder.
+		Select(loginAttemptColumns...).
+		From("login_attempts").
+		Where(squirrel.Gt{"locked_until": now}).
+		OrderBy("locked_until DESC").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("LoginAttemptRepo.GetLockedLogins - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var locked []entity.LoginAttempts
+	for rows.Next() {
+		attempts, err := scanLoginAttempts(rows)
+		if err != nil {
+			return nil, fmt.Errorf("LoginAttemptRepo.GetLockedLogins - rows.Scan: %v", err)
+		}
+		locked = append(locked, attempts)
+	}
+
+	return locked, nil
+}
+
+// DeleteStaleLoginAttempts drops keys whose last failure and lock both ended before the moment
+func (r *LoginAttemptRepo) DeleteStaleLoginAttempts(ctx context.Context, before time.Time) (int64, error) {
+	sql, args, _ := r.Builder.
+		Delete("login_attempts").
+		Where(squirrel.Expr("greatest(last_failure_at, locked_until) < ?", before)).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, fmt.Errorf("LoginAttemptRepo.DeleteStaleLoginAttempts - r.Pool.Exec: %v", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,234 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+)
+
+type MemberRepo struct {
+	*postgres.Postgres
+}
+
+func NewMemberRepo(pg *postgres.Postgres) *MemberRepo {
+	return &MemberRepo{pg}
+}
+
+var memberColumns = []string{
+	"wallet", "member", "role", "spend_limit", "status", "invited_by", "created_at",
+}
+
+func scanMember(row pgx.Row) (entity.WalletMember, error) {
+	var member entity.WalletMember
+	err := row.Scan(
+		&member.Wallet,
+		&member.Member,
+		&member.Role,
+		&member.SpendLimit,
+		&member.Status,
+		&member.InvitedBy,
+		&member.CreatedAt,
+	)
+	return member, err
+}
+
+func (r *MemberRepo) AddMember(ctx context.Context, member entity.WalletMember) error {
+	sql, args, _ := r.Builder.
+		Insert("wallet_members").
+		Columns("wallet", "member", "role", "spend_limit", "status", "invited_by").
+		Values(member.Wallet, member.Member, member.Role, member.SpendLimit, member.Status, member.InvitedBy).
+		ToSql()
+
+	_, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			switch pgErr.Code {
+			case "23505":
+				return repoerrors.ErrAlreadyExist
+			case "23503":
+				return repoerrors.ErrNotFound
+			}
+		}
+		return fmt.Errorf("MemberRepo.AddMember - r.Pool.Exec: %v", err)
+	}
+
+	return nil
+}
+
+func (r *MemberRepo) GetMember(ctx context.Context, walletUUID, memberUUID uuid.UUID) (entity.WalletMember, error) {
+	sql, args, _ := r.Builder.
+		Select(memberColumns...).
+		From("wallet_members").
+		Where(squirrel.Eq{"wallet": walletUUID, "member": memberUUID}).
+		ToSql()
+
+	member, err := scanMember(r.Pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.WalletMember{}, repoerrors.ErrNotFound
+		}
+		return entity.WalletMember{}, fmt.Errorf("MemberRepo.GetMember - r.Pool.QueryRow: %v", err)
+	}
+
+	return member, nil
+}
+
+func (r *MemberRepo) GetMembers(ctx context.Context, walletUUID uuid.UUID) ([]entity.WalletMember, error) {
+	return r.getMembers(ctx, "MemberRepo.GetMembers", squirrel.Eq{"wallet": walletUUID})
+}
+
+func (r *MemberRepo) GetInvitations(ctx context.Context, memberUUID uuid.UUID) ([]entity.WalletMember, error) {
+	return r.getMembers(ctx, "MemberRepo.GetInvitations", squirrel.Eq{
+		"member": memberUUID,
+		"status": entity.MemberStatusInvited,
+	})
+}
+
+// GetMemberships returns the wallets the user is an active member of
+func (r *MemberRepo) GetMemberships(ctx context.Context, memberUUID uuid.UUID) ([]entity.WalletMember, error) {
+	return r.getMembers(ctx, "MemberRepo.GetMemberships", squirrel.Eq{
+		"member": memberUUID,
+		"status": entity.MemberStatusActive,
+	})
+}
+
+// UpdateMember changes role and spend limit. Demoting the last active owner returns repoerrors.ErrLastOwner.
+func (r *MemberRepo) UpdateMember(ctx context.Context, member entity.WalletMember) error {
+	return r.ownersGuarded(ctx, "MemberRepo.UpdateMember", member.Wallet, member.Member, member.Role != entity.WalletRoleOwner,
+		r.Builder.
+			Update("wallet_members").
+			Set("role", member.Role).
+			Set("spend_limit", member.SpendLimit).
+			Where(squirrel.Eq{"wallet": member.Wallet, "member": member.Member}),
+	)
+}
+
+// DeleteMember removes a member or declines an invitation. Removing the last active owner returns repoerrors.ErrLastOwner.
+func (r *MemberRepo) DeleteMember(ctx context.Context, walletUUID, memberUUID uuid.UUID) error {
+	return r.ownersGuarded(ctx, "MemberRepo.DeleteMember", walletUUID, memberUUID, true,
+		r.Builder.
+			Delete("wallet_members").
+			Where(squirrel.Eq{"wallet": walletUUID, "member": memberUUID}),
+	)
+}
+
+func (r *MemberRepo) ActivateMember(ctx context.Context, walletUUID, memberUUID uuid.UUID) error {
+	sql, args, _ := r.Builder.
+		Update("wallet_members").
+		Set("status", entity.MemberStatusActive).
+		Where(squirrel.Eq{
+			"wallet": walletUUID,
+			"member": memberUUID,
+			"status": entity.MemberStatusInvited,
+		}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("MemberRepo.ActivateMember - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *MemberRepo) getMembers(ctx context.Context, op string, where squirrel.Sqlizer) ([]entity.WalletMember, error) {
+	sql, args, _ := r.Builder.
+		Select(memberColumns...).
+		From("wallet_members").
+		Where(where).
+		OrderBy("created_at").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s - r.Pool.Query: %v", op, err)
+	}
+	defer rows.Close()
+
+	var members []entity.WalletMember
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s - rows.Scan: %v", op, err)
+		}
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+// ownersGuarded runs the statement changing a membership while the wallet is locked.
+// When the change takes the owner role away, it makes sure another active owner stays.
+func (r *MemberRepo) ownersGuarded(
+	ctx context.Context,
+	op string,
+	walletUUID, memberUUID uuid.UUID,
+	dropsOwner bool,
+	statement squirrel.Sqlizer,
+) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s - r.Pool.Begin: %v", op, err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Select("uuid").
+		From("wallets").
+		Where(squirrel.Eq{"uuid": walletUUID}).
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("%s - tx.Exec: %v", op, err)
+	}
+
+	if dropsOwner {
+		sql, args, _ = r.Builder.
+			Select().
+			Column(squirrel.Expr("count(*) filter (where member <> ?)", memberUUID)).
+			Column(squirrel.Expr("count(*) filter (where member = ?)", memberUUID)).
+			From("wallet_members").
+			Where(squirrel.Eq{
+				"wallet": walletUUID,
+				"role":   entity.WalletRoleOwner,
+				"status": entity.MemberStatusActive,
+			}).
+			ToSql()
+
+		var others, self int
+		err = tx.QueryRow(ctx, sql, args...).Scan(&others, &self)
+		if err != nil {
+			return fmt.Errorf("%s - tx.QueryRow: %v", op, err)
+		}
+		if self > 0 && others == 0 {
+			return repoerrors.ErrLastOwner
+		}
+	}
+
+	sql, args, _ = statement.ToSql()
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("%s - tx.Exec: %v", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s - tx.Commit: %v", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+)
+
+type MerchantRepo struct {
+	*postgres.Postgres
+}
+
+func NewMerchantRepo(pg *postgres.Postgres) *MerchantRepo {
+	return &MerchantRepo{pg}
+}
+
+// CreateMerchant creates the merchant profile together with its settlement wallets
+func (r *MerchantRepo) CreateMerchant(ctx context.Context, merchant entity.Merchant) (uuid.UUID, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("MerchantRepo.CreateMerchant - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Insert("merchants").
+		Columns("owner", "name").
+		Values(merchant.Owner, merchant.Name).
+		Suffix("RETURNING uuid").
+		ToSql()
+
+	var merchantUUID uuid.UUID
+	if err = tx.QueryRow(ctx, sql, args...).Scan(&merchantUUID); err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23505" {
+				return uuid.Nil, repoerrors.ErrAlreadyExist
+			}
+		}
+		return uuid.Nil, fmt.Errorf("MerchantRepo.CreateMerchant - tx.QueryRow: %v", err)
+	}
+
+	if len(merchant.Wallets) > 0 {
+		insert := r.Builder.
+			Insert("merchant_wallets").
+			Columns("merchant", "wallet")
+		for _, wallet := range merchant.Wallets {
+			insert = insert.Values(merchantUUID, wallet)
+		}
+
+		sql, args, _ = insert.Suffix("ON CONFLICT DO NOTHING").ToSql()
+		if _, err = tx.Exec(ctx, sql, args...); err != nil {
+			var pgErr *pgconn.PgError
+			if ok := errors.As(err, &pgErr); ok {
+				if pgErr.Code == "23503" {
+					return uuid.Nil, repoerrors.ErrNotFound
+				}
+			}
+			return uuid.Nil, fmt.Errorf("MerchantRepo.CreateMerchant - tx.Exec: %v", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("MerchantRepo.CreateMerchant - tx.Commit: %v", err)
+	}
+
+	return merchantUUID, nil
+}
+
+func (r *MerchantRepo) GetMerchantById(ctx context.Context, merchantUUID uuid.UUID) (entity.Merchant, error) {
+	return r.getMerchant(ctx, "MerchantRepo.GetMerchantById", squirrel.Eq{"uuid": merchantUUID})
+}
+
+func (r *MerchantRepo) GetMerchantByOwner(ctx context.Context, owner uuid.UUID) (entity.Merchant, error) {
+	return r.getMerchant(ctx, "MerchantRepo.GetMerchantByOwner", squirrel.Eq{"owner": owner})
+}
+
+func (r *MerchantRepo) AddMerchantWallet(ctx context.Context, merchantUUID, walletUUID uuid.UUID) error {
+	sql, args, _ := r.Builder.
+		Insert("merchant_wallets").
+		Columns("merchant", "wallet").
+		Values(merchantUUID, walletUUID).
+		ToSql()
+
+	_, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			switch pgErr.Code {
+			case "23505":
+				return repoerrors.ErrAlreadyExist
+			case "23503":
+				return repoerrors.ErrNotFound
+			}
+		}
+		return fmt.Errorf("MerchantRepo.AddMerchantWallet - r.Pool.Exec: %v", err)
+	}
+
+	return nil
+}
+
+func (r *MerchantRepo) DeleteMerchantWallet(ctx context.Context, merchantUUID, walletUUID uuid.UUID) error {
+	sql, args, _ := r.Builder.
+		Delete("merchant_wallets").
+		Where(squirrel.Eq{"merchant": merchantUUID, "wallet": walletUUID}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("MerchantRepo.DeleteMerchantWallet - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *MerchantRepo) getMerchant(ctx context.Context, op string, where squirrel.Sqlizer) (entity.Merchant, error) {
+	sql, args, _ := r.Builder.
+		Select("uuid", "owner", "name", "created_at").
+		From("merchants").
+		Where(where).
+		ToSql()
+
+	var merchant entity.Merchant
+	err := r.Pool.QueryRow(ctx, sql, args...).
+		Scan(&merchant.UUID, &merchant.Owner, &merchant.Name, &merchant.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Merchant{}, repoerrors.ErrNotFound
+		}
+		return entity.Merchant{}, fmt.Errorf("%s - r.Pool.QueryRow: %v", op, err)
+	}
+
+	sql, args, _ = r.Builder.
+		Select("wallet").
+		From("merchant_wallets").
+		Where(squirrel.Eq{"merchant": merchant.UUID}).
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return entity.Merchant{}, fmt.Errorf("%s - r.Pool.Query: %v", op, err)
+	}
+	defer rows.Close()
+
+	merchant.Wallets = []uuid.UUID{}
+	for rows.Next() {
+		var wallet uuid.UUID
+		if err = rows.Scan(&wallet); err != nil {
+			return entity.Merchant{}, fmt.Errorf("%s - rows.Scan: %v", op, err)
+		}
+		merchant.Wallets = append(merchant.Wallets, wallet)
+	}
+
+	return merchant, nil
+}
@@ -0,0 +1,182 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+	"time"
+)
+
+type PasswordResetRepo struct {
+	*postgres.Postgres
+}
+
+func NewPasswordResetRepo(pg *postgres.Postgres) *PasswordResetRepo {
+	return &PasswordResetRepo{pg}
+}
+
+// CreatePasswordResetToken stores the token and uses up the unused tokens issued before it,
+// so only the latest one works
+func (r *PasswordResetRepo) CreatePasswordResetToken(ctx context.Context, token entity.PasswordResetToken) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("PasswordResetRepo.CreatePasswordResetToken - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err = r.useOwnerTokens(ctx, tx, token.Owner); err != nil {
+		return err
+	}
+
+	sql, args, _ := r.Builder.
+		Insert("password_reset_tokens").
+		Columns("owner", "token_hash", "expires_at").
+		Values(token.Owner, token.TokenHash, token.ExpiresAt).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23505" {
+				return repoerrors.ErrAlreadyExist
+			}
+			if pgErr.Code == "23503" {
+				return repoerrors.ErrNotFound
+			}
+		}
+		return fmt.Errorf("PasswordResetRepo.CreatePasswordResetToken - tx.Exec: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("PasswordResetRepo.CreatePasswordResetToken - tx.Commit: %v", err)
+	}
+
+	return nil
+}
+
+// ResetPassword uses up the token with the hash, sets the password of its owner and revokes the owner's tokens
+// issued before the moment in one transaction. Used and expired tokens return repoerrors.ErrUnavailable.
+func (r *PasswordResetRepo) ResetPassword(ctx context.Context, tokenHash, password string, before time.Time) (uuid.UUID, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("PasswordResetRepo.ResetPassword - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Select("owner", "expires_at", "used_at").
+		From("password_reset_tokens").
+		Where(squirrel.Eq{"token_hash": tokenHash}).
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	var token entity.PasswordResetToken
+	err = tx.QueryRow(ctx, sql, args...).Scan(&token.Owner, &token.ExpiresAt, &token.UsedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, repoerrors.ErrNotFound
+		}
+		return uuid.Nil, fmt.Errorf("PasswordResetRepo.ResetPassword - tx.QueryRow: %v", err)
+	}
+
+	if token.UsedAt != nil || !token.ExpiresAt.After(time.Now()) {
+		return uuid.Nil, repoerrors.ErrUnavailable
+	}
+
+	if err = r.useOwnerTokens(ctx, tx, token.Owner); err != nil {
+		return uuid.Nil, err
+	}
+
+	sql, args, _ = r.Builder.
+		Update("users").
+		Set("password", password).
+		Where(squirrel.Eq{"uuid": token.Owner}).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return uuid.Nil, fmt.Errorf("PasswordResetRepo.ResetPassword - tx.Exec: %v", err)
+	}
+
+	if err = revokeUserTokens(ctx, tx, r.Builder, token.Owner, before); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("PasswordResetRepo.ResetPassword - tx.Commit: %v", err)
+	}
+
+	return token.Owner, nil
+}
+
+// ChangePassword sets the password of the user and revokes the user's tokens issued before the moment
+// in one transaction
+func (r *PasswordResetRepo) ChangePassword(ctx context.Context, owner uuid.UUID, password string, before time.Time) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("PasswordResetRepo.ChangePassword - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Update("users").
+		Set("password", password).
+		Where(squirrel.Eq{"uuid": owner, "deleted_at": nil}).
+		ToSql()
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("PasswordResetRepo.ChangePassword - tx.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	if err = revokeUserTokens(ctx, tx, r.Builder, owner, before); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("PasswordResetRepo.ChangePassword - tx.Commit: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredPasswordResetTokens drops tokens that expired before the moment
+func (r *PasswordResetRepo) DeleteExpiredPasswordResetTokens(ctx context.Context, before time.Time) (int64, error) {
+	sql, args, _ := r.Builder.
+		Delete("password_reset_tokens").
+		Where(squirrel.Lt{"expires_at": before}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, fmt.Errorf("PasswordResetRepo.DeleteExpiredPasswordResetTokens - r.Pool.Exec: %v", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (r *PasswordResetRepo) useOwnerTokens(ctx context.Context, tx pgx.Tx, owner uuid.UUID) error {
+	sql, args, _ := r.Builder.
+		Update("password_reset_tokens").
+		Set("used_at", squirrel.Expr("now()")).
+		Where(squirrel.And{
+			squirrel.Eq{"owner": owner},
+			squirrel.Eq{"used_at": nil},
+		}).
+		ToSql()
+
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("PasswordResetRepo.useOwnerTokens - tx.Exec: %v", err)
+	}
+
+	return nil
+}
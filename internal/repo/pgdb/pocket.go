@@ -0,0 +1,241 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+)
+
+type PocketRepo struct {
+	*postgres.Postgres
+}
+
+func NewPocketRepo(pg *postgres.Postgres) *PocketRepo {
+	return &PocketRepo{pg}
+}
+
+var pocketColumns = []string{
+	"uuid", "wallet", "name", "balance", "target", "deadline", "created_at",
+}
+
+func scanPocket(row pgx.Row) (entity.Pocket, error) {
+	var pocket entity.Pocket
+	err := row.Scan(
+		&pocket.UUID,
+		&pocket.Wallet,
+		&pocket.Name,
+		&pocket.Balance,
+		&pocket.Target,
+		&pocket.Deadline,
+		&pocket.CreatedAt,
+	)
+	return pocket, err
+}
+
+func (r *PocketRepo) CreatePocket(ctx context.Context, pocket entity.Pocket) (uuid.UUID, error) {
+	sql, args, _ := r.Builder.
+		Insert("wallet_pockets").
+		Columns("wallet", "name", "target", "deadline").
+		Values(pocket.Wallet, pocket.Name, pocket.Target, pocket.Deadline).
+		Suffix("RETURNING uuid").
+		ToSql()
+
+	var pocketUUID uuid.UUID
+	err := r.Pool.QueryRow(ctx, sql, args...).Scan(&pocketUUID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			switch pgErr.Code {
+			case "23505":
+				return uuid.Nil, repoerrors.ErrAlreadyExist
+			case "23503":
+				return uuid.Nil, repoerrors.ErrNotFound
+			}
+		}
+		return uuid.Nil, fmt.Errorf("PocketRepo.CreatePocket - r.Pool.QueryRow: %v", err)
+	}
+
+	return pocketUUID, nil
+}
+
+func (r *PocketRepo) GetPocketById(ctx context.Context, pocketUUID uuid.UUID) (entity.Pocket, error) {
+	sql, args, _ := r.Builder.
+		Select(pocketColumns...).
+		From("wallet_pockets").
+		Where(squirrel.Eq{"uuid": pocketUUID}).
+		ToSql()
+
+	pocket, err := scanPocket(r.Pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Pocket{}, repoerrors.ErrNotFound
+		}
+		return entity.Pocket{}, fmt.Errorf("PocketRepo.GetPocketById - r.Pool.QueryRow: %v", err)
+	}
+
+	return pocket, nil
+}
+
+func (r *PocketRepo) GetWalletPockets(ctx context.Context, walletUUID uuid.UUID) ([]entity.Pocket, error) {
+	sql, args, _ := r.Builder.
+		Select(pocketColumns...).
+		From("wallet_pockets").
+		Where(squirrel.Eq{"wallet": walletUUID}).
+		OrderBy("created_at").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("PocketRepo.GetWalletPockets - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var pockets []entity.Pocket
+	for rows.Next() {
+		pocket, err := scanPocket(rows)
+		if err != nil {
+			return nil, fmt.Errorf("PocketRepo.GetWalletPockets - rows.Scan: %v", err)
+		}
+		pockets = append(pockets, pocket)
+	}
+
+	return pockets, nil
+}
+
+// UpdatePocket changes name, target and deadline of the pocket
+func (r *PocketRepo) UpdatePocket(ctx context.Context, pocket entity.Pocket) error {
+	sql, args, _ := r.Builder.
+		Update("wallet_pockets").
+		Set("name", pocket.Name).
+		Set("target", pocket.Target).
+		Set("deadline", pocket.Deadline).
+		Where(squirrel.Eq{"uuid": pocket.UUID}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23505" {
+				return repoerrors.ErrAlreadyExist
+			}
+		}
+		return fmt.Errorf("PocketRepo.UpdatePocket - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// DeletePocket returns the pocket balance to the wallet and removes the pocket
+func (r *PocketRepo) DeletePocket(ctx context.Context, pocketUUID uuid.UUID) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("PocketRepo.DeletePocket - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Delete("wallet_pockets").
+		Where(squirrel.Eq{"uuid": pocketUUID}).
+		Suffix("RETURNING wallet, balance").
+		ToSql()
+
+	var (
+		walletUUID uuid.UUID
+		balance    float64
+	)
+	err = tx.QueryRow(ctx, sql, args...).Scan(&walletUUID, &balance)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return repoerrors.ErrNotFound
+		}
+		return fmt.Errorf("PocketRepo.DeletePocket - tx.QueryRow: %v", err)
+	}
+
+	sql, args, _ = r.Builder.
+		Update("wallets").
+		Set("balance", squirrel.Expr("balance + ?", balance)).
+		Where(squirrel.Eq{"uuid": walletUUID}).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("PocketRepo.DeletePocket - tx.Exec: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("PocketRepo.DeletePocket - tx.Commit: %v", err)
+	}
+
+	return nil
+}
+
+// Deposit moves amount from the wallet balance into the pocket
+func (r *PocketRepo) Deposit(ctx context.Context, pocketUUID uuid.UUID, amount float64) error {
+	return r.move(ctx, "PocketRepo.Deposit", pocketUUID, amount)
+}
+
+// Withdraw moves amount from the pocket back to the wallet balance
+func (r *PocketRepo) Withdraw(ctx context.Context, pocketUUID uuid.UUID, amount float64) error {
+	return r.move(ctx, "PocketRepo.Withdraw", pocketUUID, -amount)
+}
+
+// move shifts delta from the wallet balance to the pocket, a negative delta moves money back.
+// Both updates are guarded: the pocket can not go below zero and deposits can not be paid from the wallet credit,
+// while withdrawals may refill an overdrawn wallet. Returns repoerrors.ErrInsufficientFunds otherwise.
+func (r *PocketRepo) move(ctx context.Context, op string, pocketUUID uuid.UUID, delta float64) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s - r.Pool.Begin: %v", op, err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Update("wallet_pockets").
+		Set("balance", squirrel.Expr("balance + ?", delta)).
+		Where(squirrel.Eq{"uuid": pocketUUID}).
+		Where(squirrel.Expr("balance + ? >= 0", delta)).
+		Suffix("RETURNING wallet").
+		ToSql()
+
+	var walletUUID uuid.UUID
+	err = tx.QueryRow(ctx, sql, args...).Scan(&walletUUID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return repoerrors.ErrInsufficientFunds
+		}
+		return fmt.Errorf("%s - tx.QueryRow: %v", op, err)
+	}
+
+	query := r.Builder.
+		Update("wallets").
+		Set("balance", squirrel.Expr("balance - ?", delta)).
+		Where(squirrel.Eq{"uuid": walletUUID})
+	if delta > 0 {
+		query = query.Where(squirrel.Expr("balance - ? >= 0", delta))
+	}
+	sql, args, _ = query.ToSql()
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("%s - tx.Exec: %v", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrInsufficientFunds
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s - tx.Commit: %v", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,339 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+)
+
+type PromotionRepo struct {
+	*postgres.Postgres
+}
+
+func NewPromotionRepo(pg *postgres.Postgres) *PromotionRepo {
+	return &PromotionRepo{pg}
+}
+
+var campaignColumns = []string{
+	"uuid", "name", "kind", "percent", "fixed_amount", "receiver", "min_amount", "budget", "spent",
+	"user_cap", "cap_period", "active", "starts_at", "ends_at", "created_at",
+}
+
+func scanCampaign(row pgx.Row) (entity.PromoCampaign, error) {
+	var campaign entity.PromoCampaign
+	err := row.Scan(
+		&campaign.UUID,
+		&campaign.Name,
+		&campaign.Kind,
+		&campaign.Percent,
+		&campaign.FixedAmount,
+		&campaign.Receiver,
+		&campaign.MinAmount,
+		&campaign.Budget,
+		&campaign.Spent,
+		&campaign.UserCap,
+		&campaign.CapPeriod,
+		&campaign.Active,
+		&campaign.StartsAt,
+		&campaign.EndsAt,
+		&campaign.CreatedAt,
+	)
+	return campaign, err
+}
+
+func (r *PromotionRepo) CreateCampaign(ctx context.Context, campaign entity.PromoCampaign) (uuid.UUID, error) {
+	sql, args, _ := r.Builder.
+		Insert("promo_campaigns").
+		Columns(
+			"name", "kind", "percent", "fixed_amount", "receiver", "min_amount", "budget",
+			"user_cap", "cap_period", "active", "starts_at", "ends_at",
+		).
+		Values(
+			campaign.Name,
+			campaign.Kind,
+			campaign.Percent,
+			campaign.FixedAmount,
+			campaign.Receiver,
+			campaign.MinAmount,
+			campaign.Budget,
+			campaign.UserCap,
+			campaign.CapPeriod,
+			campaign.Active,
+			campaign.StartsAt,
+			campaign.EndsAt,
+		).
+		Suffix("RETURNING uuid").
+		ToSql()
+
+	var campaignUUID uuid.UUID
+	if err := r.Pool.QueryRow(ctx, sql, args...).Scan(&campaignUUID); err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23505" {
+				return uuid.Nil, repoerrors.ErrAlreadyExist
+			}
+			if pgErr.Code == "23503" {
+				return uuid.Nil, repoerrors.ErrNotFound
+			}
+		}
+		return uuid.Nil, fmt.Errorf("PromotionRepo.CreateCampaign - r.Pool.QueryRow: %v", err)
+	}
+
+	return campaignUUID, nil
+}
+
+func (r *PromotionRepo) GetCampaignById(ctx context.Context, campaignUUID uuid.UUID) (entity.PromoCampaign, error) {
+	sql, args, _ := r.Builder.
+		Select(campaignColumns...).
+		From("promo_campaigns").
+		Where(squirrel.Eq{"uuid": campaignUUID}).
+		ToSql()
+
+	campaign, err := scanCampaign(r.Pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.PromoCampaign{}, repoerrors.ErrNotFound
+		}
+		return entity.PromoCampaign{}, fmt.Errorf("PromotionRepo.GetCampaignById - r.Pool.QueryRow: %v", err)
+	}
+
+	return campaign, nil
+}
+
+func (r *PromotionRepo) GetCampaigns(ctx context.Context) ([]entity.PromoCampaign, error) {
+	sql, args, _ := r.Builder.
+		Select(campaignColumns...).
+		From("promo_campaigns").
+		OrderBy("created_at DESC").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("PromotionRepo.GetCampaigns - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var campaigns []entity.PromoCampaign
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			return nil, fmt.Errorf("PromotionRepo.GetCampaigns - rows.Scan: %v", err)
+		}
+		campaigns = append(campaigns, campaign)
+	}
+
+	return campaigns, nil
+}
+
+// GetMatchingCampaigns returns running campaigns with budget left that apply to a transfer of amount into receiver
+func (r *PromotionRepo) GetMatchingCampaigns(ctx context.Context, receiver uuid.UUID, amount float64) ([]entity.PromoCampaign, error) {
+	sql, args, _ := r.Builder.
+		Select(campaignColumns...).
+		From("promo_campaigns").
+		Where(squirrel.And{
+			squirrel.Eq{"active": true},
+			squirrel.Expr("starts_at <= now()"),
+			squirrel.Expr("(ends_at is null or ends_at > now())"),
+			squirrel.Expr("spent < budget"),
+			squirrel.Or{squirrel.Eq{"receiver": nil}, squirrel.Eq{"receiver": receiver}},
+			squirrel.LtOrEq{"min_amount": amount},
+		}).
+		OrderBy("created_at").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("PromotionRepo.GetMatchingCampaigns - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var campaigns []entity.PromoCampaign
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			return nil, fmt.Errorf("PromotionRepo.GetMatchingCampaigns - rows.Scan: %v", err)
+		}
+		campaigns = append(campaigns, campaign)
+	}
+
+	return campaigns, nil
+}
+
+func (r *PromotionRepo) UpdateCampaign(ctx context.Context, campaign entity.PromoCampaign) error {
+	sql, args, _ := r.Builder.
+		Update("promo_campaigns").
+		Set("name", campaign.Name).
+		Set("percent", campaign.Percent).
+		Set("fixed_amount", campaign.FixedAmount).
+		Set("min_amount", campaign.MinAmount).
+		Set("budget", campaign.Budget).
+		Set("user_cap", campaign.UserCap).
+		Set("cap_period", campaign.CapPeriod).
+		Set("active", campaign.Active).
+		Set("starts_at", campaign.StartsAt).
+		Set("ends_at", campaign.EndsAt).
+		Where(squirrel.Eq{"uuid": campaign.UUID}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23505" {
+				return repoerrors.ErrAlreadyExist
+			}
+		}
+		return fmt.Errorf("PromotionRepo.UpdateCampaign - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// PayReward pays up to payout.Amount from the promo wallet, cut down to the budget left and the user cap.
+// Campaign row is locked, so concurrent transfers can not overspend the budget or the cap.
+// A first_transfer campaign pays every user once, for the first transfer executed while it runs,
+// whichever of the user's wallets sent it.
+// Returns the paid amount, zero when the campaign has nothing left for the user.
+func (r *PromotionRepo) PayReward(ctx context.Context, payout entity.PromoPayout, promoWallet uuid.UUID) (float64, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("PromotionRepo.PayReward - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Select(campaignColumns...).
+		From("promo_campaigns").
+		Where(squirrel.Eq{
+			"uuid":   payout.Campaign,
+			"active": true,
+		}).
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	campaign, err := scanCampaign(tx.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, repoerrors.ErrNotFound
+		}
+		return 0, fmt.Errorf("PromotionRepo.PayReward - tx.QueryRow: %v", err)
+	}
+
+	amount := min(payout.Amount, campaign.Budget-campaign.Spent)
+
+	if campaign.Kind == entity.PromoKindFirstTransfer {
+		sql, args, _ = r.Builder.
+			Select("count(*) > 0").
+			From("promo_payouts").
+			Where(squirrel.Eq{
+				"campaign":  campaign.UUID,
+				"user_uuid": payout.User,
+			}).
+			ToSql()
+
+		var rewarded bool
+		if err = tx.QueryRow(ctx, sql, args...).Scan(&rewarded); err != nil {
+			return 0, fmt.Errorf("PromotionRepo.PayReward - tx.QueryRow: %v", err)
+		}
+		if rewarded {
+			return 0, nil
+		}
+	}
+
+	if campaign.UserCap != nil {
+		query := r.Builder.
+			Select("coalesce(sum(amount), 0)").
+			From("promo_payouts").
+			Where(squirrel.Eq{
+				"campaign":  campaign.UUID,
+				"user_uuid": payout.User,
+			})
+		if campaign.CapPeriod != nil {
+			query = query.Where(squirrel.Expr("created_at >= date_trunc(?, now())", *campaign.CapPeriod))
+		}
+		sql, args, _ = query.ToSql()
+
+		var paid float64
+		if err = tx.QueryRow(ctx, sql, args...).Scan(&paid); err != nil {
+			return 0, fmt.Errorf("PromotionRepo.PayReward - tx.QueryRow: %v", err)
+		}
+		amount = min(amount, *campaign.UserCap-paid)
+	}
+
+	if amount < balanceEpsilon {
+		return 0, nil
+	}
+
+	if err = transfer(ctx, tx, r.Builder, promoWallet, payout.Wallet, amount); err != nil {
+		return 0, err
+	}
+
+	sql, args, _ = r.Builder.
+		Insert("promo_payouts").
+		Columns("campaign", "user_uuid", "wallet", "amount").
+		Values(campaign.UUID, payout.User, payout.Wallet, amount).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return 0, fmt.Errorf("PromotionRepo.PayReward - tx.Exec: %v", err)
+	}
+
+	sql, args, _ = r.Builder.
+		Update("promo_campaigns").
+		Set("spent", squirrel.Expr("spent + ?", amount)).
+		Where(squirrel.Eq{"uuid": campaign.UUID}).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return 0, fmt.Errorf("PromotionRepo.PayReward - tx.Exec: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("PromotionRepo.PayReward - tx.Commit: %v", err)
+	}
+
+	return amount, nil
+}
+
+// GetReport sums payouts of every campaign
+func (r *PromotionRepo) GetReport(ctx context.Context) ([]entity.PromoReport, error) {
+	sql, args, _ := r.Builder.
+		Select(
+			"c.uuid", "c.name", "c.budget", "c.spent",
+			"count(p.uuid)", "count(distinct p.user_uuid)",
+		).
+		From("promo_campaigns c").
+		LeftJoin("promo_payouts p ON p.campaign = c.uuid").
+		GroupBy("c.uuid").
+		OrderBy("c.created_at DESC").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("PromotionRepo.GetReport - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var report []entity.PromoReport
+	for rows.Next() {
+		var line entity.PromoReport
+		err = rows.Scan(&line.Campaign, &line.Name, &line.Budget, &line.Spent, &line.Payouts, &line.Users)
+		if err != nil {
+			return nil, fmt.Errorf("PromotionRepo.GetReport - rows.Scan: %v", err)
+		}
+		line.Remaining = line.Budget - line.Spent
+		report = append(report, line)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,211 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+	"time"
+)
+
+type RefreshTokenRepo struct {
+	*postgres.Postgres
+}
+
+func NewRefreshTokenRepo(pg *postgres.Postgres) *RefreshTokenRepo {
+	return &RefreshTokenRepo{pg}
+}
+
+var refreshTokenColumns = []string{
+	"uuid", "family", "owner", "token_hash", "created_at", "expires_at", "used_at", "revoked_at",
+}
+
+func scanRefreshToken(row pgx.Row) (entity.RefreshToken, error) {
+	var token entity.RefreshToken
+	err := row.Scan(
+		&token.UUID,
+		&token.Family,
+		&token.Owner,
+		&token.TokenHash,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.RevokedAt,
+	)
+	return token, err
+}
+
+func (r *RefreshTokenRepo) CreateRefreshToken(ctx context.Context, token entity.RefreshToken) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("RefreshTokenRepo.CreateRefreshToken - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err = r.insert(ctx, tx, token); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("RefreshTokenRepo.CreateRefreshToken - tx.Commit: %v", err)
+	}
+
+	return nil
+}
+
+// RotateRefreshToken exchanges the token with the hash for next, which joins its family.
+// A token that was already exchanged means it leaked: the whole family is revoked
+// and repoerrors.ErrAlreadyExist is returned. Revoked and expired tokens return repoerrors.ErrUnavailable.
+func (r *RefreshTokenRepo) RotateRefreshToken(ctx context.Context, tokenHash string, next entity.RefreshToken) (entity.RefreshToken, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return entity.RefreshToken{}, fmt.Errorf("RefreshTokenRepo.RotateRefreshToken - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Select(refreshTokenColumns...).
+		From("refresh_tokens").
+		Where(squirrel.Eq{"token_hash": tokenHash}).
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	token, err := scanRefreshToken(tx.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.RefreshToken{}, repoerrors.ErrNotFound
+		}
+		return entity.RefreshToken{}, fmt.Errorf("RefreshTokenRepo.RotateRefreshToken - tx.QueryRow: %v", err)
+	}
+
+	if token.RevokedAt != nil || !token.ExpiresAt.After(time.Now()) {
+		return token, repoerrors.ErrUnavailable
+	}
+
+	if token.UsedAt != nil {
+		if err = r.revokeFamily(ctx, tx, token.Family); err != nil {
+			return entity.RefreshToken{}, err
+		}
+
+		if err = tx.Commit(ctx); err != nil {
+			return entity.RefreshToken{}, fmt.Errorf("RefreshTokenRepo.RotateRefreshToken - tx.Commit: %v", err)
+		}
+
+		return token, repoerrors.ErrAlreadyExist
+	}
+
+	sql, args, _ = r.Builder.
+		Update("refresh_tokens").
+		Set("used_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"uuid": token.UUID}).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return entity.RefreshToken{}, fmt.Errorf("RefreshTokenRepo.RotateRefreshToken - tx.Exec: %v", err)
+	}
+
+	next.Family, next.Owner = token.Family, token.Owner
+	if err = r.insert(ctx, tx, next); err != nil {
+		return entity.RefreshToken{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return entity.RefreshToken{}, fmt.Errorf("RefreshTokenRepo.RotateRefreshToken - tx.Commit: %v", err)
+	}
+
+	return token, nil
+}
+
+// RevokeRefreshTokenFamily revokes the family of the owner's token with the hash
+func (r *RefreshTokenRepo) RevokeRefreshTokenFamily(ctx context.Context, owner uuid.UUID, tokenHash string) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("RefreshTokenRepo.RevokeRefreshTokenFamily - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Select("family").
+		From("refresh_tokens").
+		Where(squirrel.Eq{"owner": owner, "token_hash": tokenHash}).
+		ToSql()
+
+	var family uuid.UUID
+	if err = tx.QueryRow(ctx, sql, args...).Scan(&family); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return repoerrors.ErrNotFound
+		}
+		return fmt.Errorf("RefreshTokenRepo.RevokeRefreshTokenFamily - tx.QueryRow: %v", err)
+	}
+
+	if err = r.revokeFamily(ctx, tx, family); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("RefreshTokenRepo.RevokeRefreshTokenFamily - tx.Commit: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredRefreshTokens drops tokens that expired before the moment, they can not be rotated or reused anymore
+func (r *RefreshTokenRepo) DeleteExpiredRefreshTokens(ctx context.Context, before time.Time) (int64, error) {
+	sql, args, _ := r.Builder.
+		Delete("refresh_tokens").
+		Where(squirrel.Lt{"expires_at": before}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, fmt.Errorf("RefreshTokenRepo.DeleteExpiredRefreshTokens - r.Pool.Exec: %v", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (r *RefreshTokenRepo) insert(ctx context.Context, tx pgx.Tx, token entity.RefreshToken) error {
+	sql, args, _ := r.Builder.
+		Insert("refresh_tokens").
+		Columns("family", "owner", "token_hash", "expires_at").
+		Values(token.Family, token.Owner, token.TokenHash, token.ExpiresAt).
+		ToSql()
+
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23505" {
+				return repoerrors.ErrAlreadyExist
+			}
+			if pgErr.Code == "23503" {
+				return repoerrors.ErrNotFound
+			}
+		}
+		return fmt.Errorf("RefreshTokenRepo.insert - tx.Exec: %v", err)
+	}
+
+	return nil
+}
+
+func (r *RefreshTokenRepo) revokeFamily(ctx context.Context, tx pgx.Tx, family uuid.UUID) error {
+	sql, args, _ := r.Builder.
+		Update("refresh_tokens").
+		Set("revoked_at", squirrel.Expr("now()")).
+		Where(squirrel.And{
+			squirrel.Eq{"family": family},
+			squirrel.Eq{"revoked_at": nil},
+		}).
+		ToSql()
+
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("RefreshTokenRepo.revokeFamily - tx.Exec: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+	"time"
+)
+
+type TokenRevocationRepo struct {
+	*postgres.Postgres
+}
+
+func NewTokenRevocationRepo(pg *postgres.Postgres) *TokenRevocationRepo {
+	return &TokenRevocationRepo{pg}
+}
+
+// RevokeToken adds the access token id to the denylist, revoking it twice is not an error
+func (r *TokenRevocationRepo) RevokeToken(ctx context.Context, jti string, owner uuid.UUID, expiresAt time.Time) error {
+	sql, args, _ := r.Builder.
+		Insert("revoked_tokens").
+		Columns("jti", "owner", "expires_at").
+		Values(jti, owner, expiresAt).
+		Suffix("ON CONFLICT (jti) DO NOTHING").
+		ToSql()
+
+	if _, err := r.Pool.Exec(ctx, sql, args...); err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23503" {
+				return repoerrors.ErrNotFound
+			}
+		}
+		return fmt.Errorf("TokenRevocationRepo.RevokeToken - r.Pool.Exec: %v", err)
+	}
+
+	return nil
+}
+
+func (r *TokenRevocationRepo) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	sql, args, _ := r.Builder.
+		Select("1").
+		From("revoked_tokens").
+		Where(squirrel.Eq{"jti": jti}).
+		ToSql()
+
+	var one int
+	err := r.Pool.QueryRow(ctx, sql, args...).Scan(&one)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("TokenRevocationRepo.IsTokenRevoked - r.Pool.QueryRow: %v", err)
+	}
+
+	return true, nil
+}
+
+// RevokeUserTokens rejects every access token of the user issued before the moment
+// and revokes all refresh tokens of the user
+func (r *TokenRevocationRepo) RevokeUserTokens(ctx context.Context, owner uuid.UUID, before time.Time) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("TokenRevocationRepo.RevokeUserTokens - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err = revokeUserTokens(ctx, tx, r.Builder, owner, before); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("TokenRevocationRepo.RevokeUserTokens - tx.Commit: %v", err)
+	}
+
+	return nil
+}
+
+// GetUserTokensRevokedAt returns nil if the user never logged out from every session
+func (r *TokenRevocationRepo) GetUserTokensRevokedAt(ctx context.Context, owner uuid.UUID) (*time.Time, error) {
+	sql, args, _ := r.Builder.
+		Select("tokens_revoked_at").
+		From("users").
+		Where(squirrel.Eq{"uuid": owner}).
+		ToSql()
+
+	var revokedAt *time.Time
+	err := r.Pool.QueryRow(ctx, sql, args...).Scan(&revokedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, repoerrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("TokenRevocationRepo.GetUserTokensRevokedAt - r.Pool.QueryRow: %v", err)
+	}
+
+	return revokedAt, nil
+}
+
+// DeleteExpiredRevokedTokens drops denylist entries of tokens that expired before the moment
+func (r *TokenRevocationRepo) DeleteExpiredRevokedTokens(ctx context.Context, before time.Time) (int64, error) {
+	sql, args, _ := r.Builder.
+		Delete("revoked_tokens").
+		Where(squirrel.Lt{"expires_at": before}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, fmt.Errorf("TokenRevocationRepo.DeleteExpiredRevokedTokens - r.Pool.Exec: %v", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// revokeUserTokens is RevokeUserTokens inside tx, so a password change and the sign out everywhere commit together
+func revokeUserTokens(ctx context.Context, tx pgx.Tx, builder squirrel.StatementBuilderType, owner uuid.UUID, before time.Time) error {
+	sql, args, _ := builder.
+		Update("users").
+		Set("tokens_revoked_at", before).
+		Where(squirrel.Eq{"uuid": owner}).
+		ToSql()
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("pgdb.revokeUserTokens - tx.Exec: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	sql, args, _ = builder.
+		Update("refresh_tokens").
+		Set("revoked_at", squirrel.Expr("now()")).
+		Where(squirrel.And{
+			squirrel.Eq{"owner": owner},
+			squirrel.Eq{"revoked_at": nil},
+		}).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("pgdb.revokeUserTokens - tx.Exec: %v", err)
+	}
+
+	return nil
+}
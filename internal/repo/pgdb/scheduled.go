@@ -0,0 +1,296 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+	"time"
+)
+
+type ScheduledTransferRepo struct {
+	*postgres.Postgres
+}
+
+func NewScheduledTransferRepo(pg *postgres.Postgres) *ScheduledTransferRepo {
+	return &ScheduledTransferRepo{pg}
+}
+
+var scheduledTransferColumns = []string{
+	"uuid", "sender", "receiver", "amount", "status", "created_by", "created_at", "execute_at", "resolved_at",
+}
+
+func scanScheduledTransfer(row pgx.Row) (entity.ScheduledTransfer, error) {
+	var transfer entity.ScheduledTransfer
+	err := row.Scan(
+		&transfer.UUID,
+		&transfer.Sender,
+		&transfer.Receiver,
+		&transfer.Amount,
+		&transfer.Status,
+		&transfer.CreatedBy,
+		&transfer.CreatedAt,
+		&transfer.ExecuteAt,
+		&transfer.ResolvedAt,
+	)
+	return transfer, err
+}
+
+func (r *ScheduledTransferRepo) SetTransferDelay(ctx context.Context, walletUUID uuid.UUID, seconds int64) error {
+	sql, args, _ := r.Builder.
+		Update("wallets").
+		Set("transfer_delay_seconds", seconds).
+		Where(squirrel.Eq{"uuid": walletUUID}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("ScheduledTransferRepo.SetTransferDelay - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// CreateScheduledTransfer returns repoerrors.ErrLimitExceeded when the amount does not fit into the spend limit
+// of a child wallet, scheduled transfers count against it until they are executed or cancelled
+func (r *ScheduledTransferRepo) CreateScheduledTransfer(ctx context.Context, transfer entity.ScheduledTransfer) (uuid.UUID, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("ScheduledTransferRepo.CreateScheduledTransfer - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err = checkChildLimit(ctx, tx, r.Builder, transfer.Sender, transfer.Receiver, transfer.Amount); err != nil {
+		return uuid.Nil, err
+	}
+
+	sql, args, _ := r.Builder.
+		Insert("scheduled_transfers").
+		Columns("sender", "receiver", "amount", "status", "created_by", "execute_at").
+		Values(
+			transfer.Sender,
+			transfer.Receiver,
+			transfer.Amount,
+			entity.TransferStatusScheduled,
+			transfer.CreatedBy,
+			transfer.ExecuteAt,
+		).
+		Suffix("RETURNING uuid").
+		ToSql()
+
+	var transferUUID uuid.UUID
+	if err = tx.QueryRow(ctx, sql, args...).Scan(&transferUUID); err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23503" {
+				return uuid.Nil, repoerrors.ErrNotFound
+			}
+		}
+		return uuid.Nil, fmt.Errorf("ScheduledTransferRepo.CreateScheduledTransfer - tx.QueryRow: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("ScheduledTransferRepo.CreateScheduledTransfer - tx.Commit: %v", err)
+	}
+
+	return transferUUID, nil
+}
+
+func (r *ScheduledTransferRepo) GetScheduledTransferById(ctx context.Context, transferUUID uuid.UUID) (entity.ScheduledTransfer, error) {
+	sql, args, _ := r.Builder.
+		Select(scheduledTransferColumns...).
+		From("scheduled_transfers").
+		Where(squirrel.Eq{"uuid": transferUUID}).
+		ToSql()
+
+	transfer, err := scanScheduledTransfer(r.Pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.ScheduledTransfer{}, repoerrors.ErrNotFound
+		}
+		return entity.ScheduledTransfer{}, fmt.Errorf("ScheduledTransferRepo.GetScheduledTransferById - r.Pool.QueryRow: %v", err)
+	}
+
+	return transfer, nil
+}
+
+// GetWalletScheduledTransfers returns not yet executed transfers from or to the wallet
+func (r *ScheduledTransferRepo) GetWalletScheduledTransfers(ctx context.Context, walletUUID uuid.UUID) ([]entity.ScheduledTransfer, error) {
+	sql, args, _ := r.Builder.
+		Select(scheduledTransferColumns...).
+		From("scheduled_transfers").
+		Where(squirrel.And{
+			squirrel.Eq{"status": entity.TransferStatusScheduled},
+			squirrel.Or{
+				squirrel.Eq{"sender": walletUUID},
+				squirrel.Eq{"receiver": walletUUID},
+			},
+		}).
+		OrderBy("execute_at DESC").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ScheduledTransferRepo.GetWalletScheduledTransfers - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var transfers []entity.ScheduledTransfer
+	for rows.Next() {
+		transfer, err := scanScheduledTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ScheduledTransferRepo.GetWalletScheduledTransfers - rows.Scan: %v", err)
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers, nil
+}
+
+// CancelScheduledTransfer cancels a transfer whose delay has not passed yet,
+// an executed or already due transfer returns repoerrors.ErrNotFound
+func (r *ScheduledTransferRepo) CancelScheduledTransfer(ctx context.Context, transferUUID uuid.UUID) error {
+	sql, args, _ := r.Builder.
+		Update("scheduled_transfers").
+		Set("status", entity.TransferStatusCancelled).
+		Set("resolved_at", squirrel.Expr("now()")).
+		Where(squirrel.And{
+			squirrel.Eq{"uuid": transferUUID},
+			squirrel.Eq{"status": entity.TransferStatusScheduled},
+			squirrel.Expr("execute_at > now()"),
+		}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("ScheduledTransferRepo.CancelScheduledTransfer - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *ScheduledTransferRepo) GetDueScheduledTransfers(ctx context.Context, now time.Time) ([]uuid.UUID, error) {
+	sql, args, _ := r.Builder.
+		Select("uuid").
+		From("scheduled_transfers").
+		Where(squirrel.And{
+			squirrel.Eq{"status": entity.TransferStatusScheduled},
+			squirrel.LtOrEq{"execute_at": now},
+		}).
+		OrderBy("execute_at").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ScheduledTransferRepo.GetDueScheduledTransfers - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var transfers []uuid.UUID
+	for rows.Next() {
+		var transferUUID uuid.UUID
+		if err = rows.Scan(&transferUUID); err != nil {
+			return nil, fmt.Errorf("ScheduledTransferRepo.GetDueScheduledTransfers - rows.Scan: %v", err)
+		}
+		transfers = append(transfers, transferUUID)
+	}
+
+	return transfers, nil
+}
+
+// ExecuteScheduledTransfer moves the money of a due transfer and marks it executed in one transaction.
+// A transfer that is no longer scheduled returns repoerrors.ErrNotFound, so concurrent workers execute it once.
+func (r *ScheduledTransferRepo) ExecuteScheduledTransfer(ctx context.Context, transferUUID uuid.UUID) (entity.ScheduledTransfer, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return entity.ScheduledTransfer{}, fmt.Errorf("ScheduledTransferRepo.ExecuteScheduledTransfer - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Select(scheduledTransferColumns...).
+		From("scheduled_transfers").
+		Where(squirrel.And{
+			squirrel.Eq{"uuid": transferUUID},
+			squirrel.Eq{"status": entity.TransferStatusScheduled},
+			squirrel.Expr("execute_at <= now()"),
+		}).
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	scheduled, err := scanScheduledTransfer(tx.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.ScheduledTransfer{}, repoerrors.ErrNotFound
+		}
+		return entity.ScheduledTransfer{}, fmt.Errorf("ScheduledTransferRepo.ExecuteScheduledTransfer - tx.QueryRow: %v", err)
+	}
+
+	if err = transfer(ctx, tx, r.Builder, scheduled.Sender, scheduled.Receiver, scheduled.Amount); err != nil {
+		return entity.ScheduledTransfer{}, err
+	}
+
+	if err = r.resolve(ctx, tx, transferUUID, entity.TransferStatusExecuted); err != nil {
+		return entity.ScheduledTransfer{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return entity.ScheduledTransfer{}, fmt.Errorf("ScheduledTransferRepo.ExecuteScheduledTransfer - tx.Commit: %v", err)
+	}
+
+	scheduled.Status = entity.TransferStatusExecuted
+	return scheduled, nil
+}
+
+// FailScheduledTransfer marks a scheduled transfer that can not be executed
+func (r *ScheduledTransferRepo) FailScheduledTransfer(ctx context.Context, transferUUID uuid.UUID) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ScheduledTransferRepo.FailScheduledTransfer - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err = r.resolve(ctx, tx, transferUUID, entity.TransferStatusFailed); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ScheduledTransferRepo.FailScheduledTransfer - tx.Commit: %v", err)
+	}
+
+	return nil
+}
+
+func (r *ScheduledTransferRepo) resolve(ctx context.Context, tx pgx.Tx, transferUUID uuid.UUID, status string) error {
+	sql, args, _ := r.Builder.
+		Update("scheduled_transfers").
+		Set("status", status).
+		Set("resolved_at", squirrel.Expr("now()")).
+		Where(squirrel.And{
+			squirrel.Eq{"uuid": transferUUID},
+			squirrel.Eq{"status": entity.TransferStatusScheduled},
+		}).
+		ToSql()
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("ScheduledTransferRepo.resolve - tx.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"infotecs_trainee_task/internal/entity"
 	"infotecs_trainee_task/internal/repo/repoerrors"
 	"infotecs_trainee_task/pkg/postgres"
+	"time"
 )
 
 type TransactionRepo struct {
@@ -41,9 +43,43 @@ func (r *TransactionRepo) CreateTransaction(ctx context.Context, transaction ent
 	return nil
 }
 
+var transactionColumns = []string{"uuid", "sender", "receiver", "created_at", "amount", "invoice"}
+
+func scanTransaction(row pgx.Row) (entity.Transaction, error) {
+	var transaction entity.Transaction
+	err := row.Scan(
+		&transaction.UUID,
+		&transaction.Sender,
+		&transaction.Receiver,
+		&transaction.CreatedAt,
+		&transaction.Amount,
+		&transaction.Invoice,
+	)
+	transaction.Status = entity.TransferStatusExecuted
+	return transaction, err
+}
+
+func (r *TransactionRepo) GetTransactionById(ctx context.Context, transactionUUID uuid.UUID) (entity.Transaction, error) {
+	sql, args, _ := r.Builder.
+		Select(transactionColumns...).
+		From("transactions").
+		Where(squirrel.Eq{"uuid": transactionUUID}).
+		ToSql()
+
+	transaction, err := scanTransaction(r.Pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Transaction{}, repoerrors.ErrNotFound
+		}
+		return entity.Transaction{}, fmt.Errorf("TransactionRepo.GetTransactionById - r.Pool.QueryRow: %v", err)
+	}
+
+	return transaction, nil
+}
+
 func (r *TransactionRepo) GetWalletHistory(ctx context.Context, uuid uuid.UUID) ([]entity.Transaction, error) {
 	sql, args, _ := r.Builder.
-		Select("sender", "receiver", "created_at", "amount").
+		Select(transactionColumns...).
 		From("transactions").
 		Where(squirrel.Or{
 			squirrel.Eq{"sender": uuid},
@@ -59,8 +95,8 @@ func (r *TransactionRepo) GetWalletHistory(ctx context.Context, uuid uuid.UUID)
 
 	var transactions []entity.Transaction
 	for rows.Next() {
-		var transaction entity.Transaction
-		if err = rows.Scan(&transaction.Sender, &transaction.Receiver, &transaction.CreatedAt, &transaction.Amount); err != nil {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
 			return nil, fmt.Errorf("TransactionRepo.GetWalletHistory - rows.Scan: %v", err)
 		}
 		transactions = append(transactions, transaction)
@@ -68,3 +104,51 @@ func (r *TransactionRepo) GetWalletHistory(ctx context.Context, uuid uuid.UUID)
 
 	return transactions, nil
 }
+
+// GetCounterparties ranks wallets the wallet sent money to or received money from.
+// Every transfer adds exp(-age / decay) to the score, so both frequent and recent counterparties come first.
+func (r *TransactionRepo) GetCounterparties(
+	ctx context.Context,
+	walletUUID uuid.UUID,
+	exclude []uuid.UUID,
+	decay time.Duration,
+	limit uint64,
+) ([]entity.Counterparty, error) {
+	transfers := r.Builder.
+		Select("receiver AS counterparty", "created_at").
+		From("transactions").
+		Where(squirrel.Eq{"sender": walletUUID}).
+		Suffix("UNION ALL").
+		SuffixExpr(r.Builder.
+			Select("sender", "created_at").
+			From("transactions").
+			Where(squirrel.Eq{"receiver": walletUUID}))
+
+	sql, args, _ := r.Builder.
+		Select("counterparty", "count(*)", "max(created_at)").
+		Column(squirrel.Expr("sum(exp(-extract(epoch FROM now() - created_at) / ?))", decay.Seconds())).
+		FromSelect(transfers, "t").
+		Where(squirrel.NotEq{"counterparty": append([]uuid.UUID{walletUUID}, exclude...)}).
+		GroupBy("counterparty").
+		OrderBy("4 DESC", "3 DESC").
+		Limit(limit).
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("TransactionRepo.GetCounterparties - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var counterparties []entity.Counterparty
+	for rows.Next() {
+		var counterparty entity.Counterparty
+		err = rows.Scan(&counterparty.Wallet, &counterparty.Transfers, &counterparty.LastAt, &counterparty.Score)
+		if err != nil {
+			return nil, fmt.Errorf("TransactionRepo.GetCounterparties - rows.Scan: %v", err)
+		}
+		counterparties = append(counterparties, counterparty)
+	}
+
+	return counterparties, nil
+}
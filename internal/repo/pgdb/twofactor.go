@@ -0,0 +1,268 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+)
+
+type TwoFactorRepo struct {
+	*postgres.Postgres
+}
+
+func NewTwoFactorRepo(pg *postgres.Postgres) *TwoFactorRepo {
+	return &TwoFactorRepo{pg}
+}
+
+// SetTOTPSecret starts or restarts an enrolment, repoerrors.ErrAlreadyExist means the user has confirmed one already
+func (r *TwoFactorRepo) SetTOTPSecret(ctx context.Context, owner uuid.UUID, secret string) error {
+	sql, args, _ := r.Builder.
+		Insert("user_totp").
+		Columns("owner", "secret").
+		Values(owner, secret).
+		Suffix("ON CONFLICT (owner) DO UPDATE SET secret = excluded.secret, last_counter = 0, created_at = now() " +
+			"WHERE user_totp.confirmed_at IS NULL").
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23503" {
+				return repoerrors.ErrNotFound
+			}
+		}
+		return fmt.Errorf("TwoFactorRepo.SetTOTPSecret - r.Pool.Exec: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrAlreadyExist
+	}
+
+	return nil
+}
+
+func (r *TwoFactorRepo) GetTOTP(ctx context.Context, owner uuid.UUID) (entity.TOTP, error) {
+	sql, args, _ := r.Builder.
+		Select(
+			"owner", "secret", "confirmed_at", "last_counter", "transfer_threshold", "created_at",
+			"(SELECT count(*) FROM recovery_codes c WHERE c.owner = user_totp.owner AND c.used_at IS NULL)",
+		).
+		From("user_totp").
+		Where(squirrel.Eq{"owner": owner}).
+		ToSql()
+
+	var totp entity.TOTP
+	err := r.Pool.QueryRow(ctx, sql, args...).Scan(
+		&totp.Owner,
+		&totp.Secret,
+		&totp.ConfirmedAt,
+		&totp.LastCounter,
+		&totp.TransferThreshold,
+		&totp.CreatedAt,
+		&totp.RecoveryCodesLeft,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.TOTP{}, repoerrors.ErrNotFound
+		}
+		return entity.TOTP{}, fmt.Errorf("TwoFactorRepo.GetTOTP - r.Pool.QueryRow: %v", err)
+	}
+
+	return totp, nil
+}
+
+// ConfirmTOTP enables two-factor authentication with the first accepted time step and the recovery codes
+func (r *TwoFactorRepo) ConfirmTOTP(ctx context.Context, owner uuid.UUID, counter int64, codeHashes []string) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("TwoFactorRepo.ConfirmTOTP - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Update("user_totp").
+		Set("confirmed_at", squirrel.Expr("now()")).
+		Set("last_counter", counter).
+		Where(squirrel.And{
+			squirrel.Eq{"owner": owner},
+			squirrel.Eq{"confirmed_at": nil},
+		}).
+		ToSql()
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("TwoFactorRepo.ConfirmTOTP - tx.Exec: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	if err = r.replaceRecoveryCodes(ctx, tx, owner, codeHashes); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("TwoFactorRepo.ConfirmTOTP - tx.Commit: %v", err)
+	}
+
+	return nil
+}
+
+// UseTOTPCounter accepts the time step once, an older or the same step returns repoerrors.ErrAlreadyExist
+func (r *TwoFactorRepo) UseTOTPCounter(ctx context.Context, owner uuid.UUID, counter int64) error {
+	sql, args, _ := r.Builder.
+		Update("user_totp").
+		Set("last_counter", counter).
+		Where(squirrel.And{
+			squirrel.Eq{"owner": owner},
+			squirrel.Lt{"last_counter": counter},
+		}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("TwoFactorRepo.UseTOTPCounter - r.Pool.Exec: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrAlreadyExist
+	}
+
+	return nil
+}
+
+// UseRecoveryCode spends the code, an unknown or used code returns repoerrors.ErrNotFound
+func (r *TwoFactorRepo) UseRecoveryCode(ctx context.Context, owner uuid.UUID, codeHash string) error {
+	sql, args, _ := r.Builder.
+		Update("recovery_codes").
+		Set("used_at", squirrel.Expr("now()")).
+		Where(squirrel.And{
+			squirrel.Eq{"owner": owner},
+			squirrel.Eq{"code_hash": codeHash},
+			squirrel.Eq{"used_at": nil},
+		}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("TwoFactorRepo.UseRecoveryCode - r.Pool.Exec: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *TwoFactorRepo) ReplaceRecoveryCodes(ctx context.Context, owner uuid.UUID, codeHashes []string) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("TwoFactorRepo.ReplaceRecoveryCodes - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err = r.replaceRecoveryCodes(ctx, tx, owner, codeHashes); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("TwoFactorRepo.ReplaceRecoveryCodes - tx.Commit: %v", err)
+	}
+
+	return nil
+}
+
+func (r *TwoFactorRepo) SetTransferThreshold(ctx context.Context, owner uuid.UUID, threshold *float64) error {
+	sql, args, _ := r.Builder.
+		Update("user_totp").
+		Set("transfer_threshold", threshold).
+		Where(squirrel.And{
+			squirrel.Eq{"owner": owner},
+			squirrel.NotEq{"confirmed_at": nil},
+		}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("TwoFactorRepo.SetTransferThreshold - r.Pool.Exec: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteTOTP disables two-factor authentication and drops the recovery codes
+func (r *TwoFactorRepo) DeleteTOTP(ctx context.Context, owner uuid.UUID) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("TwoFactorRepo.DeleteTOTP - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Delete("recovery_codes").
+		Where(squirrel.Eq{"owner": owner}).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("TwoFactorRepo.DeleteTOTP - tx.Exec: %v", err)
+	}
+
+	sql, args, _ = r.Builder.
+		Delete("user_totp").
+		Where(squirrel.Eq{"owner": owner}).
+		ToSql()
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("TwoFactorRepo.DeleteTOTP - tx.Exec: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("TwoFactorRepo.DeleteTOTP - tx.Commit: %v", err)
+	}
+
+	return nil
+}
+
+func (r *TwoFactorRepo) replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, owner uuid.UUID, codeHashes []string) error {
+	sql, args, _ := r.Builder.
+		Delete("recovery_codes").
+		Where(squirrel.Eq{"owner": owner}).
+		ToSql()
+
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("TwoFactorRepo.replaceRecoveryCodes - tx.Exec: %v", err)
+	}
+
+	insert := r.Builder.
+		Insert("recovery_codes").
+		Columns("owner", "code_hash")
+	for _, codeHash := range codeHashes {
+		insert = insert.Values(owner, codeHash)
+	}
+
+	sql, args, _ = insert.ToSql()
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("TwoFactorRepo.replaceRecoveryCodes - tx.Exec: %v", err)
+	}
+
+	return nil
+}
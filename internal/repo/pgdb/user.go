@@ -11,6 +11,7 @@ import (
 	"infotecs_trainee_task/internal/entity"
 	"infotecs_trainee_task/internal/repo/repoerrors"
 	"infotecs_trainee_task/pkg/postgres"
+	"time"
 )
 
 type UserRepo struct {
@@ -44,17 +45,31 @@ func (r *UserRepo) CreateUser(ctx context.Context, user entity.User) (uuid.UUID,
 	return userUUID, nil
 }
 
-// GetUserById To delete may be unused
+var userColumns = []string{"uuid", "username", "password", "role", "display_name", "created_at", "deleted_at"}
+
+func scanUser(row pgx.Row) (entity.User, error) {
+	var user entity.User
+	err := row.Scan(
+		&user.UUID,
+		&user.Username,
+		&user.Password,
+		&user.Role,
+		&user.DisplayName,
+		&user.CreatedAt,
+		&user.DeletedAt,
+	)
+	return user, err
+}
+
+// GetUserById does not find deleted users
 func (r *UserRepo) GetUserById(ctx context.Context, uuid uuid.UUID) (entity.User, error) {
 	sql, args, _ := r.Builder.
-		Select("uuid", "username", "password", "created_at").
+		Select(userColumns...).
 		From("users").
-		Where("uuid = ?", uuid).
+		Where(squirrel.Eq{"uuid": uuid, "deleted_at": nil}).
 		ToSql()
 
-	var user entity.User
-	err := r.Pool.QueryRow(ctx, sql, args...).Scan(&user)
-
+	user, err := scanUser(r.Pool.QueryRow(ctx, sql, args...))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return entity.User{}, repoerrors.ErrNotFound
@@ -65,17 +80,14 @@ func (r *UserRepo) GetUserById(ctx context.Context, uuid uuid.UUID) (entity.User
 	return user, nil
 }
 
-// GetUserByUsername To delete may be unused
 func (r *UserRepo) GetUserByUsername(ctx context.Context, username string) (entity.User, error) {
 	sql, args, _ := r.Builder.
-		Select("uuid", "username", "password", "created_at").
+		Select(userColumns...).
 		From("users").
-		Where("username = ?", username).
+		Where(squirrel.Eq{"username": username, "deleted_at": nil}).
 		ToSql()
 
-	var user entity.User
-	err := r.Pool.QueryRow(ctx, sql, args...).Scan(&user)
-
+	user, err := scanUser(r.Pool.QueryRow(ctx, sql, args...))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return entity.User{}, repoerrors.ErrNotFound
@@ -86,31 +98,206 @@ func (r *UserRepo) GetUserByUsername(ctx context.Context, username string) (enti
 	return user, nil
 }
 
-func (r *UserRepo) GetUserByUsernameAndPassword(ctx context.Context, username, password string) (entity.User, error) {
+// UpdateProfile sets the username and the display name, a taken username returns repoerrors.ErrAlreadyExist
+func (r *UserRepo) UpdateProfile(ctx context.Context, user entity.User) error {
 	sql, args, _ := r.Builder.
-		Select("uuid", "username", "password", "created_at").
-		From("users").
-		Where(
-			squirrel.And{
-				squirrel.Eq{"username": username},
-				squirrel.Eq{"password": password},
+		Update("users").
+		Set("username", user.Username).
+		Set("display_name", user.DisplayName).
+		Where(squirrel.Eq{"uuid": user.UUID, "deleted_at": nil}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23505" {
+				return repoerrors.ErrAlreadyExist
+			}
+		}
+		return fmt.Errorf("UserRepo.UpdateProfile - r.Pool.Exec: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteUser anonymizes the user, drops their memberships, aliases, contacts, two-factor secrets and API keys
+// and revokes their access tokens issued before the moment and all refresh tokens.
+// Wallets the user owns alone are closed: their other members and aliases are dropped and they stop receiving money,
+// their history stays with the counterparties. Such a wallet must be empty and have no held escrows, pending or
+// scheduled transfers, active vouchers, open disputes or unpaid interest, otherwise repoerrors.ErrUnavailable is returned.
+func (r *UserRepo) DeleteUser(ctx context.Context, userUUID uuid.UUID, before time.Time) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("UserRepo.DeleteUser - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Select("w.uuid").
+		From("wallets w").
+		Join("wallet_members m ON m.wallet = w.uuid").
+		Where(squirrel.Eq{
+			"m.member":    userUUID,
+			"m.role":      entity.WalletRoleOwner,
+			"m.status":    entity.MemberStatusActive,
+			"w.closed_at": nil,
+		}).
+		Where(squirrel.Expr("NOT EXISTS (SELECT 1 FROM wallet_members o "+
+			"WHERE o.wallet = w.uuid AND o.member <> ? AND o.role = ? AND o.status = ?)",
+			userUUID, entity.WalletRoleOwner, entity.MemberStatusActive)).
+		Suffix("FOR UPDATE OF w").
+		ToSql()
+
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("UserRepo.DeleteUser - tx.Query: %v", err)
+	}
+	var wallets []uuid.UUID
+	for rows.Next() {
+		var wallet uuid.UUID
+		if err = rows.Scan(&wallet); err != nil {
+			rows.Close()
+			return fmt.Errorf("UserRepo.DeleteUser - rows.Scan: %v", err)
+		}
+		wallets = append(wallets, wallet)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("UserRepo.DeleteUser - rows.Err: %v", err)
+	}
+
+	if len(wallets) > 0 {
+		sql, args, _ = r.Builder.
+			Select("1").
+			From("wallets w").
+			Where(squirrel.Eq{"w.uuid": wallets}).
+			Where(squirrel.Or{
+				squirrel.NotEq{"w.balance": 0},
+				squirrel.Expr("EXISTS (SELECT 1 FROM wallet_pockets p WHERE p.wallet = w.uuid AND p.balance <> 0)"),
+				squirrel.Expr("EXISTS (SELECT 1 FROM escrows e "+
+					"WHERE (e.buyer = w.uuid OR e.seller = w.uuid) AND e.status = ?)", entity.EscrowStatusHeld),
+				squirrel.Expr("EXISTS (SELECT 1 FROM pending_transfers t "+
+					"WHERE (t.sender = w.uuid OR t.receiver = w.uuid) AND t.status = ?)", entity.TransferStatusPending),
+				squirrel.Expr("EXISTS (SELECT 1 FROM scheduled_transfers t "+
+					"WHERE (t.sender = w.uuid OR t.receiver = w.uuid) AND t.status = ?)", entity.TransferStatusScheduled),
+				squirrel.Expr("EXISTS (SELECT 1 FROM vouchers v WHERE v.wallet = w.uuid AND v.status = ?)",
+					entity.VoucherStatusActive),
+				squirrel.Expr("EXISTS (SELECT 1 FROM disputes d "+
+					"WHERE (d.payer = w.uuid OR d.receiver = w.uuid) AND d.status = ?)", entity.DisputeStatusOpen),
+				squirrel.Expr("EXISTS (SELECT 1 FROM interest_accruals a WHERE a.wallet = w.uuid AND a.paid_at IS NULL)"),
 			}).
+			Limit(1).
+			ToSql()
+
+		var busy int
+		err = tx.QueryRow(ctx, sql, args...).Scan(&busy)
+		if err == nil {
+			return repoerrors.ErrUnavailable
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("UserRepo.DeleteUser - tx.QueryRow: %v", err)
+		}
+
+		closing := []squirrel.Sqlizer{
+			r.Builder.Update("wallets").Set("closed_at", squirrel.Expr("now()")).Where(squirrel.Eq{"uuid": wallets}),
+			r.Builder.Delete("wallet_members").Where(squirrel.Eq{"wallet": wallets}),
+			r.Builder.Delete("wallet_aliases").Where(squirrel.Eq{"wallet": wallets}),
+			r.Builder.Update("users").Set("default_wallet", nil).Where(squirrel.Eq{"default_wallet": wallets}),
+		}
+		for _, statement := range closing {
+			sql, args, _ = statement.ToSql()
+			if _, err = tx.Exec(ctx, sql, args...); err != nil {
+				return fmt.Errorf("UserRepo.DeleteUser - tx.Exec: %v", err)
+			}
+		}
+	}
+
+	statements := []squirrel.Sqlizer{
+		r.Builder.Delete("wallet_members").Where(squirrel.Eq{"member": userUUID}),
+		r.Builder.Delete("wallet_aliases").Where(squirrel.Eq{"owner": userUUID}),
+		r.Builder.Delete("contacts").Where(squirrel.Eq{"owner": userUUID}),
+		r.Builder.Delete("recovery_codes").Where(squirrel.Eq{"owner": userUUID}),
+		r.Builder.Delete("user_totp").Where(squirrel.Eq{"owner": userUUID}),
+		r.Builder.
+			Update("api_keys").
+			Set("revoked_at", squirrel.Expr("now()")).
+			Where(squirrel.Eq{"owner": userUUID, "revoked_at": nil}),
+	}
+	for _, statement := range statements {
+		sql, args, _ = statement.ToSql()
+		if _, err = tx.Exec(ctx, sql, args...); err != nil {
+			return fmt.Errorf("UserRepo.DeleteUser - tx.Exec: %v", err)
+		}
+	}
+
+	if err = revokeUserTokens(ctx, tx, r.Builder, userUUID, before); err != nil {
+		return err
+	}
+
+	sql, args, _ = r.Builder.
+		Update("users").
+		Set("username", squirrel.Expr("'deleted-' || uuid")).
+		Set("password", "").
+		Set("display_name", nil).
+		Set("default_wallet", nil).
+		Set("deleted_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"uuid": userUUID, "deleted_at": nil}).
 		ToSql()
 
-	var user entity.User
-	err := r.Pool.QueryRow(ctx, sql, args...).Scan(
-		&user.UUID,
-		&user.Username,
-		&user.Password,
-		&user.CreatedAt,
-	)
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("UserRepo.DeleteUser - tx.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("UserRepo.DeleteUser - tx.Commit: %v", err)
+	}
+
+	return nil
+}
+
+func (r *UserRepo) UpdatePassword(ctx context.Context, uuid uuid.UUID, password string) error {
+	sql, args, _ := r.Builder.
+		Update("users").
+		Set("password", password).
+		Where(squirrel.Eq{"uuid": uuid}).
+		ToSql()
+
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("UserRepo.UpdatePassword - r.Pool.Exec: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *UserRepo) GetUserRole(ctx context.Context, uuid uuid.UUID) (string, error) {
+	sql, args, _ := r.Builder.
+		Select("role").
+		From("users").
+		Where(squirrel.Eq{"uuid": uuid}).
+		ToSql()
+
+	var role string
+	err := r.Pool.QueryRow(ctx, sql, args...).Scan(&role)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return entity.User{}, repoerrors.ErrNotFound
+			return "", repoerrors.ErrNotFound
 		}
-		return entity.User{}, fmt.Errorf("UserRepo.GetUserByUsername - r.Pool.QueryRow: %v", err)
+		return "", fmt.Errorf("UserRepo.GetUserRole - r.Pool.QueryRow: %v", err)
 	}
 
-	return user, nil
+	return role, nil
 }
@@ -0,0 +1,269 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/postgres"
+	"time"
+)
+
+type VoucherRepo struct {
+	*postgres.Postgres
+}
+
+func NewVoucherRepo(pg *postgres.Postgres) *VoucherRepo {
+	return &VoucherRepo{pg}
+}
+
+var voucherColumns = []string{
+	"uuid", "code_hash", "issuer", "wallet", "amount", "currency",
+	"max_redemptions", "redemptions", "status", "expires_at", "created_at",
+}
+
+func scanVoucher(row pgx.Row) (entity.Voucher, error) {
+	var voucher entity.Voucher
+	err := row.Scan(
+		&voucher.UUID,
+		&voucher.CodeHash,
+		&voucher.Issuer,
+		&voucher.Wallet,
+		&voucher.Amount,
+		&voucher.Currency,
+		&voucher.MaxRedemptions,
+		&voucher.Redemptions,
+		&voucher.Status,
+		&voucher.ExpiresAt,
+		&voucher.CreatedAt,
+	)
+	return voucher, err
+}
+
+// CreateVoucher moves amount for every redemption from the issuing wallet into the holding wallet
+// and registers the voucher in one transaction
+func (r *VoucherRepo) CreateVoucher(ctx context.Context, voucher entity.Voucher, holdingWallet uuid.UUID) (uuid.UUID, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("VoucherRepo.CreateVoucher - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	funding := voucher.Amount * float64(voucher.MaxRedemptions)
+	if err = transfer(ctx, tx, r.Builder, voucher.Wallet, holdingWallet, funding); err != nil {
+		return uuid.Nil, err
+	}
+
+	sql, args, _ := r.Builder.
+		Insert("vouchers").
+		Columns("code_hash", "issuer", "wallet", "amount", "currency", "max_redemptions", "status", "expires_at").
+		Values(
+			voucher.CodeHash,
+			voucher.Issuer,
+			voucher.Wallet,
+			voucher.Amount,
+			voucher.Currency,
+			voucher.MaxRedemptions,
+			entity.VoucherStatusActive,
+			voucher.ExpiresAt,
+		).
+		Suffix("RETURNING uuid").
+		ToSql()
+
+	var voucherUUID uuid.UUID
+	if err = tx.QueryRow(ctx, sql, args...).Scan(&voucherUUID); err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23505" {
+				return uuid.Nil, repoerrors.ErrAlreadyExist
+			}
+		}
+		return uuid.Nil, fmt.Errorf("VoucherRepo.CreateVoucher - tx.QueryRow: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("VoucherRepo.CreateVoucher - tx.Commit: %v", err)
+	}
+
+	return voucherUUID, nil
+}
+
+func (r *VoucherRepo) GetVoucherById(ctx context.Context, voucherUUID uuid.UUID) (entity.Voucher, error) {
+	sql, args, _ := r.Builder.
+		Select(voucherColumns...).
+		From("vouchers").
+		Where(squirrel.Eq{"uuid": voucherUUID}).
+		ToSql()
+
+	voucher, err := scanVoucher(r.Pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Voucher{}, repoerrors.ErrNotFound
+		}
+		return entity.Voucher{}, fmt.Errorf("VoucherRepo.GetVoucherById - r.Pool.QueryRow: %v", err)
+	}
+
+	return voucher, nil
+}
+
+func (r *VoucherRepo) GetIssuerVouchers(ctx context.Context, issuer uuid.UUID) ([]entity.Voucher, error) {
+	sql, args, _ := r.Builder.
+		Select(voucherColumns...).
+		From("vouchers").
+		Where(squirrel.Eq{"issuer": issuer}).
+		OrderBy("created_at DESC").
+		ToSql()
+
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("VoucherRepo.GetIssuerVouchers - r.Pool.Query: %v", err)
+	}
+	defer rows.Close()
+
+	var vouchers []entity.Voucher
+	for rows.Next() {
+		voucher, err := scanVoucher(rows)
+		if err != nil {
+			return nil, fmt.Errorf("VoucherRepo.GetIssuerVouchers - rows.Scan: %v", err)
+		}
+		vouchers = append(vouchers, voucher)
+	}
+
+	return vouchers, nil
+}
+
+// RedeemVoucher pays one redemption from the holding wallet into the wallet.
+// Voucher row is locked, so concurrent redemptions of one code are serialized and can not exceed the cap.
+// Unknown code returns repoerrors.ErrNotFound, a revoked, expired or exhausted one repoerrors.ErrUnavailable
+// and a second redemption by the same user repoerrors.ErrAlreadyExist.
+func (r *VoucherRepo) RedeemVoucher(
+	ctx context.Context,
+	codeHash string,
+	redeemer, walletUUID, holdingWallet uuid.UUID,
+) (entity.Voucher, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return entity.Voucher{}, fmt.Errorf("VoucherRepo.RedeemVoucher - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Select(voucherColumns...).
+		From("vouchers").
+		Where(squirrel.Eq{"code_hash": codeHash}).
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	voucher, err := scanVoucher(tx.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Voucher{}, repoerrors.ErrNotFound
+		}
+		return entity.Voucher{}, fmt.Errorf("VoucherRepo.RedeemVoucher - tx.QueryRow: %v", err)
+	}
+
+	if voucher.Status != entity.VoucherStatusActive ||
+		voucher.ExpiresAt != nil && !voucher.ExpiresAt.After(time.Now()) ||
+		voucher.Redemptions >= voucher.MaxRedemptions {
+		return entity.Voucher{}, repoerrors.ErrUnavailable
+	}
+
+	sql, args, _ = r.Builder.
+		Insert("voucher_redemptions").
+		Columns("voucher", "redeemed_by", "wallet").
+		Values(voucher.UUID, redeemer, walletUUID).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		var pgErr *pgconn.PgError
+		if ok := errors.As(err, &pgErr); ok {
+			if pgErr.Code == "23505" {
+				return entity.Voucher{}, repoerrors.ErrAlreadyExist
+			}
+			if pgErr.Code == "23503" {
+				return entity.Voucher{}, repoerrors.ErrNotFound
+			}
+		}
+		return entity.Voucher{}, fmt.Errorf("VoucherRepo.RedeemVoucher - tx.Exec: %v", err)
+	}
+
+	if err = transfer(ctx, tx, r.Builder, holdingWallet, walletUUID, voucher.Amount); err != nil {
+		return entity.Voucher{}, err
+	}
+
+	voucher.Redemptions++
+	if voucher.Redemptions == voucher.MaxRedemptions {
+		voucher.Status = entity.VoucherStatusRedeemed
+	}
+
+	sql, args, _ = r.Builder.
+		Update("vouchers").
+		Set("redemptions", voucher.Redemptions).
+		Set("status", voucher.Status).
+		Where(squirrel.Eq{"uuid": voucher.UUID}).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return entity.Voucher{}, fmt.Errorf("VoucherRepo.RedeemVoucher - tx.Exec: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return entity.Voucher{}, fmt.Errorf("VoucherRepo.RedeemVoucher - tx.Commit: %v", err)
+	}
+
+	return voucher, nil
+}
+
+// RevokeVoucher returns the unredeemed rest from the holding wallet to the issuing wallet.
+// Only an active voucher can be revoked, otherwise repoerrors.ErrNotFound is returned.
+func (r *VoucherRepo) RevokeVoucher(ctx context.Context, voucherUUID, holdingWallet uuid.UUID) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("VoucherRepo.RevokeVoucher - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	sql, args, _ := r.Builder.
+		Select(voucherColumns...).
+		From("vouchers").
+		Where(squirrel.Eq{
+			"uuid":   voucherUUID,
+			"status": entity.VoucherStatusActive,
+		}).
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	voucher, err := scanVoucher(tx.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return repoerrors.ErrNotFound
+		}
+		return fmt.Errorf("VoucherRepo.RevokeVoucher - tx.QueryRow: %v", err)
+	}
+
+	rest := voucher.Amount * float64(voucher.MaxRedemptions-voucher.Redemptions)
+	if err = transfer(ctx, tx, r.Builder, holdingWallet, voucher.Wallet, rest); err != nil {
+		return err
+	}
+
+	sql, args, _ = r.Builder.
+		Update("vouchers").
+		Set("status", entity.VoucherStatusRevoked).
+		Where(squirrel.Eq{"uuid": voucherUUID}).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("VoucherRepo.RevokeVoucher - tx.Exec: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("VoucherRepo.RevokeVoucher - tx.Commit: %v", err)
+	}
+
+	return nil
+}
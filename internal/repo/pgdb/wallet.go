@@ -11,8 +11,12 @@ import (
 	"infotecs_trainee_task/internal/entity"
 	"infotecs_trainee_task/internal/repo/repoerrors"
 	"infotecs_trainee_task/pkg/postgres"
+	"math"
 )
 
+// balanceEpsilon absorbs float rounding when comparing sums of balances
+const balanceEpsilon = 1e-6
+
 type WalletRepo struct {
 	*postgres.Postgres
 }
@@ -21,16 +25,23 @@ func NewWalletRepo(pg *postgres.Postgres) *WalletRepo {
 	return &WalletRepo{pg}
 }
 
-func (r *WalletRepo) CreateWallet(ctx context.Context, wallet entity.Wallet) (uuid.UUID, error) {
+// CreateWallet creates the wallet and makes owner its first member
+func (r *WalletRepo) CreateWallet(ctx context.Context, wallet entity.Wallet, owner uuid.UUID) (uuid.UUID, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("WalletRepo.CreateWallet - r.Pool.Begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
 	sql, args, _ := r.Builder.
 		Insert("wallets").
-		Columns("balance").
-		Values(wallet.Balance).
+		Columns("balance", "parent").
+		Values(wallet.Balance, wallet.Parent).
 		Suffix("RETURNING uuid").
 		ToSql()
 
 	var walletUUID uuid.UUID
-	err := r.Pool.QueryRow(ctx, sql, args...).Scan(&walletUUID)
+	err = tx.QueryRow(ctx, sql, args...).Scan(&walletUUID)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -39,7 +50,21 @@ func (r *WalletRepo) CreateWallet(ctx context.Context, wallet entity.Wallet) (uu
 				return uuid.Nil, repoerrors.ErrAlreadyExist
 			}
 		}
-		return uuid.Nil, fmt.Errorf("WalletRepo.CreateWallet - r.Pool.QueryRow: %v", err)
+		return uuid.Nil, fmt.Errorf("WalletRepo.CreateWallet - tx.QueryRow: %v", err)
+	}
+
+	sql, args, _ = r.Builder.
+		Insert("wallet_members").
+		Columns("wallet", "member", "role", "status").
+		Values(walletUUID, owner, entity.WalletRoleOwner, entity.MemberStatusActive).
+		ToSql()
+
+	if _, err = tx.Exec(ctx, sql, args...); err != nil {
+		return uuid.Nil, fmt.Errorf("WalletRepo.CreateWallet - tx.Exec: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("WalletRepo.CreateWallet - tx.Commit: %v", err)
 	}
 
 	return walletUUID, nil
@@ -47,13 +72,21 @@ func (r *WalletRepo) CreateWallet(ctx context.Context, wallet entity.Wallet) (uu
 
 func (r *WalletRepo) GetWalletStateById(ctx context.Context, uuid uuid.UUID) (entity.Wallet, error) {
 	sql, args, _ := r.Builder.
-		Select("uuid", "balance").
+		Select("uuid", "balance", "parent", "credit_limit", "credit_rate", "transfer_delay_seconds").
 		From("wallets").
-		Where(squirrel.Eq{"uuid": uuid}).
+		Where(squirrel.Eq{"uuid": uuid, "closed_at": nil}).
 		ToSql()
 
 	var wallet entity.Wallet
-	err := r.Pool.QueryRow(ctx, sql, args...).Scan(&wallet.UUID, &wallet.Balance)
+	err := r.Pool.QueryRow(ctx, sql, args...).
+		Scan(
+			&wallet.UUID,
+			&wallet.Balance,
+			&wallet.Parent,
+			&wallet.CreditLimit,
+			&wallet.CreditRate,
+			&wallet.TransferDelaySeconds,
+		)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -65,6 +98,9 @@ func (r *WalletRepo) GetWalletStateById(ctx context.Context, uuid uuid.UUID) (en
 	return wallet, nil
 }
 
+// CashTransfer moves amount between the wallets and records it in the transactions ledger. The sender may use
+// its credit limit, a transfer beyond it returns repoerrors.ErrInsufficientFunds, a closed receiver
+// repoerrors.ErrNotFound and a transfer above the spend limit of a child wallet repoerrors.ErrLimitExceeded.
 func (r *WalletRepo) CashTransfer(ctx context.Context, sender, receiver entity.Wallet, amount float64) error {
 	tx, err := r.Pool.Begin(ctx)
 	if err != nil {
@@ -72,32 +108,82 @@ func (r *WalletRepo) CashTransfer(ctx context.Context, sender, receiver entity.W
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
 
+	if err = checkChildLimit(ctx, tx, r.Builder, sender.UUID, receiver.UUID, amount); err != nil {
+		return err
+	}
+
+	if err = transfer(ctx, tx, r.Builder, sender.UUID, receiver.UUID, amount); err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("WalletRepo.CashTransfer - tx.Commit: %v\n", err)
+	}
+
+	return nil
+}
+
+func (r *WalletRepo) GetReconciliation(ctx context.Context, holdingWallet uuid.UUID) (entity.Reconciliation, error) {
 	sql, args, _ := r.Builder.
-		Update("wallets").
-		Set("balance", sender.Balance-amount).
-		Where(squirrel.Eq{"uuid": sender.UUID}).
+		Select("coalesce(sum(balance), 0)").
+		Column(squirrel.Expr("coalesce(sum(balance) filter (where uuid = ?), 0)", holdingWallet)).
+		From("wallets").
 		ToSql()
 
-	_, err = tx.Exec(ctx, sql, args...)
+	var reconciliation entity.Reconciliation
+	err := r.Pool.QueryRow(ctx, sql, args...).
+		Scan(&reconciliation.TotalBalance, &reconciliation.HoldingBalance)
 	if err != nil {
-		return fmt.Errorf("WalletRepo.CashTransfer - tx.Exec: %v", err)
+		return entity.Reconciliation{}, fmt.Errorf("WalletRepo.GetReconciliation - r.Pool.QueryRow: %v", err)
 	}
 
 	sql, args, _ = r.Builder.
-		Update("wallets").
-		Set("balance", receiver.Balance+amount).
-		Where(squirrel.Eq{"uuid": receiver.UUID}).
+		Select("coalesce(sum(balance), 0)").
+		From("wallet_pockets").
 		ToSql()
 
-	_, err = tx.Exec(ctx, sql, args...)
+	err = r.Pool.QueryRow(ctx, sql, args...).Scan(&reconciliation.PocketBalance)
 	if err != nil {
-		return fmt.Errorf("WalletRepo.CashTransfer - tx.Exec: %v", err)
+		return entity.Reconciliation{}, fmt.Errorf("WalletRepo.GetReconciliation - r.Pool.QueryRow: %v", err)
 	}
+	reconciliation.TotalBalance += reconciliation.PocketBalance
 
-	err = tx.Commit(ctx)
+	sql, args, _ = r.Builder.
+		Select("coalesce(sum(amount), 0)").
+		From("escrows").
+		Where(squirrel.Eq{"status": entity.EscrowStatusHeld}).
+		ToSql()
+
+	err = r.Pool.QueryRow(ctx, sql, args...).Scan(&reconciliation.EscrowHeld)
 	if err != nil {
-		return fmt.Errorf("WalletRepo.CashTransfer - tx.Commit: %v\n", err)
+		return entity.Reconciliation{}, fmt.Errorf("WalletRepo.GetReconciliation - r.Pool.QueryRow: %v", err)
 	}
 
-	return nil
+	sql, args, _ = r.Builder.
+		Select("coalesce(sum(amount * (max_redemptions - redemptions)), 0)").
+		From("vouchers").
+		Where(squirrel.Eq{"status": entity.VoucherStatusActive}).
+		ToSql()
+
+	err = r.Pool.QueryRow(ctx, sql, args...).Scan(&reconciliation.VoucherHeld)
+	if err != nil {
+		return entity.Reconciliation{}, fmt.Errorf("WalletRepo.GetReconciliation - r.Pool.QueryRow: %v", err)
+	}
+
+	sql, args, _ = r.Builder.
+		Select("coalesce(sum(held), 0)").
+		From("disputes").
+		Where(squirrel.Eq{"status": entity.DisputeStatusOpen}).
+		ToSql()
+
+	err = r.Pool.QueryRow(ctx, sql, args...).Scan(&reconciliation.DisputeHeld)
+	if err != nil {
+		return entity.Reconciliation{}, fmt.Errorf("WalletRepo.GetReconciliation - r.Pool.QueryRow: %v", err)
+	}
+
+	held := reconciliation.EscrowHeld + reconciliation.VoucherHeld + reconciliation.DisputeHeld
+	reconciliation.Balanced = math.Abs(reconciliation.HoldingBalance-held) < balanceEpsilon
+
+	return reconciliation, nil
 }
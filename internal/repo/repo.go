@@ -6,30 +6,263 @@ import (
 	"infotecs_trainee_task/internal/entity"
 	"infotecs_trainee_task/internal/repo/pgdb"
 	"infotecs_trainee_task/pkg/postgres"
+	"time"
 )
 
 type User interface {
 	CreateUser(ctx context.Context, user entity.User) (uuid.UUID, error)
 	GetUserById(ctx context.Context, uuid uuid.UUID) (entity.User, error)
 	GetUserByUsername(ctx context.Context, username string) (entity.User, error)
-	GetUserByUsernameAndPassword(ctx context.Context, username, password string) (entity.User, error)
+	UpdatePassword(ctx context.Context, uuid uuid.UUID, password string) error
+	GetUserRole(ctx context.Context, uuid uuid.UUID) (string, error)
+	UpdateProfile(ctx context.Context, user entity.User) error
+	DeleteUser(ctx context.Context, uuid uuid.UUID, before time.Time) error
 }
 
 type Wallet interface {
-	CreateWallet(ctx context.Context, wallet entity.Wallet) (uuid.UUID, error)
+	CreateWallet(ctx context.Context, wallet entity.Wallet, owner uuid.UUID) (uuid.UUID, error)
 	GetWalletStateById(ctx context.Context, uuid uuid.UUID) (entity.Wallet, error)
 	CashTransfer(ctx context.Context, sender, receiver entity.Wallet, amount float64) error
+	GetReconciliation(ctx context.Context, holdingWallet uuid.UUID) (entity.Reconciliation, error)
 }
 
 type Transaction interface {
 	CreateTransaction(ctx context.Context, transaction entity.Transaction) error
+	GetTransactionById(ctx context.Context, uuid uuid.UUID) (entity.Transaction, error)
 	GetWalletHistory(ctx context.Context, uuid uuid.UUID) ([]entity.Transaction, error)
+	GetCounterparties(
+		ctx context.Context,
+		walletUUID uuid.UUID,
+		exclude []uuid.UUID,
+		decay time.Duration,
+		limit uint64,
+	) ([]entity.Counterparty, error)
+}
+
+type Contact interface {
+	CreateContact(ctx context.Context, contact entity.Contact) (uuid.UUID, error)
+	GetContacts(ctx context.Context, owner uuid.UUID) ([]entity.Contact, error)
+	UpdateContact(ctx context.Context, contact entity.Contact) error
+	DeleteContact(ctx context.Context, contactUUID, owner uuid.UUID) error
+}
+
+type Alias interface {
+	CreateAlias(ctx context.Context, alias entity.Alias) error
+	GetAlias(ctx context.Context, alias string) (entity.Alias, error)
+	GetUserAliases(ctx context.Context, owner uuid.UUID) ([]entity.Alias, error)
+	DeleteAlias(ctx context.Context, alias string, owner uuid.UUID) error
+	SetDefaultWallet(ctx context.Context, userUUID uuid.UUID, wallet uuid.NullUUID) error
+	GetDefaultWallet(ctx context.Context, username string) (uuid.UUID, uuid.NullUUID, error)
+}
+
+type Member interface {
+	AddMember(ctx context.Context, member entity.WalletMember) error
+	GetMember(ctx context.Context, walletUUID, memberUUID uuid.UUID) (entity.WalletMember, error)
+	GetMembers(ctx context.Context, walletUUID uuid.UUID) ([]entity.WalletMember, error)
+	GetInvitations(ctx context.Context, memberUUID uuid.UUID) ([]entity.WalletMember, error)
+	GetMemberships(ctx context.Context, memberUUID uuid.UUID) ([]entity.WalletMember, error)
+	UpdateMember(ctx context.Context, member entity.WalletMember) error
+	DeleteMember(ctx context.Context, walletUUID, memberUUID uuid.UUID) error
+	ActivateMember(ctx context.Context, walletUUID, memberUUID uuid.UUID) error
+}
+
+type Pocket interface {
+	CreatePocket(ctx context.Context, pocket entity.Pocket) (uuid.UUID, error)
+	GetPocketById(ctx context.Context, uuid uuid.UUID) (entity.Pocket, error)
+	GetWalletPockets(ctx context.Context, walletUUID uuid.UUID) ([]entity.Pocket, error)
+	UpdatePocket(ctx context.Context, pocket entity.Pocket) error
+	DeletePocket(ctx context.Context, uuid uuid.UUID) error
+	Deposit(ctx context.Context, pocketUUID uuid.UUID, amount float64) error
+	Withdraw(ctx context.Context, pocketUUID uuid.UUID, amount float64) error
+}
+
+type Family interface {
+	SetParent(ctx context.Context, child, parent uuid.UUID) error
+	GetChildren(ctx context.Context, parent uuid.UUID) ([]entity.Wallet, error)
+	SetChildPolicy(ctx context.Context, policy entity.ChildPolicy) error
+	GetChildPolicy(ctx context.Context, child uuid.UUID) (entity.ChildPolicy, error)
+	CreateAllowance(ctx context.Context, allowance entity.Allowance) (uuid.UUID, error)
+	GetAllowances(ctx context.Context, child uuid.UUID) ([]entity.Allowance, error)
+	DeleteAllowance(ctx context.Context, allowanceUUID, child uuid.UUID) error
+	GetDueAllowances(ctx context.Context, now time.Time) ([]uuid.UUID, error)
+	PayAllowance(ctx context.Context, allowanceUUID uuid.UUID) error
+}
+
+type Merchant interface {
+	CreateMerchant(ctx context.Context, merchant entity.Merchant) (uuid.UUID, error)
+	GetMerchantById(ctx context.Context, uuid uuid.UUID) (entity.Merchant, error)
+	GetMerchantByOwner(ctx context.Context, owner uuid.UUID) (entity.Merchant, error)
+	AddMerchantWallet(ctx context.Context, merchantUUID, walletUUID uuid.UUID) error
+	DeleteMerchantWallet(ctx context.Context, merchantUUID, walletUUID uuid.UUID) error
+}
+
+type Invoice interface {
+	CreateInvoice(ctx context.Context, invoice entity.Invoice) (uuid.UUID, error)
+	GetInvoiceById(ctx context.Context, uuid uuid.UUID) (entity.Invoice, error)
+	GetMerchantInvoices(ctx context.Context, merchantUUID uuid.UUID) ([]entity.Invoice, error)
+	CancelInvoice(ctx context.Context, uuid uuid.UUID) error
+	PayInvoice(ctx context.Context, invoiceUUID, payer uuid.UUID, amount float64) (entity.Invoice, error)
+}
+
+type Voucher interface {
+	CreateVoucher(ctx context.Context, voucher entity.Voucher, holdingWallet uuid.UUID) (uuid.UUID, error)
+	GetVoucherById(ctx context.Context, uuid uuid.UUID) (entity.Voucher, error)
+	GetIssuerVouchers(ctx context.Context, issuer uuid.UUID) ([]entity.Voucher, error)
+	RedeemVoucher(ctx context.Context, codeHash string, redeemer, walletUUID, holdingWallet uuid.UUID) (entity.Voucher, error)
+	RevokeVoucher(ctx context.Context, voucherUUID, holdingWallet uuid.UUID) error
+}
+
+type Promotion interface {
+	CreateCampaign(ctx context.Context, campaign entity.PromoCampaign) (uuid.UUID, error)
+	GetCampaignById(ctx context.Context, uuid uuid.UUID) (entity.PromoCampaign, error)
+	GetCampaigns(ctx context.Context) ([]entity.PromoCampaign, error)
+	GetMatchingCampaigns(ctx context.Context, receiver uuid.UUID, amount float64) ([]entity.PromoCampaign, error)
+	UpdateCampaign(ctx context.Context, campaign entity.PromoCampaign) error
+	PayReward(ctx context.Context, payout entity.PromoPayout, promoWallet uuid.UUID) (float64, error)
+	GetReport(ctx context.Context) ([]entity.PromoReport, error)
+}
+
+type Credit interface {
+	SetCreditLine(ctx context.Context, walletUUID uuid.UUID, limit, rate float64) error
+	GetOverdrawnWallets(ctx context.Context) ([]uuid.UUID, error)
+	ChargeFee(ctx context.Context, walletUUID, feeWallet uuid.UUID, day time.Time) (float64, error)
+}
+
+type Interest interface {
+	CreateProduct(ctx context.Context, product entity.InterestProduct) (uuid.UUID, error)
+	GetProducts(ctx context.Context) ([]entity.InterestProduct, error)
+	UpdateProduct(ctx context.Context, product entity.InterestProduct) error
+	AssignProduct(ctx context.Context, walletUUID uuid.UUID, product uuid.NullUUID) error
+	AccrueInterest(ctx context.Context, day time.Time) (int64, error)
+	GetUnpaidWallets(ctx context.Context, before time.Time) ([]uuid.UUID, error)
+	PayInterest(ctx context.Context, walletUUID, expenseWallet uuid.UUID, before time.Time) (float64, error)
+	GetSummary(ctx context.Context, walletUUID uuid.UUID) (entity.InterestSummary, error)
+}
+
+type Escrow interface {
+	CreateEscrow(ctx context.Context, escrow entity.Escrow, holdingWallet uuid.UUID) (uuid.UUID, error)
+	GetEscrowById(ctx context.Context, uuid uuid.UUID) (entity.Escrow, error)
+	GetWalletEscrows(ctx context.Context, walletUUID uuid.UUID) ([]entity.Escrow, error)
+	GetWalletEscrowTotals(ctx context.Context, walletUUID uuid.UUID) (held, incoming float64, err error)
+	GetExpiredEscrows(ctx context.Context, now time.Time) ([]uuid.UUID, error)
+	SettleEscrow(ctx context.Context, escrowUUID, holdingWallet uuid.UUID, status string) error
+}
+
+type Dispute interface {
+	OpenDispute(ctx context.Context, dispute entity.Dispute, holdingWallet uuid.UUID) (uuid.UUID, error)
+	GetDisputeById(ctx context.Context, uuid uuid.UUID) (entity.Dispute, error)
+	GetWalletDisputes(ctx context.Context, walletUUID uuid.UUID) ([]entity.Dispute, error)
+	GetDisputes(ctx context.Context, status string) ([]entity.Dispute, error)
+	GetDisputeEvents(ctx context.Context, disputeUUID uuid.UUID) ([]entity.DisputeEvent, error)
+	AddEvent(ctx context.Context, disputeUUID uuid.UUID, event entity.DisputeEvent) error
+	AddAttachment(ctx context.Context, attachment entity.Attachment) (uuid.UUID, error)
+	GetAttachments(ctx context.Context, disputeUUID uuid.UUID) ([]entity.Attachment, error)
+	GetAttachment(ctx context.Context, disputeUUID, attachmentUUID uuid.UUID) (entity.Attachment, error)
+	ResolveDispute(ctx context.Context, disputeUUID uuid.UUID, event entity.DisputeEvent, holdingWallet uuid.UUID) error
+}
+
+type Approval interface {
+	SetApprovalPolicy(ctx context.Context, policy entity.ApprovalPolicy) error
+	GetApprovalPolicy(ctx context.Context, walletUUID uuid.UUID) (entity.ApprovalPolicy, error)
+	DeleteApprovalPolicy(ctx context.Context, walletUUID uuid.UUID) error
+	CreatePendingTransfer(ctx context.Context, transfer entity.PendingTransfer) (uuid.UUID, error)
+	GetPendingTransferById(ctx context.Context, uuid uuid.UUID) (entity.PendingTransfer, error)
+	GetApproverPendingTransfers(ctx context.Context, approver uuid.UUID) ([]entity.PendingTransfer, error)
+	ApproveTransfer(ctx context.Context, transferUUID, approver uuid.UUID) (string, error)
+	RejectTransfer(ctx context.Context, transferUUID, approver uuid.UUID) error
+	ExpirePendingTransfers(ctx context.Context, now time.Time) error
+}
+
+type ScheduledTransfer interface {
+	SetTransferDelay(ctx context.Context, walletUUID uuid.UUID, seconds int64) error
+	CreateScheduledTransfer(ctx context.Context, transfer entity.ScheduledTransfer) (uuid.UUID, error)
+	GetScheduledTransferById(ctx context.Context, uuid uuid.UUID) (entity.ScheduledTransfer, error)
+	GetWalletScheduledTransfers(ctx context.Context, walletUUID uuid.UUID) ([]entity.ScheduledTransfer, error)
+	CancelScheduledTransfer(ctx context.Context, transferUUID uuid.UUID) error
+	GetDueScheduledTransfers(ctx context.Context, now time.Time) ([]uuid.UUID, error)
+	ExecuteScheduledTransfer(ctx context.Context, transferUUID uuid.UUID) (entity.ScheduledTransfer, error)
+	FailScheduledTransfer(ctx context.Context, transferUUID uuid.UUID) error
+}
+
+// RefreshToken.RotateRefreshToken revokes the whole family when an already used token is presented
+type RefreshToken interface {
+	CreateRefreshToken(ctx context.Context, token entity.RefreshToken) error
+	RotateRefreshToken(ctx context.Context, tokenHash string, next entity.RefreshToken) (entity.RefreshToken, error)
+	RevokeRefreshTokenFamily(ctx context.Context, owner uuid.UUID, tokenHash string) error
+	DeleteExpiredRefreshTokens(ctx context.Context, before time.Time) (int64, error)
+}
+
+type TokenRevocation interface {
+	RevokeToken(ctx context.Context, jti string, owner uuid.UUID, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	RevokeUserTokens(ctx context.Context, owner uuid.UUID, before time.Time) error
+	GetUserTokensRevokedAt(ctx context.Context, owner uuid.UUID) (*time.Time, error)
+	DeleteExpiredRevokedTokens(ctx context.Context, before time.Time) (int64, error)
+}
+
+type APIKey interface {
+	CreateAPIKey(ctx context.Context, key entity.APIKey) (entity.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (entity.APIKey, error)
+	GetUserAPIKeys(ctx context.Context, owner uuid.UUID) ([]entity.APIKey, error)
+	RevokeAPIKey(ctx context.Context, owner, keyUUID uuid.UUID) error
+	TouchAPIKey(ctx context.Context, keyUUID uuid.UUID) error
+}
+
+type TwoFactor interface {
+	SetTOTPSecret(ctx context.Context, owner uuid.UUID, secret string) error
+	GetTOTP(ctx context.Context, owner uuid.UUID) (entity.TOTP, error)
+	ConfirmTOTP(ctx context.Context, owner uuid.UUID, counter int64, codeHashes []string) error
+	UseTOTPCounter(ctx context.Context, owner uuid.UUID, counter int64) error
+	UseRecoveryCode(ctx context.Context, owner uuid.UUID, codeHash string) error
+	ReplaceRecoveryCodes(ctx context.Context, owner uuid.UUID, codeHashes []string) error
+	SetTransferThreshold(ctx context.Context, owner uuid.UUID, threshold *float64) error
+	DeleteTOTP(ctx context.Context, owner uuid.UUID) error
+}
+
+// PasswordReset.ResetPassword sets the password and uses up every reset token of the owner
+type PasswordReset interface {
+	CreatePasswordResetToken(ctx context.Context, token entity.PasswordResetToken) error
+	ResetPassword(ctx context.Context, tokenHash, password string, before time.Time) (uuid.UUID, error)
+	ChangePassword(ctx context.Context, owner uuid.UUID, password string, before time.Time) error
+	DeleteExpiredPasswordResetTokens(ctx context.Context, before time.Time) (int64, error)
+}
+
+// LoginAttempt.AddFailedLogin starts the count over when the previous failure is older than resetAfter.
+// Implementations: pgdb.LoginAttemptRepo shared by all instances, memory.LoginAttemptRepo for a single one.
+type LoginAttempt interface {
+	GetLoginAttempts(ctx context.Context, key string) (entity.LoginAttempts, error)
+	AddFailedLogin(ctx context.Context, key string, now time.Time, resetAfter time.Duration) (entity.LoginAttempts, error)
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	ResetLoginAttempts(ctx context.Context, key string) error
+	GetLockedLogins(ctx context.Context, now time.Time) ([]entity.LoginAttempts, error)
+	DeleteStaleLoginAttempts(ctx context.Context, before time.Time) (int64, error)
 }
 
 type Repositories struct {
 	User        User
 	Wallet      Wallet
 	Transaction Transaction
+	Alias       Alias
+	Contact     Contact
+	Member      Member
+	Pocket      Pocket
+	Family      Family
+	Merchant    Merchant
+	Invoice     Invoice
+	Voucher     Voucher
+	Promotion   Promotion
+	Credit      Credit
+	Interest    Interest
+	Escrow      Escrow
+	Dispute     Dispute
+	Approval    Approval
+	Scheduled   ScheduledTransfer
+	Refresh     RefreshToken
+	Revocation  TokenRevocation
+	APIKey      APIKey
+	TwoFactor   TwoFactor
+	Lockout     LoginAttempt
+	Reset       PasswordReset
 }
 
 func NewRepositories(pg *postgres.Postgres) *Repositories {
@@ -37,5 +270,26 @@ func NewRepositories(pg *postgres.Postgres) *Repositories {
 		User:        pgdb.NewUserRepo(pg),
 		Wallet:      pgdb.NewWalletRepo(pg),
 		Transaction: pgdb.NewTransactionRepo(pg),
+		Alias:       pgdb.NewAliasRepo(pg),
+		Contact:     pgdb.NewContactRepo(pg),
+		Member:      pgdb.NewMemberRepo(pg),
+		Pocket:      pgdb.NewPocketRepo(pg),
+		Family:      pgdb.NewFamilyRepo(pg),
+		Merchant:    pgdb.NewMerchantRepo(pg),
+		Invoice:     pgdb.NewInvoiceRepo(pg),
+		Voucher:     pgdb.NewVoucherRepo(pg),
+		Promotion:   pgdb.NewPromotionRepo(pg),
+		Credit:      pgdb.NewCreditRepo(pg),
+		Interest:    pgdb.NewInterestRepo(pg),
+		Escrow:      pgdb.NewEscrowRepo(pg),
+		Dispute:     pgdb.NewDisputeRepo(pg),
+		Approval:    pgdb.NewApprovalRepo(pg),
+		Scheduled:   pgdb.NewScheduledTransferRepo(pg),
+		Refresh:     pgdb.NewRefreshTokenRepo(pg),
+		Revocation:  pgdb.NewTokenRevocationRepo(pg),
+		APIKey:      pgdb.NewAPIKeyRepo(pg),
+		TwoFactor:   pgdb.NewTwoFactorRepo(pg),
+		Lockout:     pgdb.NewLoginAttemptRepo(pg),
+		Reset:       pgdb.NewPasswordResetRepo(pg),
 	}
 }
@@ -3,6 +3,10 @@ package repoerrors
 import "errors"
 
 var (
-	ErrNotFound     = errors.New("not found")
-	ErrAlreadyExist = errors.New("already exists")
+	ErrNotFound          = errors.New("not found")
+	ErrAlreadyExist      = errors.New("already exists")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrLastOwner         = errors.New("last owner")
+	ErrUnavailable       = errors.New("unavailable")
+	ErrLimitExceeded     = errors.New("limit exceeded")
 )
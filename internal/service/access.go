@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+	"slices"
+)
+
+var walletRoleRank = map[string]int{
+	entity.WalletRoleViewer:  1,
+	entity.WalletRoleSpender: 2,
+	entity.WalletRoleOwner:   3,
+}
+
+// walletMember returns the active membership of the user if its wallet role is at least minRole
+func walletMember(
+	ctx context.Context,
+	walletRepo repo.Wallet,
+	memberRepo repo.Member,
+	walletUUID, userUUID uuid.UUID,
+	minRole string,
+) (entity.WalletMember, error) {
+	member, err := memberRepo.GetMember(ctx, walletUUID, userUUID)
+	if err != nil {
+		if !errors.Is(err, repoerrors.ErrNotFound) {
+			slog.Error("service.walletMember", "error", err)
+			return entity.WalletMember{}, err
+		}
+
+		// not a member: tell a missing wallet from a foreign one
+		wallet, err := walletRepo.GetWalletStateById(ctx, walletUUID)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return entity.WalletMember{}, ErrCannotGetWallet
+			}
+			slog.Error("service.walletMember", "error", err)
+			return entity.WalletMember{}, err
+		}
+
+		// owners of a parent wallet can look into its child wallets
+		if wallet.Parent.Valid && walletRoleRank[minRole] <= walletRoleRank[entity.WalletRoleViewer] {
+			parent, err := memberRepo.GetMember(ctx, wallet.Parent.UUID, userUUID)
+			if err == nil && parent.Status == entity.MemberStatusActive && parent.Role == entity.WalletRoleOwner {
+				return entity.WalletMember{
+					Wallet: walletUUID,
+					Member: userUUID,
+					Role:   entity.WalletRoleViewer,
+					Status: entity.MemberStatusActive,
+				}, nil
+			}
+		}
+
+		return entity.WalletMember{}, ErrWalletAccessDenied
+	}
+
+	if member.Status != entity.MemberStatusActive || walletRoleRank[member.Role] < walletRoleRank[minRole] {
+		return entity.WalletMember{}, ErrWalletAccessDenied
+	}
+
+	return member, nil
+}
+
+// checkSpendLimit rejects amounts above the per-member limit of a spender
+func checkSpendLimit(member entity.WalletMember, amount float64) error {
+	if member.Role == entity.WalletRoleSpender && member.SpendLimit != nil && amount > *member.SpendLimit {
+		return ErrSpendLimitExceeded
+	}
+	return nil
+}
+
+// checkChildPolicy applies the counterparties the parent allowed for a child wallet. Sending back to the parent is
+// always allowed. The spend limit is enforced by the repositories inside the transaction that debits the wallet.
+func checkChildPolicy(ctx context.Context, familyRepo repo.Family, sender entity.Wallet, receiver uuid.UUID) error {
+	if !sender.Parent.Valid || receiver == sender.Parent.UUID {
+		return nil
+	}
+
+	policy, err := familyRepo.GetChildPolicy(ctx, sender.UUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return nil
+		}
+		slog.Error("service.checkChildPolicy", "error", err)
+		return err
+	}
+
+	if len(policy.Counterparties) > 0 && !slices.Contains(policy.Counterparties, receiver) {
+		return ErrCounterpartyNotAllowed
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+)
+
+type AliasService struct {
+	aliasRepo  repo.Alias
+	walletRepo repo.Wallet
+	memberRepo repo.Member
+	resolver   *Resolver
+}
+
+func NewAliasService(aliasRepo repo.Alias, walletRepo repo.Wallet, memberRepo repo.Member, resolver *Resolver) *AliasService {
+	return &AliasService{
+		aliasRepo:  aliasRepo,
+		walletRepo: walletRepo,
+		memberRepo: memberRepo,
+		resolver:   resolver,
+	}
+}
+
+func (s *AliasService) GetAliases(ctx context.Context, userUUID uuid.UUID) ([]entity.Alias, error) {
+	aliases, err := s.aliasRepo.GetUserAliases(ctx, userUUID)
+	if err != nil {
+		slog.Error("AliasService.GetAliases", "error", err)
+		return nil, ErrCannotUpdateAlias
+	}
+
+	return aliases, nil
+}
+
+// CreateAlias registers a handle or a phone-like id for a wallet owned by the user.
+// An alias can not take somebody else's username.
+func (s *AliasService) CreateAlias(ctx context.Context, userUUID uuid.UUID, alias entity.Alias) error {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, alias.Wallet, userUUID, entity.WalletRoleOwner); err != nil {
+		return err
+	}
+
+	alias.Alias = normalizeAlias(alias.Alias)
+	alias.Owner = userUUID
+	if !validAlias(alias.Alias) {
+		return ErrInvalidAlias
+	}
+
+	owner, _, err := s.aliasRepo.GetDefaultWallet(ctx, alias.Alias)
+	switch {
+	case err == nil && owner != userUUID:
+		return ErrAliasAlreadyExists
+	case err != nil && !errors.Is(err, repoerrors.ErrNotFound):
+		slog.Error("AliasService.CreateAlias", "error", err)
+		return ErrCannotUpdateAlias
+	}
+
+	err = s.aliasRepo.CreateAlias(ctx, alias)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrAlreadyExist) {
+			return ErrAliasAlreadyExists
+		}
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrCannotGetWallet
+		}
+		slog.Error("AliasService.CreateAlias", "error", err)
+		return ErrCannotUpdateAlias
+	}
+
+	return nil
+}
+
+func (s *AliasService) DeleteAlias(ctx context.Context, userUUID uuid.UUID, alias string) error {
+	err := s.aliasRepo.DeleteAlias(ctx, normalizeAlias(alias), userUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrAliasNotFound
+		}
+		slog.Error("AliasService.DeleteAlias", "error", err)
+		return ErrCannotUpdateAlias
+	}
+
+	return nil
+}
+
+// SetDefaultWallet makes the owned wallet receive transfers sent to the username, uuid.NullUUID{} unsets it
+func (s *AliasService) SetDefaultWallet(ctx context.Context, userUUID uuid.UUID, wallet uuid.NullUUID) error {
+	if wallet.Valid {
+		if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, wallet.UUID, userUUID, entity.WalletRoleOwner); err != nil {
+			return err
+		}
+	}
+
+	err := s.aliasRepo.SetDefaultWallet(ctx, userUUID, wallet)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		slog.Error("AliasService.SetDefaultWallet", "error", err)
+		return ErrCannotUpdateAlias
+	}
+
+	return nil
+}
+
+func (s *AliasService) Resolve(ctx context.Context, to string) (uuid.UUID, error) {
+	return s.resolver.Resolve(ctx, to)
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// API keys look like wk_<43 base64url chars>, the first apiKeyPrefixLength chars are kept to tell keys apart
+const (
+	apiKeyMarker       = "wk_"
+	apiKeyLength       = 32
+	apiKeyPrefixLength = 11
+)
+
+var apiKeyScopes = []string{
+	entity.ScopeWalletRead,
+	entity.ScopeWalletTransfer,
+	entity.ScopeWalletCreate,
+}
+
+type APIKeyService struct {
+	apiKeyRepo repo.APIKey
+	walletRepo repo.Wallet
+	memberRepo repo.Member
+}
+
+func NewAPIKeyService(apiKeyRepo repo.APIKey, walletRepo repo.Wallet, memberRepo repo.Member) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo: apiKeyRepo,
+		walletRepo: walletRepo,
+		memberRepo: memberRepo,
+	}
+}
+
+// CreateAPIKey creates a key of key.Owner on behalf of createdBy, the owner itself or an admin.
+// The key is returned only once, restricted wallets must be accessible to the owner.
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, createdBy uuid.UUID, key entity.APIKey) (entity.APIKey, string, error) {
+	slices.Sort(key.Scopes)
+	key.Scopes = slices.Compact(key.Scopes)
+	if len(key.Scopes) == 0 || key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now()) {
+		return entity.APIKey{}, "", ErrInvalidAPIKey
+	}
+	for _, scope := range key.Scopes {
+		if !slices.Contains(apiKeyScopes, scope) {
+			return entity.APIKey{}, "", ErrInvalidAPIKey
+		}
+	}
+
+	for _, wallet := range key.Wallets {
+		if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, wallet, key.Owner, entity.WalletRoleViewer); err != nil {
+			return entity.APIKey{}, "", err
+		}
+	}
+
+	secret, err := generateAPIKey()
+	if err != nil {
+		slog.Error("APIKeyService.CreateAPIKey", "error", err)
+		return entity.APIKey{}, "", ErrCannotCreateAPIKey
+	}
+
+	key.Prefix = secret[:apiKeyPrefixLength]
+	key.KeyHash = hashAPIKey(secret)
+	key.CreatedBy = createdBy
+
+	created, err := s.apiKeyRepo.CreateAPIKey(ctx, key)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.APIKey{}, "", ErrUserNotFound
+		}
+		slog.Error("APIKeyService.CreateAPIKey", "error", err)
+		return entity.APIKey{}, "", ErrCannotCreateAPIKey
+	}
+
+	return created, secret, nil
+}
+
+func (s *APIKeyService) GetAPIKeys(ctx context.Context, owner uuid.UUID) ([]entity.APIKey, error) {
+	keys, err := s.apiKeyRepo.GetUserAPIKeys(ctx, owner)
+	if err != nil {
+		slog.Error("APIKeyService.GetAPIKeys", "error", err)
+		return nil, ErrCannotGetAPIKey
+	}
+
+	return keys, nil
+}
+
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, owner, keyUUID uuid.UUID) error {
+	if err := s.apiKeyRepo.RevokeAPIKey(ctx, owner, keyUUID); err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrAPIKeyNotFound
+		}
+		slog.Error("APIKeyService.RevokeAPIKey", "error", err)
+		return ErrCannotRevokeAPIKey
+	}
+
+	return nil
+}
+
+// Authenticate returns the key if it is neither revoked nor expired and records its use
+func (s *APIKeyService) Authenticate(ctx context.Context, secret string) (entity.APIKey, error) {
+	if !strings.HasPrefix(secret, apiKeyMarker) {
+		return entity.APIKey{}, ErrInvalidAPIKeyCredentials
+	}
+
+	key, err := s.apiKeyRepo.GetAPIKeyByHash(ctx, hashAPIKey(secret))
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.APIKey{}, ErrInvalidAPIKeyCredentials
+		}
+		slog.Error("APIKeyService.Authenticate", "error", err)
+		return entity.APIKey{}, ErrCannotGetAPIKey
+	}
+
+	if key.RevokedAt != nil || key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now()) {
+		return entity.APIKey{}, ErrInvalidAPIKeyCredentials
+	}
+
+	// losing a last-used mark must not fail the request
+	if err = s.apiKeyRepo.TouchAPIKey(ctx, key.UUID); err != nil {
+		slog.Error("APIKeyService.Authenticate: can not record key use", "error", err)
+	}
+
+	return key, nil
+}
+
+func generateAPIKey() (string, error) {
+	buf := make([]byte, apiKeyLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return apiKeyMarker + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashAPIKey does not need a slow hash, keys are random and long
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
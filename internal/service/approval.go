@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+type ApprovalService struct {
+	approvalRepo repo.Approval
+	walletRepo   repo.Wallet
+	memberRepo   repo.Member
+	promotions   Promotion
+	defaultTTL   time.Duration
+}
+
+func NewApprovalService(
+	approvalRepo repo.Approval,
+	walletRepo repo.Wallet,
+	memberRepo repo.Member,
+	promotions Promotion,
+	defaultTTL time.Duration,
+) *ApprovalService {
+	return &ApprovalService{
+		approvalRepo: approvalRepo,
+		walletRepo:   walletRepo,
+		memberRepo:   memberRepo,
+		promotions:   promotions,
+		defaultTTL:   defaultTTL,
+	}
+}
+
+func (s *ApprovalService) SetPolicy(ctx context.Context, userUUID uuid.UUID, policy entity.ApprovalPolicy) error {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, policy.Wallet, userUUID, entity.WalletRoleOwner); err != nil {
+		return err
+	}
+
+	approvers := make([]uuid.UUID, 0, len(policy.Approvers))
+	for _, approver := range policy.Approvers {
+		if !slices.Contains(approvers, approver) {
+			approvers = append(approvers, approver)
+		}
+	}
+	policy.Approvers = approvers
+
+	if policy.TTLSeconds == 0 {
+		policy.TTLSeconds = int64(s.defaultTTL / time.Second)
+	}
+
+	if policy.Threshold < 0 || policy.TTLSeconds < 0 ||
+		policy.RequiredApprovals < 1 || policy.RequiredApprovals > len(policy.Approvers) {
+		return ErrInvalidApprovalPolicy
+	}
+
+	err := s.approvalRepo.SetApprovalPolicy(ctx, policy)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrApproverNotFound
+		}
+		slog.Error("ApprovalService.SetPolicy", "error", err)
+		return ErrCannotSetApprovalPolicy
+	}
+
+	return nil
+}
+
+func (s *ApprovalService) GetPolicy(ctx context.Context, userUUID, walletUUID uuid.UUID) (entity.ApprovalPolicy, error) {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, entity.WalletRoleOwner); err != nil {
+		return entity.ApprovalPolicy{}, err
+	}
+
+	policy, err := s.approvalRepo.GetApprovalPolicy(ctx, walletUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.ApprovalPolicy{}, ErrApprovalPolicyNotFound
+		}
+		slog.Error("ApprovalService.GetPolicy", "error", err)
+		return entity.ApprovalPolicy{}, ErrCannotGetApprovalPolicy
+	}
+
+	return policy, nil
+}
+
+func (s *ApprovalService) DeletePolicy(ctx context.Context, userUUID, walletUUID uuid.UUID) error {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, entity.WalletRoleOwner); err != nil {
+		return err
+	}
+
+	err := s.approvalRepo.DeleteApprovalPolicy(ctx, walletUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrApprovalPolicyNotFound
+		}
+		slog.Error("ApprovalService.DeletePolicy", "error", err)
+		return ErrCannotSetApprovalPolicy
+	}
+
+	return nil
+}
+
+func (s *ApprovalService) GetPendingTransfers(ctx context.Context, userUUID uuid.UUID) ([]entity.PendingTransfer, error) {
+	transfers, err := s.approvalRepo.GetApproverPendingTransfers(ctx, userUUID)
+	if err != nil {
+		slog.Error("ApprovalService.GetPendingTransfers", "error", err)
+		return nil, ErrCannotGetPendingTransfer
+	}
+
+	return transfers, nil
+}
+
+// GetPendingTransfer returns the transfer to its creator and to approvers of the sender wallet
+func (s *ApprovalService) GetPendingTransfer(ctx context.Context, userUUID, transferUUID uuid.UUID) (entity.PendingTransfer, error) {
+	transfer, err := s.getPendingTransfer(ctx, transferUUID)
+	if err != nil {
+		return entity.PendingTransfer{}, err
+	}
+
+	if transfer.CreatedBy == userUUID {
+		return transfer, nil
+	}
+
+	if err = s.checkApprover(ctx, transfer, userUUID); err != nil {
+		return entity.PendingTransfer{}, err
+	}
+
+	return transfer, nil
+}
+
+func (s *ApprovalService) Approve(ctx context.Context, userUUID, transferUUID uuid.UUID) (string, error) {
+	transfer, err := s.decidable(ctx, userUUID, transferUUID)
+	if err != nil {
+		return "", err
+	}
+
+	status, err := s.approvalRepo.ApproveTransfer(ctx, transfer.UUID, userUUID)
+	if err != nil {
+		return "", s.decisionError("ApprovalService.Approve", err)
+	}
+
+	// the last approval executes the transfer, it is rewarded as if it was sent right away
+	if status == entity.TransferStatusExecuted {
+		s.promotions.Reward(ctx, RewardInput{
+			UserUUID: transfer.CreatedBy,
+			Sender:   transfer.Sender,
+			Receiver: transfer.Receiver,
+			Amount:   transfer.Amount,
+		})
+	}
+
+	return status, nil
+}
+
+func (s *ApprovalService) Reject(ctx context.Context, userUUID, transferUUID uuid.UUID) error {
+	transfer, err := s.decidable(ctx, userUUID, transferUUID)
+	if err != nil {
+		return err
+	}
+
+	if err = s.approvalRepo.RejectTransfer(ctx, transfer.UUID, userUUID); err != nil {
+		return s.decisionError("ApprovalService.Reject", err)
+	}
+
+	return nil
+}
+
+// ExpirePending marks transfers that were not approved in time as expired
+func (s *ApprovalService) ExpirePending(ctx context.Context) error {
+	if err := s.approvalRepo.ExpirePendingTransfers(ctx, time.Now()); err != nil {
+		return fmt.Errorf("ApprovalService.ExpirePending: %v", err)
+	}
+	return nil
+}
+
+func (s *ApprovalService) getPendingTransfer(ctx context.Context, transferUUID uuid.UUID) (entity.PendingTransfer, error) {
+	transfer, err := s.approvalRepo.GetPendingTransferById(ctx, transferUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.PendingTransfer{}, ErrPendingTransferNotFound
+		}
+		slog.Error("ApprovalService.getPendingTransfer", "error", err)
+		return entity.PendingTransfer{}, ErrCannotGetPendingTransfer
+	}
+
+	return transfer, nil
+}
+
+func (s *ApprovalService) checkApprover(ctx context.Context, transfer entity.PendingTransfer, userUUID uuid.UUID) error {
+	policy, err := s.approvalRepo.GetApprovalPolicy(ctx, transfer.Sender)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrNotApprover
+		}
+		slog.Error("ApprovalService.checkApprover", "error", err)
+		return ErrCannotGetApprovalPolicy
+	}
+
+	if !slices.Contains(policy.Approvers, userUUID) {
+		return ErrNotApprover
+	}
+
+	return nil
+}
+
+// decidable checks that the user may approve or reject the transfer right now
+func (s *ApprovalService) decidable(ctx context.Context, userUUID, transferUUID uuid.UUID) (entity.PendingTransfer, error) {
+	transfer, err := s.getPendingTransfer(ctx, transferUUID)
+	if err != nil {
+		return entity.PendingTransfer{}, err
+	}
+
+	if transfer.Status != entity.TransferStatusPending || !transfer.ExpiresAt.After(time.Now()) {
+		return entity.PendingTransfer{}, ErrTransferNotPending
+	}
+
+	if transfer.CreatedBy == userUUID {
+		return entity.PendingTransfer{}, ErrSelfApproval
+	}
+
+	if err = s.checkApprover(ctx, transfer, userUUID); err != nil {
+		return entity.PendingTransfer{}, err
+	}
+
+	return transfer, nil
+}
+
+func (s *ApprovalService) decisionError(op string, err error) error {
+	switch {
+	case errors.Is(err, repoerrors.ErrNotFound):
+		return ErrTransferNotPending
+	case errors.Is(err, repoerrors.ErrAlreadyExist):
+		return ErrTransferAlreadyDecided
+	case errors.Is(err, repoerrors.ErrInsufficientFunds):
+		return ErrNotEnoughMoney
+	}
+	slog.Error(op, "error", err)
+	return ErrCannotDecidePendingTransfer
+}
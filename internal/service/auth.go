@@ -2,6 +2,10 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
@@ -10,6 +14,10 @@ import (
 	"infotecs_trainee_task/internal/repo"
 	"infotecs_trainee_task/internal/repo/repoerrors"
 	"infotecs_trainee_task/pkg/hasher"
+	"infotecs_trainee_task/pkg/keyset"
+	"infotecs_trainee_task/pkg/notify"
+	"infotecs_trainee_task/pkg/ratelimit"
+	"infotecs_trainee_task/pkg/ttlcache"
 	"log/slog"
 	"time"
 )
@@ -19,31 +27,95 @@ type TokenClaims struct {
 	UserUUID uuid.UUID
 }
 
+// TokenConfig describes issued tokens. Access tokens are signed by the signing key of Keys and verified by any
+// key of Keys still in its grace period. TTL is the lifetime of access tokens, RefreshTTL of refresh tokens.
+// Leeway is the clock skew tolerated when checking exp, nbf and iat. RevocationCacheTTL is how long
+// a token that is not revoked is trusted without asking Postgres again. TwoFactorTTL is the lifetime of the token
+// exchanged for the pair together with a two-factor code, ResetTTL of password reset tokens.
+type TokenConfig struct {
+	Keys               *keyset.KeySet
+	TTL                time.Duration
+	RefreshTTL         time.Duration
+	Issuer             string
+	Audience           string
+	Leeway             time.Duration
+	RevocationCacheTTL time.Duration
+	TwoFactorTTL       time.Duration
+	ResetTTL           time.Duration
+}
+
+// TokenPair.ExpiresIn is the access token lifetime in seconds
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+}
+
+const opaqueTokenLength = 32
+
+// twoFactorAudience is appended to the audience of two-factor tokens, so they are never accepted as access tokens
+const twoFactorAudience = ":2fa"
+
 type AuthService struct {
 	userRepo       repo.User
+	refreshRepo    repo.RefreshToken
+	revocationRepo repo.TokenRevocation
+	resetRepo      repo.PasswordReset
 	passwordHasher hasher.PasswordHasher
-	signKey        string
-	tokenTTL       time.Duration
+	twoFactor      TwoFactor
+	lockout        Lockout
+	notifier       notify.Notifier
+	token          TokenConfig
+
+	// twoFactorAttempts counts codes by the jti of the two-factor token, resetRequests reset requests by username
+	twoFactorAttempts *ratelimit.Limiter
+	resetRequests     *ratelimit.Limiter
+
+	// revokedTokens caches denylist lookups by jti, revokedBefore the logout-all moment by user uuid
+	revokedTokens *ttlcache.Cache[bool]
+	revokedBefore *ttlcache.Cache[time.Time]
 }
 
 func NewAuthService(
 	userRepo repo.User,
+	refreshRepo repo.RefreshToken,
+	revocationRepo repo.TokenRevocation,
+	resetRepo repo.PasswordReset,
 	hasher hasher.PasswordHasher,
-	signKey string,
-	tokenTTL time.Duration,
+	twoFactor TwoFactor,
+	lockout Lockout,
+	notifier notify.Notifier,
+	twoFactorAttempts *ratelimit.Limiter,
+	resetRequests *ratelimit.Limiter,
+	token TokenConfig,
 ) *AuthService {
 	return &AuthService{
-		userRepo:       userRepo,
-		passwordHasher: hasher,
-		signKey:        signKey,
-		tokenTTL:       tokenTTL,
+		userRepo:          userRepo,
+		refreshRepo:       refreshRepo,
+		revocationRepo:    revocationRepo,
+		resetRepo:         resetRepo,
+		passwordHasher:    hasher,
+		twoFactor:         twoFactor,
+		lockout:           lockout,
+		notifier:          notifier,
+		token:             token,
+		twoFactorAttempts: twoFactorAttempts,
+		resetRequests:     resetRequests,
+		revokedTokens:     ttlcache.New[bool](token.TTL),
+		revokedBefore:     ttlcache.New[time.Time](token.TTL),
 	}
 }
 
 func (s *AuthService) CreateUser(ctx context.Context, input InputData) (uuid.UUID, error) {
+	hash, err := s.passwordHasher.Hash(input.Password)
+	if err != nil {
+		slog.Error("AuthService.CreateUser", "error", err)
+		return uuid.Nil, ErrCannotCreateUser
+	}
+
 	user := entity.User{
 		Username: input.Username,
-		Password: s.passwordHasher.Hash(input.Password),
+		Password: hash,
 	}
 
 	userUUID, err := s.userRepo.CreateUser(ctx, user)
@@ -52,54 +124,308 @@ func (s *AuthService) CreateUser(ctx context.Context, input InputData) (uuid.UUI
 		if errors.Is(err, repoerrors.ErrAlreadyExist) {
 			return uuid.Nil, ErrUserAlreadyExists
 		}
-		slog.Error("AuthService.CreateUser", err.Error())
+		slog.Error("AuthService.CreateUser", "error", err)
 		return uuid.Nil, ErrCannotCreateUser
 	}
 
 	return userUUID, nil
 }
 
-func (s *AuthService) GenerateToken(ctx context.Context, input InputData) (string, error) {
-	user, err := s.userRepo.GetUserByUsernameAndPassword(ctx, input.Username, s.passwordHasher.Hash(input.Password))
+// SignIn starts a new refresh token family for the user. With two-factor authentication enabled
+// it returns only a two-factor token, SignInTwoFactor exchanges it for the pair.
+// A locked username or address gets ErrSignInLocked without checking the password.
+func (s *AuthService) SignIn(ctx context.Context, input InputData) (SignInResult, error) {
+	if err := s.lockout.CheckSignIn(ctx, input.Username, input.IP); err != nil {
+		return SignInResult{}, err
+	}
 
+	user, err := s.checkPassword(ctx, input)
 	if err != nil {
-		if errors.Is(err, repoerrors.ErrNotFound) {
-			return "", ErrUserNotFound
+		if errors.Is(err, ErrUserNotFound) {
+			s.lockout.SignInFailed(ctx, input.Username, input.IP)
+		}
+		return SignInResult{}, err
+	}
+	s.lockout.SignInSucceeded(ctx, user.Username)
+
+	enabled, err := s.twoFactor.IsEnabled(ctx, user.UUID)
+	if err != nil {
+		return SignInResult{}, err
+	}
+
+	if enabled {
+		token, err := s.signToken(user.UUID, s.token.Audience+twoFactorAudience, s.token.TwoFactorTTL)
+		if err != nil {
+			return SignInResult{}, err
 		}
-		slog.Error("AuthService.GenerateToken", err.Error())
-		return "", ErrCannotGetUser
+		return SignInResult{TwoFactorToken: token}, nil
+	}
+
+	tokens, err := s.startSession(ctx, user.UUID)
+	if err != nil {
+		return SignInResult{}, err
+	}
+
+	return SignInResult{Tokens: tokens}, nil
+}
+
+// SignInTwoFactor finishes the sign-in with a code of the app or a recovery code. The two-factor token
+// is used up by the first valid code and takes at most MaxAttempts codes.
+func (s *AuthService) SignInTwoFactor(ctx context.Context, twoFactorToken, code string) (TokenPair, error) {
+	claims, err := s.parseClaims(twoFactorToken, s.token.Audience+twoFactorAudience)
+	if err != nil {
+		return TokenPair{}, ErrInvalidTwoFactorToken
+	}
+
+	if !s.twoFactorAttempts.Allow(claims.ID) {
+		return TokenPair{}, ErrTooManyAttempts
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &TokenClaims{
-		UserUUID: user.UUID,
+	if err = s.checkRevoked(ctx, claims); err != nil {
+		if errors.Is(err, ErrTokenRevoked) {
+			return TokenPair{}, ErrInvalidTwoFactorToken
+		}
+		return TokenPair{}, err
+	}
+
+	if err = s.twoFactor.VerifyCode(ctx, claims.UserUUID, code); err != nil {
+		return TokenPair{}, err
+	}
+
+	expiresAt := claims.ExpiresAt.Time.Add(s.token.Leeway)
+	if err = s.revocationRepo.RevokeToken(ctx, claims.ID, claims.UserUUID, expiresAt); err != nil {
+		slog.Error("AuthService.SignInTwoFactor", "error", err)
+		return TokenPair{}, ErrCannotIssueToken
+	}
+	s.revokedTokens.Set(claims.ID, true, expiresAt)
+
+	return s.startSession(ctx, claims.UserUUID)
+}
+
+// startSession issues a pair with a new refresh token family
+func (s *AuthService) startSession(ctx context.Context, userUUID uuid.UUID) (TokenPair, error) {
+	refreshToken, hash, err := generateOpaqueToken()
+	if err != nil {
+		slog.Error("AuthService.startSession", "error", err)
+		return TokenPair{}, ErrCannotIssueToken
+	}
+
+	err = s.refreshRepo.CreateRefreshToken(ctx, entity.RefreshToken{
+		Family:    uuid.New(),
+		Owner:     userUUID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.token.RefreshTTL),
 	})
+	if err != nil {
+		slog.Error("AuthService.startSession", "error", err)
+		return TokenPair{}, ErrCannotIssueToken
+	}
 
-	tokenString, err := token.SignedString([]byte(s.signKey))
+	return s.tokenPair(userUUID, refreshToken)
+}
+
+// Refresh exchanges the refresh token for a new pair, the presented token can not be used again.
+// Presenting an already exchanged token revokes every token of its family and returns ErrRefreshTokenReused.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (TokenPair, error) {
+	nextToken, nextHash, err := generateOpaqueToken()
+	if err != nil {
+		slog.Error("AuthService.Refresh", "error", err)
+		return TokenPair{}, ErrCannotIssueToken
+	}
+
+	used, err := s.refreshRepo.RotateRefreshToken(ctx, hashOpaqueToken(refreshToken), entity.RefreshToken{
+		TokenHash: nextHash,
+		ExpiresAt: time.Now().Add(s.token.RefreshTTL),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, repoerrors.ErrAlreadyExist):
+			slog.Warn("AuthService.Refresh: refresh token reused, family revoked", "family", used.Family, "owner", used.Owner)
+			return TokenPair{}, ErrRefreshTokenReused
+		case errors.Is(err, repoerrors.ErrNotFound), errors.Is(err, repoerrors.ErrUnavailable):
+			return TokenPair{}, ErrInvalidRefreshToken
+		}
+		slog.Error("AuthService.Refresh", "error", err)
+		return TokenPair{}, ErrCannotIssueToken
+	}
+
+	return s.tokenPair(used.Owner, nextToken)
+}
+
+func (s *AuthService) tokenPair(userUUID uuid.UUID, refreshToken string) (TokenPair, error) {
+	accessToken, err := s.signToken(userUUID, s.token.Audience, s.token.TTL)
 	if err != nil {
-		slog.Error("AuthService.GenerateToken: can not sign key", err.Error())
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.token.TTL.Seconds()),
+	}, nil
+}
+
+// signToken issues an access token, or a two-factor token with the two-factor audience
+func (s *AuthService) signToken(userUUID uuid.UUID, audience string, ttl time.Duration) (string, error) {
+	key := s.token.Keys.Signing()
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), &TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    s.token.Issuer,
+			Subject:   userUUID.String(),
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		UserUUID: userUUID,
+	})
+
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(key.Private)
+	if err != nil {
+		slog.Error("AuthService.signToken: can not sign key", "error", err)
 		return "", ErrCannotSignToken
 	}
 
 	return tokenString, nil
 }
 
-func (s *AuthService) ParseToken(accessToken string) (uuid.UUID, error) {
+// ParseToken checks the signature, the standard claims and the revocation of the token.
+// An expired token returns ErrTokenExpired, a revoked one ErrTokenRevoked, any other invalid token ErrCannotParseToken.
+func (s *AuthService) ParseToken(ctx context.Context, accessToken string) (uuid.UUID, error) {
+	claims, err := s.parseClaims(accessToken, s.token.Audience)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if err = s.checkRevoked(ctx, claims); err != nil {
+		return uuid.Nil, err
+	}
+
+	return claims.UserUUID, nil
+}
+
+func (s *AuthService) parseClaims(accessToken, audience string) (*TokenClaims, error) {
 	token, err := jwt.ParseWithClaims(accessToken, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return uuid.Nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.token.Keys.Verifying(kid, time.Now())
+		if !ok {
+			return nil, fmt.Errorf("unknown or retired key: %q", kid)
 		}
 
-		return []byte(s.signKey), nil
-	})
+		// the algorithm is bound to the key, so a token can not pick a weaker one
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return key.Public, nil
+	}, jwt.WithoutClaimsValidation())
 
 	if err != nil {
-		return uuid.Nil, ErrCannotParseToken
+		return nil, ErrCannotParseToken
 	}
 
 	claims, ok := token.Claims.(*TokenClaims)
 	if !ok {
-		return uuid.Nil, ErrCannotParseToken
+		return nil, ErrCannotParseToken
 	}
 
-	return claims.UserUUID, nil
+	if err = s.validateClaims(claims, audience); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// validateClaims requires all standard claims, the jwt library checks time claims without leeway
+func (s *AuthService) validateClaims(claims *TokenClaims, audience string) error {
+	now := time.Now()
+
+	if !claims.VerifyExpiresAt(now.Add(-s.token.Leeway), true) {
+		return ErrTokenExpired
+	}
+
+	if !claims.VerifyNotBefore(now.Add(s.token.Leeway), true) ||
+		!claims.VerifyIssuedAt(now.Add(s.token.Leeway), true) ||
+		!claims.VerifyIssuer(s.token.Issuer, true) ||
+		!claims.VerifyAudience(audience, true) ||
+		claims.ID == "" {
+		return ErrCannotParseToken
+	}
+
+	return nil
+}
+
+// JWKS returns the public keys other services verify access tokens with
+func (s *AuthService) JWKS() keyset.JSONWebKeySet {
+	return s.token.Keys.JWKS(time.Now())
+}
+
+func (s *AuthService) IsAdmin(ctx context.Context, userUUID uuid.UUID) (bool, error) {
+	role, err := s.userRepo.GetUserRole(ctx, userUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return false, ErrUserNotFound
+		}
+		slog.Error("AuthService.IsAdmin", "error", err)
+		return false, ErrCannotGetUser
+	}
+
+	return role == entity.RoleAdmin, nil
+}
+
+// checkPassword returns the user if the password matches. A hash of an older scheme or weaker cost
+// is replaced on success, a failed upgrade does not fail the sign-in.
+func (s *AuthService) checkPassword(ctx context.Context, input InputData) (entity.User, error) {
+	user, err := s.userRepo.GetUserByUsername(ctx, input.Username)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			// spend the same time as for a wrong password, so usernames can not be probed
+			_, _ = s.passwordHasher.Hash(input.Password)
+			return entity.User{}, ErrUserNotFound
+		}
+		slog.Error("AuthService.checkPassword", "error", err)
+		return entity.User{}, ErrCannotGetUser
+	}
+
+	ok, err := s.passwordHasher.Verify(input.Password, user.Password)
+	if err != nil {
+		slog.Error("AuthService.checkPassword", "error", err)
+		return entity.User{}, ErrCannotGetUser
+	}
+	if !ok {
+		return entity.User{}, ErrUserNotFound
+	}
+
+	if s.passwordHasher.NeedsRehash(user.Password) {
+		hash, err := s.passwordHasher.Hash(input.Password)
+		if err == nil {
+			err = s.userRepo.UpdatePassword(ctx, user.UUID, hash)
+		}
+		if err != nil {
+			slog.Error("AuthService.checkPassword: can not upgrade password hash", "error", err)
+		}
+	}
+
+	return user, nil
+}
+
+// generateOpaqueToken returns a refresh or reset token for the client and the hash stored instead of it
+func generateOpaqueToken() (string, string, error) {
+	buf := make([]byte, opaqueTokenLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashOpaqueToken(token), nil
+}
+
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
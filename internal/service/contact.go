@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+	"time"
+)
+
+const (
+	// counterpartyDecay is how fast old transfers stop counting when ranking counterparties
+	counterpartyDecay = 30 * 24 * time.Hour
+
+	defaultCounterparties = 10
+	maxCounterparties     = 100
+)
+
+type ContactService struct {
+	contactRepo     repo.Contact
+	transactionRepo repo.Transaction
+	walletRepo      repo.Wallet
+	memberRepo      repo.Member
+	resolver        *Resolver
+	systemWallets   []uuid.UUID
+}
+
+func NewContactService(
+	contactRepo repo.Contact,
+	transactionRepo repo.Transaction,
+	walletRepo repo.Wallet,
+	memberRepo repo.Member,
+	resolver *Resolver,
+	holdingWallet, promoWallet, feeWallet, interestWallet uuid.UUID,
+) *ContactService {
+	return &ContactService{
+		contactRepo:     contactRepo,
+		transactionRepo: transactionRepo,
+		walletRepo:      walletRepo,
+		memberRepo:      memberRepo,
+		resolver:        resolver,
+		systemWallets:   []uuid.UUID{holdingWallet, promoWallet, feeWallet, interestWallet},
+	}
+}
+
+func (s *ContactService) GetContacts(ctx context.Context, userUUID uuid.UUID) ([]entity.Contact, error) {
+	contacts, err := s.contactRepo.GetContacts(ctx, userUUID)
+	if err != nil {
+		slog.Error("ContactService.GetContacts", "error", err)
+		return nil, ErrCannotUpdateContact
+	}
+
+	return contacts, nil
+}
+
+// CreateContact saves a name for a wallet id or an alias. The target has to resolve at the moment of saving.
+func (s *ContactService) CreateContact(ctx context.Context, userUUID uuid.UUID, contact entity.Contact) (uuid.UUID, error) {
+	if err := s.checkTarget(ctx, contact.To); err != nil {
+		return uuid.Nil, err
+	}
+
+	contact.Owner = userUUID
+
+	contactUUID, err := s.contactRepo.CreateContact(ctx, contact)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrAlreadyExist) {
+			return uuid.Nil, ErrContactAlreadyExists
+		}
+		slog.Error("ContactService.CreateContact", "error", err)
+		return uuid.Nil, ErrCannotUpdateContact
+	}
+
+	return contactUUID, nil
+}
+
+func (s *ContactService) UpdateContact(ctx context.Context, userUUID uuid.UUID, contact entity.Contact) error {
+	if err := s.checkTarget(ctx, contact.To); err != nil {
+		return err
+	}
+
+	contact.Owner = userUUID
+
+	err := s.contactRepo.UpdateContact(ctx, contact)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrContactNotFound
+		}
+		if errors.Is(err, repoerrors.ErrAlreadyExist) {
+			return ErrContactAlreadyExists
+		}
+		slog.Error("ContactService.UpdateContact", "error", err)
+		return ErrCannotUpdateContact
+	}
+
+	return nil
+}
+
+func (s *ContactService) DeleteContact(ctx context.Context, userUUID, contactUUID uuid.UUID) error {
+	err := s.contactRepo.DeleteContact(ctx, contactUUID, userUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrContactNotFound
+		}
+		slog.Error("ContactService.DeleteContact", "error", err)
+		return ErrCannotUpdateContact
+	}
+
+	return nil
+}
+
+// GetCounterparties returns wallets the wallet exchanges money with, most frequent and recent first
+func (s *ContactService) GetCounterparties(ctx context.Context, userUUID, walletUUID uuid.UUID, limit int) ([]entity.Counterparty, error) {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, entity.WalletRoleViewer); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultCounterparties
+	}
+	limit = min(limit, maxCounterparties)
+
+	counterparties, err := s.transactionRepo.GetCounterparties(
+		ctx, walletUUID, s.systemWallets, counterpartyDecay, uint64(limit),
+	)
+	if err != nil {
+		slog.Error("ContactService.GetCounterparties", "error", err)
+		return nil, ErrCannotGetTransaction
+	}
+
+	return counterparties, nil
+}
+
+func (s *ContactService) checkTarget(ctx context.Context, to string) error {
+	walletUUID, err := s.resolver.Resolve(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	if _, err = s.walletRepo.GetWalletStateById(ctx, walletUUID); err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrCannotGetWallet
+		}
+		slog.Error("ContactService.checkTarget", "error", err)
+		return ErrCannotUpdateContact
+	}
+
+	return nil
+}
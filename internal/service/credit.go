@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+type CreditService struct {
+	creditRepo    repo.Credit
+	feeWallet     uuid.UUID
+	systemWallets []uuid.UUID
+}
+
+func NewCreditService(creditRepo repo.Credit, holdingWallet, promoWallet, feeWallet, interestWallet uuid.UUID) *CreditService {
+	return &CreditService{
+		creditRepo:    creditRepo,
+		feeWallet:     feeWallet,
+		systemWallets: []uuid.UUID{holdingWallet, promoWallet, feeWallet, interestWallet},
+	}
+}
+
+// SetCreditLine approves the credit limit of the wallet and its daily fee rate, zero limit closes the line.
+// Lowering the limit below the current debt only blocks further spending.
+func (s *CreditService) SetCreditLine(ctx context.Context, walletUUID uuid.UUID, limit, rate float64) error {
+	if slices.Contains(s.systemWallets, walletUUID) {
+		return ErrSystemWallet
+	}
+
+	if limit < 0 || rate < 0 || rate > 1 {
+		return ErrInvalidCreditLine
+	}
+
+	err := s.creditRepo.SetCreditLine(ctx, walletUUID, limit, rate)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrCannotGetWallet
+		}
+		slog.Error("CreditService.SetCreditLine", "error", err)
+		return ErrCannotSetCreditLine
+	}
+
+	return nil
+}
+
+// AccrueFees charges today's fee on every negative balance. Each wallet is charged once a day,
+// so the job may run more often than daily.
+func (s *CreditService) AccrueFees(ctx context.Context) error {
+	wallets, err := s.creditRepo.GetOverdrawnWallets(ctx)
+	if err != nil {
+		return fmt.Errorf("CreditService.AccrueFees: %v", err)
+	}
+
+	now := time.Now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	for _, walletUUID := range wallets {
+		_, err = s.creditRepo.ChargeFee(ctx, walletUUID, s.feeWallet, day)
+		if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+			return fmt.Errorf("CreditService.AccrueFees: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+const (
+	DisputeDecisionPayer    = "payer"
+	DisputeDecisionReceiver = "receiver"
+)
+
+// attachmentTypes are the content types accepted as dispute evidence
+var attachmentTypes = []string{"image/png", "image/jpeg", "application/pdf", "text/plain"}
+
+type DisputeService struct {
+	disputeRepo       repo.Dispute
+	transactionRepo   repo.Transaction
+	walletRepo        repo.Wallet
+	memberRepo        repo.Member
+	userRepo          repo.User
+	holdingWallet     uuid.UUID
+	systemWallets     []uuid.UUID
+	window            time.Duration
+	maxAttachmentSize int64
+}
+
+func NewDisputeService(
+	disputeRepo repo.Dispute,
+	transactionRepo repo.Transaction,
+	walletRepo repo.Wallet,
+	memberRepo repo.Member,
+	userRepo repo.User,
+	holdingWallet, promoWallet, feeWallet, interestWallet uuid.UUID,
+	window time.Duration,
+	maxAttachmentSize int64,
+) *DisputeService {
+	return &DisputeService{
+		disputeRepo:       disputeRepo,
+		transactionRepo:   transactionRepo,
+		walletRepo:        walletRepo,
+		memberRepo:        memberRepo,
+		userRepo:          userRepo,
+		holdingWallet:     holdingWallet,
+		systemWallets:     []uuid.UUID{holdingWallet, promoWallet, feeWallet, interestWallet},
+		window:            window,
+		maxAttachmentSize: maxAttachmentSize,
+	}
+}
+
+// OpenDispute disputes the transaction on behalf of its sender wallet, zero amount disputes the whole transaction
+func (s *DisputeService) OpenDispute(ctx context.Context, input DisputeInput) (uuid.UUID, error) {
+	transaction, err := s.transactionRepo.GetTransactionById(ctx, input.Transaction)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return uuid.Nil, ErrTransactionNotFound
+		}
+		slog.Error("DisputeService.OpenDispute", "error", err)
+		return uuid.Nil, ErrCannotGetTransaction
+	}
+
+	if slices.Contains(s.systemWallets, transaction.Sender) || slices.Contains(s.systemWallets, transaction.Receiver) {
+		return uuid.Nil, ErrSystemWallet
+	}
+
+	_, err = walletMember(ctx, s.walletRepo, s.memberRepo, transaction.Sender, input.UserUUID, entity.WalletRoleSpender)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if time.Since(transaction.CreatedAt) > s.window {
+		return uuid.Nil, ErrDisputeWindowClosed
+	}
+
+	amount := input.Amount
+	if amount == 0 {
+		amount = transaction.Amount
+	}
+	if amount < 0 || amount > transaction.Amount || input.Reason == "" {
+		return uuid.Nil, ErrInvalidDispute
+	}
+
+	disputeUUID, err := s.disputeRepo.OpenDispute(ctx, entity.Dispute{
+		Transaction: transaction.UUID,
+		Payer:       transaction.Sender,
+		Receiver:    transaction.Receiver,
+		OpenedBy:    input.UserUUID,
+		Amount:      amount,
+		Reason:      input.Reason,
+	}, s.holdingWallet)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrAlreadyExist) {
+			return uuid.Nil, ErrDisputeAlreadyExists
+		}
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return uuid.Nil, ErrCannotGetWallet
+		}
+		slog.Error("DisputeService.OpenDispute", "error", err)
+		return uuid.Nil, ErrCannotOpenDispute
+	}
+
+	return disputeUUID, nil
+}
+
+// GetDispute returns the dispute with its timeline and the list of attachments
+func (s *DisputeService) GetDispute(ctx context.Context, userUUID, disputeUUID uuid.UUID) (entity.Dispute, error) {
+	dispute, err := s.party(ctx, userUUID, disputeUUID)
+	if err != nil {
+		return entity.Dispute{}, err
+	}
+
+	dispute.Events, err = s.disputeRepo.GetDisputeEvents(ctx, disputeUUID)
+	if err != nil {
+		slog.Error("DisputeService.GetDispute", "error", err)
+		return entity.Dispute{}, ErrCannotGetDispute
+	}
+
+	dispute.Attachments, err = s.disputeRepo.GetAttachments(ctx, disputeUUID)
+	if err != nil {
+		slog.Error("DisputeService.GetDispute", "error", err)
+		return entity.Dispute{}, ErrCannotGetDispute
+	}
+
+	return dispute, nil
+}
+
+func (s *DisputeService) GetWalletDisputes(ctx context.Context, userUUID, walletUUID uuid.UUID) ([]entity.Dispute, error) {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, entity.WalletRoleViewer); err != nil {
+		return nil, err
+	}
+
+	disputes, err := s.disputeRepo.GetWalletDisputes(ctx, walletUUID)
+	if err != nil {
+		slog.Error("DisputeService.GetWalletDisputes", "error", err)
+		return nil, ErrCannotGetDispute
+	}
+
+	return disputes, nil
+}
+
+// GetDisputes lists disputes for admins, empty status lists all of them
+func (s *DisputeService) GetDisputes(ctx context.Context, status string) ([]entity.Dispute, error) {
+	disputes, err := s.disputeRepo.GetDisputes(ctx, status)
+	if err != nil {
+		slog.Error("DisputeService.GetDisputes", "error", err)
+		return nil, ErrCannotGetDispute
+	}
+
+	return disputes, nil
+}
+
+func (s *DisputeService) AddComment(ctx context.Context, userUUID, disputeUUID uuid.UUID, note string) error {
+	dispute, err := s.party(ctx, userUUID, disputeUUID)
+	if err != nil {
+		return err
+	}
+
+	if dispute.Status != entity.DisputeStatusOpen {
+		return ErrDisputeNotOpen
+	}
+	if note == "" {
+		return ErrInvalidDispute
+	}
+
+	err = s.disputeRepo.AddEvent(ctx, disputeUUID, entity.DisputeEvent{
+		Actor: uuid.NullUUID{UUID: userUUID, Valid: true},
+		Kind:  entity.DisputeEventComment,
+		Note:  note,
+	})
+	if err != nil {
+		slog.Error("DisputeService.AddComment", "error", err)
+		return ErrCannotUpdateDispute
+	}
+
+	return nil
+}
+
+func (s *DisputeService) AddAttachment(ctx context.Context, userUUID uuid.UUID, attachment entity.Attachment) (uuid.UUID, error) {
+	dispute, err := s.party(ctx, userUUID, attachment.Dispute)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if dispute.Status != entity.DisputeStatusOpen {
+		return uuid.Nil, ErrDisputeNotOpen
+	}
+	if len(attachment.Data) == 0 || int64(len(attachment.Data)) > s.maxAttachmentSize ||
+		!slices.Contains(attachmentTypes, attachment.ContentType) {
+		return uuid.Nil, ErrInvalidAttachment
+	}
+
+	attachment.UploadedBy = userUUID
+	attachmentUUID, err := s.disputeRepo.AddAttachment(ctx, attachment)
+	if err != nil {
+		slog.Error("DisputeService.AddAttachment", "error", err)
+		return uuid.Nil, ErrCannotUpdateDispute
+	}
+
+	return attachmentUUID, nil
+}
+
+func (s *DisputeService) GetAttachment(ctx context.Context, userUUID, disputeUUID, attachmentUUID uuid.UUID) (entity.Attachment, error) {
+	if _, err := s.party(ctx, userUUID, disputeUUID); err != nil {
+		return entity.Attachment{}, err
+	}
+
+	attachment, err := s.disputeRepo.GetAttachment(ctx, disputeUUID, attachmentUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.Attachment{}, ErrAttachmentNotFound
+		}
+		slog.Error("DisputeService.GetAttachment", "error", err)
+		return entity.Attachment{}, ErrCannotGetDispute
+	}
+
+	return attachment, nil
+}
+
+// ResolveDispute is the admin decision in favor of the payer (reversal) or the receiver (release)
+func (s *DisputeService) ResolveDispute(ctx context.Context, adminUUID, disputeUUID uuid.UUID, decision, note string) error {
+	var kind string
+	switch decision {
+	case DisputeDecisionPayer:
+		kind = entity.DisputeEventReversed
+	case DisputeDecisionReceiver:
+		kind = entity.DisputeEventReleased
+	default:
+		return ErrInvalidDisputeDecision
+	}
+
+	dispute, err := s.getDispute(ctx, disputeUUID)
+	if err != nil {
+		return err
+	}
+	if dispute.Status != entity.DisputeStatusOpen {
+		return ErrDisputeNotOpen
+	}
+
+	err = s.disputeRepo.ResolveDispute(ctx, disputeUUID, entity.DisputeEvent{
+		Actor: uuid.NullUUID{UUID: adminUUID, Valid: true},
+		Kind:  kind,
+		Note:  note,
+	}, s.holdingWallet)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrDisputeNotOpen
+		}
+		slog.Error("DisputeService.ResolveDispute", "error", err)
+		return ErrCannotUpdateDispute
+	}
+
+	return nil
+}
+
+// party returns the dispute if the user can view the payer or the receiver wallet, admins see every dispute
+func (s *DisputeService) party(ctx context.Context, userUUID, disputeUUID uuid.UUID) (entity.Dispute, error) {
+	dispute, err := s.getDispute(ctx, disputeUUID)
+	if err != nil {
+		return entity.Dispute{}, err
+	}
+
+	for _, walletUUID := range []uuid.UUID{dispute.Payer, dispute.Receiver} {
+		_, err = walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, entity.WalletRoleViewer)
+		if err == nil {
+			return dispute, nil
+		}
+		if !errors.Is(err, ErrWalletAccessDenied) && !errors.Is(err, ErrCannotGetWallet) {
+			return entity.Dispute{}, err
+		}
+	}
+
+	role, err := s.userRepo.GetUserRole(ctx, userUUID)
+	if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+		slog.Error("DisputeService.party", "error", err)
+		return entity.Dispute{}, ErrCannotGetUser
+	}
+	if role != entity.RoleAdmin {
+		return entity.Dispute{}, ErrWalletAccessDenied
+	}
+
+	return dispute, nil
+}
+
+func (s *DisputeService) getDispute(ctx context.Context, disputeUUID uuid.UUID) (entity.Dispute, error) {
+	dispute, err := s.disputeRepo.GetDisputeById(ctx, disputeUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.Dispute{}, ErrDisputeNotFound
+		}
+		slog.Error("DisputeService.getDispute", "error", err)
+		return entity.Dispute{}, ErrCannotGetDispute
+	}
+
+	return dispute, nil
+}
@@ -9,15 +9,183 @@ var (
 	ErrCannotCreateUser  = errors.New("can not create user")
 	ErrUserNotFound      = errors.New("user not found")
 	ErrCannotGetUser     = errors.New("can not get user")
+	ErrCannotUpdateUser  = errors.New("can not update user")
+	ErrCannotDeleteUser  = errors.New("can not delete user")
+	ErrWalletsNotEmpty   = errors.New("wallets owned alone must be empty and settled before the account is deleted")
 
 	ErrCannotSignToken  = errors.New("cannot sign token")
 	ErrCannotParseToken = errors.New("cannot parse token")
+	ErrTokenExpired     = errors.New("token expired")
+	ErrTokenRevoked     = errors.New("token revoked")
+	ErrCannotCheckToken = errors.New("can not check token revocation")
+	ErrCannotLogout     = errors.New("can not logout")
+
+	ErrInvalidPassword      = errors.New("invalid password")
+	ErrInvalidResetToken    = errors.New("invalid or expired reset token")
+	ErrCannotChangePassword = errors.New("can not change password")
+	ErrCannotResetPassword  = errors.New("can not reset password")
+
+	ErrSignInLocked      = errors.New("too many failed sign-in attempts, try again later")
+	ErrCannotCheckSignIn = errors.New("can not check sign-in attempts")
+	ErrCannotGetLockouts = errors.New("can not get lockouts")
+	ErrCannotUnlock      = errors.New("can not unlock sign-in")
+
+	ErrTwoFactorNotEnabled      = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorAlreadyEnabled  = errors.New("two-factor authentication already enabled")
+	ErrInvalidTwoFactorCode     = errors.New("invalid or already used two-factor code")
+	ErrTwoFactorCodeRequired    = errors.New("two-factor code required for this transfer")
+	ErrInvalidTwoFactorToken    = errors.New("invalid or expired two-factor token")
+	ErrTwoFactorLocked          = errors.New("too many wrong two-factor codes, try again later")
+	ErrInvalidTransferThreshold = errors.New("invalid transfer threshold")
+	ErrCannotEnrollTwoFactor    = errors.New("can not enroll two-factor authentication")
+	ErrCannotGetTwoFactor       = errors.New("can not get two-factor authentication")
+	ErrCannotUpdateTwoFactor    = errors.New("can not update two-factor authentication")
+
+	ErrAPIKeyNotFound           = errors.New("api key not found")
+	ErrInvalidAPIKey            = errors.New("invalid api key: unknown scope, no scopes or past expiry")
+	ErrInvalidAPIKeyCredentials = errors.New("invalid, revoked or expired api key")
+	ErrCannotCreateAPIKey       = errors.New("can not create api key")
+	ErrCannotGetAPIKey          = errors.New("can not get api key")
+	ErrCannotRevokeAPIKey       = errors.New("can not revoke api key")
+
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrCannotIssueToken    = errors.New("can not issue refresh token")
 
 	ErrWalletAlreadyExists = errors.New("wallet already exist")
 	ErrCannotCreateWallet  = errors.New("can not create wallet")
 	ErrCannotGetWallet     = errors.New("can not get wallet")
+	ErrWalletAccessDenied  = errors.New("wallet access denied")
+	ErrSystemWallet        = errors.New("system wallet can not be used directly")
+	ErrNotEnoughMoney      = errors.New("not enough money")
+	ErrSpendLimitExceeded  = errors.New("member spend limit exceeded")
+	ErrInvalidCreditLine   = errors.New("invalid credit line")
+	ErrCannotSetCreditLine = errors.New("can not set credit line")
+
+	ErrInterestProductNotFound      = errors.New("interest product not found")
+	ErrInterestProductAlreadyExists = errors.New("interest product with this name already exist")
+	ErrInvalidInterestProduct       = errors.New("invalid interest product")
+	ErrCannotGetInterest            = errors.New("can not get interest")
+	ErrCannotUpdateInterest         = errors.New("can not update interest")
+
+	ErrAliasNotFound      = errors.New("alias not found")
+	ErrAmbiguousAlias     = errors.New("alias matches several wallets, use the wallet id")
+	ErrNoDefaultWallet    = errors.New("user has no default receiving wallet")
+	ErrInvalidAlias       = errors.New("invalid alias")
+	ErrAliasAlreadyExists = errors.New("alias already taken")
+	ErrCannotUpdateAlias  = errors.New("can not update alias")
+
+	ErrContactNotFound      = errors.New("contact not found")
+	ErrContactAlreadyExists = errors.New("contact with this name already exist")
+	ErrCannotUpdateContact  = errors.New("can not update contact")
+
+	ErrMemberNotFound      = errors.New("wallet member not found")
+	ErrMemberAlreadyExists = errors.New("user is already a wallet member")
+	ErrInvalidWalletRole   = errors.New("invalid wallet role")
+	ErrLastWalletOwner     = errors.New("wallet must keep at least one owner")
+	ErrInvitationNotFound  = errors.New("invitation not found")
+	ErrCannotGetMembers    = errors.New("can not get wallet members")
+	ErrCannotUpdateMember  = errors.New("can not update wallet member")
+
+	ErrNotChildWallet          = errors.New("wallet is not a child of the parent wallet")
+	ErrInvalidWalletHierarchy  = errors.New("wallet can not become a child of this wallet")
+	ErrInvalidPeriod           = errors.New("invalid period")
+	ErrCounterpartyNotAllowed  = errors.New("counterparty is not allowed for the child wallet")
+	ErrChildSpendLimitExceeded = errors.New("child wallet spend limit exceeded")
+	ErrChildPolicyNotFound     = errors.New("child wallet policy not found")
+	ErrAllowanceNotFound       = errors.New("allowance not found")
+	ErrCannotUpdateFamily      = errors.New("can not update child wallets")
+
+	ErrPocketNotFound       = errors.New("pocket not found")
+	ErrPocketAlreadyExists  = errors.New("pocket with this name already exist")
+	ErrNotEnoughPocketMoney = errors.New("not enough money in the pocket")
+	ErrCannotGetPockets     = errors.New("can not get pockets")
+	ErrCannotUpdatePocket   = errors.New("can not update pocket")
 
+	ErrMerchantNotFound       = errors.New("merchant not found")
+	ErrMerchantAlreadyExists  = errors.New("merchant profile already exist")
+	ErrSettlementWalletExists = errors.New("wallet is already a settlement wallet")
+	ErrNotSettlementWallet    = errors.New("wallet is not a settlement wallet of the merchant")
+	ErrCannotUpdateMerchant   = errors.New("can not update merchant")
+
+	ErrInvoiceNotFound      = errors.New("invoice not found")
+	ErrInvalidInvoice       = errors.New("invalid invoice")
+	ErrInvalidCurrency      = errors.New("unsupported currency")
+	ErrInvoiceNotPayable    = errors.New("invoice can not be paid")
+	ErrInvoiceExpired       = errors.New("invoice expired")
+	ErrInvalidInvoiceAmount = errors.New("invalid invoice payment amount")
+	ErrInvoiceNeedsApproval = errors.New("payment is above the wallet approval threshold")
+	ErrCannotCreateInvoice  = errors.New("can not create invoice")
+	ErrCannotGetInvoice     = errors.New("can not get invoice")
+	ErrCannotPayInvoice     = errors.New("can not pay invoice")
+
+	ErrVoucherNotFound        = errors.New("voucher not found")
+	ErrInvalidVoucher         = errors.New("invalid voucher")
+	ErrNotVoucherIssuer       = errors.New("only admins and merchants can issue vouchers")
+	ErrVoucherUnavailable     = errors.New("voucher is revoked, expired or fully redeemed")
+	ErrVoucherAlreadyRedeemed = errors.New("voucher already redeemed by the user")
+	ErrVoucherNeedsApproval   = errors.New("voucher funding is above the wallet approval threshold")
+	ErrTooManyAttempts        = errors.New("too many failed attempts, try again later")
+	ErrCannotCreateVoucher    = errors.New("can not create voucher")
+	ErrCannotGetVoucher       = errors.New("can not get voucher")
+	ErrCannotRedeemVoucher    = errors.New("can not redeem voucher")
+
+	ErrCampaignNotFound      = errors.New("promo campaign not found")
+	ErrCampaignAlreadyExists = errors.New("promo campaign with this name already exist")
+	ErrInvalidCampaign       = errors.New("invalid promo campaign")
+	ErrCannotGetCampaign     = errors.New("can not get promo campaigns")
+	ErrCannotUpdateCampaign  = errors.New("can not update promo campaign")
+
+	ErrInvalidPaymentPayload = errors.New("invalid payment payload")
+	ErrPaymentRequestExpired = errors.New("payment request expired")
+	ErrCannotEncodePayload   = errors.New("can not encode payment payload")
+
+	ErrCannotGetReconciliation = errors.New("can not get reconciliation")
+
+	ErrTransactionNotFound      = errors.New("transaction not found")
 	ErrTransactionAlreadyExists = errors.New("transaction already exist")
 	ErrCannotCreateTransaction  = errors.New("can not create transaction")
 	ErrCannotGetTransaction     = errors.New("can not get slug")
+
+	ErrEscrowNotFound        = errors.New("escrow not found")
+	ErrEscrowAlreadySettled  = errors.New("escrow already settled")
+	ErrEscrowSameWallet      = errors.New("buyer and seller must be different wallets")
+	ErrInvalidEscrowTimeout  = errors.New("invalid escrow timeout")
+	ErrInvalidEscrowDecision = errors.New("invalid escrow decision")
+	ErrEscrowNeedsApproval   = errors.New("escrow amount is above the wallet approval threshold")
+	ErrCannotCreateEscrow    = errors.New("can not create escrow")
+	ErrCannotGetEscrow       = errors.New("can not get escrow")
+	ErrCannotSettleEscrow    = errors.New("can not settle escrow")
+
+	ErrDisputeNotFound        = errors.New("dispute not found")
+	ErrDisputeAlreadyExists   = errors.New("transaction already disputed")
+	ErrDisputeNotOpen         = errors.New("dispute already resolved")
+	ErrDisputeWindowClosed    = errors.New("transaction is too old to be disputed")
+	ErrInvalidDispute         = errors.New("invalid dispute")
+	ErrInvalidDisputeDecision = errors.New("invalid dispute decision")
+	ErrAttachmentNotFound     = errors.New("attachment not found")
+	ErrInvalidAttachment      = errors.New("attachment type is not allowed or file is too large")
+	ErrCannotOpenDispute      = errors.New("can not open dispute")
+	ErrCannotGetDispute       = errors.New("can not get dispute")
+	ErrCannotUpdateDispute    = errors.New("can not update dispute")
+
+	ErrScheduledTransferNotFound  = errors.New("scheduled transfer not found")
+	ErrTransferNotScheduled       = errors.New("transfer is already executed or cancelled")
+	ErrInvalidTransferDelay       = errors.New("invalid transfer delay")
+	ErrCannotGetScheduledTransfer = errors.New("can not get scheduled transfer")
+	ErrCannotCancelTransfer       = errors.New("can not cancel transfer")
+	ErrCannotSetTransferDelay     = errors.New("can not set transfer delay")
+
+	ErrApprovalPolicyNotFound      = errors.New("approval policy not found")
+	ErrInvalidApprovalPolicy       = errors.New("invalid approval policy")
+	ErrApproverNotFound            = errors.New("approver not found")
+	ErrCannotSetApprovalPolicy     = errors.New("can not set approval policy")
+	ErrCannotGetApprovalPolicy     = errors.New("can not get approval policy")
+	ErrPendingTransferNotFound     = errors.New("pending transfer not found")
+	ErrTransferNotPending          = errors.New("transfer is not pending approval")
+	ErrNotApprover                 = errors.New("user is not an approver of the wallet")
+	ErrTransferAlreadyDecided      = errors.New("transfer already decided by the approver")
+	ErrSelfApproval                = errors.New("transfer creator can not approve it")
+	ErrCannotGetPendingTransfer    = errors.New("can not get pending transfer")
+	ErrCannotDecidePendingTransfer = errors.New("can not decide pending transfer")
 )
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+	"time"
+)
+
+const (
+	EscrowDecisionRelease = "release"
+	EscrowDecisionRefund  = "refund"
+)
+
+type EscrowService struct {
+	escrowRepo     repo.Escrow
+	walletRepo     repo.Wallet
+	memberRepo     repo.Member
+	familyRepo     repo.Family
+	approvalRepo   repo.Approval
+	twoFactor      TwoFactor
+	holdingWallet  uuid.UUID
+	defaultTimeout time.Duration
+	maxTimeout     time.Duration
+}
+
+func NewEscrowService(
+	escrowRepo repo.Escrow,
+	walletRepo repo.Wallet,
+	memberRepo repo.Member,
+	familyRepo repo.Family,
+	approvalRepo repo.Approval,
+	twoFactor TwoFactor,
+	holdingWallet uuid.UUID,
+	defaultTimeout time.Duration,
+	maxTimeout time.Duration,
+) *EscrowService {
+	return &EscrowService{
+		escrowRepo:     escrowRepo,
+		walletRepo:     walletRepo,
+		memberRepo:     memberRepo,
+		familyRepo:     familyRepo,
+		approvalRepo:   approvalRepo,
+		twoFactor:      twoFactor,
+		holdingWallet:  holdingWallet,
+		defaultTimeout: defaultTimeout,
+		maxTimeout:     maxTimeout,
+	}
+}
+
+func (s *EscrowService) CreateEscrow(ctx context.Context, input EscrowInput) (uuid.UUID, error) {
+	if input.Buyer == input.Seller {
+		return uuid.Nil, ErrEscrowSameWallet
+	}
+
+	if input.Buyer == s.holdingWallet || input.Seller == s.holdingWallet {
+		return uuid.Nil, ErrSystemWallet
+	}
+
+	timeout := input.Timeout
+	if timeout == 0 {
+		timeout = s.defaultTimeout
+	}
+	if timeout < 0 || timeout > s.maxTimeout {
+		return uuid.Nil, ErrInvalidEscrowTimeout
+	}
+
+	member, err := walletMember(ctx, s.walletRepo, s.memberRepo, input.Buyer, input.UserUUID, entity.WalletRoleSpender)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if err = checkSpendLimit(member, input.Amount); err != nil {
+		return uuid.Nil, err
+	}
+
+	buyerWallet, err := s.walletRepo.GetWalletStateById(ctx, input.Buyer)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return uuid.Nil, ErrCannotGetWallet
+		}
+		slog.Error("EscrowService.CreateEscrow", "error", err)
+		return uuid.Nil, ErrCannotCreateEscrow
+	}
+
+	if err = checkChildPolicy(ctx, s.familyRepo, buyerWallet, input.Seller); err != nil {
+		return uuid.Nil, err
+	}
+
+	// the hold leaves the buyer wallet right away, big amounts under an approval policy go via /send
+	policy, err := s.approvalRepo.GetApprovalPolicy(ctx, input.Buyer)
+	if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+		slog.Error("EscrowService.CreateEscrow", "error", err)
+		return uuid.Nil, ErrCannotCreateEscrow
+	}
+	if err == nil && input.Amount > policy.Threshold {
+		return uuid.Nil, ErrEscrowNeedsApproval
+	}
+
+	if _, err = s.walletRepo.GetWalletStateById(ctx, input.Seller); err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return uuid.Nil, ErrCannotGetWallet
+		}
+		slog.Error("EscrowService.CreateEscrow", "error", err)
+		return uuid.Nil, ErrCannotCreateEscrow
+	}
+
+	// checked last, so an escrow that fails anyway does not use up the code
+	if err = s.twoFactor.CheckTransfer(ctx, input.UserUUID, input.Amount, input.TOTPCode); err != nil {
+		return uuid.Nil, err
+	}
+
+	escrowUUID, err := s.escrowRepo.CreateEscrow(ctx, entity.Escrow{
+		Buyer:     input.Buyer,
+		Seller:    input.Seller,
+		Amount:    input.Amount,
+		CreatedBy: input.UserUUID,
+		ExpiresAt: time.Now().Add(timeout),
+	}, s.holdingWallet)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrInsufficientFunds) {
+			return uuid.Nil, ErrNotEnoughMoney
+		}
+		if errors.Is(err, repoerrors.ErrLimitExceeded) {
+			return uuid.Nil, ErrChildSpendLimitExceeded
+		}
+		slog.Error("EscrowService.CreateEscrow", "error", err)
+		return uuid.Nil, ErrCannotCreateEscrow
+	}
+
+	return escrowUUID, nil
+}
+
+// GetEscrow returns the escrow if the user is a member of its buyer or seller wallet
+func (s *EscrowService) GetEscrow(ctx context.Context, userUUID, escrowUUID uuid.UUID) (entity.Escrow, error) {
+	escrow, err := s.getEscrow(ctx, escrowUUID)
+	if err != nil {
+		return entity.Escrow{}, err
+	}
+
+	if _, err = s.member(ctx, escrow.Buyer, userUUID, entity.WalletRoleViewer); err == nil {
+		return escrow, nil
+	}
+	if _, err = s.member(ctx, escrow.Seller, userUUID, entity.WalletRoleViewer); err != nil {
+		return entity.Escrow{}, err
+	}
+
+	return escrow, nil
+}
+
+func (s *EscrowService) GetWalletEscrows(ctx context.Context, userUUID, walletUUID uuid.UUID) ([]entity.Escrow, error) {
+	if _, err := s.member(ctx, walletUUID, userUUID, entity.WalletRoleViewer); err != nil {
+		return nil, err
+	}
+
+	escrows, err := s.escrowRepo.GetWalletEscrows(ctx, walletUUID)
+	if err != nil {
+		slog.Error("EscrowService.GetWalletEscrows", "error", err)
+		return nil, ErrCannotGetEscrow
+	}
+
+	return escrows, nil
+}
+
+// ReleaseEscrow is the buyer confirmation: held funds go to the seller
+func (s *EscrowService) ReleaseEscrow(ctx context.Context, userUUID, escrowUUID uuid.UUID) error {
+	escrow, err := s.getEscrow(ctx, escrowUUID)
+	if err != nil {
+		return err
+	}
+
+	if _, err = s.member(ctx, escrow.Buyer, userUUID, entity.WalletRoleSpender); err != nil {
+		return err
+	}
+
+	return s.settle(ctx, escrow, entity.EscrowStatusReleased)
+}
+
+// CancelEscrow is the seller cancellation: held funds go back to the buyer
+func (s *EscrowService) CancelEscrow(ctx context.Context, userUUID, escrowUUID uuid.UUID) error {
+	escrow, err := s.getEscrow(ctx, escrowUUID)
+	if err != nil {
+		return err
+	}
+
+	if _, err = s.member(ctx, escrow.Seller, userUUID, entity.WalletRoleSpender); err != nil {
+		return err
+	}
+
+	return s.settle(ctx, escrow, entity.EscrowStatusRefunded)
+}
+
+// ResolveEscrow is the admin decision in favor of the seller (release) or the buyer (refund)
+func (s *EscrowService) ResolveEscrow(ctx context.Context, escrowUUID uuid.UUID, decision string) error {
+	var status string
+	switch decision {
+	case EscrowDecisionRelease:
+		status = entity.EscrowStatusReleased
+	case EscrowDecisionRefund:
+		status = entity.EscrowStatusRefunded
+	default:
+		return ErrInvalidEscrowDecision
+	}
+
+	escrow, err := s.getEscrow(ctx, escrowUUID)
+	if err != nil {
+		return err
+	}
+
+	return s.settle(ctx, escrow, status)
+}
+
+// ReleaseExpired releases to sellers all escrows whose timeout has passed
+func (s *EscrowService) ReleaseExpired(ctx context.Context) error {
+	escrows, err := s.escrowRepo.GetExpiredEscrows(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("EscrowService.ReleaseExpired: %v", err)
+	}
+
+	// one escrow that can not be settled must not hold back the others
+	for _, escrowUUID := range escrows {
+		err = s.escrowRepo.SettleEscrow(ctx, escrowUUID, s.holdingWallet, entity.EscrowStatusReleased)
+		if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+			slog.Error("EscrowService.ReleaseExpired", "escrow", escrowUUID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *EscrowService) member(ctx context.Context, walletUUID, userUUID uuid.UUID, minRole string) (entity.WalletMember, error) {
+	return walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, minRole)
+}
+
+func (s *EscrowService) getEscrow(ctx context.Context, escrowUUID uuid.UUID) (entity.Escrow, error) {
+	escrow, err := s.escrowRepo.GetEscrowById(ctx, escrowUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.Escrow{}, ErrEscrowNotFound
+		}
+		slog.Error("EscrowService.getEscrow", "error", err)
+		return entity.Escrow{}, ErrCannotGetEscrow
+	}
+
+	return escrow, nil
+}
+
+func (s *EscrowService) settle(ctx context.Context, escrow entity.Escrow, status string) error {
+	if escrow.Status != entity.EscrowStatusHeld {
+		return ErrEscrowAlreadySettled
+	}
+
+	err := s.escrowRepo.SettleEscrow(ctx, escrow.UUID, s.holdingWallet, status)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrEscrowAlreadySettled
+		}
+		slog.Error("EscrowService.settle", "error", err)
+		return ErrCannotSettleEscrow
+	}
+
+	return nil
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+	"time"
+)
+
+var periods = map[string]bool{
+	entity.PeriodDay:   true,
+	entity.PeriodWeek:  true,
+	entity.PeriodMonth: true,
+}
+
+type FamilyService struct {
+	familyRepo repo.Family
+	walletRepo repo.Wallet
+	memberRepo repo.Member
+}
+
+func NewFamilyService(familyRepo repo.Family, walletRepo repo.Wallet, memberRepo repo.Member) *FamilyService {
+	return &FamilyService{
+		familyRepo: familyRepo,
+		walletRepo: walletRepo,
+		memberRepo: memberRepo,
+	}
+}
+
+// CreateChild creates an empty wallet funded by the parent wallet. The caller becomes its owner.
+func (s *FamilyService) CreateChild(ctx context.Context, userUUID, parent uuid.UUID) (uuid.UUID, error) {
+	parentWallet, err := s.ownedParent(ctx, userUUID, parent)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if parentWallet.Parent.Valid {
+		return uuid.Nil, ErrInvalidWalletHierarchy
+	}
+
+	childUUID, err := s.walletRepo.CreateWallet(ctx, entity.Wallet{
+		Parent: uuid.NullUUID{UUID: parent, Valid: true},
+	}, userUUID)
+	if err != nil {
+		slog.Error("FamilyService.CreateChild", "error", err)
+		return uuid.Nil, ErrCannotCreateWallet
+	}
+
+	return childUUID, nil
+}
+
+// LinkChild makes an existing wallet owned by the caller a child of the parent wallet.
+// Hierarchy is one level deep: a child can not have children of its own.
+func (s *FamilyService) LinkChild(ctx context.Context, userUUID, parent, child uuid.UUID) error {
+	parentWallet, err := s.ownedParent(ctx, userUUID, parent)
+	if err != nil {
+		return err
+	}
+
+	if _, err = walletMember(ctx, s.walletRepo, s.memberRepo, child, userUUID, entity.WalletRoleOwner); err != nil {
+		return err
+	}
+
+	if parent == child || parentWallet.Parent.Valid {
+		return ErrInvalidWalletHierarchy
+	}
+
+	grandchildren, err := s.familyRepo.GetChildren(ctx, child)
+	if err != nil {
+		slog.Error("FamilyService.LinkChild", "error", err)
+		return ErrCannotUpdateFamily
+	}
+	if len(grandchildren) > 0 {
+		return ErrInvalidWalletHierarchy
+	}
+
+	if err = s.familyRepo.SetParent(ctx, child, parent); err != nil {
+		slog.Error("FamilyService.LinkChild", "error", err)
+		return ErrCannotUpdateFamily
+	}
+
+	return nil
+}
+
+func (s *FamilyService) GetChildren(ctx context.Context, userUUID, parent uuid.UUID) ([]entity.Wallet, error) {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, parent, userUUID, entity.WalletRoleViewer); err != nil {
+		return nil, err
+	}
+
+	children, err := s.familyRepo.GetChildren(ctx, parent)
+	if err != nil {
+		slog.Error("FamilyService.GetChildren", "error", err)
+		return nil, ErrCannotGetWallet
+	}
+
+	return children, nil
+}
+
+func (s *FamilyService) SetChildPolicy(ctx context.Context, userUUID, parent uuid.UUID, policy entity.ChildPolicy) error {
+	if err := s.childOf(ctx, userUUID, parent, policy.Wallet); err != nil {
+		return err
+	}
+
+	if !periods[policy.LimitPeriod] {
+		return ErrInvalidPeriod
+	}
+
+	err := s.familyRepo.SetChildPolicy(ctx, policy)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrCannotGetWallet
+		}
+		slog.Error("FamilyService.SetChildPolicy", "error", err)
+		return ErrCannotUpdateFamily
+	}
+
+	return nil
+}
+
+func (s *FamilyService) GetChildPolicy(ctx context.Context, userUUID, parent, child uuid.UUID) (entity.ChildPolicy, error) {
+	if err := s.childOf(ctx, userUUID, parent, child); err != nil {
+		return entity.ChildPolicy{}, err
+	}
+
+	policy, err := s.familyRepo.GetChildPolicy(ctx, child)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.ChildPolicy{}, ErrChildPolicyNotFound
+		}
+		slog.Error("FamilyService.GetChildPolicy", "error", err)
+		return entity.ChildPolicy{}, ErrCannotUpdateFamily
+	}
+
+	return policy, nil
+}
+
+// CreateAllowance schedules recurring top-ups of the child wallet, the first one is paid right away
+func (s *FamilyService) CreateAllowance(ctx context.Context, userUUID uuid.UUID, allowance entity.Allowance) (uuid.UUID, error) {
+	if err := s.childOf(ctx, userUUID, allowance.Parent, allowance.Child); err != nil {
+		return uuid.Nil, err
+	}
+
+	if !periods[allowance.Period] {
+		return uuid.Nil, ErrInvalidPeriod
+	}
+
+	allowance.NextRun = time.Now()
+
+	allowanceUUID, err := s.familyRepo.CreateAllowance(ctx, allowance)
+	if err != nil {
+		slog.Error("FamilyService.CreateAllowance", "error", err)
+		return uuid.Nil, ErrCannotUpdateFamily
+	}
+
+	return allowanceUUID, nil
+}
+
+func (s *FamilyService) GetAllowances(ctx context.Context, userUUID, parent, child uuid.UUID) ([]entity.Allowance, error) {
+	if err := s.childOf(ctx, userUUID, parent, child); err != nil {
+		return nil, err
+	}
+
+	allowances, err := s.familyRepo.GetAllowances(ctx, child)
+	if err != nil {
+		slog.Error("FamilyService.GetAllowances", "error", err)
+		return nil, ErrCannotUpdateFamily
+	}
+
+	return allowances, nil
+}
+
+func (s *FamilyService) DeleteAllowance(ctx context.Context, userUUID, parent, child, allowanceUUID uuid.UUID) error {
+	if err := s.childOf(ctx, userUUID, parent, child); err != nil {
+		return err
+	}
+
+	err := s.familyRepo.DeleteAllowance(ctx, allowanceUUID, child)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrAllowanceNotFound
+		}
+		slog.Error("FamilyService.DeleteAllowance", "error", err)
+		return ErrCannotUpdateFamily
+	}
+
+	return nil
+}
+
+// PayAllowances tops up child wallets whose allowance is due
+func (s *FamilyService) PayAllowances(ctx context.Context) error {
+	allowances, err := s.familyRepo.GetDueAllowances(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("FamilyService.PayAllowances: %v", err)
+	}
+
+	// one allowance that can not be paid must not hold back the others
+	for _, allowanceUUID := range allowances {
+		err = s.familyRepo.PayAllowance(ctx, allowanceUUID)
+		switch {
+		case err == nil, errors.Is(err, repoerrors.ErrNotFound):
+		case errors.Is(err, repoerrors.ErrInsufficientFunds):
+			slog.Warn("FamilyService.PayAllowances: allowance skipped, not enough money", "allowance", allowanceUUID)
+		default:
+			slog.Error("FamilyService.PayAllowances", "allowance", allowanceUUID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *FamilyService) ownedParent(ctx context.Context, userUUID, parent uuid.UUID) (entity.Wallet, error) {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, parent, userUUID, entity.WalletRoleOwner); err != nil {
+		return entity.Wallet{}, err
+	}
+
+	wallet, err := s.walletRepo.GetWalletStateById(ctx, parent)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.Wallet{}, ErrCannotGetWallet
+		}
+		slog.Error("FamilyService.ownedParent", "error", err)
+		return entity.Wallet{}, err
+	}
+
+	return wallet, nil
+}
+
+// childOf checks that the user owns the parent wallet and the child belongs to it
+func (s *FamilyService) childOf(ctx context.Context, userUUID, parent, child uuid.UUID) error {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, parent, userUUID, entity.WalletRoleOwner); err != nil {
+		return err
+	}
+
+	wallet, err := s.walletRepo.GetWalletStateById(ctx, child)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrCannotGetWallet
+		}
+		slog.Error("FamilyService.childOf", "error", err)
+		return err
+	}
+
+	if !wallet.Parent.Valid || wallet.Parent.UUID != parent {
+		return ErrNotChildWallet
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// dayCounts are the supported year lengths of the daily rate
+var dayCounts = []int{360, 365}
+
+type InterestService struct {
+	interestRepo  repo.Interest
+	walletRepo    repo.Wallet
+	memberRepo    repo.Member
+	expenseWallet uuid.UUID
+	systemWallets []uuid.UUID
+}
+
+func NewInterestService(
+	interestRepo repo.Interest,
+	walletRepo repo.Wallet,
+	memberRepo repo.Member,
+	holdingWallet, promoWallet, feeWallet, expenseWallet uuid.UUID,
+) *InterestService {
+	return &InterestService{
+		interestRepo:  interestRepo,
+		walletRepo:    walletRepo,
+		memberRepo:    memberRepo,
+		expenseWallet: expenseWallet,
+		systemWallets: []uuid.UUID{holdingWallet, promoWallet, feeWallet, expenseWallet},
+	}
+}
+
+func (s *InterestService) CreateProduct(ctx context.Context, product entity.InterestProduct) (uuid.UUID, error) {
+	if product.DayCount == 0 {
+		product.DayCount = 365
+	}
+	if err := validateInterestProduct(product); err != nil {
+		return uuid.Nil, err
+	}
+
+	productUUID, err := s.interestRepo.CreateProduct(ctx, product)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrAlreadyExist) {
+			return uuid.Nil, ErrInterestProductAlreadyExists
+		}
+		slog.Error("InterestService.CreateProduct", "error", err)
+		return uuid.Nil, ErrCannotUpdateInterest
+	}
+
+	return productUUID, nil
+}
+
+func (s *InterestService) GetProducts(ctx context.Context) ([]entity.InterestProduct, error) {
+	products, err := s.interestRepo.GetProducts(ctx)
+	if err != nil {
+		slog.Error("InterestService.GetProducts", "error", err)
+		return nil, ErrCannotGetInterest
+	}
+
+	return products, nil
+}
+
+// UpdateProduct changes the product for the following days, accrued interest keeps the old rate
+func (s *InterestService) UpdateProduct(ctx context.Context, product entity.InterestProduct) error {
+	if product.DayCount == 0 {
+		product.DayCount = 365
+	}
+	if err := validateInterestProduct(product); err != nil {
+		return err
+	}
+
+	err := s.interestRepo.UpdateProduct(ctx, product)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrInterestProductNotFound
+		}
+		if errors.Is(err, repoerrors.ErrAlreadyExist) {
+			return ErrInterestProductAlreadyExists
+		}
+		slog.Error("InterestService.UpdateProduct", "error", err)
+		return ErrCannotUpdateInterest
+	}
+
+	return nil
+}
+
+// AssignProduct puts the wallet on the product, an invalid product stops further accruals
+func (s *InterestService) AssignProduct(ctx context.Context, walletUUID uuid.UUID, product uuid.NullUUID) error {
+	if slices.Contains(s.systemWallets, walletUUID) {
+		return ErrSystemWallet
+	}
+
+	err := s.interestRepo.AssignProduct(ctx, walletUUID, product)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			if product.Valid {
+				return ErrInterestProductNotFound
+			}
+			return ErrCannotGetWallet
+		}
+		slog.Error("InterestService.AssignProduct", "error", err)
+		return ErrCannotUpdateInterest
+	}
+
+	return nil
+}
+
+func (s *InterestService) GetSummary(ctx context.Context, userUUID, walletUUID uuid.UUID) (entity.InterestSummary, error) {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, entity.WalletRoleViewer); err != nil {
+		return entity.InterestSummary{}, err
+	}
+
+	summary, err := s.interestRepo.GetSummary(ctx, walletUUID)
+	if err != nil {
+		slog.Error("InterestService.GetSummary", "error", err)
+		return entity.InterestSummary{}, ErrCannotGetInterest
+	}
+
+	return summary, nil
+}
+
+// AccrueAndPay accrues today's interest on the balance the wallet has at the first run of the day,
+// together with the days missed since the previous run, and pays out everything accrued before the current month. Both steps are idempotent,
+// so the job may run any number of times a day and be restarted after a crash.
+func (s *InterestService) AccrueAndPay(ctx context.Context) error {
+	now := time.Now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	if _, err := s.interestRepo.AccrueInterest(ctx, day); err != nil {
+		return fmt.Errorf("InterestService.AccrueAndPay: %v", err)
+	}
+
+	wallets, err := s.interestRepo.GetUnpaidWallets(ctx, month)
+	if err != nil {
+		return fmt.Errorf("InterestService.AccrueAndPay: %v", err)
+	}
+
+	// one wallet that can not be paid must not hold back the others
+	for _, walletUUID := range wallets {
+		if _, err = s.interestRepo.PayInterest(ctx, walletUUID, s.expenseWallet, month); err != nil {
+			slog.Error("InterestService.AccrueAndPay", "wallet", walletUUID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func validateInterestProduct(product entity.InterestProduct) error {
+	if product.Name == "" || product.APR <= 0 || product.APR > 100 || product.MinBalance < 0 ||
+		!slices.Contains(dayCounts, product.DayCount) {
+		return ErrInvalidInterestProduct
+	}
+	return nil
+}
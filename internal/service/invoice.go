@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+	"math"
+	"slices"
+	"time"
+)
+
+// invoiceEpsilon absorbs float rounding when comparing payments with the invoice amount
+const invoiceEpsilon = 1e-6
+
+type InvoiceService struct {
+	invoiceRepo   repo.Invoice
+	merchantRepo  repo.Merchant
+	walletRepo    repo.Wallet
+	memberRepo    repo.Member
+	familyRepo    repo.Family
+	approvalRepo  repo.Approval
+	twoFactor     TwoFactor
+	holdingWallet uuid.UUID
+	currency      string
+}
+
+func NewInvoiceService(
+	invoiceRepo repo.Invoice,
+	merchantRepo repo.Merchant,
+	walletRepo repo.Wallet,
+	memberRepo repo.Member,
+	familyRepo repo.Family,
+	approvalRepo repo.Approval,
+	twoFactor TwoFactor,
+	holdingWallet uuid.UUID,
+	currency string,
+) *InvoiceService {
+	return &InvoiceService{
+		invoiceRepo:   invoiceRepo,
+		merchantRepo:  merchantRepo,
+		walletRepo:    walletRepo,
+		memberRepo:    memberRepo,
+		familyRepo:    familyRepo,
+		approvalRepo:  approvalRepo,
+		twoFactor:     twoFactor,
+		holdingWallet: holdingWallet,
+		currency:      currency,
+	}
+}
+
+// CreateInvoice issues an invoice of the user's merchant. With line items the amount is their total.
+func (s *InvoiceService) CreateInvoice(ctx context.Context, userUUID uuid.UUID, invoice entity.Invoice) (uuid.UUID, error) {
+	merchant, err := merchantOf(ctx, s.merchantRepo, userUUID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if !slices.Contains(merchant.Wallets, invoice.Wallet) {
+		return uuid.Nil, ErrNotSettlementWallet
+	}
+
+	if invoice.Currency == "" {
+		invoice.Currency = s.currency
+	}
+	if invoice.Currency != s.currency {
+		return uuid.Nil, ErrInvalidCurrency
+	}
+
+	if len(invoice.Items) > 0 {
+		var total float64
+		for _, item := range invoice.Items {
+			total += float64(item.Quantity) * item.Price
+		}
+		if invoice.Amount != 0 && math.Abs(invoice.Amount-total) > invoiceEpsilon {
+			return uuid.Nil, ErrInvalidInvoice
+		}
+		invoice.Amount = total
+	}
+
+	if invoice.Amount <= 0 || invoice.ExpiresAt != nil && invoice.ExpiresAt.Before(time.Now()) {
+		return uuid.Nil, ErrInvalidInvoice
+	}
+
+	invoice.Merchant = merchant.UUID
+
+	invoiceUUID, err := s.invoiceRepo.CreateInvoice(ctx, invoice)
+	if err != nil {
+		slog.Error("InvoiceService.CreateInvoice", "error", err)
+		return uuid.Nil, ErrCannotCreateInvoice
+	}
+
+	return invoiceUUID, nil
+}
+
+// GetInvoice returns the invoice to anybody knowing its id, so it can be shared with the payer
+func (s *InvoiceService) GetInvoice(ctx context.Context, invoiceUUID uuid.UUID) (entity.Invoice, error) {
+	return s.getInvoice(ctx, invoiceUUID)
+}
+
+func (s *InvoiceService) GetInvoices(ctx context.Context, userUUID uuid.UUID) ([]entity.Invoice, error) {
+	merchant, err := merchantOf(ctx, s.merchantRepo, userUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	invoices, err := s.invoiceRepo.GetMerchantInvoices(ctx, merchant.UUID)
+	if err != nil {
+		slog.Error("InvoiceService.GetInvoices", "error", err)
+		return nil, ErrCannotGetInvoice
+	}
+
+	for i := range invoices {
+		expire(&invoices[i])
+	}
+
+	return invoices, nil
+}
+
+func (s *InvoiceService) CancelInvoice(ctx context.Context, userUUID, invoiceUUID uuid.UUID) error {
+	merchant, err := merchantOf(ctx, s.merchantRepo, userUUID)
+	if err != nil {
+		return err
+	}
+
+	invoice, err := s.getInvoice(ctx, invoiceUUID)
+	if err != nil {
+		return err
+	}
+
+	if invoice.Merchant != merchant.UUID {
+		return ErrInvoiceNotFound
+	}
+
+	err = s.invoiceRepo.CancelInvoice(ctx, invoiceUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrInvoiceNotPayable
+		}
+		slog.Error("InvoiceService.CancelInvoice", "error", err)
+		return ErrCannotPayInvoice
+	}
+
+	return nil
+}
+
+// PayInvoice pays the invoice from the wallet. Without an amount the whole remaining sum is paid.
+func (s *InvoiceService) PayInvoice(ctx context.Context, input InvoicePaymentInput) (entity.Invoice, error) {
+	if input.Wallet == s.holdingWallet {
+		return entity.Invoice{}, ErrSystemWallet
+	}
+
+	invoice, err := s.getInvoice(ctx, input.Invoice)
+	if err != nil {
+		return entity.Invoice{}, err
+	}
+
+	switch invoice.Status {
+	case entity.InvoiceStatusOpen, entity.InvoiceStatusPartiallyPaid:
+	case entity.InvoiceStatusExpired:
+		return entity.Invoice{}, ErrInvoiceExpired
+	default:
+		return entity.Invoice{}, ErrInvoiceNotPayable
+	}
+
+	remaining := invoice.Amount - invoice.Paid
+	amount := input.Amount
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount <= 0 || amount > remaining+invoiceEpsilon ||
+		!invoice.AllowPartial && amount < remaining-invoiceEpsilon {
+		return entity.Invoice{}, ErrInvalidInvoiceAmount
+	}
+
+	if input.Wallet == invoice.Wallet {
+		return entity.Invoice{}, ErrInvalidInvoiceAmount
+	}
+
+	member, err := walletMember(ctx, s.walletRepo, s.memberRepo, input.Wallet, input.UserUUID, entity.WalletRoleSpender)
+	if err != nil {
+		return entity.Invoice{}, err
+	}
+
+	if err = checkSpendLimit(member, amount); err != nil {
+		return entity.Invoice{}, err
+	}
+
+	wallet, err := s.walletRepo.GetWalletStateById(ctx, input.Wallet)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.Invoice{}, ErrCannotGetWallet
+		}
+		slog.Error("InvoiceService.PayInvoice", "error", err)
+		return entity.Invoice{}, ErrCannotPayInvoice
+	}
+
+	if err = checkChildPolicy(ctx, s.familyRepo, wallet, invoice.Wallet); err != nil {
+		return entity.Invoice{}, err
+	}
+
+	// payments can not wait for approvers, wallets under an approval policy pay big invoices via /send
+	policy, err := s.approvalRepo.GetApprovalPolicy(ctx, input.Wallet)
+	if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+		slog.Error("InvoiceService.PayInvoice", "error", err)
+		return entity.Invoice{}, ErrCannotPayInvoice
+	}
+	if err == nil && amount > policy.Threshold {
+		return entity.Invoice{}, ErrInvoiceNeedsApproval
+	}
+
+	// checked last, so a payment that fails anyway does not use up the code
+	if err = s.twoFactor.CheckTransfer(ctx, input.UserUUID, amount, input.TOTPCode); err != nil {
+		return entity.Invoice{}, err
+	}
+
+	invoice, err = s.invoiceRepo.PayInvoice(ctx, input.Invoice, input.Wallet, amount)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrInsufficientFunds) {
+			return entity.Invoice{}, ErrNotEnoughMoney
+		}
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.Invoice{}, ErrInvoiceNotPayable
+		}
+		if errors.Is(err, repoerrors.ErrLimitExceeded) {
+			return entity.Invoice{}, ErrChildSpendLimitExceeded
+		}
+		slog.Error("InvoiceService.PayInvoice", "error", err)
+		return entity.Invoice{}, ErrCannotPayInvoice
+	}
+
+	return invoice, nil
+}
+
+func (s *InvoiceService) getInvoice(ctx context.Context, invoiceUUID uuid.UUID) (entity.Invoice, error) {
+	invoice, err := s.invoiceRepo.GetInvoiceById(ctx, invoiceUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.Invoice{}, ErrInvoiceNotFound
+		}
+		slog.Error("InvoiceService.getInvoice", "error", err)
+		return entity.Invoice{}, ErrCannotGetInvoice
+	}
+
+	expire(&invoice)
+
+	return invoice, nil
+}
+
+// expire reports unpaid invoices past their expiry as expired, payments made before stay with the merchant
+func expire(invoice *entity.Invoice) {
+	if invoice.ExpiresAt == nil || invoice.ExpiresAt.After(time.Now()) {
+		return
+	}
+	if invoice.Status == entity.InvoiceStatusOpen || invoice.Status == entity.InvoiceStatusPartiallyPaid {
+		invoice.Status = entity.InvoiceStatusExpired
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+	"time"
+)
+
+// LockoutConfig describes sign-in lockout. A username is locked after UserThreshold failures in a row,
+// an address after IPThreshold. The first lock lasts BaseDelay, every further failure doubles it up to MaxDelay.
+// The count starts over after ResetAfter without failures.
+type LockoutConfig struct {
+	UserThreshold int
+	IPThreshold   int
+	BaseDelay     time.Duration
+	MaxDelay      time.Duration
+	ResetAfter    time.Duration
+}
+
+type LockoutService struct {
+	attemptRepo repo.LoginAttempt
+	userRepo    repo.User
+	cfg         LockoutConfig
+}
+
+func NewLockoutService(attemptRepo repo.LoginAttempt, userRepo repo.User, cfg LockoutConfig) *LockoutService {
+	return &LockoutService{
+		attemptRepo: attemptRepo,
+		userRepo:    userRepo,
+		cfg:         cfg,
+	}
+}
+
+// CheckSignIn returns ErrSignInLocked while the username or the address is locked
+func (s *LockoutService) CheckSignIn(ctx context.Context, username, ip string) error {
+	now := time.Now()
+	for _, key := range []string{userLockoutKey(username), ipLockoutKey(ip)} {
+		attempts, err := s.attemptRepo.GetLoginAttempts(ctx, key)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				continue
+			}
+			slog.Error("LockoutService.CheckSignIn", "error", err)
+			return ErrCannotCheckSignIn
+		}
+
+		if attempts.LockedUntil != nil && attempts.LockedUntil.After(now) {
+			return ErrSignInLocked
+		}
+	}
+
+	return nil
+}
+
+// SignInFailed counts the failure for the username and the address and locks the ones over their threshold.
+// Errors are only logged, the sign-in fails anyway.
+func (s *LockoutService) SignInFailed(ctx context.Context, username, ip string) {
+	s.addFailure(ctx, userLockoutKey(username), s.cfg.UserThreshold)
+	s.addFailure(ctx, ipLockoutKey(ip), s.cfg.IPThreshold)
+}
+
+// SignInSucceeded forgets the failures of the username. Those of the address are kept,
+// otherwise signing in to an own account would reset the count of an attacker.
+func (s *LockoutService) SignInSucceeded(ctx context.Context, username string) {
+	err := s.attemptRepo.ResetLoginAttempts(ctx, userLockoutKey(username))
+	if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+		slog.Error("LockoutService.SignInSucceeded", "error", err)
+	}
+}
+
+func (s *LockoutService) GetLockouts(ctx context.Context) ([]entity.LoginAttempts, error) {
+	locked, err := s.attemptRepo.GetLockedLogins(ctx, time.Now())
+	if err != nil {
+		slog.Error("LockoutService.GetLockouts", "error", err)
+		return nil, ErrCannotGetLockouts
+	}
+
+	return locked, nil
+}
+
+// Unlock lets the user sign in again at once and forgets the failures of the username
+func (s *LockoutService) Unlock(ctx context.Context, adminUUID, userUUID uuid.UUID) error {
+	user, err := s.userRepo.GetUserById(ctx, userUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		slog.Error("LockoutService.Unlock", "error", err)
+		return ErrCannotUnlock
+	}
+
+	err = s.attemptRepo.ResetLoginAttempts(ctx, userLockoutKey(user.Username))
+	if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+		slog.Error("LockoutService.Unlock", "error", err)
+		return ErrCannotUnlock
+	}
+
+	slog.Warn("security event: sign-in unlocked", "user", userUUID, "admin", adminUUID)
+
+	return nil
+}
+
+// CleanupAttempts drops counts that would start over anyway and have no running lock
+func (s *LockoutService) CleanupAttempts(ctx context.Context) error {
+	if _, err := s.attemptRepo.DeleteStaleLoginAttempts(ctx, time.Now().Add(-s.cfg.ResetAfter)); err != nil {
+		return fmt.Errorf("LockoutService.CleanupAttempts: %v", err)
+	}
+
+	return nil
+}
+
+func (s *LockoutService) addFailure(ctx context.Context, key string, threshold int) {
+	now := time.Now()
+	attempts, err := s.attemptRepo.AddFailedLogin(ctx, key, now, s.cfg.ResetAfter)
+	if err != nil {
+		slog.Error("LockoutService.addFailure", "error", err)
+		return
+	}
+
+	if attempts.Failures < threshold {
+		return
+	}
+
+	until := now.Add(s.lockDuration(attempts.Failures - threshold))
+	if err = s.attemptRepo.LockLogin(ctx, key, until); err != nil {
+		slog.Error("LockoutService.addFailure", "error", err)
+		return
+	}
+
+	slog.Warn("security event: sign-in locked", "key", key, "failures", attempts.Failures, "until", until)
+}
+
+// lockDuration doubles BaseDelay for every failure over the threshold, capped by MaxDelay
+func (s *LockoutService) lockDuration(over int) time.Duration {
+	delay := s.cfg.BaseDelay
+	for i := 0; i < over && delay < s.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, s.cfg.MaxDelay)
+}
+
+func userLockoutKey(username string) string {
+	return "user:" + username
+}
+
+func ipLockoutKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+)
+
+type MemberService struct {
+	memberRepo repo.Member
+	walletRepo repo.Wallet
+}
+
+func NewMemberService(memberRepo repo.Member, walletRepo repo.Wallet) *MemberService {
+	return &MemberService{
+		memberRepo: memberRepo,
+		walletRepo: walletRepo,
+	}
+}
+
+func (s *MemberService) GetMembers(ctx context.Context, userUUID, walletUUID uuid.UUID) ([]entity.WalletMember, error) {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, entity.WalletRoleViewer); err != nil {
+		return nil, err
+	}
+
+	members, err := s.memberRepo.GetMembers(ctx, walletUUID)
+	if err != nil {
+		slog.Error("MemberService.GetMembers", "error", err)
+		return nil, ErrCannotGetMembers
+	}
+
+	return members, nil
+}
+
+// InviteMember creates an invitation the invited user has to accept before getting access
+func (s *MemberService) InviteMember(ctx context.Context, userUUID uuid.UUID, member entity.WalletMember) error {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, member.Wallet, userUUID, entity.WalletRoleOwner); err != nil {
+		return err
+	}
+
+	if err := normalizeMember(&member); err != nil {
+		return err
+	}
+
+	member.Status = entity.MemberStatusInvited
+	member.InvitedBy = uuid.NullUUID{UUID: userUUID, Valid: true}
+
+	err := s.memberRepo.AddMember(ctx, member)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrAlreadyExist) {
+			return ErrMemberAlreadyExists
+		}
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		slog.Error("MemberService.InviteMember", "error", err)
+		return ErrCannotUpdateMember
+	}
+
+	return nil
+}
+
+func (s *MemberService) UpdateMember(ctx context.Context, userUUID uuid.UUID, member entity.WalletMember) error {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, member.Wallet, userUUID, entity.WalletRoleOwner); err != nil {
+		return err
+	}
+
+	if err := normalizeMember(&member); err != nil {
+		return err
+	}
+
+	return s.memberError("MemberService.UpdateMember", s.memberRepo.UpdateMember(ctx, member))
+}
+
+// RemoveMember removes a member by an owner, or lets any member leave the wallet
+func (s *MemberService) RemoveMember(ctx context.Context, userUUID, walletUUID, memberUUID uuid.UUID) error {
+	minRole := entity.WalletRoleOwner
+	if memberUUID == userUUID {
+		minRole = entity.WalletRoleViewer
+	}
+
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, minRole); err != nil {
+		return err
+	}
+
+	return s.memberError("MemberService.RemoveMember", s.memberRepo.DeleteMember(ctx, walletUUID, memberUUID))
+}
+
+func (s *MemberService) GetInvitations(ctx context.Context, userUUID uuid.UUID) ([]entity.WalletMember, error) {
+	invitations, err := s.memberRepo.GetInvitations(ctx, userUUID)
+	if err != nil {
+		slog.Error("MemberService.GetInvitations", "error", err)
+		return nil, ErrCannotGetMembers
+	}
+
+	return invitations, nil
+}
+
+func (s *MemberService) AcceptInvitation(ctx context.Context, userUUID, walletUUID uuid.UUID) error {
+	err := s.memberRepo.ActivateMember(ctx, walletUUID, userUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrInvitationNotFound
+		}
+		slog.Error("MemberService.AcceptInvitation", "error", err)
+		return ErrCannotUpdateMember
+	}
+
+	return nil
+}
+
+func (s *MemberService) DeclineInvitation(ctx context.Context, userUUID, walletUUID uuid.UUID) error {
+	member, err := s.memberRepo.GetMember(ctx, walletUUID, userUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrInvitationNotFound
+		}
+		slog.Error("MemberService.DeclineInvitation", "error", err)
+		return ErrCannotUpdateMember
+	}
+
+	if member.Status != entity.MemberStatusInvited {
+		return ErrInvitationNotFound
+	}
+
+	return s.memberError("MemberService.DeclineInvitation", s.memberRepo.DeleteMember(ctx, walletUUID, userUUID))
+}
+
+func (s *MemberService) memberError(op string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repoerrors.ErrNotFound):
+		return ErrMemberNotFound
+	case errors.Is(err, repoerrors.ErrLastOwner):
+		return ErrLastWalletOwner
+	}
+	slog.Error(op, "error", err)
+	return ErrCannotUpdateMember
+}
+
+// normalizeMember validates the role and keeps the spend limit for spenders only
+func normalizeMember(member *entity.WalletMember) error {
+	if _, ok := walletRoleRank[member.Role]; !ok {
+		return ErrInvalidWalletRole
+	}
+
+	if member.Role != entity.WalletRoleSpender {
+		member.SpendLimit = nil
+	}
+
+	return nil
+}
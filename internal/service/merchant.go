@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+)
+
+type MerchantService struct {
+	merchantRepo repo.Merchant
+	walletRepo   repo.Wallet
+	memberRepo   repo.Member
+}
+
+func NewMerchantService(merchantRepo repo.Merchant, walletRepo repo.Wallet, memberRepo repo.Member) *MerchantService {
+	return &MerchantService{
+		merchantRepo: merchantRepo,
+		walletRepo:   walletRepo,
+		memberRepo:   memberRepo,
+	}
+}
+
+// CreateMerchant attaches a merchant profile to the user. Settlement wallets must be owned by the user.
+func (s *MerchantService) CreateMerchant(ctx context.Context, userUUID uuid.UUID, merchant entity.Merchant) (uuid.UUID, error) {
+	for _, wallet := range merchant.Wallets {
+		if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, wallet, userUUID, entity.WalletRoleOwner); err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	merchant.Owner = userUUID
+
+	merchantUUID, err := s.merchantRepo.CreateMerchant(ctx, merchant)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrAlreadyExist) {
+			return uuid.Nil, ErrMerchantAlreadyExists
+		}
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return uuid.Nil, ErrCannotGetWallet
+		}
+		slog.Error("MerchantService.CreateMerchant", "error", err)
+		return uuid.Nil, ErrCannotUpdateMerchant
+	}
+
+	return merchantUUID, nil
+}
+
+func (s *MerchantService) GetMerchant(ctx context.Context, userUUID uuid.UUID) (entity.Merchant, error) {
+	return merchantOf(ctx, s.merchantRepo, userUUID)
+}
+
+func (s *MerchantService) AddWallet(ctx context.Context, userUUID, walletUUID uuid.UUID) error {
+	merchant, err := merchantOf(ctx, s.merchantRepo, userUUID)
+	if err != nil {
+		return err
+	}
+
+	if _, err = walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, entity.WalletRoleOwner); err != nil {
+		return err
+	}
+
+	err = s.merchantRepo.AddMerchantWallet(ctx, merchant.UUID, walletUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrAlreadyExist) {
+			return ErrSettlementWalletExists
+		}
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrCannotGetWallet
+		}
+		slog.Error("MerchantService.AddWallet", "error", err)
+		return ErrCannotUpdateMerchant
+	}
+
+	return nil
+}
+
+// RemoveWallet stops using the wallet for new invoices, issued invoices are still paid into it
+func (s *MerchantService) RemoveWallet(ctx context.Context, userUUID, walletUUID uuid.UUID) error {
+	merchant, err := merchantOf(ctx, s.merchantRepo, userUUID)
+	if err != nil {
+		return err
+	}
+
+	err = s.merchantRepo.DeleteMerchantWallet(ctx, merchant.UUID, walletUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrNotSettlementWallet
+		}
+		slog.Error("MerchantService.RemoveWallet", "error", err)
+		return ErrCannotUpdateMerchant
+	}
+
+	return nil
+}
+
+// merchantOf returns the merchant profile of the user
+func merchantOf(ctx context.Context, merchantRepo repo.Merchant, userUUID uuid.UUID) (entity.Merchant, error) {
+	merchant, err := merchantRepo.GetMerchantByOwner(ctx, userUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.Merchant{}, ErrMerchantNotFound
+		}
+		slog.Error("service.merchantOf", "error", err)
+		return entity.Merchant{}, ErrCannotUpdateMerchant
+	}
+
+	return merchant, nil
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/notify"
+	"log/slog"
+	"time"
+)
+
+// ChangePassword sets a new password after checking the old one and signs the user out everywhere
+func (s *AuthService) ChangePassword(ctx context.Context, userUUID uuid.UUID, oldPassword, newPassword string) error {
+	user, err := s.userRepo.GetUserById(ctx, userUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		slog.Error("AuthService.ChangePassword", "error", err)
+		return ErrCannotChangePassword
+	}
+
+	ok, err := s.passwordHasher.Verify(oldPassword, user.Password)
+	if err != nil {
+		slog.Error("AuthService.ChangePassword", "error", err)
+		return ErrCannotChangePassword
+	}
+	if !ok {
+		return ErrInvalidPassword
+	}
+
+	hash, err := s.passwordHasher.Hash(newPassword)
+	if err != nil {
+		slog.Error("AuthService.ChangePassword", "error", err)
+		return ErrCannotChangePassword
+	}
+
+	// the password and the sign out everywhere commit together, a failure can not leave old sessions working
+	now := time.Now()
+	if err = s.resetRepo.ChangePassword(ctx, userUUID, hash, now); err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		slog.Error("AuthService.ChangePassword", "error", err)
+		return ErrCannotChangePassword
+	}
+	s.CacheRevocation(userUUID, now)
+
+	slog.Warn("security event: password changed", "user", userUUID)
+
+	return nil
+}
+
+// RequestPasswordReset sends a reset token to the user. It succeeds for unknown usernames too and
+// drops requests over the limit silently, so neither tells whether the username exists.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, username string) error {
+	if !s.resetRequests.Allow(username) {
+		slog.Warn("AuthService.RequestPasswordReset: too many requests", "username", username)
+		return nil
+	}
+
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return nil
+		}
+		slog.Error("AuthService.RequestPasswordReset", "error", err)
+		return ErrCannotResetPassword
+	}
+
+	token, hash, err := generateOpaqueToken()
+	if err != nil {
+		slog.Error("AuthService.RequestPasswordReset", "error", err)
+		return ErrCannotResetPassword
+	}
+
+	expiresAt := time.Now().Add(s.token.ResetTTL)
+	err = s.resetRepo.CreatePasswordResetToken(ctx, entity.PasswordResetToken{
+		Owner:     user.UUID,
+		TokenHash: hash,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		slog.Error("AuthService.RequestPasswordReset", "error", err)
+		return ErrCannotResetPassword
+	}
+
+	err = s.notifier.Notify(ctx, notify.Message{
+		To:      user.Username,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Use the token %s to set a new password, it expires at %s. "+
+			"If you did not ask for it, ignore this message.", token, expiresAt.Format(time.RFC3339)),
+	})
+	if err != nil {
+		slog.Error("AuthService.RequestPasswordReset", "error", err)
+		return ErrCannotResetPassword
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token, the token and every older one stop working.
+// The user is signed out everywhere.
+func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	hash, err := s.passwordHasher.Hash(newPassword)
+	if err != nil {
+		slog.Error("AuthService.ResetPassword", "error", err)
+		return ErrCannotResetPassword
+	}
+
+	now := time.Now()
+	owner, err := s.resetRepo.ResetPassword(ctx, hashOpaqueToken(token), hash, now)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) || errors.Is(err, repoerrors.ErrUnavailable) {
+			return ErrInvalidResetToken
+		}
+		slog.Error("AuthService.ResetPassword", "error", err)
+		return ErrCannotResetPassword
+	}
+	s.CacheRevocation(owner, now)
+
+	slog.Warn("security event: password reset", "user", owner)
+
+	return nil
+}
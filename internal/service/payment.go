@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/emvqr"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// numeric ISO 4217 codes used by EMV QR payloads
+var currencyCodes = map[string]string{
+	"RUB": "643",
+	"USD": "840",
+	"EUR": "978",
+}
+
+// PaymentQR describes how payment payloads are labelled, see emvqr for the format
+type PaymentQR struct {
+	GUID         string
+	MerchantName string
+	MerchantCity string
+	CountryCode  string
+	ImageSize    int
+}
+
+type PaymentService struct {
+	walletRepo repo.Wallet
+	memberRepo repo.Member
+	currency   string
+	qr         PaymentQR
+}
+
+func NewPaymentService(walletRepo repo.Wallet, memberRepo repo.Member, currency string, qr PaymentQR) *PaymentService {
+	return &PaymentService{
+		walletRepo: walletRepo,
+		memberRepo: memberRepo,
+		currency:   currency,
+		qr:         qr,
+	}
+}
+
+// EncodePayload builds an EMV QR payload asking to pay into the wallet. Members of the wallet only.
+//
+// Wallet goes into merchant account template 26 (00 - GUID, 01 - wallet id), memo into
+// purpose of transaction of template 62 and expiry as unix seconds into template 80 (00 - GUID, 01 - expiry).
+func (s *PaymentService) EncodePayload(ctx context.Context, userUUID uuid.UUID, request entity.PaymentRequest) (string, error) {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, request.To, userUUID, entity.WalletRoleViewer); err != nil {
+		return "", err
+	}
+
+	if request.Currency == "" {
+		request.Currency = s.currency
+	}
+	currency, ok := currencyCodes[request.Currency]
+	if !ok || request.Currency != s.currency {
+		return "", ErrInvalidCurrency
+	}
+
+	if request.Amount < 0 || request.ExpiresAt != nil && request.ExpiresAt.Before(time.Now()) {
+		return "", ErrInvalidPaymentPayload
+	}
+
+	account, err := emvqr.Template(
+		emvqr.Field{ID: emvqr.IDGloballyUnique, Value: s.qr.GUID},
+		emvqr.Field{ID: "01", Value: request.To.String()},
+	)
+	if err != nil {
+		slog.Error("PaymentService.EncodePayload", "error", err)
+		return "", ErrCannotEncodePayload
+	}
+
+	point := emvqr.StaticPoint
+	if request.Amount > 0 || request.ExpiresAt != nil {
+		point = emvqr.DynamicPoint
+	}
+
+	fields := []emvqr.Field{
+		{ID: emvqr.IDPayloadFormat, Value: emvqr.PayloadFormat},
+		{ID: emvqr.IDPointOfInitiation, Value: point},
+		{ID: emvqr.IDMerchantAccount, Value: account},
+		{ID: emvqr.IDMerchantCategory, Value: "0000"},
+		{ID: emvqr.IDCurrency, Value: currency},
+	}
+
+	if request.Amount > 0 {
+		fields = append(fields, emvqr.Field{ID: emvqr.IDAmount, Value: strconv.FormatFloat(request.Amount, 'f', 2, 64)})
+	}
+
+	fields = append(fields,
+		emvqr.Field{ID: emvqr.IDCountry, Value: s.qr.CountryCode},
+		emvqr.Field{ID: emvqr.IDMerchantName, Value: s.qr.MerchantName},
+		emvqr.Field{ID: emvqr.IDMerchantCity, Value: s.qr.MerchantCity},
+	)
+
+	if request.Memo != "" {
+		additional, err := emvqr.Template(emvqr.Field{ID: emvqr.IDPurpose, Value: request.Memo})
+		if err != nil {
+			return "", ErrInvalidPaymentPayload
+		}
+		fields = append(fields, emvqr.Field{ID: emvqr.IDAdditionalData, Value: additional})
+	}
+
+	if request.ExpiresAt != nil {
+		expiry, err := emvqr.Template(
+			emvqr.Field{ID: emvqr.IDGloballyUnique, Value: s.qr.GUID},
+			emvqr.Field{ID: "01", Value: strconv.FormatInt(request.ExpiresAt.Unix(), 10)},
+		)
+		if err != nil {
+			slog.Error("PaymentService.EncodePayload", "error", err)
+			return "", ErrCannotEncodePayload
+		}
+		fields = append(fields, emvqr.Field{ID: emvqr.IDUnreserved, Value: expiry})
+	}
+
+	payload, err := emvqr.Encode(fields)
+	if err != nil {
+		slog.Error("PaymentService.EncodePayload", "error", err)
+		return "", ErrCannotEncodePayload
+	}
+
+	return payload, nil
+}
+
+// EncodeQR renders the payment payload as a PNG QR code
+func (s *PaymentService) EncodeQR(ctx context.Context, userUUID uuid.UUID, request entity.PaymentRequest) ([]byte, error) {
+	payload, err := s.EncodePayload(ctx, userUUID, request)
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := qrcode.Encode(payload, qrcode.Medium, s.qr.ImageSize)
+	if err != nil {
+		slog.Error("PaymentService.EncodeQR", "error", err)
+		return nil, ErrCannotEncodePayload
+	}
+
+	return image, nil
+}
+
+// DecodePayload validates a scanned payload and turns it into a transfer quote
+func (s *PaymentService) DecodePayload(ctx context.Context, payload string) (entity.PaymentRequest, error) {
+	fields, err := emvqr.Decode(payload)
+	if err != nil {
+		return entity.PaymentRequest{}, ErrInvalidPaymentPayload
+	}
+
+	if format, _ := emvqr.Lookup(fields, emvqr.IDPayloadFormat); format != emvqr.PayloadFormat {
+		return entity.PaymentRequest{}, ErrInvalidPaymentPayload
+	}
+
+	var request entity.PaymentRequest
+
+	account, err := s.template(fields, emvqr.IDMerchantAccount)
+	if err != nil {
+		return entity.PaymentRequest{}, err
+	}
+	wallet, _ := emvqr.Lookup(account, "01")
+	if request.To, err = uuid.Parse(wallet); err != nil {
+		return entity.PaymentRequest{}, ErrInvalidPaymentPayload
+	}
+
+	currency, _ := emvqr.Lookup(fields, emvqr.IDCurrency)
+	if currency != currencyCodes[s.currency] {
+		return entity.PaymentRequest{}, ErrInvalidCurrency
+	}
+	request.Currency = s.currency
+
+	if amount, ok := emvqr.Lookup(fields, emvqr.IDAmount); ok {
+		if request.Amount, err = strconv.ParseFloat(amount, 64); err != nil || request.Amount <= 0 {
+			return entity.PaymentRequest{}, ErrInvalidPaymentPayload
+		}
+	}
+
+	if additional, ok := emvqr.Lookup(fields, emvqr.IDAdditionalData); ok {
+		data, err := emvqr.ParseTemplate(additional)
+		if err != nil {
+			return entity.PaymentRequest{}, ErrInvalidPaymentPayload
+		}
+		request.Memo, _ = emvqr.Lookup(data, emvqr.IDPurpose)
+	}
+
+	if _, ok := emvqr.Lookup(fields, emvqr.IDUnreserved); ok {
+		expiry, err := s.template(fields, emvqr.IDUnreserved)
+		if err != nil {
+			return entity.PaymentRequest{}, err
+		}
+		value, _ := emvqr.Lookup(expiry, "01")
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return entity.PaymentRequest{}, ErrInvalidPaymentPayload
+		}
+		expiresAt := time.Unix(seconds, 0).UTC()
+		if expiresAt.Before(time.Now()) {
+			return entity.PaymentRequest{}, ErrPaymentRequestExpired
+		}
+		request.ExpiresAt = &expiresAt
+	}
+
+	if _, err = s.walletRepo.GetWalletStateById(ctx, request.To); err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.PaymentRequest{}, ErrCannotGetWallet
+		}
+		slog.Error("PaymentService.DecodePayload", "error", err)
+		return entity.PaymentRequest{}, err
+	}
+
+	return request, nil
+}
+
+// template parses the template field and makes sure it was issued by us
+func (s *PaymentService) template(fields []emvqr.Field, id string) ([]emvqr.Field, error) {
+	value, ok := emvqr.Lookup(fields, id)
+	if !ok {
+		return nil, ErrInvalidPaymentPayload
+	}
+
+	template, err := emvqr.ParseTemplate(value)
+	if err != nil {
+		return nil, ErrInvalidPaymentPayload
+	}
+
+	if guid, _ := emvqr.Lookup(template, emvqr.IDGloballyUnique); guid != s.qr.GUID {
+		return nil, ErrInvalidPaymentPayload
+	}
+
+	return template, nil
+}
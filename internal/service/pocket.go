@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+	"math"
+)
+
+type PocketService struct {
+	pocketRepo repo.Pocket
+	walletRepo repo.Wallet
+	memberRepo repo.Member
+}
+
+func NewPocketService(pocketRepo repo.Pocket, walletRepo repo.Wallet, memberRepo repo.Member) *PocketService {
+	return &PocketService{
+		pocketRepo: pocketRepo,
+		walletRepo: walletRepo,
+		memberRepo: memberRepo,
+	}
+}
+
+func (s *PocketService) GetPockets(ctx context.Context, userUUID, walletUUID uuid.UUID) ([]entity.Pocket, error) {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, entity.WalletRoleViewer); err != nil {
+		return nil, err
+	}
+
+	pockets, err := s.pocketRepo.GetWalletPockets(ctx, walletUUID)
+	if err != nil {
+		slog.Error("PocketService.GetPockets", "error", err)
+		return nil, ErrCannotGetPockets
+	}
+
+	return withProgress(pockets), nil
+}
+
+func (s *PocketService) CreatePocket(ctx context.Context, userUUID uuid.UUID, pocket entity.Pocket) (uuid.UUID, error) {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, pocket.Wallet, userUUID, entity.WalletRoleOwner); err != nil {
+		return uuid.Nil, err
+	}
+
+	pocketUUID, err := s.pocketRepo.CreatePocket(ctx, pocket)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrAlreadyExist) {
+			return uuid.Nil, ErrPocketAlreadyExists
+		}
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return uuid.Nil, ErrCannotGetWallet
+		}
+		slog.Error("PocketService.CreatePocket", "error", err)
+		return uuid.Nil, ErrCannotUpdatePocket
+	}
+
+	return pocketUUID, nil
+}
+
+func (s *PocketService) UpdatePocket(ctx context.Context, userUUID uuid.UUID, pocket entity.Pocket) error {
+	if _, err := s.pocket(ctx, userUUID, pocket.Wallet, pocket.UUID, entity.WalletRoleOwner); err != nil {
+		return err
+	}
+
+	return s.pocketError("PocketService.UpdatePocket", s.pocketRepo.UpdatePocket(ctx, pocket))
+}
+
+// DeletePocket removes the pocket, its money goes back to the wallet balance
+func (s *PocketService) DeletePocket(ctx context.Context, userUUID, walletUUID, pocketUUID uuid.UUID) error {
+	if _, err := s.pocket(ctx, userUUID, walletUUID, pocketUUID, entity.WalletRoleOwner); err != nil {
+		return err
+	}
+
+	return s.pocketError("PocketService.DeletePocket", s.pocketRepo.DeletePocket(ctx, pocketUUID))
+}
+
+// Deposit puts money aside, it can not be sent until withdrawn back to the wallet
+func (s *PocketService) Deposit(ctx context.Context, userUUID, walletUUID, pocketUUID uuid.UUID, amount float64) error {
+	if _, err := s.pocket(ctx, userUUID, walletUUID, pocketUUID, entity.WalletRoleSpender); err != nil {
+		return err
+	}
+
+	err := s.pocketRepo.Deposit(ctx, pocketUUID, amount)
+	if errors.Is(err, repoerrors.ErrInsufficientFunds) {
+		return ErrNotEnoughMoney
+	}
+
+	return s.pocketError("PocketService.Deposit", err)
+}
+
+func (s *PocketService) Withdraw(ctx context.Context, userUUID, walletUUID, pocketUUID uuid.UUID, amount float64) error {
+	if _, err := s.pocket(ctx, userUUID, walletUUID, pocketUUID, entity.WalletRoleSpender); err != nil {
+		return err
+	}
+
+	err := s.pocketRepo.Withdraw(ctx, pocketUUID, amount)
+	if errors.Is(err, repoerrors.ErrInsufficientFunds) {
+		return ErrNotEnoughPocketMoney
+	}
+
+	return s.pocketError("PocketService.Withdraw", err)
+}
+
+// pocket checks the user role on the wallet and that the pocket belongs to it
+func (s *PocketService) pocket(ctx context.Context, userUUID, walletUUID, pocketUUID uuid.UUID, minRole string) (entity.Pocket, error) {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, minRole); err != nil {
+		return entity.Pocket{}, err
+	}
+
+	pocket, err := s.pocketRepo.GetPocketById(ctx, pocketUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.Pocket{}, ErrPocketNotFound
+		}
+		slog.Error("PocketService.pocket", "error", err)
+		return entity.Pocket{}, ErrCannotGetPockets
+	}
+
+	if pocket.Wallet != walletUUID {
+		return entity.Pocket{}, ErrPocketNotFound
+	}
+
+	return pocket, nil
+}
+
+func (s *PocketService) pocketError(op string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repoerrors.ErrNotFound):
+		return ErrPocketNotFound
+	case errors.Is(err, repoerrors.ErrAlreadyExist):
+		return ErrPocketAlreadyExists
+	default:
+		slog.Error(op, "error", err)
+		return ErrCannotUpdatePocket
+	}
+}
+
+// withProgress fills saved percent of the target for pockets that have one
+func withProgress(pockets []entity.Pocket) []entity.Pocket {
+	for i := range pockets {
+		if target := pockets[i].Target; target != nil && *target > 0 {
+			progress := math.Min(pockets[i].Balance / *target * 100, 100)
+			pockets[i].Progress = &progress
+		}
+	}
+	return pockets
+}
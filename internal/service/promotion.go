@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+	"time"
+)
+
+type PromotionService struct {
+	promotionRepo repo.Promotion
+	promoWallet   uuid.UUID
+	holdingWallet uuid.UUID
+}
+
+func NewPromotionService(promotionRepo repo.Promotion, promoWallet, holdingWallet uuid.UUID) *PromotionService {
+	return &PromotionService{
+		promotionRepo: promotionRepo,
+		promoWallet:   promoWallet,
+		holdingWallet: holdingWallet,
+	}
+}
+
+// CreateCampaign registers a campaign, without a start time it runs immediately
+func (s *PromotionService) CreateCampaign(ctx context.Context, campaign entity.PromoCampaign) (uuid.UUID, error) {
+	if campaign.StartsAt.IsZero() {
+		campaign.StartsAt = time.Now()
+	}
+
+	if err := s.validate(campaign); err != nil {
+		return uuid.Nil, err
+	}
+
+	campaignUUID, err := s.promotionRepo.CreateCampaign(ctx, campaign)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrAlreadyExist) {
+			return uuid.Nil, ErrCampaignAlreadyExists
+		}
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return uuid.Nil, ErrCannotGetWallet
+		}
+		slog.Error("PromotionService.CreateCampaign", "error", err)
+		return uuid.Nil, ErrCannotUpdateCampaign
+	}
+
+	return campaignUUID, nil
+}
+
+func (s *PromotionService) GetCampaigns(ctx context.Context) ([]entity.PromoCampaign, error) {
+	campaigns, err := s.promotionRepo.GetCampaigns(ctx)
+	if err != nil {
+		slog.Error("PromotionService.GetCampaigns", "error", err)
+		return nil, ErrCannotGetCampaign
+	}
+
+	return campaigns, nil
+}
+
+func (s *PromotionService) GetCampaign(ctx context.Context, campaignUUID uuid.UUID) (entity.PromoCampaign, error) {
+	campaign, err := s.promotionRepo.GetCampaignById(ctx, campaignUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.PromoCampaign{}, ErrCampaignNotFound
+		}
+		slog.Error("PromotionService.GetCampaign", "error", err)
+		return entity.PromoCampaign{}, ErrCannotGetCampaign
+	}
+
+	return campaign, nil
+}
+
+// UpdateCampaign replaces the campaign rules. Kind and receiver are fixed at creation,
+// and the budget can not drop below what was already paid out.
+func (s *PromotionService) UpdateCampaign(ctx context.Context, campaign entity.PromoCampaign) error {
+	current, err := s.GetCampaign(ctx, campaign.UUID)
+	if err != nil {
+		return err
+	}
+
+	campaign.Kind = current.Kind
+	campaign.Receiver = current.Receiver
+	if err = s.validate(campaign); err != nil {
+		return err
+	}
+	if campaign.Budget < current.Spent {
+		return ErrInvalidCampaign
+	}
+
+	err = s.promotionRepo.UpdateCampaign(ctx, campaign)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrCampaignNotFound
+		}
+		if errors.Is(err, repoerrors.ErrAlreadyExist) {
+			return ErrCampaignAlreadyExists
+		}
+		slog.Error("PromotionService.UpdateCampaign", "error", err)
+		return ErrCannotUpdateCampaign
+	}
+
+	return nil
+}
+
+func (s *PromotionService) GetReport(ctx context.Context) ([]entity.PromoReport, error) {
+	report, err := s.promotionRepo.GetReport(ctx)
+	if err != nil {
+		slog.Error("PromotionService.GetReport", "error", err)
+		return nil, ErrCannotGetCampaign
+	}
+
+	return report, nil
+}
+
+// Reward pays the rewards of every campaign matching an executed transfer to the sender wallet.
+// Rewards never fail the transfer itself, problems are only logged.
+func (s *PromotionService) Reward(ctx context.Context, input RewardInput) {
+	if input.Receiver == s.promoWallet || input.Receiver == s.holdingWallet {
+		return
+	}
+
+	campaigns, err := s.promotionRepo.GetMatchingCampaigns(ctx, input.Receiver, input.Amount)
+	if err != nil {
+		slog.Error("PromotionService.Reward", "error", err)
+		return
+	}
+
+	for _, campaign := range campaigns {
+		var amount float64
+		switch campaign.Kind {
+		case entity.PromoKindCashback:
+			amount = input.Amount * campaign.Percent / 100
+		case entity.PromoKindFirstTransfer:
+			// whether the user has already been rewarded is decided by PayReward under the campaign lock
+			amount = campaign.FixedAmount
+		}
+
+		_, err = s.promotionRepo.PayReward(ctx, entity.PromoPayout{
+			Campaign: campaign.UUID,
+			User:     input.UserUUID,
+			Wallet:   input.Sender,
+			Amount:   amount,
+		}, s.promoWallet)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrInsufficientFunds) {
+				slog.Warn("PromotionService.Reward: promo wallet is empty", "campaign", campaign.UUID)
+				return
+			}
+			if !errors.Is(err, repoerrors.ErrNotFound) {
+				slog.Error("PromotionService.Reward", "error", err)
+			}
+		}
+	}
+}
+
+func (s *PromotionService) validate(campaign entity.PromoCampaign) error {
+	switch campaign.Kind {
+	case entity.PromoKindCashback:
+		if campaign.Percent <= 0 || campaign.Percent > 100 {
+			return ErrInvalidCampaign
+		}
+	case entity.PromoKindFirstTransfer:
+		if campaign.FixedAmount <= 0 {
+			return ErrInvalidCampaign
+		}
+	default:
+		return ErrInvalidCampaign
+	}
+
+	if campaign.Budget <= 0 || campaign.MinAmount < 0 ||
+		campaign.UserCap != nil && *campaign.UserCap <= 0 ||
+		campaign.EndsAt != nil && !campaign.EndsAt.After(campaign.StartsAt) {
+		return ErrInvalidCampaign
+	}
+
+	if campaign.CapPeriod != nil && (campaign.UserCap == nil || !periods[*campaign.CapPeriod]) {
+		return ErrInvalidPeriod
+	}
+
+	if campaign.Receiver.Valid && (campaign.Receiver.UUID == s.promoWallet || campaign.Receiver.UUID == s.holdingWallet) {
+		return ErrSystemWallet
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+var (
+	handleRe = regexp.MustCompile(`^[a-z0-9_.]{3,32}$`)
+	phoneRe  = regexp.MustCompile(`^\+?[0-9]{7,15}$`)
+
+	phoneSeparators = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "")
+)
+
+// Resolver turns what the sender typed into a wallet: a wallet id, a registered alias
+// or a username of a user with a default receiving wallet
+type Resolver struct {
+	aliasRepo repo.Alias
+}
+
+func NewResolver(aliasRepo repo.Alias) *Resolver {
+	return &Resolver{aliasRepo: aliasRepo}
+}
+
+func (r *Resolver) Resolve(ctx context.Context, to string) (uuid.UUID, error) {
+	to = strings.TrimSpace(to)
+	if walletUUID, err := uuid.Parse(to); err == nil {
+		return walletUUID, nil
+	}
+
+	var wallets []uuid.UUID
+
+	alias, err := r.aliasRepo.GetAlias(ctx, normalizeAlias(to))
+	switch {
+	case err == nil:
+		wallets = append(wallets, alias.Wallet)
+	case !errors.Is(err, repoerrors.ErrNotFound):
+		slog.Error("Resolver.Resolve", "error", err)
+		return uuid.Nil, err
+	}
+
+	noDefault := false
+	_, wallet, err := r.aliasRepo.GetDefaultWallet(ctx, strings.TrimPrefix(to, "@"))
+	switch {
+	case err == nil && wallet.Valid:
+		if len(wallets) == 0 || wallets[0] != wallet.UUID {
+			wallets = append(wallets, wallet.UUID)
+		}
+	case err == nil:
+		noDefault = true
+	case !errors.Is(err, repoerrors.ErrNotFound):
+		slog.Error("Resolver.Resolve", "error", err)
+		return uuid.Nil, err
+	}
+
+	switch len(wallets) {
+	case 0:
+		if noDefault {
+			return uuid.Nil, ErrNoDefaultWallet
+		}
+		return uuid.Nil, ErrAliasNotFound
+	case 1:
+		return wallets[0], nil
+	default:
+		return uuid.Nil, ErrAmbiguousAlias
+	}
+}
+
+// normalizeAlias drops the leading @, lower cases handles and removes separators from phone numbers
+func normalizeAlias(alias string) string {
+	alias = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(alias), "@"))
+	if phone := phoneSeparators.Replace(alias); phoneRe.MatchString(phone) {
+		return phone
+	}
+	return alias
+}
+
+func validAlias(alias string) bool {
+	return handleRe.MatchString(alias) || phoneRe.MatchString(alias)
+}
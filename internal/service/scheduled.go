@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+	"time"
+)
+
+type ScheduledTransferService struct {
+	scheduledRepo repo.ScheduledTransfer
+	walletRepo    repo.Wallet
+	memberRepo    repo.Member
+	promotions    Promotion
+	maxDelay      time.Duration
+}
+
+func NewScheduledTransferService(
+	scheduledRepo repo.ScheduledTransfer,
+	walletRepo repo.Wallet,
+	memberRepo repo.Member,
+	promotions Promotion,
+	maxDelay time.Duration,
+) *ScheduledTransferService {
+	return &ScheduledTransferService{
+		scheduledRepo: scheduledRepo,
+		walletRepo:    walletRepo,
+		memberRepo:    memberRepo,
+		promotions:    promotions,
+		maxDelay:      maxDelay,
+	}
+}
+
+// SetTransferDelay makes further transfers from the wallet wait for the delay, zero executes them immediately
+func (s *ScheduledTransferService) SetTransferDelay(ctx context.Context, userUUID, walletUUID uuid.UUID, delay time.Duration) error {
+	if delay < 0 || delay > s.maxDelay || delay%time.Second != 0 {
+		return ErrInvalidTransferDelay
+	}
+
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, entity.WalletRoleOwner); err != nil {
+		return err
+	}
+
+	err := s.scheduledRepo.SetTransferDelay(ctx, walletUUID, int64(delay/time.Second))
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrCannotGetWallet
+		}
+		slog.Error("ScheduledTransferService.SetTransferDelay", "error", err)
+		return ErrCannotSetTransferDelay
+	}
+
+	return nil
+}
+
+func (s *ScheduledTransferService) GetScheduledTransfers(ctx context.Context, userUUID, walletUUID uuid.UUID) ([]entity.ScheduledTransfer, error) {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, entity.WalletRoleViewer); err != nil {
+		return nil, err
+	}
+
+	transfers, err := s.scheduledRepo.GetWalletScheduledTransfers(ctx, walletUUID)
+	if err != nil {
+		slog.Error("ScheduledTransferService.GetScheduledTransfers", "error", err)
+		return nil, ErrCannotGetScheduledTransfer
+	}
+
+	return transfers, nil
+}
+
+// CancelTransfer cancels a transfer from the wallet while its delay has not passed
+func (s *ScheduledTransferService) CancelTransfer(ctx context.Context, userUUID, walletUUID, transferUUID uuid.UUID) error {
+	transfer, err := s.scheduledRepo.GetScheduledTransferById(ctx, transferUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrScheduledTransferNotFound
+		}
+		slog.Error("ScheduledTransferService.CancelTransfer", "error", err)
+		return ErrCannotGetScheduledTransfer
+	}
+
+	if transfer.Sender != walletUUID {
+		return ErrScheduledTransferNotFound
+	}
+
+	if _, err = walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, entity.WalletRoleSpender); err != nil {
+		return err
+	}
+
+	if err = s.scheduledRepo.CancelScheduledTransfer(ctx, transferUUID); err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrTransferNotScheduled
+		}
+		slog.Error("ScheduledTransferService.CancelTransfer", "error", err)
+		return ErrCannotCancelTransfer
+	}
+
+	return nil
+}
+
+// ExecuteDue executes transfers whose delay has passed. Transfers live in the database,
+// so the ones due while the service was down are executed on the first run after a restart.
+// A transfer the sender can no longer afford is marked failed.
+func (s *ScheduledTransferService) ExecuteDue(ctx context.Context) error {
+	transfers, err := s.scheduledRepo.GetDueScheduledTransfers(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("ScheduledTransferService.ExecuteDue: %v", err)
+	}
+
+	// one transfer that can not be executed must not hold back the others
+	for _, transferUUID := range transfers {
+		transfer, err := s.scheduledRepo.ExecuteScheduledTransfer(ctx, transferUUID)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				continue
+			}
+			if errors.Is(err, repoerrors.ErrInsufficientFunds) {
+				slog.Warn("ScheduledTransferService.ExecuteDue: transfer failed, not enough money", "transfer", transferUUID)
+				if err = s.scheduledRepo.FailScheduledTransfer(ctx, transferUUID); err != nil &&
+					!errors.Is(err, repoerrors.ErrNotFound) {
+					slog.Error("ScheduledTransferService.ExecuteDue", "transfer", transferUUID, "error", err)
+				}
+				continue
+			}
+			slog.Error("ScheduledTransferService.ExecuteDue", "transfer", transferUUID, "error", err)
+			continue
+		}
+
+		s.promotions.Reward(ctx, RewardInput{
+			UserUUID: transfer.CreatedBy,
+			Sender:   transfer.Sender,
+			Receiver: transfer.Receiver,
+			Amount:   transfer.Amount,
+		})
+	}
+
+	return nil
+}
@@ -6,42 +6,479 @@ import (
 	"infotecs_trainee_task/internal/entity"
 	"infotecs_trainee_task/internal/repo"
 	"infotecs_trainee_task/pkg/hasher"
+	"infotecs_trainee_task/pkg/keyset"
+	"infotecs_trainee_task/pkg/notify"
+	"infotecs_trainee_task/pkg/ratelimit"
 	"time"
 )
 
+// InputData.IP is the client address, sign-in attempts are counted by it too
 type InputData struct {
 	Username string
 	Password string
+	IP       string
+}
+
+// ProfileInput changes only the fields that are set
+type ProfileInput struct {
+	Username    *string
+	DisplayName *string
+}
+
+// TransferInput sends money to To, a wallet id or an alias resolved by Resolver.
+// TOTPCode is needed only above the two-factor transfer threshold of the user.
+type TransferInput struct {
+	UserUUID uuid.UUID
+	Sender   uuid.UUID
+	To       string
+	Amount   float64
+	TOTPCode string
+}
+
+// SignInResult has either the tokens or, when two-factor authentication is enabled,
+// the TwoFactorToken to exchange for them together with a code
+type SignInResult struct {
+	Tokens         TokenPair
+	TwoFactorToken string
+}
+
+// TransferResult tells whether the transfer was executed or is waiting for approvals
+type TransferResult struct {
+	Status   string
+	Transfer uuid.UUID
+	Receiver uuid.UUID
+}
+
+// RewardInput describes an executed transfer for the promotion rules
+type RewardInput struct {
+	UserUUID uuid.UUID
+	Sender   uuid.UUID
+	Receiver uuid.UUID
+	Amount   float64
+}
+
+// EscrowInput holds Amount from the buyer wallet,
+// TOTPCode is needed only above the two-factor transfer threshold of the user
+type EscrowInput struct {
+	UserUUID uuid.UUID
+	Buyer    uuid.UUID
+	Seller   uuid.UUID
+	Amount   float64
+	Timeout  time.Duration
+	TOTPCode string
+}
+
+// DisputeInput disputes the transaction, zero amount disputes the whole transaction
+type DisputeInput struct {
+	UserUUID    uuid.UUID
+	Transaction uuid.UUID
+	Amount      float64
+	Reason      string
+}
+
+// InvoicePaymentInput pays the invoice from the wallet, zero amount pays the whole remaining sum.
+// TOTPCode is needed only above the two-factor transfer threshold of the user.
+type InvoicePaymentInput struct {
+	UserUUID uuid.UUID
+	Invoice  uuid.UUID
+	Wallet   uuid.UUID
+	Amount   float64
+	TOTPCode string
 }
 
 type Auth interface {
 	CreateUser(ctx context.Context, input InputData) (uuid.UUID, error)
-	GenerateToken(ctx context.Context, input InputData) (string, error)
-	ParseToken(string) (uuid.UUID, error)
+	SignIn(ctx context.Context, input InputData) (SignInResult, error)
+	SignInTwoFactor(ctx context.Context, twoFactorToken, code string) (TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (TokenPair, error)
+	ParseToken(ctx context.Context, accessToken string) (uuid.UUID, error)
+	Logout(ctx context.Context, accessToken, refreshToken string) error
+	LogoutAll(ctx context.Context, userUUID uuid.UUID) error
+	CacheRevocation(userUUID uuid.UUID, before time.Time)
+	ChangePassword(ctx context.Context, userUUID uuid.UUID, oldPassword, newPassword string) error
+	RequestPasswordReset(ctx context.Context, username string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	CleanupRevokedTokens(ctx context.Context) error
+	JWKS() keyset.JSONWebKeySet
+	IsAdmin(ctx context.Context, userUUID uuid.UUID) (bool, error)
+}
+
+type User interface {
+	GetProfile(ctx context.Context, userUUID uuid.UUID) (entity.Profile, error)
+	UpdateProfile(ctx context.Context, userUUID uuid.UUID, input ProfileInput) (entity.Profile, error)
+	DeleteAccount(ctx context.Context, userUUID uuid.UUID, password string) error
+}
+
+// Lockout.SignInFailed and Lockout.SignInSucceeded are called by Auth.SignIn, the rest is for admins and jobs
+type Lockout interface {
+	CheckSignIn(ctx context.Context, username, ip string) error
+	SignInFailed(ctx context.Context, username, ip string)
+	SignInSucceeded(ctx context.Context, username string)
+	GetLockouts(ctx context.Context) ([]entity.LoginAttempts, error)
+	Unlock(ctx context.Context, adminUUID, userUUID uuid.UUID) error
+	CleanupAttempts(ctx context.Context) error
+}
+
+// TwoFactor.Confirm and TwoFactor.RegenerateRecoveryCodes return the recovery codes, they are never shown again
+type TwoFactor interface {
+	Enroll(ctx context.Context, userUUID uuid.UUID) (TOTPEnrollment, error)
+	Confirm(ctx context.Context, userUUID uuid.UUID, code string) ([]string, error)
+	GetTwoFactor(ctx context.Context, userUUID uuid.UUID) (entity.TOTP, error)
+	IsEnabled(ctx context.Context, userUUID uuid.UUID) (bool, error)
+	VerifyCode(ctx context.Context, userUUID uuid.UUID, code string) error
+	CheckTransfer(ctx context.Context, userUUID uuid.UUID, amount float64, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userUUID uuid.UUID, code string) ([]string, error)
+	SetTransferThreshold(ctx context.Context, userUUID uuid.UUID, threshold *float64, code string) error
+	Disable(ctx context.Context, userUUID uuid.UUID, code string) error
 }
 
 type Wallet interface {
-	CreateWallet(ctx context.Context) (uuid.UUID, error)
-	MakeTransaction(ctx context.Context, sender, receiver uuid.UUID, amount float64) error
-	GetWalletState(ctx context.Context, walletUUID uuid.UUID) (entity.Wallet, error)
-	GetTransactionsHistory(ctx context.Context, walletUUID uuid.UUID) ([]entity.Transaction, error)
+	CreateWallet(ctx context.Context, owner uuid.UUID) (uuid.UUID, error)
+	MakeTransaction(ctx context.Context, input TransferInput) (TransferResult, error)
+	GetWalletState(ctx context.Context, userUUID, walletUUID uuid.UUID) (entity.WalletState, error)
+	GetTransactionsHistory(ctx context.Context, userUUID, walletUUID uuid.UUID) ([]entity.Transaction, error)
+	Reconcile(ctx context.Context) (entity.Reconciliation, error)
+}
+
+type Credit interface {
+	SetCreditLine(ctx context.Context, walletUUID uuid.UUID, limit, rate float64) error
+	AccrueFees(ctx context.Context) error
+}
+
+type Interest interface {
+	CreateProduct(ctx context.Context, product entity.InterestProduct) (uuid.UUID, error)
+	GetProducts(ctx context.Context) ([]entity.InterestProduct, error)
+	UpdateProduct(ctx context.Context, product entity.InterestProduct) error
+	AssignProduct(ctx context.Context, walletUUID uuid.UUID, product uuid.NullUUID) error
+	GetSummary(ctx context.Context, userUUID, walletUUID uuid.UUID) (entity.InterestSummary, error)
+	AccrueAndPay(ctx context.Context) error
+}
+
+type Alias interface {
+	GetAliases(ctx context.Context, userUUID uuid.UUID) ([]entity.Alias, error)
+	CreateAlias(ctx context.Context, userUUID uuid.UUID, alias entity.Alias) error
+	DeleteAlias(ctx context.Context, userUUID uuid.UUID, alias string) error
+	SetDefaultWallet(ctx context.Context, userUUID uuid.UUID, wallet uuid.NullUUID) error
+	Resolve(ctx context.Context, to string) (uuid.UUID, error)
+}
+
+type Contact interface {
+	GetContacts(ctx context.Context, userUUID uuid.UUID) ([]entity.Contact, error)
+	CreateContact(ctx context.Context, userUUID uuid.UUID, contact entity.Contact) (uuid.UUID, error)
+	UpdateContact(ctx context.Context, userUUID uuid.UUID, contact entity.Contact) error
+	DeleteContact(ctx context.Context, userUUID, contactUUID uuid.UUID) error
+	GetCounterparties(ctx context.Context, userUUID, walletUUID uuid.UUID, limit int) ([]entity.Counterparty, error)
+}
+
+type Member interface {
+	GetMembers(ctx context.Context, userUUID, walletUUID uuid.UUID) ([]entity.WalletMember, error)
+	InviteMember(ctx context.Context, userUUID uuid.UUID, member entity.WalletMember) error
+	UpdateMember(ctx context.Context, userUUID uuid.UUID, member entity.WalletMember) error
+	RemoveMember(ctx context.Context, userUUID, walletUUID, memberUUID uuid.UUID) error
+	GetInvitations(ctx context.Context, userUUID uuid.UUID) ([]entity.WalletMember, error)
+	AcceptInvitation(ctx context.Context, userUUID, walletUUID uuid.UUID) error
+	DeclineInvitation(ctx context.Context, userUUID, walletUUID uuid.UUID) error
+}
+
+type Pocket interface {
+	GetPockets(ctx context.Context, userUUID, walletUUID uuid.UUID) ([]entity.Pocket, error)
+	CreatePocket(ctx context.Context, userUUID uuid.UUID, pocket entity.Pocket) (uuid.UUID, error)
+	UpdatePocket(ctx context.Context, userUUID uuid.UUID, pocket entity.Pocket) error
+	DeletePocket(ctx context.Context, userUUID, walletUUID, pocketUUID uuid.UUID) error
+	Deposit(ctx context.Context, userUUID, walletUUID, pocketUUID uuid.UUID, amount float64) error
+	Withdraw(ctx context.Context, userUUID, walletUUID, pocketUUID uuid.UUID, amount float64) error
+}
+
+type Family interface {
+	CreateChild(ctx context.Context, userUUID, parent uuid.UUID) (uuid.UUID, error)
+	LinkChild(ctx context.Context, userUUID, parent, child uuid.UUID) error
+	GetChildren(ctx context.Context, userUUID, parent uuid.UUID) ([]entity.Wallet, error)
+	SetChildPolicy(ctx context.Context, userUUID, parent uuid.UUID, policy entity.ChildPolicy) error
+	GetChildPolicy(ctx context.Context, userUUID, parent, child uuid.UUID) (entity.ChildPolicy, error)
+	CreateAllowance(ctx context.Context, userUUID uuid.UUID, allowance entity.Allowance) (uuid.UUID, error)
+	GetAllowances(ctx context.Context, userUUID, parent, child uuid.UUID) ([]entity.Allowance, error)
+	DeleteAllowance(ctx context.Context, userUUID, parent, child, allowanceUUID uuid.UUID) error
+	PayAllowances(ctx context.Context) error
+}
+
+type Merchant interface {
+	CreateMerchant(ctx context.Context, userUUID uuid.UUID, merchant entity.Merchant) (uuid.UUID, error)
+	GetMerchant(ctx context.Context, userUUID uuid.UUID) (entity.Merchant, error)
+	AddWallet(ctx context.Context, userUUID, walletUUID uuid.UUID) error
+	RemoveWallet(ctx context.Context, userUUID, walletUUID uuid.UUID) error
+}
+
+type Invoice interface {
+	CreateInvoice(ctx context.Context, userUUID uuid.UUID, invoice entity.Invoice) (uuid.UUID, error)
+	GetInvoice(ctx context.Context, invoiceUUID uuid.UUID) (entity.Invoice, error)
+	GetInvoices(ctx context.Context, userUUID uuid.UUID) ([]entity.Invoice, error)
+	CancelInvoice(ctx context.Context, userUUID, invoiceUUID uuid.UUID) error
+	PayInvoice(ctx context.Context, input InvoicePaymentInput) (entity.Invoice, error)
+}
+
+type Voucher interface {
+	CreateVoucher(ctx context.Context, userUUID uuid.UUID, voucher entity.Voucher, totpCode string) (uuid.UUID, string, error)
+	GetVouchers(ctx context.Context, userUUID uuid.UUID) ([]entity.Voucher, error)
+	RevokeVoucher(ctx context.Context, userUUID, voucherUUID uuid.UUID) error
+	RedeemVoucher(ctx context.Context, userUUID, walletUUID uuid.UUID, code string) (entity.Voucher, error)
+}
+
+type Promotion interface {
+	CreateCampaign(ctx context.Context, campaign entity.PromoCampaign) (uuid.UUID, error)
+	GetCampaigns(ctx context.Context) ([]entity.PromoCampaign, error)
+	GetCampaign(ctx context.Context, campaignUUID uuid.UUID) (entity.PromoCampaign, error)
+	UpdateCampaign(ctx context.Context, campaign entity.PromoCampaign) error
+	GetReport(ctx context.Context) ([]entity.PromoReport, error)
+	Reward(ctx context.Context, input RewardInput)
+}
+
+type Payment interface {
+	EncodePayload(ctx context.Context, userUUID uuid.UUID, request entity.PaymentRequest) (string, error)
+	EncodeQR(ctx context.Context, userUUID uuid.UUID, request entity.PaymentRequest) ([]byte, error)
+	DecodePayload(ctx context.Context, payload string) (entity.PaymentRequest, error)
+}
+
+type Escrow interface {
+	CreateEscrow(ctx context.Context, input EscrowInput) (uuid.UUID, error)
+	GetEscrow(ctx context.Context, userUUID, escrowUUID uuid.UUID) (entity.Escrow, error)
+	GetWalletEscrows(ctx context.Context, userUUID, walletUUID uuid.UUID) ([]entity.Escrow, error)
+	ReleaseEscrow(ctx context.Context, userUUID, escrowUUID uuid.UUID) error
+	CancelEscrow(ctx context.Context, userUUID, escrowUUID uuid.UUID) error
+	ResolveEscrow(ctx context.Context, escrowUUID uuid.UUID, decision string) error
+	ReleaseExpired(ctx context.Context) error
+}
+
+type Dispute interface {
+	OpenDispute(ctx context.Context, input DisputeInput) (uuid.UUID, error)
+	GetDispute(ctx context.Context, userUUID, disputeUUID uuid.UUID) (entity.Dispute, error)
+	GetWalletDisputes(ctx context.Context, userUUID, walletUUID uuid.UUID) ([]entity.Dispute, error)
+	GetDisputes(ctx context.Context, status string) ([]entity.Dispute, error)
+	AddComment(ctx context.Context, userUUID, disputeUUID uuid.UUID, note string) error
+	AddAttachment(ctx context.Context, userUUID uuid.UUID, attachment entity.Attachment) (uuid.UUID, error)
+	GetAttachment(ctx context.Context, userUUID, disputeUUID, attachmentUUID uuid.UUID) (entity.Attachment, error)
+	ResolveDispute(ctx context.Context, adminUUID, disputeUUID uuid.UUID, decision, note string) error
+}
+
+type Approval interface {
+	SetPolicy(ctx context.Context, userUUID uuid.UUID, policy entity.ApprovalPolicy) error
+	GetPolicy(ctx context.Context, userUUID, walletUUID uuid.UUID) (entity.ApprovalPolicy, error)
+	DeletePolicy(ctx context.Context, userUUID, walletUUID uuid.UUID) error
+	GetPendingTransfers(ctx context.Context, userUUID uuid.UUID) ([]entity.PendingTransfer, error)
+	GetPendingTransfer(ctx context.Context, userUUID, transferUUID uuid.UUID) (entity.PendingTransfer, error)
+	Approve(ctx context.Context, userUUID, transferUUID uuid.UUID) (string, error)
+	Reject(ctx context.Context, userUUID, transferUUID uuid.UUID) error
+	ExpirePending(ctx context.Context) error
+}
+
+type ScheduledTransfer interface {
+	SetTransferDelay(ctx context.Context, userUUID, walletUUID uuid.UUID, delay time.Duration) error
+	GetScheduledTransfers(ctx context.Context, userUUID, walletUUID uuid.UUID) ([]entity.ScheduledTransfer, error)
+	CancelTransfer(ctx context.Context, userUUID, walletUUID, transferUUID uuid.UUID) error
+	ExecuteDue(ctx context.Context) error
+}
+
+// APIKey.CreateAPIKey returns the key itself, it is never shown again
+type APIKey interface {
+	CreateAPIKey(ctx context.Context, createdBy uuid.UUID, key entity.APIKey) (entity.APIKey, string, error)
+	GetAPIKeys(ctx context.Context, owner uuid.UUID) ([]entity.APIKey, error)
+	RevokeAPIKey(ctx context.Context, owner, keyUUID uuid.UUID) error
+	Authenticate(ctx context.Context, secret string) (entity.APIKey, error)
 }
 
 type Services struct {
-	Auth   Auth
-	Wallet Wallet
+	Auth      Auth
+	User      User
+	TwoFactor TwoFactor
+	Lockout   Lockout
+	APIKey    APIKey
+	Wallet    Wallet
+	Credit    Credit
+	Interest  Interest
+	Alias     Alias
+	Contact   Contact
+	Member    Member
+	Pocket    Pocket
+	Family    Family
+	Merchant  Merchant
+	Invoice   Invoice
+	Payment   Payment
+	Voucher   Voucher
+	Promotion Promotion
+	Escrow    Escrow
+	Dispute   Dispute
+	Approval  Approval
+	Scheduled ScheduledTransfer
 }
 
 type Dependencies struct {
 	Repos    *repo.Repositories
 	Hasher   hasher.PasswordHasher
-	SignKey  string
-	TokenTTL time.Duration
+	Notifier notify.Notifier
+	Token    TokenConfig
+
+	ResetMaxRequests    int
+	ResetRequestsWindow time.Duration
+
+	TwoFactor TwoFactorConfig
+	Lockout   LockoutConfig
+
+	HoldingWallet    uuid.UUID
+	PromoWallet      uuid.UUID
+	FeeWallet        uuid.UUID
+	InterestWallet   uuid.UUID
+	Currency         string
+	PaymentQR        PaymentQR
+	EscrowTimeout    time.Duration
+	EscrowMaxTimeout time.Duration
+	ApprovalTTL      time.Duration
+	MaxTransferDelay time.Duration
+
+	VoucherMaxAttempts    int
+	VoucherAttemptsWindow time.Duration
+
+	DisputeWindow            time.Duration
+	DisputeMaxAttachmentSize int64
 }
 
 func NewServices(deps *Dependencies) *Services {
+	resolver := NewResolver(deps.Repos.Alias)
+	promotions := NewPromotionService(deps.Repos.Promotion, deps.PromoWallet, deps.HoldingWallet)
+	twoFactor := NewTwoFactorService(
+		deps.Repos.TwoFactor,
+		deps.Repos.User,
+		ratelimit.New(deps.TwoFactor.MaxAttempts, deps.TwoFactor.LockDuration),
+		deps.TwoFactor,
+	)
+	lockout := NewLockoutService(deps.Repos.Lockout, deps.Repos.User, deps.Lockout)
+
+	auth := NewAuthService(
+		deps.Repos.User,
+		deps.Repos.Refresh,
+		deps.Repos.Revocation,
+		deps.Repos.Reset,
+		deps.Hasher,
+		twoFactor,
+		lockout,
+		deps.Notifier,
+		ratelimit.New(deps.TwoFactor.MaxAttempts, deps.Token.TwoFactorTTL),
+		ratelimit.New(deps.ResetMaxRequests, deps.ResetRequestsWindow),
+		deps.Token,
+	)
+
 	return &Services{
-		Auth:   NewAuthService(deps.Repos.User, deps.Hasher, deps.SignKey, deps.TokenTTL),
-		Wallet: NewWalletService(deps.Repos.Wallet, deps.Repos.Transaction),
+		Auth:      auth,
+		User:      NewUserService(deps.Repos.User, deps.Repos.Member, deps.Hasher, auth),
+		TwoFactor: twoFactor,
+		Lockout:   lockout,
+		APIKey:    NewAPIKeyService(deps.Repos.APIKey, deps.Repos.Wallet, deps.Repos.Member),
+		Wallet: NewWalletService(
+			deps.Repos.Wallet,
+			deps.Repos.Transaction,
+			deps.Repos.Member,
+			deps.Repos.Pocket,
+			deps.Repos.Family,
+			deps.Repos.Escrow,
+			deps.Repos.Approval,
+			deps.Repos.Scheduled,
+			promotions,
+			resolver,
+			twoFactor,
+			deps.HoldingWallet,
+		),
+		Credit: NewCreditService(
+			deps.Repos.Credit,
+			deps.HoldingWallet,
+			deps.PromoWallet,
+			deps.FeeWallet,
+			deps.InterestWallet,
+		),
+		Interest: NewInterestService(
+			deps.Repos.Interest,
+			deps.Repos.Wallet,
+			deps.Repos.Member,
+			deps.HoldingWallet,
+			deps.PromoWallet,
+			deps.FeeWallet,
+			deps.InterestWallet,
+		),
+		Alias: NewAliasService(deps.Repos.Alias, deps.Repos.Wallet, deps.Repos.Member, resolver),
+		Contact: NewContactService(
+			deps.Repos.Contact,
+			deps.Repos.Transaction,
+			deps.Repos.Wallet,
+			deps.Repos.Member,
+			resolver,
+			deps.HoldingWallet,
+			deps.PromoWallet,
+			deps.FeeWallet,
+			deps.InterestWallet,
+		),
+		Escrow: NewEscrowService(
+			deps.Repos.Escrow,
+			deps.Repos.Wallet,
+			deps.Repos.Member,
+			deps.Repos.Family,
+			deps.Repos.Approval,
+			twoFactor,
+			deps.HoldingWallet,
+			deps.EscrowTimeout,
+			deps.EscrowMaxTimeout,
+		),
+		Dispute: NewDisputeService(
+			deps.Repos.Dispute,
+			deps.Repos.Transaction,
+			deps.Repos.Wallet,
+			deps.Repos.Member,
+			deps.Repos.User,
+			deps.HoldingWallet,
+			deps.PromoWallet,
+			deps.FeeWallet,
+			deps.InterestWallet,
+			deps.DisputeWindow,
+			deps.DisputeMaxAttachmentSize,
+		),
+		Member:   NewMemberService(deps.Repos.Member, deps.Repos.Wallet),
+		Pocket:   NewPocketService(deps.Repos.Pocket, deps.Repos.Wallet, deps.Repos.Member),
+		Family:   NewFamilyService(deps.Repos.Family, deps.Repos.Wallet, deps.Repos.Member),
+		Merchant: NewMerchantService(deps.Repos.Merchant, deps.Repos.Wallet, deps.Repos.Member),
+		Invoice: NewInvoiceService(
+			deps.Repos.Invoice,
+			deps.Repos.Merchant,
+			deps.Repos.Wallet,
+			deps.Repos.Member,
+			deps.Repos.Family,
+			deps.Repos.Approval,
+			twoFactor,
+			deps.HoldingWallet,
+			deps.Currency,
+		),
+		Payment: NewPaymentService(deps.Repos.Wallet, deps.Repos.Member, deps.Currency, deps.PaymentQR),
+		Voucher: NewVoucherService(
+			deps.Repos.Voucher,
+			deps.Repos.User,
+			deps.Repos.Merchant,
+			deps.Repos.Wallet,
+			deps.Repos.Member,
+			deps.Repos.Approval,
+			twoFactor,
+			ratelimit.New(deps.VoucherMaxAttempts, deps.VoucherAttemptsWindow),
+			deps.HoldingWallet,
+			deps.Currency,
+		),
+		Promotion: promotions,
+		Approval: NewApprovalService(
+			deps.Repos.Approval,
+			deps.Repos.Wallet,
+			deps.Repos.Member,
+			promotions,
+			deps.ApprovalTTL,
+		),
+		Scheduled: NewScheduledTransferService(
+			deps.Repos.Scheduled,
+			deps.Repos.Wallet,
+			deps.Repos.Member,
+			promotions,
+			deps.MaxTransferDelay,
+		),
 	}
 }
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"log/slog"
+	"time"
+)
+
+// Logout revokes the access token and, if given, the family of the refresh token of the same session.
+// Logging out twice is not an error.
+func (s *AuthService) Logout(ctx context.Context, accessToken, refreshToken string) error {
+	claims, err := s.parseClaims(accessToken, s.token.Audience)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := claims.ExpiresAt.Time.Add(s.token.Leeway)
+	if err = s.revocationRepo.RevokeToken(ctx, claims.ID, claims.UserUUID, expiresAt); err != nil {
+		slog.Error("AuthService.Logout", "error", err)
+		return ErrCannotLogout
+	}
+	s.revokedTokens.Set(claims.ID, true, expiresAt)
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	err = s.refreshRepo.RevokeRefreshTokenFamily(ctx, claims.UserUUID, hashOpaqueToken(refreshToken))
+	if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+		slog.Error("AuthService.Logout", "error", err)
+		return ErrCannotLogout
+	}
+
+	return nil
+}
+
+// LogoutAll rejects every access token issued to the user so far and revokes all refresh tokens
+func (s *AuthService) LogoutAll(ctx context.Context, userUUID uuid.UUID) error {
+	now := time.Now()
+	if err := s.revocationRepo.RevokeUserTokens(ctx, userUUID, now); err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		slog.Error("AuthService.LogoutAll", "error", err)
+		return ErrCannotLogout
+	}
+	s.CacheRevocation(userUUID, now)
+
+	return nil
+}
+
+// CacheRevocation lets this instance reject access tokens revoked in the database before the moment
+// without asking the database, it is for callers that revoke them in their own transaction
+func (s *AuthService) CacheRevocation(userUUID uuid.UUID, before time.Time) {
+	s.revokedBefore.Set(userUUID.String(), before, before.Add(s.token.RevocationCacheTTL))
+}
+
+// CleanupRevokedTokens drops denylist entries, refresh and password reset tokens that expired,
+// none of them can be presented anymore
+func (s *AuthService) CleanupRevokedTokens(ctx context.Context) error {
+	now := time.Now()
+
+	if _, err := s.revocationRepo.DeleteExpiredRevokedTokens(ctx, now); err != nil {
+		return fmt.Errorf("AuthService.CleanupRevokedTokens: %v", err)
+	}
+
+	if _, err := s.refreshRepo.DeleteExpiredRefreshTokens(ctx, now); err != nil {
+		return fmt.Errorf("AuthService.CleanupRevokedTokens: %v", err)
+	}
+
+	if _, err := s.resetRepo.DeleteExpiredPasswordResetTokens(ctx, now); err != nil {
+		return fmt.Errorf("AuthService.CleanupRevokedTokens: %v", err)
+	}
+
+	return nil
+}
+
+// checkRevoked looks the token up in the caches first. A revoked token stays cached until it expires,
+// a token that is not revoked is trusted for RevocationCacheTTL, so a logout on another instance
+// takes effect here after at most that long.
+func (s *AuthService) checkRevoked(ctx context.Context, claims *TokenClaims) error {
+	now := time.Now()
+	expiresAt := claims.ExpiresAt.Time.Add(s.token.Leeway)
+	trustUntil := now.Add(s.token.RevocationCacheTTL)
+	if trustUntil.After(expiresAt) {
+		trustUntil = expiresAt
+	}
+
+	revoked, ok := s.revokedTokens.Get(claims.ID)
+	if !ok {
+		var err error
+		revoked, err = s.revocationRepo.IsTokenRevoked(ctx, claims.ID)
+		if err != nil {
+			slog.Error("AuthService.checkRevoked", "error", err)
+			return ErrCannotCheckToken
+		}
+
+		if revoked {
+			s.revokedTokens.Set(claims.ID, true, expiresAt)
+		} else {
+			s.revokedTokens.Set(claims.ID, false, trustUntil)
+		}
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+
+	key := claims.UserUUID.String()
+	before, ok := s.revokedBefore.Get(key)
+	if !ok {
+		revokedAt, err := s.revocationRepo.GetUserTokensRevokedAt(ctx, claims.UserUUID)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrTokenRevoked
+			}
+			slog.Error("AuthService.checkRevoked", "error", err)
+			return ErrCannotCheckToken
+		}
+
+		if revokedAt != nil {
+			before = *revokedAt
+		}
+		s.revokedBefore.Set(key, before, now.Add(s.token.RevocationCacheTTL))
+	}
+
+	// iat has whole seconds, a token issued in the same second as the logout is rejected too
+	if !before.IsZero() && !claims.IssuedAt.Time.After(before.Truncate(time.Second)) {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
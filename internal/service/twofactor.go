@@ -0,0 +1,359 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/ratelimit"
+	"infotecs_trainee_task/pkg/totp"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// recovery codes are printed and typed like voucher codes, so they use voucherAlphabet
+const (
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+	recoveryCodeGroup  = 5
+)
+
+// TwoFactorConfig describes TOTP enrolment. Skew is the number of time steps accepted around the current one,
+// MaxAttempts the number of codes per two-factor token and of wrong codes per user within LockDuration,
+// ImageSize the side of the QR code PNG in pixels.
+type TwoFactorConfig struct {
+	Issuer       string
+	Skew         int
+	MaxAttempts  int
+	LockDuration time.Duration
+	ImageSize    int
+}
+
+// TOTPEnrollment is shown once, QR is a PNG of URI for authenticator apps
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+	QR     []byte
+}
+
+type TwoFactorService struct {
+	twoFactorRepo repo.TwoFactor
+	userRepo      repo.User
+	cfg           TwoFactorConfig
+
+	// attempts counts wrong codes by user uuid, whatever asked for the code
+	attempts *ratelimit.Limiter
+}
+
+func NewTwoFactorService(twoFactorRepo repo.TwoFactor, userRepo repo.User, attempts *ratelimit.Limiter, cfg TwoFactorConfig) *TwoFactorService {
+	return &TwoFactorService{
+		twoFactorRepo: twoFactorRepo,
+		userRepo:      userRepo,
+		cfg:           cfg,
+		attempts:      attempts,
+	}
+}
+
+// Enroll generates a new secret, two-factor authentication is enabled only after Confirm.
+// Enrolling again before confirming replaces the secret.
+func (s *TwoFactorService) Enroll(ctx context.Context, userUUID uuid.UUID) (TOTPEnrollment, error) {
+	user, err := s.userRepo.GetUserById(ctx, userUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return TOTPEnrollment{}, ErrUserNotFound
+		}
+		slog.Error("TwoFactorService.Enroll", "error", err)
+		return TOTPEnrollment{}, ErrCannotEnrollTwoFactor
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		slog.Error("TwoFactorService.Enroll", "error", err)
+		return TOTPEnrollment{}, ErrCannotEnrollTwoFactor
+	}
+
+	if err = s.twoFactorRepo.SetTOTPSecret(ctx, userUUID, secret); err != nil {
+		if errors.Is(err, repoerrors.ErrAlreadyExist) {
+			return TOTPEnrollment{}, ErrTwoFactorAlreadyEnabled
+		}
+		slog.Error("TwoFactorService.Enroll", "error", err)
+		return TOTPEnrollment{}, ErrCannotEnrollTwoFactor
+	}
+
+	uri := totp.ProvisioningURI(secret, s.cfg.Issuer, user.Username)
+	image, err := qrcode.Encode(uri, qrcode.Medium, s.cfg.ImageSize)
+	if err != nil {
+		slog.Error("TwoFactorService.Enroll", "error", err)
+		return TOTPEnrollment{}, ErrCannotEnrollTwoFactor
+	}
+
+	return TOTPEnrollment{Secret: secret, URI: uri, QR: image}, nil
+}
+
+// Confirm enables two-factor authentication with the first code of the app and returns the recovery codes,
+// they are shown only once
+func (s *TwoFactorService) Confirm(ctx context.Context, userUUID uuid.UUID, code string) ([]string, error) {
+	secret, err := s.getTOTP(ctx, userUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret.ConfirmedAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	counter, ok := totp.Validate(secret.Secret, normalizeCode(code), time.Now(), s.cfg.Skew)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		slog.Error("TwoFactorService.Confirm", "error", err)
+		return nil, ErrCannotUpdateTwoFactor
+	}
+
+	if err = s.twoFactorRepo.ConfirmTOTP(ctx, userUUID, counter, hashes); err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return nil, ErrTwoFactorAlreadyEnabled
+		}
+		slog.Error("TwoFactorService.Confirm", "error", err)
+		return nil, ErrCannotUpdateTwoFactor
+	}
+
+	return codes, nil
+}
+
+func (s *TwoFactorService) GetTwoFactor(ctx context.Context, userUUID uuid.UUID) (entity.TOTP, error) {
+	return s.getTOTP(ctx, userUUID)
+}
+
+// IsEnabled reports whether the user has confirmed an authenticator
+func (s *TwoFactorService) IsEnabled(ctx context.Context, userUUID uuid.UUID) (bool, error) {
+	secret, err := s.getTOTP(ctx, userUUID)
+	if err != nil {
+		if errors.Is(err, ErrTwoFactorNotEnabled) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return secret.ConfirmedAt != nil, nil
+}
+
+// VerifyCode accepts a code of the app or an unused recovery code, both only once.
+// Wrong codes of both kinds count towards the lock of the second factor.
+func (s *TwoFactorService) VerifyCode(ctx context.Context, userUUID uuid.UUID, code string) error {
+	secret, err := s.getEnabledTOTP(ctx, userUUID)
+	if err != nil {
+		return err
+	}
+
+	code = normalizeCode(code)
+	if len(code) == totp.Digits {
+		return s.useTOTPCode(ctx, secret, code)
+	}
+
+	return s.attempt(userUUID, func() error {
+		if err := s.twoFactorRepo.UseRecoveryCode(ctx, userUUID, hashRecoveryCode(code)); err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrInvalidTwoFactorCode
+			}
+			slog.Error("TwoFactorService.VerifyCode", "error", err)
+			return ErrCannotGetTwoFactor
+		}
+
+		slog.Warn("TwoFactorService.VerifyCode: recovery code used", "user", userUUID)
+
+		return nil
+	})
+}
+
+// CheckTransfer asks a fresh code of the app for transfers above the threshold of the user, recovery codes do not count
+func (s *TwoFactorService) CheckTransfer(ctx context.Context, userUUID uuid.UUID, amount float64, code string) error {
+	secret, err := s.getEnabledTOTP(ctx, userUUID)
+	if err != nil {
+		if errors.Is(err, ErrTwoFactorNotEnabled) {
+			return nil
+		}
+		return err
+	}
+
+	if secret.TransferThreshold == nil || amount <= *secret.TransferThreshold {
+		return nil
+	}
+
+	if code == "" {
+		return ErrTwoFactorCodeRequired
+	}
+
+	return s.useTOTPCode(ctx, secret, normalizeCode(code))
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, the old ones stop working
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userUUID uuid.UUID, code string) ([]string, error) {
+	if err := s.VerifyCode(ctx, userUUID, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		slog.Error("TwoFactorService.RegenerateRecoveryCodes", "error", err)
+		return nil, ErrCannotUpdateTwoFactor
+	}
+
+	if err = s.twoFactorRepo.ReplaceRecoveryCodes(ctx, userUUID, hashes); err != nil {
+		slog.Error("TwoFactorService.RegenerateRecoveryCodes", "error", err)
+		return nil, ErrCannotUpdateTwoFactor
+	}
+
+	return codes, nil
+}
+
+// SetTransferThreshold changes which transfers need a code, nil never asks
+func (s *TwoFactorService) SetTransferThreshold(ctx context.Context, userUUID uuid.UUID, threshold *float64, code string) error {
+	if threshold != nil && *threshold < 0 {
+		return ErrInvalidTransferThreshold
+	}
+
+	if err := s.VerifyCode(ctx, userUUID, code); err != nil {
+		return err
+	}
+
+	if err := s.twoFactorRepo.SetTransferThreshold(ctx, userUUID, threshold); err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrTwoFactorNotEnabled
+		}
+		slog.Error("TwoFactorService.SetTransferThreshold", "error", err)
+		return ErrCannotUpdateTwoFactor
+	}
+
+	return nil
+}
+
+// Disable removes the authenticator and the recovery codes, it needs a valid code like any other change
+func (s *TwoFactorService) Disable(ctx context.Context, userUUID uuid.UUID, code string) error {
+	if err := s.VerifyCode(ctx, userUUID, code); err != nil {
+		return err
+	}
+
+	if err := s.twoFactorRepo.DeleteTOTP(ctx, userUUID); err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrTwoFactorNotEnabled
+		}
+		slog.Error("TwoFactorService.Disable", "error", err)
+		return ErrCannotUpdateTwoFactor
+	}
+
+	return nil
+}
+
+func (s *TwoFactorService) useTOTPCode(ctx context.Context, secret entity.TOTP, code string) error {
+	return s.attempt(secret.Owner, func() error {
+		counter, ok := totp.Validate(secret.Secret, code, time.Now(), s.cfg.Skew)
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+
+		if err := s.twoFactorRepo.UseTOTPCounter(ctx, secret.Owner, counter); err != nil {
+			if errors.Is(err, repoerrors.ErrAlreadyExist) {
+				return ErrInvalidTwoFactorCode
+			}
+			slog.Error("TwoFactorService.useTOTPCode", "error", err)
+			return ErrCannotGetTwoFactor
+		}
+
+		return nil
+	})
+}
+
+// attempt runs check of a code the user typed. The attempt is counted before the check, so concurrent requests
+// can not try more than MaxAttempts codes together, and given back unless the code was wrong.
+// Reaching MaxAttempts wrong codes locks the second factor of the user for the rest of LockDuration.
+func (s *TwoFactorService) attempt(userUUID uuid.UUID, check func() error) error {
+	key := userUUID.String()
+	if !s.attempts.Allow(key) {
+		slog.Warn("security event: two-factor locked", "user", userUUID)
+		return ErrTwoFactorLocked
+	}
+
+	err := check()
+	if !errors.Is(err, ErrInvalidTwoFactorCode) {
+		s.attempts.Release(key)
+	}
+
+	return err
+}
+
+func (s *TwoFactorService) getTOTP(ctx context.Context, userUUID uuid.UUID) (entity.TOTP, error) {
+	secret, err := s.twoFactorRepo.GetTOTP(ctx, userUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.TOTP{}, ErrTwoFactorNotEnabled
+		}
+		slog.Error("TwoFactorService.getTOTP", "error", err)
+		return entity.TOTP{}, ErrCannotGetTwoFactor
+	}
+
+	return secret, nil
+}
+
+func (s *TwoFactorService) getEnabledTOTP(ctx context.Context, userUUID uuid.UUID) (entity.TOTP, error) {
+	secret, err := s.getTOTP(ctx, userUUID)
+	if err != nil {
+		return entity.TOTP{}, err
+	}
+
+	if secret.ConfirmedAt == nil {
+		return entity.TOTP{}, ErrTwoFactorNotEnabled
+	}
+
+	return secret, nil
+}
+
+// normalizeCode drops the separators people type, recovery codes are compared in upper case
+func normalizeCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(code)))
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	buf := make([]byte, recoveryCodeLength)
+	for i := 0; i < recoveryCodeCount; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+
+		var code strings.Builder
+		for j, b := range buf {
+			if j > 0 && j%recoveryCodeGroup == 0 {
+				code.WriteByte('-')
+			}
+			code.WriteByte(voucherAlphabet[int(b)%len(voucherAlphabet)])
+		}
+
+		codes = append(codes, code.String())
+		hashes = append(hashes, hashRecoveryCode(normalizeCode(code.String())))
+	}
+
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/hasher"
+	"log/slog"
+	"time"
+)
+
+type UserService struct {
+	userRepo       repo.User
+	memberRepo     repo.Member
+	passwordHasher hasher.PasswordHasher
+	auth           Auth
+}
+
+func NewUserService(userRepo repo.User, memberRepo repo.Member, hasher hasher.PasswordHasher, auth Auth) *UserService {
+	return &UserService{
+		userRepo:       userRepo,
+		memberRepo:     memberRepo,
+		passwordHasher: hasher,
+		auth:           auth,
+	}
+}
+
+func (s *UserService) GetProfile(ctx context.Context, userUUID uuid.UUID) (entity.Profile, error) {
+	user, err := s.userRepo.GetUserById(ctx, userUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.Profile{}, ErrUserNotFound
+		}
+		slog.Error("UserService.GetProfile", "error", err)
+		return entity.Profile{}, ErrCannotGetUser
+	}
+
+	wallets, err := s.memberRepo.GetMemberships(ctx, userUUID)
+	if err != nil {
+		slog.Error("UserService.GetProfile", "error", err)
+		return entity.Profile{}, ErrCannotGetUser
+	}
+
+	return entity.Profile{
+		UUID:        user.UUID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		CreatedAt:   user.CreatedAt,
+		Wallets:     wallets,
+	}, nil
+}
+
+// UpdateProfile changes the fields set in the input, an empty display name removes it
+func (s *UserService) UpdateProfile(ctx context.Context, userUUID uuid.UUID, input ProfileInput) (entity.Profile, error) {
+	user, err := s.userRepo.GetUserById(ctx, userUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.Profile{}, ErrUserNotFound
+		}
+		slog.Error("UserService.UpdateProfile", "error", err)
+		return entity.Profile{}, ErrCannotUpdateUser
+	}
+
+	if input.Username != nil {
+		user.Username = *input.Username
+	}
+	if input.DisplayName != nil {
+		user.DisplayName = input.DisplayName
+		if *input.DisplayName == "" {
+			user.DisplayName = nil
+		}
+	}
+
+	if err = s.userRepo.UpdateProfile(ctx, user); err != nil {
+		switch {
+		case errors.Is(err, repoerrors.ErrAlreadyExist):
+			return entity.Profile{}, ErrUserAlreadyExists
+		case errors.Is(err, repoerrors.ErrNotFound):
+			return entity.Profile{}, ErrUserNotFound
+		}
+		slog.Error("UserService.UpdateProfile", "error", err)
+		return entity.Profile{}, ErrCannotUpdateUser
+	}
+
+	return s.GetProfile(ctx, userUUID)
+}
+
+// DeleteAccount checks the password, anonymizes the user and signs them out everywhere.
+// Wallets the user owns alone have to be emptied first.
+func (s *UserService) DeleteAccount(ctx context.Context, userUUID uuid.UUID, password string) error {
+	user, err := s.userRepo.GetUserById(ctx, userUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		slog.Error("UserService.DeleteAccount", "error", err)
+		return ErrCannotDeleteUser
+	}
+
+	ok, err := s.passwordHasher.Verify(password, user.Password)
+	if err != nil {
+		slog.Error("UserService.DeleteAccount", "error", err)
+		return ErrCannotDeleteUser
+	}
+	if !ok {
+		return ErrInvalidPassword
+	}
+
+	// the sign out everywhere commits together with the deletion
+	now := time.Now()
+	if err = s.userRepo.DeleteUser(ctx, userUUID, now); err != nil {
+		switch {
+		case errors.Is(err, repoerrors.ErrUnavailable):
+			return ErrWalletsNotEmpty
+		case errors.Is(err, repoerrors.ErrNotFound):
+			return ErrUserNotFound
+		}
+		slog.Error("UserService.DeleteAccount", "error", err)
+		return ErrCannotDeleteUser
+	}
+
+	s.auth.CacheRevocation(userUUID, now)
+
+	slog.Warn("security event: account deleted", "user", userUUID)
+
+	return nil
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"infotecs_trainee_task/internal/entity"
+	"infotecs_trainee_task/internal/repo"
+	"infotecs_trainee_task/internal/repo/repoerrors"
+	"infotecs_trainee_task/pkg/ratelimit"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// voucherAlphabet has no 0/O and 1/I, codes are read and typed by people
+const (
+	voucherAlphabet    = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	voucherCodeLength  = 16
+	voucherGroupLength = 4
+)
+
+type VoucherService struct {
+	voucherRepo   repo.Voucher
+	userRepo      repo.User
+	merchantRepo  repo.Merchant
+	walletRepo    repo.Wallet
+	memberRepo    repo.Member
+	approvalRepo  repo.Approval
+	twoFactor     TwoFactor
+	attempts      *ratelimit.Limiter
+	holdingWallet uuid.UUID
+	currency      string
+}
+
+func NewVoucherService(
+	voucherRepo repo.Voucher,
+	userRepo repo.User,
+	merchantRepo repo.Merchant,
+	walletRepo repo.Wallet,
+	memberRepo repo.Member,
+	approvalRepo repo.Approval,
+	twoFactor TwoFactor,
+	attempts *ratelimit.Limiter,
+	holdingWallet uuid.UUID,
+	currency string,
+) *VoucherService {
+	return &VoucherService{
+		voucherRepo:   voucherRepo,
+		userRepo:      userRepo,
+		merchantRepo:  merchantRepo,
+		walletRepo:    walletRepo,
+		memberRepo:    memberRepo,
+		approvalRepo:  approvalRepo,
+		twoFactor:     twoFactor,
+		attempts:      attempts,
+		holdingWallet: holdingWallet,
+		currency:      currency,
+	}
+}
+
+// CreateVoucher funds the voucher from the issuing wallet and returns its code. The code is shown only once.
+// totpCode is needed only above the two-factor transfer threshold of the user.
+func (s *VoucherService) CreateVoucher(ctx context.Context, userUUID uuid.UUID, voucher entity.Voucher, totpCode string) (uuid.UUID, string, error) {
+	if err := s.checkIssuer(ctx, userUUID); err != nil {
+		return uuid.Nil, "", err
+	}
+
+	if voucher.Wallet == s.holdingWallet {
+		return uuid.Nil, "", ErrSystemWallet
+	}
+
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, voucher.Wallet, userUUID, entity.WalletRoleOwner); err != nil {
+		return uuid.Nil, "", err
+	}
+
+	if voucher.Currency == "" {
+		voucher.Currency = s.currency
+	}
+	if voucher.Currency != s.currency {
+		return uuid.Nil, "", ErrInvalidCurrency
+	}
+
+	if voucher.MaxRedemptions == 0 {
+		voucher.MaxRedemptions = 1
+	}
+	if voucher.Amount <= 0 || voucher.MaxRedemptions < 0 ||
+		voucher.ExpiresAt != nil && voucher.ExpiresAt.Before(time.Now()) {
+		return uuid.Nil, "", ErrInvalidVoucher
+	}
+
+	// the whole funding leaves the issuing wallet now, big vouchers under an approval policy are not issued
+	policy, err := s.approvalRepo.GetApprovalPolicy(ctx, voucher.Wallet)
+	if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+		slog.Error("VoucherService.CreateVoucher", "error", err)
+		return uuid.Nil, "", ErrCannotCreateVoucher
+	}
+	if err == nil && voucher.Amount*float64(voucher.MaxRedemptions) > policy.Threshold {
+		return uuid.Nil, "", ErrVoucherNeedsApproval
+	}
+
+	if err = s.twoFactor.CheckTransfer(ctx, userUUID, voucher.Amount*float64(voucher.MaxRedemptions), totpCode); err != nil {
+		return uuid.Nil, "", err
+	}
+
+	code, err := generateVoucherCode()
+	if err != nil {
+		slog.Error("VoucherService.CreateVoucher", "error", err)
+		return uuid.Nil, "", ErrCannotCreateVoucher
+	}
+
+	voucher.Issuer = userUUID
+	voucher.CodeHash = hashVoucherCode(code)
+
+	voucherUUID, err := s.voucherRepo.CreateVoucher(ctx, voucher, s.holdingWallet)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrInsufficientFunds) {
+			return uuid.Nil, "", ErrNotEnoughMoney
+		}
+		slog.Error("VoucherService.CreateVoucher", "error", err)
+		return uuid.Nil, "", ErrCannotCreateVoucher
+	}
+
+	return voucherUUID, code, nil
+}
+
+func (s *VoucherService) GetVouchers(ctx context.Context, userUUID uuid.UUID) ([]entity.Voucher, error) {
+	vouchers, err := s.voucherRepo.GetIssuerVouchers(ctx, userUUID)
+	if err != nil {
+		slog.Error("VoucherService.GetVouchers", "error", err)
+		return nil, ErrCannotGetVoucher
+	}
+
+	return vouchers, nil
+}
+
+// RevokeVoucher returns the unredeemed rest to the issuing wallet
+func (s *VoucherService) RevokeVoucher(ctx context.Context, userUUID, voucherUUID uuid.UUID) error {
+	voucher, err := s.voucherRepo.GetVoucherById(ctx, voucherUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrVoucherNotFound
+		}
+		slog.Error("VoucherService.RevokeVoucher", "error", err)
+		return ErrCannotGetVoucher
+	}
+
+	if voucher.Issuer != userUUID {
+		return ErrVoucherNotFound
+	}
+
+	err = s.voucherRepo.RevokeVoucher(ctx, voucherUUID, s.holdingWallet)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrVoucherUnavailable
+		}
+		slog.Error("VoucherService.RevokeVoucher", "error", err)
+		return ErrCannotRedeemVoucher
+	}
+
+	return nil
+}
+
+// RedeemVoucher pays one redemption of the code into the wallet.
+// Unknown codes count as failed attempts, a user over the limit is rejected before the code is looked up.
+// The attempt is counted up front, so concurrent requests can not pass the limit together, and given back
+// unless the code was unknown.
+func (s *VoucherService) RedeemVoucher(ctx context.Context, userUUID, walletUUID uuid.UUID, code string) (voucher entity.Voucher, err error) {
+	key := userUUID.String()
+	if !s.attempts.Allow(key) {
+		return entity.Voucher{}, ErrTooManyAttempts
+	}
+	defer func() {
+		if !errors.Is(err, ErrVoucherNotFound) {
+			s.attempts.Release(key)
+		}
+	}()
+
+	if walletUUID == s.holdingWallet {
+		return entity.Voucher{}, ErrSystemWallet
+	}
+
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, entity.WalletRoleViewer); err != nil {
+		return entity.Voucher{}, err
+	}
+
+	voucher, err = s.voucherRepo.RedeemVoucher(ctx, hashVoucherCode(code), userUUID, walletUUID, s.holdingWallet)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return entity.Voucher{}, ErrVoucherNotFound
+		}
+		if errors.Is(err, repoerrors.ErrUnavailable) {
+			return entity.Voucher{}, ErrVoucherUnavailable
+		}
+		if errors.Is(err, repoerrors.ErrAlreadyExist) {
+			return entity.Voucher{}, ErrVoucherAlreadyRedeemed
+		}
+		slog.Error("VoucherService.RedeemVoucher", "error", err)
+		return entity.Voucher{}, ErrCannotRedeemVoucher
+	}
+
+	return voucher, nil
+}
+
+// checkIssuer lets admins and users with a merchant profile issue vouchers
+func (s *VoucherService) checkIssuer(ctx context.Context, userUUID uuid.UUID) error {
+	role, err := s.userRepo.GetUserRole(ctx, userUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		slog.Error("VoucherService.checkIssuer", "error", err)
+		return ErrCannotGetUser
+	}
+
+	if role == entity.RoleAdmin {
+		return nil
+	}
+
+	_, err = s.merchantRepo.GetMerchantByOwner(ctx, userUUID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrNotVoucherIssuer
+		}
+		slog.Error("VoucherService.checkIssuer", "error", err)
+		return ErrCannotCreateVoucher
+	}
+
+	return nil
+}
+
+// generateVoucherCode returns a random code grouped as XXXX-XXXX-XXXX-XXXX, 80 bits of entropy
+func generateVoucherCode() (string, error) {
+	buf := make([]byte, voucherCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	var code strings.Builder
+	for i, b := range buf {
+		if i > 0 && i%voucherGroupLength == 0 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(voucherAlphabet[int(b)%len(voucherAlphabet)])
+	}
+
+	return code.String(), nil
+}
+
+// hashVoucherCode ignores case, spaces and dashes, so a code is accepted however it was typed
+func hashVoucherCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(code)))
+
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -10,97 +10,275 @@ import (
 	"infotecs_trainee_task/internal/repo"
 	"infotecs_trainee_task/internal/repo/repoerrors"
 	"log/slog"
+	"time"
 )
 
 type WalletService struct {
 	walletRepo      repo.Wallet
 	transactionRepo repo.Transaction
+	memberRepo      repo.Member
+	pocketRepo      repo.Pocket
+	familyRepo      repo.Family
+	escrowRepo      repo.Escrow
+	approvalRepo    repo.Approval
+	scheduledRepo   repo.ScheduledTransfer
+	promotions      Promotion
+	resolver        *Resolver
+	twoFactor       TwoFactor
+	holdingWallet   uuid.UUID
 }
 
-func NewWalletService(walletRepo repo.Wallet, transactionRepo repo.Transaction) *WalletService {
+func NewWalletService(
+	walletRepo repo.Wallet,
+	transactionRepo repo.Transaction,
+	memberRepo repo.Member,
+	pocketRepo repo.Pocket,
+	familyRepo repo.Family,
+	escrowRepo repo.Escrow,
+	approvalRepo repo.Approval,
+	scheduledRepo repo.ScheduledTransfer,
+	promotions Promotion,
+	resolver *Resolver,
+	twoFactor TwoFactor,
+	holdingWallet uuid.UUID,
+) *WalletService {
 	return &WalletService{
 		walletRepo:      walletRepo,
 		transactionRepo: transactionRepo,
+		memberRepo:      memberRepo,
+		pocketRepo:      pocketRepo,
+		familyRepo:      familyRepo,
+		escrowRepo:      escrowRepo,
+		approvalRepo:    approvalRepo,
+		scheduledRepo:   scheduledRepo,
+		promotions:      promotions,
+		resolver:        resolver,
+		twoFactor:       twoFactor,
+		holdingWallet:   holdingWallet,
 	}
 }
 
-func (s *WalletService) CreateWallet(ctx context.Context) (uuid.UUID, error) {
+func (s *WalletService) CreateWallet(ctx context.Context, owner uuid.UUID) (uuid.UUID, error) {
 
 	wallet := entity.Wallet{
 		Balance: 100.0,
 	}
 
-	walletUUID, err := s.walletRepo.CreateWallet(ctx, wallet)
+	walletUUID, err := s.walletRepo.CreateWallet(ctx, wallet, owner)
 	if err != nil {
 		if errors.Is(err, repoerrors.ErrAlreadyExist) {
 			return uuid.Nil, ErrWalletAlreadyExists
 		}
-		slog.Error("WalletService.CreateWallet", err)
+		slog.Error("WalletService.CreateWallet", "error", err)
 		return uuid.Nil, ErrCannotCreateWallet
 	}
 
 	return walletUUID, nil
 }
 
-func (s *WalletService) MakeTransaction(ctx context.Context, sender, receiver uuid.UUID, amount float64) error {
+func (s *WalletService) MakeTransaction(ctx context.Context, input TransferInput) (TransferResult, error) {
+	sender, amount := input.Sender, input.Amount
+
+	receiver, err := s.resolver.Resolve(ctx, input.To)
+	if err != nil {
+		return TransferResult{}, err
+	}
+
+	if sender == s.holdingWallet || receiver == s.holdingWallet {
+		return TransferResult{}, ErrSystemWallet
+	}
+
+	member, err := walletMember(ctx, s.walletRepo, s.memberRepo, sender, input.UserUUID, entity.WalletRoleSpender)
+	if err != nil {
+		return TransferResult{}, err
+	}
+
+	if err = checkSpendLimit(member, amount); err != nil {
+		return TransferResult{}, err
+	}
 
 	senderWallet, err := s.walletRepo.GetWalletStateById(ctx, sender)
 	if err != nil {
 		if errors.Is(err, repoerrors.ErrNotFound) {
-			return ErrCannotGetWallet
+			return TransferResult{}, ErrCannotGetWallet
 		}
-		slog.Error("WalletService.MakeTransaction", err)
-		return ErrCannotCreateTransaction
+		slog.Error("WalletService.MakeTransaction", "error", err)
+		return TransferResult{}, ErrCannotCreateTransaction
+	}
+
+	if err = checkChildPolicy(ctx, s.familyRepo, senderWallet, receiver); err != nil {
+		return TransferResult{}, err
 	}
 
 	receiverWallet, err := s.walletRepo.GetWalletStateById(ctx, receiver)
 	if err != nil {
 		if errors.Is(err, repoerrors.ErrNotFound) {
-			return ErrCannotGetWallet
+			return TransferResult{}, ErrCannotGetWallet
 		}
-		slog.Error("WalletService.MakeTransaction", err)
-		return ErrCannotCreateTransaction
+		slog.Error("WalletService.MakeTransaction", "error", err)
+		return TransferResult{}, ErrCannotCreateTransaction
+	}
+
+	if senderWallet.Balance+senderWallet.CreditLimit-amount < 0 {
+		return TransferResult{}, ErrNotEnoughMoney
 	}
 
-	if senderWallet.Balance-amount < 0 {
-		return fmt.Errorf("not enought money to send: %f", senderWallet.Balance)
+	// checked last, so a transfer that fails anyway does not use up the code
+	if err = s.twoFactor.CheckTransfer(ctx, input.UserUUID, amount, input.TOTPCode); err != nil {
+		return TransferResult{}, err
+	}
+
+	policy, err := s.approvalRepo.GetApprovalPolicy(ctx, sender)
+	if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+		slog.Error("WalletService.MakeTransaction", "error", err)
+		return TransferResult{}, ErrCannotCreateTransaction
+	}
+
+	if err == nil && amount > policy.Threshold {
+		transferUUID, err := s.approvalRepo.CreatePendingTransfer(ctx, entity.PendingTransfer{
+			Sender:            sender,
+			Receiver:          receiver,
+			Amount:            amount,
+			RequiredApprovals: policy.RequiredApprovals,
+			CreatedBy:         input.UserUUID,
+			ExpiresAt:         time.Now().Add(time.Duration(policy.TTLSeconds) * time.Second),
+		})
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrLimitExceeded) {
+				return TransferResult{}, ErrChildSpendLimitExceeded
+			}
+			slog.Error("WalletService.MakeTransaction", "error", err)
+			return TransferResult{}, ErrCannotCreateTransaction
+		}
+
+		return TransferResult{Status: entity.TransferStatusPending, Transfer: transferUUID, Receiver: receiver}, nil
+	}
+
+	if senderWallet.TransferDelaySeconds > 0 {
+		transferUUID, err := s.scheduledRepo.CreateScheduledTransfer(ctx, entity.ScheduledTransfer{
+			Sender:    sender,
+			Receiver:  receiver,
+			Amount:    amount,
+			CreatedBy: input.UserUUID,
+			ExecuteAt: time.Now().Add(time.Duration(senderWallet.TransferDelaySeconds) * time.Second),
+		})
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return TransferResult{}, ErrCannotGetWallet
+			}
+			if errors.Is(err, repoerrors.ErrLimitExceeded) {
+				return TransferResult{}, ErrChildSpendLimitExceeded
+			}
+			slog.Error("WalletService.MakeTransaction", "error", err)
+			return TransferResult{}, ErrCannotCreateTransaction
+		}
+
+		return TransferResult{Status: entity.TransferStatusScheduled, Transfer: transferUUID, Receiver: receiver}, nil
 	}
 
 	err = s.walletRepo.CashTransfer(ctx, senderWallet, receiverWallet, amount)
 	if err != nil {
+		if errors.Is(err, repoerrors.ErrInsufficientFunds) {
+			return TransferResult{}, ErrNotEnoughMoney
+		}
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return TransferResult{}, ErrCannotGetWallet
+		}
+		if errors.Is(err, repoerrors.ErrLimitExceeded) {
+			return TransferResult{}, ErrChildSpendLimitExceeded
+		}
 		if errors.Is(err, pgx.ErrTxCommitRollback) {
-			return fmt.Errorf("transaction rollbacked: %v", err)
+			return TransferResult{}, fmt.Errorf("transaction rollbacked: %v", err)
 		}
+		slog.Error("WalletService.MakeTransaction", "error", err)
+		return TransferResult{}, ErrCannotCreateTransaction
 	}
 
-	transaction := entity.Transaction{
+	s.promotions.Reward(ctx, RewardInput{
+		UserUUID: input.UserUUID,
 		Sender:   sender,
 		Receiver: receiver,
 		Amount:   amount,
+	})
+
+	return TransferResult{Status: entity.TransferStatusExecuted, Receiver: receiver}, nil
+}
+
+func (s *WalletService) GetWalletState(ctx context.Context, userUUID, walletUUID uuid.UUID) (entity.WalletState, error) {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, entity.WalletRoleViewer); err != nil {
+		return entity.WalletState{}, err
 	}
 
-	err = s.transactionRepo.CreateTransaction(ctx, transaction)
+	wallet, err := s.walletRepo.GetWalletStateById(ctx, walletUUID)
 	if err != nil {
-		if errors.Is(err, repoerrors.ErrAlreadyExist) {
-			return ErrTransactionAlreadyExists
-		}
-		slog.Error("WalletService.MakeTransaction", err)
-		return ErrCannotCreateTransaction
+		return entity.WalletState{}, err
+	}
+
+	held, incoming, err := s.escrowRepo.GetWalletEscrowTotals(ctx, walletUUID)
+	if err != nil {
+		return entity.WalletState{}, err
+	}
+
+	pockets, err := s.pocketRepo.GetWalletPockets(ctx, walletUUID)
+	if err != nil {
+		return entity.WalletState{}, err
+	}
+
+	total := wallet.Balance
+	for _, pocket := range pockets {
+		total += pocket.Balance
 	}
 
-	return nil
+	return entity.WalletState{
+		Wallet:         wallet,
+		Total:          total,
+		Available:      wallet.Balance + wallet.CreditLimit,
+		Pockets:        withProgress(pockets),
+		EscrowHeld:     held,
+		EscrowIncoming: incoming,
+	}, nil
 }
 
-func (s *WalletService) GetWalletState(ctx context.Context, walletUUID uuid.UUID) (entity.Wallet, error) {
-	return s.walletRepo.GetWalletStateById(ctx, walletUUID)
+func (s *WalletService) GetTransactionsHistory(ctx context.Context, userUUID, walletUUID uuid.UUID) ([]entity.Transaction, error) {
+	if _, err := walletMember(ctx, s.walletRepo, s.memberRepo, walletUUID, userUUID, entity.WalletRoleViewer); err != nil {
+		return []entity.Transaction{}, err
+	}
+
+	transactions, err := s.transactionRepo.GetWalletHistory(ctx, walletUUID)
+	if err != nil {
+		return []entity.Transaction{}, err
+	}
+
+	// transfers waiting for their delay come first, they are the latest ones
+	scheduled, err := s.scheduledRepo.GetWalletScheduledTransfers(ctx, walletUUID)
+	if err != nil {
+		slog.Error("WalletService.GetTransactionsHistory", "error", err)
+		return []entity.Transaction{}, ErrCannotGetTransaction
+	}
+
+	history := make([]entity.Transaction, 0, len(scheduled)+len(transactions))
+	for _, transfer := range scheduled {
+		executeAt := transfer.ExecuteAt
+		history = append(history, entity.Transaction{
+			UUID:      transfer.UUID,
+			Sender:    transfer.Sender,
+			Receiver:  transfer.Receiver,
+			CreatedAt: transfer.CreatedAt,
+			Amount:    transfer.Amount,
+			Status:    transfer.Status,
+			ExecuteAt: &executeAt,
+		})
+	}
+
+	return append(history, transactions...), nil
 }
 
-func (s *WalletService) GetTransactionsHistory(ctx context.Context, walletUUID uuid.UUID) ([]entity.Transaction, error) {
-	_, err := s.walletRepo.GetWalletStateById(ctx, walletUUID)
+func (s *WalletService) Reconcile(ctx context.Context) (entity.Reconciliation, error) {
+	reconciliation, err := s.walletRepo.GetReconciliation(ctx, s.holdingWallet)
 	if err != nil {
-		if errors.Is(err, repoerrors.ErrNotFound) {
-			return []entity.Transaction{}, ErrCannotGetWallet
-		}
+		slog.Error("WalletService.Reconcile", "error", err)
+		return entity.Reconciliation{}, ErrCannotGetReconciliation
 	}
-	return s.transactionRepo.GetWalletHistory(ctx, walletUUID)
+
+	return reconciliation, nil
 }
@@ -0,0 +1,23 @@
+alter table users add column role varchar(16) not null default 'user';
+
+alter table wallets add column owner uuid references users(uuid) on delete set null;
+
+-- system account that keeps funds debited from a wallet but not yet settled
+insert into wallets (uuid, balance) values ('00000000-0000-0000-0000-000000000001', 0);
+
+create table escrows (
+    uuid uuid default uuid_generate_v4() primary key,
+    buyer uuid not null,
+    seller uuid not null,
+    amount float8 not null,
+    status varchar(16) not null default 'held',
+    created_by uuid not null,
+    created_at timestamp not null default now(),
+    expires_at timestamp not null,
+    resolved_at timestamp,
+    foreign key (buyer) references wallets(uuid) on delete cascade,
+    foreign key (seller) references wallets(uuid) on delete cascade,
+    foreign key (created_by) references users(uuid) on delete cascade
+);
+
+create index escrows_status_expires_at_idx on escrows (status, expires_at);
@@ -0,0 +1,43 @@
+create table approval_policies (
+    wallet uuid primary key,
+    threshold float8 not null,
+    required_approvals int not null,
+    ttl_seconds bigint not null,
+    foreign key (wallet) references wallets(uuid) on delete cascade
+);
+
+create table approval_policy_approvers (
+    wallet uuid not null,
+    approver uuid not null,
+    primary key (wallet, approver),
+    foreign key (wallet) references approval_policies(wallet) on delete cascade,
+    foreign key (approver) references users(uuid) on delete cascade
+);
+
+create table pending_transfers (
+    uuid uuid default uuid_generate_v4() primary key,
+    sender uuid not null,
+    receiver uuid not null,
+    amount float8 not null,
+    status varchar(16) not null default 'pending',
+    required_approvals int not null,
+    created_by uuid not null,
+    created_at timestamp not null default now(),
+    expires_at timestamp not null,
+    resolved_at timestamp,
+    foreign key (sender) references wallets(uuid) on delete cascade,
+    foreign key (receiver) references wallets(uuid) on delete cascade,
+    foreign key (created_by) references users(uuid) on delete cascade
+);
+
+create index pending_transfers_status_expires_at_idx on pending_transfers (status, expires_at);
+
+create table transfer_approvals (
+    transfer uuid not null,
+    approver uuid not null,
+    decision varchar(16) not null,
+    created_at timestamp not null default now(),
+    primary key (transfer, approver),
+    foreign key (transfer) references pending_transfers(uuid) on delete cascade,
+    foreign key (approver) references users(uuid) on delete cascade
+);
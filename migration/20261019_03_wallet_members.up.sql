@@ -0,0 +1,22 @@
+create table wallet_members (
+    wallet uuid not null,
+    member uuid not null,
+    role varchar(16) not null,
+    spend_limit float8,
+    status varchar(16) not null default 'invited',
+    invited_by uuid,
+    created_at timestamp not null default now(),
+    primary key (wallet, member),
+    foreign key (wallet) references wallets(uuid) on delete cascade,
+    foreign key (member) references users(uuid) on delete cascade,
+    foreign key (invited_by) references users(uuid) on delete set null
+);
+
+create index wallet_members_member_idx on wallet_members (member);
+
+-- wallet owners become the first members of their wallets,
+-- wallets created before owners were tracked need an owner added by hand, see README.md
+insert into wallet_members (wallet, member, role, status)
+select uuid, owner, 'owner', 'active' from wallets where owner is not null;
+
+alter table wallets drop column owner;
@@ -0,0 +1,33 @@
+alter table wallets add column parent uuid references wallets(uuid) on delete set null;
+
+create index wallets_parent_idx on wallets (parent);
+
+create table child_wallet_policies (
+    wallet uuid primary key,
+    spend_limit float8,
+    limit_period varchar(8) not null default 'day',
+    foreign key (wallet) references wallets(uuid) on delete cascade
+);
+
+-- empty list means the child may send to anybody
+create table child_wallet_counterparties (
+    wallet uuid not null,
+    counterparty uuid not null,
+    primary key (wallet, counterparty),
+    foreign key (wallet) references child_wallet_policies(wallet) on delete cascade,
+    foreign key (counterparty) references wallets(uuid) on delete cascade
+);
+
+create table allowances (
+    uuid uuid default uuid_generate_v4() primary key,
+    parent uuid not null,
+    child uuid not null,
+    amount float8 not null,
+    period varchar(8) not null,
+    next_run timestamp not null,
+    created_at timestamp not null default now(),
+    foreign key (parent) references wallets(uuid) on delete cascade,
+    foreign key (child) references wallets(uuid) on delete cascade
+);
+
+create index allowances_next_run_idx on allowances (next_run);
@@ -0,0 +1,12 @@
+-- pocket money is moved out of wallets.balance, so wallets.balance stays the spendable part
+create table wallet_pockets (
+    uuid uuid default uuid_generate_v4() primary key,
+    wallet uuid not null,
+    name varchar(64) not null,
+    balance float8 not null default 0 check (balance >= 0),
+    target float8,
+    deadline timestamp,
+    created_at timestamp not null default now(),
+    unique (wallet, name),
+    foreign key (wallet) references wallets(uuid) on delete cascade
+);
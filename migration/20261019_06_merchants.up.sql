@@ -0,0 +1,47 @@
+create table merchants (
+    uuid uuid default uuid_generate_v4() primary key,
+    owner uuid not null unique,
+    name varchar(128) not null,
+    created_at timestamp not null default now(),
+    foreign key (owner) references users(uuid) on delete cascade
+);
+
+-- settlement wallets receiving invoice payments
+create table merchant_wallets (
+    merchant uuid not null,
+    wallet uuid not null,
+    primary key (merchant, wallet),
+    foreign key (merchant) references merchants(uuid) on delete cascade,
+    foreign key (wallet) references wallets(uuid) on delete cascade
+);
+
+create table invoices (
+    uuid uuid default uuid_generate_v4() primary key,
+    merchant uuid not null,
+    wallet uuid not null,
+    amount float8 not null check (amount > 0),
+    paid float8 not null default 0,
+    currency varchar(3) not null,
+    description text not null default '',
+    allow_partial boolean not null default false,
+    status varchar(16) not null default 'open',
+    expires_at timestamp,
+    created_at timestamp not null default now(),
+    paid_at timestamp,
+    foreign key (merchant) references merchants(uuid) on delete cascade,
+    foreign key (wallet) references wallets(uuid) on delete cascade
+);
+
+create index invoices_merchant_idx on invoices (merchant, created_at);
+
+create table invoice_items (
+    invoice uuid not null,
+    position int not null,
+    name varchar(255) not null,
+    quantity int not null check (quantity > 0),
+    price float8 not null check (price >= 0),
+    primary key (invoice, position),
+    foreign key (invoice) references invoices(uuid) on delete cascade
+);
+
+alter table transactions add column invoice uuid references invoices(uuid) on delete set null;
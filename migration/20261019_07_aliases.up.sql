@@ -0,0 +1,13 @@
+alter table users add column default_wallet uuid references wallets(uuid) on delete set null;
+
+-- aliases are stored normalized: lower case, without the leading @ and phone separators
+create table wallet_aliases (
+    alias varchar(64) primary key,
+    wallet uuid not null,
+    owner uuid not null,
+    created_at timestamp not null default now(),
+    foreign key (wallet) references wallets(uuid) on delete cascade,
+    foreign key (owner) references users(uuid) on delete cascade
+);
+
+create index wallet_aliases_owner_idx on wallet_aliases (owner);
@@ -0,0 +1,13 @@
+-- contact target is a wallet id or an alias, resolved when money is sent
+create table contacts (
+    uuid uuid default uuid_generate_v4() primary key,
+    owner uuid not null,
+    name varchar(128) not null,
+    target varchar(64) not null,
+    created_at timestamp not null default now(),
+    unique (owner, name),
+    foreign key (owner) references users(uuid) on delete cascade
+);
+
+create index transactions_sender_idx on transactions (sender, created_at);
+create index transactions_receiver_idx on transactions (receiver, created_at);
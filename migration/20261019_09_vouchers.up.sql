@@ -0,0 +1,29 @@
+-- voucher money waits in the holding wallet: amount * (max_redemptions - redemptions) while active
+create table vouchers (
+    uuid uuid default uuid_generate_v4() primary key,
+    code_hash varchar(64) not null unique,
+    issuer uuid not null,
+    wallet uuid not null,
+    amount float8 not null check (amount > 0),
+    currency varchar(3) not null,
+    max_redemptions int not null check (max_redemptions > 0),
+    redemptions int not null default 0,
+    status varchar(16) not null default 'active',
+    expires_at timestamp,
+    created_at timestamp not null default now(),
+    foreign key (issuer) references users(uuid) on delete cascade,
+    foreign key (wallet) references wallets(uuid) on delete cascade
+);
+
+create index vouchers_issuer_idx on vouchers (issuer, created_at);
+
+create table voucher_redemptions (
+    voucher uuid not null,
+    redeemed_by uuid not null,
+    wallet uuid not null,
+    created_at timestamp not null default now(),
+    primary key (voucher, redeemed_by),
+    foreign key (voucher) references vouchers(uuid) on delete cascade,
+    foreign key (redeemed_by) references users(uuid) on delete cascade,
+    foreign key (wallet) references wallets(uuid) on delete cascade
+);
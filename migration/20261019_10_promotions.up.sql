@@ -0,0 +1,37 @@
+-- system account paying out rewards, marketing tops it up with ordinary transfers
+insert into wallets (uuid, balance) values ('00000000-0000-0000-0000-000000000002', 0);
+
+-- kind 'cashback' pays percent of the transfer, 'first_transfer' pays fixed_amount once per user, for the first transfer sent while the campaign runs
+create table promo_campaigns (
+    uuid uuid default uuid_generate_v4() primary key,
+    name varchar(128) not null unique,
+    kind varchar(16) not null,
+    percent float8 not null default 0 check (percent >= 0 and percent <= 100),
+    fixed_amount float8 not null default 0 check (fixed_amount >= 0),
+    receiver uuid,
+    min_amount float8 not null default 0,
+    budget float8 not null check (budget > 0),
+    spent float8 not null default 0,
+    user_cap float8,
+    cap_period varchar(16),
+    active boolean not null default true,
+    starts_at timestamp not null default now(),
+    ends_at timestamp,
+    created_at timestamp not null default now(),
+    check (spent <= budget),
+    foreign key (receiver) references wallets(uuid) on delete cascade
+);
+
+create table promo_payouts (
+    uuid uuid default uuid_generate_v4() primary key,
+    campaign uuid not null,
+    user_uuid uuid not null,
+    wallet uuid not null,
+    amount float8 not null check (amount > 0),
+    created_at timestamp not null default now(),
+    foreign key (campaign) references promo_campaigns(uuid) on delete cascade,
+    foreign key (user_uuid) references users(uuid) on delete cascade,
+    foreign key (wallet) references wallets(uuid) on delete cascade
+);
+
+create index promo_payouts_user_idx on promo_payouts (campaign, user_uuid, created_at);
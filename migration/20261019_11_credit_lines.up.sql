@@ -0,0 +1,20 @@
+-- wallets may go negative down to -credit_limit, credit_rate is the daily fee share of the negative balance
+alter table wallets add column credit_limit float8 not null default 0 check (credit_limit >= 0);
+alter table wallets add column credit_rate float8 not null default 0 check (credit_rate >= 0);
+
+create index wallets_overdrawn_idx on wallets (uuid) where balance < 0;
+
+-- system account collecting credit fees
+insert into wallets (uuid, balance) values ('00000000-0000-0000-0000-000000000003', 0);
+
+-- one fee per wallet and day, so the accrual job can run any number of times a day
+create table credit_fees (
+    wallet uuid not null,
+    day date not null,
+    balance float8 not null,
+    rate float8 not null,
+    amount float8 not null check (amount > 0),
+    created_at timestamp not null default now(),
+    primary key (wallet, day),
+    foreign key (wallet) references wallets(uuid) on delete cascade
+);
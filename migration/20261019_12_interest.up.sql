@@ -0,0 +1,30 @@
+-- system account paying interest, its balance goes negative by the interest paid so far
+insert into wallets (uuid, balance) values ('00000000-0000-0000-0000-000000000004', 0);
+
+create table interest_products (
+    uuid uuid default uuid_generate_v4() primary key,
+    name varchar(128) not null unique,
+    apr float8 not null check (apr > 0 and apr <= 100),
+    day_count int not null default 365 check (day_count in (360, 365)),
+    min_balance float8 not null default 0 check (min_balance >= 0),
+    active boolean not null default true,
+    created_at timestamp not null default now()
+);
+
+alter table wallets add column interest_product uuid references interest_products(uuid) on delete set null;
+
+-- one accrual per wallet and day keeps the daily job idempotent, paid_at is set by the monthly payout
+create table interest_accruals (
+    wallet uuid not null,
+    day date not null,
+    product uuid not null,
+    balance float8 not null,
+    apr float8 not null,
+    amount float8 not null check (amount >= 0),
+    paid_at timestamp,
+    primary key (wallet, day),
+    foreign key (wallet) references wallets(uuid) on delete cascade,
+    foreign key (product) references interest_products(uuid) on delete cascade
+);
+
+create index interest_accruals_unpaid_idx on interest_accruals (day) where paid_at is null;
@@ -0,0 +1,48 @@
+-- one dispute per transaction, held is the part of amount taken from the receiver into the holding wallet
+create table disputes (
+    uuid uuid default uuid_generate_v4() primary key,
+    transaction uuid not null unique,
+    payer uuid not null,
+    receiver uuid not null,
+    opened_by uuid not null,
+    amount float8 not null check (amount > 0),
+    held float8 not null default 0 check (held >= 0),
+    reason text not null,
+    status varchar(16) not null default 'open',
+    created_at timestamp not null default now(),
+    resolved_at timestamp,
+    foreign key (transaction) references transactions(uuid) on delete cascade,
+    foreign key (payer) references wallets(uuid) on delete cascade,
+    foreign key (receiver) references wallets(uuid) on delete cascade,
+    foreign key (opened_by) references users(uuid) on delete cascade
+);
+
+create index disputes_payer_idx on disputes (payer, created_at);
+create index disputes_receiver_idx on disputes (receiver, created_at);
+
+create table dispute_attachments (
+    uuid uuid default uuid_generate_v4() primary key,
+    dispute uuid not null,
+    uploaded_by uuid not null,
+    name varchar(255) not null,
+    content_type varchar(128) not null,
+    data bytea not null,
+    created_at timestamp not null default now(),
+    foreign key (dispute) references disputes(uuid) on delete cascade,
+    foreign key (uploaded_by) references users(uuid) on delete cascade
+);
+
+-- timeline of the dispute, actor is null for system events
+create table dispute_events (
+    uuid uuid default uuid_generate_v4() primary key,
+    dispute uuid not null,
+    actor uuid,
+    kind varchar(32) not null,
+    note text not null default '',
+    amount float8,
+    created_at timestamp not null default now(),
+    foreign key (dispute) references disputes(uuid) on delete cascade,
+    foreign key (actor) references users(uuid) on delete set null
+);
+
+create index dispute_events_dispute_idx on dispute_events (dispute, created_at);
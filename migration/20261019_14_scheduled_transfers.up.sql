@@ -0,0 +1,21 @@
+-- transfers from the wallet wait transfer_delay_seconds before execution and can be cancelled meanwhile
+alter table wallets add column transfer_delay_seconds int not null default 0 check (transfer_delay_seconds >= 0);
+
+create table scheduled_transfers (
+    uuid uuid default uuid_generate_v4() primary key,
+    sender uuid not null,
+    receiver uuid not null,
+    amount float8 not null check (amount > 0),
+    status varchar(16) not null default 'scheduled',
+    created_by uuid not null,
+    created_at timestamp not null default now(),
+    execute_at timestamp not null,
+    resolved_at timestamp,
+    foreign key (sender) references wallets(uuid) on delete cascade,
+    foreign key (receiver) references wallets(uuid) on delete cascade,
+    foreign key (created_by) references users(uuid) on delete cascade
+);
+
+create index scheduled_transfers_status_execute_at_idx on scheduled_transfers (status, execute_at);
+create index scheduled_transfers_sender_idx on scheduled_transfers (sender) where status = 'scheduled';
+create index scheduled_transfers_receiver_idx on scheduled_transfers (receiver) where status = 'scheduled';
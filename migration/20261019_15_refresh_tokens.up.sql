@@ -0,0 +1,14 @@
+-- opaque refresh tokens stored as sha256, every rotation adds a token to the family of the sign-in
+create table refresh_tokens (
+    uuid uuid default uuid_generate_v4() primary key,
+    family uuid not null,
+    owner uuid not null,
+    token_hash varchar(64) not null unique,
+    created_at timestamp not null default now(),
+    expires_at timestamp not null,
+    used_at timestamp,
+    revoked_at timestamp,
+    foreign key (owner) references users(uuid) on delete cascade
+);
+
+create index refresh_tokens_family_idx on refresh_tokens (family);
@@ -0,0 +1,17 @@
+-- access tokens issued before this moment are rejected, set by logout from every session
+alter table users add column tokens_revoked_at timestamp;
+
+-- denylist of access token ids, rows are useless once the token expires
+create table revoked_tokens (
+    jti varchar(64) primary key,
+    owner uuid not null,
+    expires_at timestamp not null,
+    revoked_at timestamp not null default now(),
+    foreign key (owner) references users(uuid) on delete cascade
+);
+
+create index revoked_tokens_expires_at_idx on revoked_tokens (expires_at);
+
+create index refresh_tokens_owner_idx on refresh_tokens (owner);
+
+create index refresh_tokens_expires_at_idx on refresh_tokens (expires_at);
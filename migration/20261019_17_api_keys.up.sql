@@ -0,0 +1,20 @@
+-- keys are shown once on creation, only the sha256 of the key is kept; prefix lets owners tell keys apart
+create table api_keys (
+    uuid uuid default uuid_generate_v4() primary key,
+    owner uuid not null,
+    name varchar(64) not null,
+    prefix varchar(16) not null,
+    key_hash varchar(64) not null unique,
+    scopes text[] not null,
+    -- empty means every wallet of the owner
+    wallets uuid[] not null default '{}',
+    created_by uuid not null,
+    created_at timestamp not null default now(),
+    expires_at timestamp,
+    last_used_at timestamp,
+    revoked_at timestamp,
+    foreign key (owner) references users(uuid) on delete cascade,
+    foreign key (created_by) references users(uuid) on delete cascade
+);
+
+create index api_keys_owner_idx on api_keys (owner);
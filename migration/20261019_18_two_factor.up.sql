@@ -0,0 +1,22 @@
+-- confirmed_at is null until the user proves the authenticator app works,
+-- last_counter is the last accepted time step, so a code can not be used twice
+create table user_totp (
+    owner uuid primary key,
+    secret varchar(64) not null,
+    confirmed_at timestamp,
+    last_counter bigint not null default 0,
+    -- transfers above it need a fresh code, null never asks
+    transfer_threshold float8,
+    created_at timestamp not null default now(),
+    foreign key (owner) references users(uuid) on delete cascade
+);
+
+create table recovery_codes (
+    uuid uuid default uuid_generate_v4() primary key,
+    owner uuid not null,
+    code_hash varchar(64) not null,
+    used_at timestamp,
+    foreign key (owner) references users(uuid) on delete cascade
+);
+
+create unique index recovery_codes_owner_code_hash_idx on recovery_codes (owner, code_hash);
@@ -0,0 +1,9 @@
+-- failed sign-ins by "user:<username>" or "ip:<address>", the count starts over after a quiet period
+create table login_attempts (
+    key varchar(320) primary key,
+    failures int not null default 0,
+    last_failure_at timestamp not null default now(),
+    locked_until timestamp
+);
+
+create index login_attempts_locked_until_idx on login_attempts (locked_until);
@@ -0,0 +1,14 @@
+-- single use password reset tokens, only the sha256 of the token is stored
+create table password_reset_tokens (
+    uuid uuid default uuid_generate_v4() primary key,
+    owner uuid not null,
+    token_hash varchar(64) not null unique,
+    created_at timestamp not null default now(),
+    expires_at timestamp not null,
+    used_at timestamp,
+    foreign key (owner) references users(uuid) on delete cascade
+);
+
+create index password_reset_tokens_owner_idx on password_reset_tokens (owner);
+
+create index password_reset_tokens_expires_at_idx on password_reset_tokens (expires_at);
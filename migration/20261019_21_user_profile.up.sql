@@ -0,0 +1,4 @@
+alter table users add column display_name varchar(64);
+
+-- deleted users are kept anonymized, so transactions and disputes still point at a row
+alter table users add column deleted_at timestamp;
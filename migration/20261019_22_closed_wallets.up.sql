@@ -0,0 +1,2 @@
+-- wallets left without an owner when their owner deletes the account, they can not be addressed or receive money
+alter table wallets add column closed_at timestamp;
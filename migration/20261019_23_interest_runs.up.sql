@@ -0,0 +1,4 @@
+-- days the interest job has accrued, days after the last one were missed and are accrued on the next run
+create table interest_runs (
+    day date primary key
+);
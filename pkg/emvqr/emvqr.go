@@ -0,0 +1,155 @@
+// Package emvqr encodes and decodes payloads in the EMV QR Code merchant-presented format.
+//
+// A payload is a sequence of TLV objects: two digit id, two digit length in characters and the value.
+// Templates nest the same encoding inside a value. The last object is always the CRC
+// (id 63, CRC-16/CCITT-FALSE over the whole payload including "6304").
+package emvqr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	IDPayloadFormat     = "00"
+	IDPointOfInitiation = "01"
+	IDMerchantAccount   = "26"
+	IDMerchantCategory  = "52"
+	IDCurrency          = "53"
+	IDAmount            = "54"
+	IDCountry           = "58"
+	IDMerchantName      = "59"
+	IDMerchantCity      = "60"
+	IDAdditionalData    = "62"
+	IDCRC               = "63"
+	IDUnreserved        = "80"
+
+	// sub ids of the additional data template
+	IDPurpose = "08"
+
+	// sub ids of account and unreserved templates
+	IDGloballyUnique = "00"
+
+	PayloadFormat = "01"
+	StaticPoint   = "11"
+	DynamicPoint  = "12"
+)
+
+var (
+	ErrMalformed   = errors.New("emvqr: malformed payload")
+	ErrChecksum    = errors.New("emvqr: checksum mismatch")
+	ErrValueLength = errors.New("emvqr: value longer than 99 characters")
+)
+
+type Field struct {
+	ID    string
+	Value string
+}
+
+// Encode builds a payload from fields and appends the checksum
+func Encode(fields []Field) (string, error) {
+	payload, err := encode(fields)
+	if err != nil {
+		return "", err
+	}
+
+	payload += IDCRC + "04"
+	return payload + fmt.Sprintf("%04X", crc16(payload)), nil
+}
+
+// Decode verifies the checksum and returns top level fields without the CRC one
+func Decode(payload string) ([]Field, error) {
+	if len(payload) < 8 || payload[len(payload)-8:len(payload)-4] != IDCRC+"04" {
+		return nil, ErrMalformed
+	}
+
+	body, sum := payload[:len(payload)-4], payload[len(payload)-4:]
+	if !strings.EqualFold(sum, fmt.Sprintf("%04X", crc16(body))) {
+		return nil, ErrChecksum
+	}
+
+	return decode(body[:len(body)-4])
+}
+
+// Template encodes fields nested into the value of a template field
+func Template(fields ...Field) (string, error) {
+	return encode(fields)
+}
+
+// ParseTemplate decodes fields nested into the value of a template field
+func ParseTemplate(value string) ([]Field, error) {
+	return decode(value)
+}
+
+// Lookup returns the value of the first field with the id
+func Lookup(fields []Field, id string) (string, bool) {
+	for _, field := range fields {
+		if field.ID == id {
+			return field.Value, true
+		}
+	}
+	return "", false
+}
+
+func encode(fields []Field) (string, error) {
+	var b strings.Builder
+	for _, field := range fields {
+		if len(field.ID) != 2 {
+			return "", ErrMalformed
+		}
+		length := utf8.RuneCountInString(field.Value)
+		if length > 99 {
+			return "", ErrValueLength
+		}
+		fmt.Fprintf(&b, "%s%02d%s", field.ID, length, field.Value)
+	}
+	return b.String(), nil
+}
+
+func decode(data string) ([]Field, error) {
+	runes := []rune(data)
+
+	var fields []Field
+	for len(runes) > 0 {
+		if len(runes) < 4 {
+			return nil, ErrMalformed
+		}
+
+		// Atoi alone would take "-1" or "+1", the length is exactly two digits
+		if !isDigit(runes[2]) || !isDigit(runes[3]) {
+			return nil, ErrMalformed
+		}
+
+		length, err := strconv.Atoi(string(runes[2:4]))
+		if err != nil || len(runes) < 4+length {
+			return nil, ErrMalformed
+		}
+
+		fields = append(fields, Field{ID: string(runes[:2]), Value: string(runes[4 : 4+length])})
+		runes = runes[4+length:]
+	}
+	return fields, nil
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// crc16 is CRC-16/CCITT-FALSE: polynomial 0x1021, initial value 0xFFFF
+func crc16(data string) uint16 {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
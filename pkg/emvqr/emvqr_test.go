@@ -0,0 +1,67 @@
+package emvqr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// withCRC appends a valid checksum, so the tests reach the TLV decoding
+func withCRC(body string) string {
+	body += IDCRC + "04"
+	return body + fmt.Sprintf("%04X", crc16(body))
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		err     error
+	}{
+		{name: "too short", payload: "6304", err: ErrMalformed},
+		{name: "no crc field", payload: "000201ABCD", err: ErrMalformed},
+		{name: "bad checksum", payload: "0002016304FFFF", err: ErrChecksum},
+		{name: "negative length", payload: withCRC("00-1ab"), err: ErrMalformed},
+		{name: "signed length", payload: withCRC("00+1a"), err: ErrMalformed},
+		{name: "non digit length", payload: withCRC("000x01"), err: ErrMalformed},
+		{name: "length past end", payload: withCRC("000501"), err: ErrMalformed},
+		{name: "truncated header", payload: withCRC("000201000"), err: ErrMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := Decode(tt.payload)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Decode(%q) = %v, %v; want error %v", tt.payload, fields, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	fields := []Field{
+		{ID: IDPayloadFormat, Value: PayloadFormat},
+		{ID: IDPointOfInitiation, Value: DynamicPoint},
+		{ID: IDMerchantName, Value: "Кофейня"},
+		{ID: IDAmount, Value: "150.00"},
+	}
+
+	payload, err := Encode(fields)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	decoded, err := Decode(payload)
+	if err != nil {
+		t.Fatalf("Decode(%q): %v", payload, err)
+	}
+
+	if len(decoded) != len(fields) {
+		t.Fatalf("Decode(%q) = %v, want %v", payload, decoded, fields)
+	}
+	for i := range fields {
+		if decoded[i] != fields[i] {
+			t.Fatalf("Decode(%q)[%d] = %v, want %v", payload, i, decoded[i], fields[i])
+		}
+	}
+}
@@ -2,13 +2,25 @@ package hasher
 
 import (
 	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"fmt"
 )
 
+// ErrInvalidHash is returned when the encoded hash was not produced by the hasher
+var ErrInvalidHash = errors.New("hasher: hash is not in the expected format")
+
 type PasswordHasher interface {
-	Hash(password string) string
+	Hash(password string) (string, error)
+	// Verify reports whether the password matches the encoded hash
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash reports whether the hash uses another scheme or weaker parameters than the hasher
+	NeedsRehash(encoded string) bool
 }
 
+// SHA256Hasher is the legacy scheme: the salt is prepended to the digest instead of being hashed in.
+// It is kept to verify old hashes until users sign in and get them upgraded.
 type SHA256Hasher struct {
 	salt string
 }
@@ -17,9 +29,57 @@ func NewSHA256Hasher(salt string) *SHA256Hasher {
 	return &SHA256Hasher{salt: salt}
 }
 
-func (h *SHA256Hasher) Hash(password string) string {
+func (h *SHA256Hasher) Hash(password string) (string, error) {
 	hash := sha256.New()
 	hash.Write([]byte(password))
 
-	return fmt.Sprintf("%x", hash.Sum([]byte(h.salt)))
+	return fmt.Sprintf("%x", hash.Sum([]byte(h.salt))), nil
+}
+
+func (h *SHA256Hasher) Verify(password, encoded string) (bool, error) {
+	if _, err := hex.DecodeString(encoded); err != nil || len(encoded) != 2*(len(h.salt)+sha256.Size) {
+		return false, ErrInvalidHash
+	}
+
+	hash, _ := h.Hash(password)
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(encoded)) == 1, nil
+}
+
+func (h *SHA256Hasher) NeedsRehash(string) bool {
+	return true
+}
+
+// MigratingHasher hashes with the current scheme and still verifies hashes of the legacy ones,
+// which always need a rehash
+type MigratingHasher struct {
+	current PasswordHasher
+	legacy  []PasswordHasher
+}
+
+func NewMigratingHasher(current PasswordHasher, legacy ...PasswordHasher) *MigratingHasher {
+	return &MigratingHasher{current: current, legacy: legacy}
+}
+
+func (h *MigratingHasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+func (h *MigratingHasher) Verify(password, encoded string) (bool, error) {
+	ok, err := h.current.Verify(password, encoded)
+	if !errors.Is(err, ErrInvalidHash) {
+		return ok, err
+	}
+
+	for _, legacy := range h.legacy {
+		ok, err = legacy.Verify(password, encoded)
+		if !errors.Is(err, ErrInvalidHash) {
+			return ok, err
+		}
+	}
+
+	return false, ErrInvalidHash
+}
+
+func (h *MigratingHasher) NeedsRehash(encoded string) bool {
+	return h.current.NeedsRehash(encoded)
 }
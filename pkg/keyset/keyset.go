@@ -0,0 +1,95 @@
+package keyset
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+var (
+	ErrUnknownKey       = errors.New("keyset: unknown key id")
+	ErrUnknownAlgorithm = errors.New("keyset: unknown algorithm")
+	ErrKeyMismatch      = errors.New("keyset: key does not match the algorithm")
+)
+
+// Key is an asymmetric key identified by ID, the kid of tokens it signs. Private is nil for keys
+// that only verify. A zero VerifyUntil verifies forever, otherwise the key is dropped after it.
+type Key struct {
+	ID          string
+	Algorithm   string
+	Private     crypto.Signer
+	Public      crypto.PublicKey
+	VerifyUntil time.Time
+}
+
+// KeySet signs with one key and verifies with every key that is not past VerifyUntil,
+// so tokens signed by a rotated out key stay valid during the grace period
+type KeySet struct {
+	signing Key
+	keys    map[string]Key
+	order   []string
+}
+
+func New(signingID string, keys ...Key) (*KeySet, error) {
+	set := &KeySet{keys: make(map[string]Key, len(keys))}
+
+	for _, key := range keys {
+		if _, ok := set.keys[key.ID]; ok {
+			return nil, fmt.Errorf("keyset: duplicate key id %q", key.ID)
+		}
+		set.keys[key.ID] = key
+		set.order = append(set.order, key.ID)
+	}
+
+	signing, ok := set.keys[signingID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, signingID)
+	}
+	if signing.Private == nil {
+		return nil, fmt.Errorf("keyset: signing key %q has no private key", signingID)
+	}
+	if !signing.VerifyUntil.IsZero() {
+		return nil, fmt.Errorf("keyset: signing key %q can not be retired", signingID)
+	}
+	set.signing = signing
+
+	return set, nil
+}
+
+// Signing returns the key new tokens are signed with
+func (s *KeySet) Signing() Key {
+	return s.signing
+}
+
+// Verifying returns the key with the id if it still verifies at the moment
+func (s *KeySet) Verifying(id string, now time.Time) (Key, bool) {
+	key, ok := s.keys[id]
+	if !ok || !key.verifies(now) {
+		return Key{}, false
+	}
+
+	return key, true
+}
+
+// JWKS returns the public keys that still verify at the moment, in configuration order
+func (s *KeySet) JWKS(now time.Time) JSONWebKeySet {
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(s.order))}
+	for _, id := range s.order {
+		if key := s.keys[id]; key.verifies(now) {
+			set.Keys = append(set.Keys, key.jwk())
+		}
+	}
+
+	return set
+}
+
+func (k Key) verifies(now time.Time) bool {
+	return k.VerifyUntil.IsZero() || now.Before(k.VerifyUntil)
+}
@@ -0,0 +1,109 @@
+package keyset
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ParseKey builds a key from PEM data. The public key is taken from privatePEM when it is given,
+// publicPEM alone gives a key that only verifies.
+func ParseKey(id, algorithm string, privatePEM, publicPEM []byte, verifyUntil time.Time) (Key, error) {
+	key := Key{ID: id, Algorithm: algorithm, VerifyUntil: verifyUntil}
+
+	switch {
+	case len(privatePEM) > 0:
+		private, err := parsePrivateKey(privatePEM)
+		if err != nil {
+			return Key{}, fmt.Errorf("keyset: key %q: %v", id, err)
+		}
+		key.Private, key.Public = private, private.Public()
+	case len(publicPEM) > 0:
+		public, err := parsePublicKey(publicPEM)
+		if err != nil {
+			return Key{}, fmt.Errorf("keyset: key %q: %v", id, err)
+		}
+		key.Public = public
+	default:
+		return Key{}, fmt.Errorf("keyset: key %q has neither private nor public key", id)
+	}
+
+	if err := checkAlgorithm(algorithm, key.Public); err != nil {
+		return Key{}, fmt.Errorf("%w: key %q, algorithm %q", err, id, algorithm)
+	}
+
+	return key, nil
+}
+
+func checkAlgorithm(algorithm string, public crypto.PublicKey) error {
+	var ok bool
+	switch algorithm {
+	case AlgorithmRS256:
+		var rsaKey *rsa.PublicKey
+		rsaKey, ok = public.(*rsa.PublicKey)
+		ok = ok && rsaKey.N.BitLen() >= 2048
+	case AlgorithmES256:
+		var ecKey *ecdsa.PublicKey
+		ecKey, ok = public.(*ecdsa.PublicKey)
+		ok = ok && ecKey.Curve == elliptic.P256()
+	case AlgorithmEdDSA:
+		_, ok = public.(ed25519.PublicKey)
+	default:
+		return ErrUnknownAlgorithm
+	}
+
+	if !ok {
+		return ErrKeyMismatch
+	}
+
+	return nil
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		return signer, nil
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return nil, errors.New("unsupported private key format")
+}
+
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return nil, errors.New("unsupported public key format")
+}
@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message is addressed to a user, To is whatever the deployment delivers to, e.g. a username or an address
+type Message struct {
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Outbox appends messages as JSON lines to a local file, a mail relay or a developer picks them up from there
+type Outbox struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewOutbox(path string) *Outbox {
+	return &Outbox{path: path}
+}
+
+func (o *Outbox) Notify(_ context.Context, msg Message) error {
+	msg.SentAt = time.Now()
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	file, err := os.OpenFile(o.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// Log writes messages to the application log, it is meant for local runs only: the body may hold secrets
+type Log struct{}
+
+func NewLog() Log {
+	return Log{}
+}
+
+func (Log) Notify(_ context.Context, msg Message) error {
+	slog.Info("notify: message", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
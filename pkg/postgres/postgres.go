@@ -71,7 +71,7 @@ func New(url string, opts ...Option) (*Postgres, error) {
 			break
 		}
 
-		slog.Info("Postgres is trying to connect. Attempts left", pg.connAttempts)
+		slog.Info("Postgres is trying to connect", "attempts_left", pg.connAttempts)
 		time.Sleep(pg.connTimeout)
 		pg.connAttempts--
 	}
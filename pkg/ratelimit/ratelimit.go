@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter counts events per key in fixed windows, it is safe for concurrent use
+type Limiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+type entry struct {
+	count int
+	reset time.Time
+}
+
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:     limit,
+		window:    window,
+		entries:   make(map[string]*entry),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow records an event for the key if it is still below the limit, checking and counting under one lock,
+// so concurrent callers can not slip past the limit together
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := l.current(key, time.Now())
+	if e.count >= l.limit {
+		return false
+	}
+	e.count++
+	return true
+}
+
+// Release takes back an event recorded by Allow, for callers counting only the attempts that failed
+func (l *Limiter) Release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.entries[key]; ok && e.count > 0 {
+		e.count--
+	}
+}
+
+// current returns the entry of the key in the current window, starting a new one when the last has finished
+func (l *Limiter) current(key string, now time.Time) *entry {
+	l.sweep(now)
+
+	e, ok := l.entries[key]
+	if !ok || now.After(e.reset) {
+		e = &entry{reset: now.Add(l.window)}
+		l.entries[key] = e
+	}
+	return e
+}
+
+// sweep drops finished windows once per window, so idle keys do not pile up
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+
+	for key, e := range l.entries {
+		if now.After(e.reset) {
+			delete(l.entries, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type Job func(ctx context.Context) error
+
+type Scheduler struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Every runs job in background each interval until Shutdown is called
+func (s *Scheduler) Every(name string, interval time.Duration, job Job) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				if err := job(s.ctx); err != nil {
+					slog.Error("scheduler: job failed", "job", name, "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Shutdown stops all jobs and waits for running ones to finish
+func (s *Scheduler) Shutdown() {
+	s.cancel()
+	s.wg.Wait()
+}
@@ -0,0 +1,70 @@
+package ttlcache
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache keeps values until their own expiry, it is safe for concurrent use
+type Cache[V any] struct {
+	mu         sync.Mutex
+	entries    map[string]entry[V]
+	sweepEvery time.Duration
+	lastSweep  time.Time
+}
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// New returns a cache that drops expired entries at most once per sweepEvery
+func New[V any](sweepEvery time.Duration) *Cache[V] {
+	return &Cache[V]{
+		entries:    make(map[string]entry[V]),
+		sweepEvery: sweepEvery,
+		lastSweep:  time.Now(),
+	}
+}
+
+// Get returns the value of the key unless it is missing or expired
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || !time.Now().Before(e.expiresAt) {
+		var zero V
+		return zero, false
+	}
+
+	return e.value, true
+}
+
+// Set stores the value until expiresAt, a past expiresAt removes the key
+func (c *Cache[V]) Set(key string, value V, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.sweep(now)
+
+	if !now.Before(expiresAt) {
+		delete(c.entries, key)
+		return
+	}
+	c.entries[key] = entry[V]{value: value, expiresAt: expiresAt}
+}
+
+func (c *Cache[V]) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.sweepEvery {
+		return
+	}
+
+	for key, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	c.lastSweep = now
+}